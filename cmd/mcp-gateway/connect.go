@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/connect"
+)
+
+// runConnect 实现 `mcp-gateway connect`：作为本地 stdio MCP server，把消息转发到远端网关。
+// stdout 只承载 MCP 消息，日志输出到 stderr。
+func runConnect(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	var opts connect.Options
+	fs.StringVar(&opts.URL, "url", "", "Remote gateway Streamable HTTP endpoint, e.g. https://gateway.example.com/stream")
+	fs.StringVar(&opts.Workspace, "workspace", "", "Workspace to use (default: the gateway's default workspace)")
+	fs.StringVar(&opts.Token, "token", os.Getenv("MCP_GATEWAY_TOKEN"), "Bearer token for the gateway (default: $MCP_GATEWAY_TOKEN)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	bridge, err := connect.New(opts)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := bridge.Run(ctx, os.Stdin, os.Stdout); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	return nil
+}
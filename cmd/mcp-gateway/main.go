@@ -23,6 +23,14 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "connect" {
+		if err := runConnect(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var protocolFlag, cfgPath string
 	var assumeYes bool
 	flag.StringVar(&protocolFlag, "protocol", "", "Gateway protocol: all, sse or streamhttp")
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+)
+
+// handleV1ListApprovals 返回 workspace 内等待审批的工具调用。
+func (h *Handler) handleV1ListApprovals(c echo.Context) error {
+	wsID := c.Param("ws")
+	if err := h.requireWorkspaceRole(c, wsID, identity.RoleWorkspaceAdmin); err != nil {
+		return err
+	}
+	approvals := h.services.ListApprovals(xlog.NewLogger("approval"), workspaces.NameArg{Workspace: wsID})
+	return respondOK(c, map[string]interface{}{"approvals": approvals})
+}
+
+// handleV1DecideApproval 批准或拒绝一条等待审批的工具调用。批准后调用照常转发，
+// 拒绝时 client 收到带原因的错误；决定以审批人身份写入审计日志。
+func (h *Handler) handleV1DecideApproval(c echo.Context) error {
+	wsID := c.Param("ws")
+	if err := h.requireWorkspaceRole(c, wsID, identity.RoleWorkspaceAdmin); err != nil {
+		return err
+	}
+	var req struct {
+		ID       string `json:"id"`
+		Decision string `json:"decision"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	if req.ID == "" {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "id is required", nil)
+	}
+	if req.Decision != "approve" && req.Decision != "deny" {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", "decision must be approve or deny", nil)
+	}
+
+	principal := h.currentPrincipal(c)
+	decision := sessions.ApprovalDecision{Approve: req.Decision == "approve", Approver: principal.AccountID, Reason: req.Reason}
+	approval, err := h.services.DecideApproval(xlog.NewLogger("approval"), workspaces.NameArg{Workspace: wsID}, req.ID, decision)
+	if errors.Is(err, sessions.ErrApprovalNotFound) {
+		return respondError(c, http.StatusNotFound, "NOT_FOUND", "approval not found or already resolved", nil)
+	}
+	if err != nil {
+		return respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+	}
+
+	h.appendAudit(c, "approval."+req.Decision, "approval", approval.ID, wsID, map[string]interface{}{
+		"session_id":     approval.SessionID,
+		"tool":           approval.Tool,
+		"approval_rule":  approval.Rule,
+		"reason":         req.Reason,
+		"approver_id":    principal.AccountID,
+		"approver_email": principal.Email,
+		"requested_by":   approval.AccountID,
+	})
+	return respondOK(c, map[string]interface{}{"approval": approval, "decision": req.Decision})
+}
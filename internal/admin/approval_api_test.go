@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func postApprovalDecision(t *testing.T, h *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/workspaces/team-a/approvals", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("ws")
+	c.SetParamValues("team-a")
+	assert.NoError(t, h.handleV1DecideApproval(c))
+	return rec
+}
+
+func TestHandleV1ListApprovals(t *testing.T) {
+	h, mockServiceMgr := createTestServerManager()
+	mockServiceMgr.On("ListApprovals", mock.Anything, workspaces.NameArg{Workspace: "team-a"}).
+		Return([]sessions.Approval{{ID: "ap-1", Tool: "fs_delete_file", Rule: sessions.ApprovalRuleDestructive}}).Once()
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/workspaces/team-a/approvals", nil), rec)
+	c.SetParamNames("ws")
+	c.SetParamValues("team-a")
+	assert.NoError(t, h.handleV1ListApprovals(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Data struct {
+			Approvals []sessions.Approval `json:"approvals"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data.Approvals, 1)
+	assert.Equal(t, "fs_delete_file", resp.Data.Approvals[0].Tool)
+	mockServiceMgr.AssertExpectations(t)
+}
+
+func TestHandleV1DecideApproval(t *testing.T) {
+	h, mockServiceMgr := createTestServerManager()
+	mockServiceMgr.On("DecideApproval", mock.Anything, workspaces.NameArg{Workspace: "team-a"}, "ap-1", sessions.ApprovalDecision{Approver: "admin", Reason: "too risky"}).
+		Return(sessions.Approval{ID: "ap-1", Tool: "fs_delete_file"}, nil).Once()
+	mockServiceMgr.On("DecideApproval", mock.Anything, workspaces.NameArg{Workspace: "team-a"}, "ap-2", mock.Anything).
+		Return(sessions.Approval{}, sessions.ErrApprovalNotFound).Once()
+
+	rec := postApprovalDecision(t, h, `{"id":"ap-1","decision":"deny","reason":"too risky"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = postApprovalDecision(t, h, `{"id":"ap-2","decision":"approve"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = postApprovalDecision(t, h, `{"id":"ap-3","decision":"maybe"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockServiceMgr.AssertExpectations(t)
+}
@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/federation"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+)
+
+type discoverGatewayRequest struct {
+	URL    string `json:"url"`
+	APIKey string `json:"api_key"`
+	// Service 为已有 gateway 服务的名称，提供时复用其地址与凭证
+	Service string `json:"service"`
+}
+
+type discoveredWorkspace struct {
+	ID       string               `json:"id"`
+	Name     string               `json:"name"`
+	Services []federation.Service `json:"services"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// handleV1DiscoverGateway 通过远端网关的 admin API 列出服务凭证可见的 workspace 及其服务，
+// 用于创建 gateway 类型服务前选择 remote_workspace。任意 url 只允许系统管理员探测，
+// workspace 管理员只能复用已有的 gateway 服务；远端返回的错误内容只记录日志，不回显。
+func (h *Handler) handleV1DiscoverGateway(c echo.Context) error {
+	wsID := c.Param("ws")
+	if err := h.requireWorkspaceRole(c, wsID, identity.RoleWorkspaceAdmin); err != nil {
+		return err
+	}
+	var req discoverGatewayRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	if req.Service == "" && !h.currentPrincipal(c).IsSystemAdmin {
+		return respondError(c, http.StatusForbidden, "FORBIDDEN", "discovering an arbitrary gateway url requires system admin; use an existing gateway service", nil)
+	}
+	if req.Service != "" {
+		svc, ok := h.services.GetMcpServices(nilLogger{}, workspaces.NameArg{Workspace: wsID})[req.Service]
+		var cfg config.MCPServerConfig
+		if ok {
+			cfg = svc.Info().Config
+		}
+		if !cfg.IsGateway() {
+			return respondError(c, http.StatusNotFound, "NOT_FOUND", "gateway service not found", nil)
+		}
+		req.URL = cfg.URL
+		if req.APIKey == "" {
+			req.APIKey = cfg.Env[config.GatewayAPIKeyEnv]
+		}
+	}
+	if err := validateGatewayURL(req.URL); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+
+	xl := xlog.NewLogger("FEDERATION-DISCOVER")
+	ctx := c.Request().Context()
+	client := federation.New(req.URL, req.APIKey)
+	remote, err := client.Workspaces(ctx)
+	if err != nil {
+		xl.Warnf("Failed to list remote workspaces: %v", err)
+		return respondError(c, http.StatusBadGateway, "REMOTE_GATEWAY_ERROR", "remote gateway request failed", nil)
+	}
+	items := make([]discoveredWorkspace, 0, len(remote))
+	for _, ws := range remote {
+		item := discoveredWorkspace{ID: ws.ID, Name: ws.Name, Services: []federation.Service{}}
+		// 单个 workspace 失败（如凭证无该 workspace 的服务权限）不影响其它 workspace
+		if services, err := client.Services(ctx, ws.ID); err != nil {
+			xl.Warnf("Failed to list services of remote workspace %s: %v", ws.ID, err)
+			item.Error = "failed to list services"
+		} else if services != nil {
+			item.Services = services
+		}
+		items = append(items, item)
+	}
+	return respondOK(c, map[string]interface{}{"url": req.URL, "workspaces": items})
+}
+
+// parseGatewayServiceRequest 解析 type=gateway 的服务请求，api_key 保存到 MCP_GATEWAY_API_KEY。
+func parseGatewayServiceRequest(raw map[string]interface{}, cfg *config.MCPServerConfig, meta *serviceMeta) error {
+	gatewayURL := asString(raw["url"])
+	if err := validateGatewayURL(gatewayURL); err != nil {
+		return err
+	}
+	meta.SourceType = config.ServiceTypeGateway
+	meta.SourceRef = gatewayURL
+	cfg.Type = config.ServiceTypeGateway
+	cfg.URL = gatewayURL
+	cfg.RemoteWorkspace = asString(raw["remote_workspace"])
+	cfg.Env = asStringMap(raw["env"])
+	if apiKey := asString(raw["api_key"]); apiKey != "" {
+		cfg.Env[config.GatewayAPIKeyEnv] = apiKey
+	}
+	cfg.Stateful, _ = raw["stateful"].(bool)
+	cfg.Cache = config.ToolCacheConfigFromMap(raw["cache"])
+	return nil
+}
+
+func validateGatewayURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("gateway url is required")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("gateway url must be an absolute http(s) url")
+	}
+	return nil
+}
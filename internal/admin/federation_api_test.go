@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func discoverGateway(t *testing.T, h *Handler, body string, principal ...*identity.Principal) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/workspaces/team-a/federation/discover", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("ws")
+	c.SetParamValues("team-a")
+	if len(principal) > 0 {
+		c.Set("auth.principal", principal[0])
+	}
+	assert.NoError(t, h.handleV1DiscoverGateway(c))
+	return rec
+}
+
+func TestHandleV1DiscoverGatewayListsRemoteWorkspaces(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer remote-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"success":false,"error":{"code":"UNAUTHORIZED","message":"invalid api key"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/workspaces":
+			_, _ = w.Write([]byte(`{"success":true,"data":{"items":[{"id":"eu-team","name":"EU Team"},{"id":"locked","name":"Locked"}],"total":2}}`))
+		case "/api/v1/workspaces/eu-team/services":
+			_, _ = w.Write([]byte(`{"success":true,"data":{"items":[{"name":"github","status":"running","tools_count":3}]}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"success":false,"error":{"code":"FORBIDDEN","message":"workspace access denied"}}`))
+		}
+	}))
+	defer remote.Close()
+
+	h, _ := createTestServerManager()
+	rec := discoverGateway(t, h, `{"url":"`+remote.URL+`","api_key":"remote-key"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp struct {
+		Data struct {
+			Workspaces []discoveredWorkspace `json:"workspaces"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Workspaces, 2)
+	assert.Equal(t, "github", resp.Data.Workspaces[0].Services[0].Name)
+	assert.Equal(t, "failed to list services", resp.Data.Workspaces[1].Error)
+
+	rec = discoverGateway(t, h, `{"url":"`+remote.URL+`","api_key":"wrong"}`)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "invalid api key")
+	rec = discoverGateway(t, h, `{"url":"ftp://remote"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleV1DiscoverGatewayRestrictsArbitraryURLs(t *testing.T) {
+	h, mockMgr := createTestServerManager()
+	mockMgr.On("GetMcpServices", nilLogger{}, workspaces.NameArg{Workspace: "team-a"}).Return(map[string]runtime.ExportMcpService{})
+	owner := &identity.Principal{AccountID: "acc-1", Role: identity.RoleWorkspaceOwner}
+
+	rec := discoverGateway(t, h, `{"url":"http://169.254.169.254"}`, owner)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	// 复用已有 gateway 服务不需要系统管理员
+	rec = discoverGateway(t, h, `{"service":"eu"}`, owner)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestParseGatewayServiceRequest(t *testing.T) {
+	h, _ := createTestServerManager()
+	name, cfg, meta, err := h.parseServiceRequest(context.Background(), "team-a", map[string]interface{}{
+		"name":             "eu",
+		"type":             "gateway",
+		"url":              "https://eu.gw.example",
+		"remote_workspace": "eu-team",
+		"api_key":          "remote-key",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "eu", name)
+	assert.True(t, cfg.IsGateway())
+	assert.Equal(t, "eu-team", cfg.RemoteWorkspace)
+	assert.Equal(t, "remote-key", cfg.Env[config.GatewayAPIKeyEnv])
+	assert.Equal(t, config.ServiceTypeGateway, meta.SourceType)
+
+	_, _, _, err = h.parseServiceRequest(context.Background(), "team-a", map[string]interface{}{"name": "eu", "type": "gateway"})
+	assert.Error(t, err)
+}
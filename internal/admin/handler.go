@@ -7,6 +7,9 @@ import (
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/webhook"
 
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
 )
@@ -22,11 +25,15 @@ type Handler struct {
 	state    *controlPlaneState
 	market   *marketStore
 	oauth    *mcpOAuthFlowStore
+	limits   *ratelimit.Limiter
+	meter    *usage.Meter
+	hooks    *webhook.Dispatcher
 	mu       sync.RWMutex
 }
 
-// NewHandler 构造一个 admin Handler。
-func NewHandler(services workspaces.ServiceManagerI, cfg *config.Config, auth *identity.Service, stores ...oplog.Store) *Handler {
+// NewHandler 构造一个 admin Handler，limits 与 meter 为 gateway 共用的限流器与用量计量，
+// hooks 为 webhook 投递器，均可以为 nil。
+func NewHandler(services workspaces.ServiceManagerI, cfg *config.Config, auth *identity.Service, limits *ratelimit.Limiter, meter *usage.Meter, hooks *webhook.Dispatcher, stores ...oplog.Store) *Handler {
 	market := newMarketStore()
 	for _, adapter := range defaultMarketAdapters(nil) {
 		market.registerAdapter(adapter)
@@ -43,6 +50,9 @@ func NewHandler(services workspaces.ServiceManagerI, cfg *config.Config, auth *i
 		state:    newControlPlaneState(),
 		market:   market,
 		oauth:    newMCPOAuthFlowStore(),
+		limits:   limits,
+		meter:    meter,
+		hooks:    hooks,
 	}
 }
 
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+)
+
+// handleV1GetToolPolicies 返回 workspace 的工具参数策略。
+func (h *Handler) handleV1GetToolPolicies(c echo.Context) error {
+	wsID := c.Param("ws")
+	if err := h.requireWorkspaceRole(c, wsID, identity.RoleWorkspaceAdmin); err != nil {
+		return err
+	}
+	h.mu.RLock()
+	rules := h.cfg.ToolPolicies[wsID]
+	h.mu.RUnlock()
+	if rules == nil {
+		rules = []config.ToolPolicyRule{}
+	}
+	return respondOK(c, map[string]interface{}{"rules": rules})
+}
+
+// handleV1PutToolPolicies 整体替换 workspace 的工具参数策略，只有 workspace owner 可以修改。
+// 新策略立即对已有 session 生效，并写回配置文件。
+func (h *Handler) handleV1PutToolPolicies(c echo.Context) error {
+	wsID := c.Param("ws")
+	if err := h.requireWorkspaceRole(c, wsID, identity.RoleWorkspaceOwner); err != nil {
+		return err
+	}
+	var req struct {
+		Rules []config.ToolPolicyRule `json:"rules"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	if err := config.ValidateToolPolicies(req.Rules); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	if req.Rules == nil {
+		req.Rules = []config.ToolPolicyRule{}
+	}
+
+	h.mu.Lock()
+	// 配置中的 map 与 service manager 共享，替换而不是原地修改
+	policies := make(map[string][]config.ToolPolicyRule, len(h.cfg.ToolPolicies)+1)
+	for id, rules := range h.cfg.ToolPolicies {
+		policies[id] = rules
+	}
+	if len(req.Rules) == 0 {
+		delete(policies, wsID)
+	} else {
+		policies[wsID] = req.Rules
+	}
+	h.cfg.ToolPolicies = policies
+	err := h.cfg.SaveConfig()
+	h.mu.Unlock()
+	if err != nil && h.cfg.CfgPath() != "" {
+		return respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+	}
+
+	h.services.SetToolPolicies(xlog.NewLogger("tool-policy"), workspaces.NameArg{Workspace: wsID}, req.Rules)
+	names := make([]string, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		names = append(names, rule.Name)
+	}
+	h.appendAudit(c, "workspace.tool_policies_update", "workspace", wsID, wsID, map[string]interface{}{"rules": names})
+	return respondOK(c, map[string]interface{}{"rules": req.Rules})
+}
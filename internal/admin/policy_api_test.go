@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func putToolPolicies(t *testing.T, h *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/workspaces/team-a/policies", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("ws")
+	c.SetParamValues("team-a")
+	assert.NoError(t, h.handleV1PutToolPolicies(c))
+	return rec
+}
+
+func TestHandleV1PutToolPoliciesAppliesRules(t *testing.T) {
+	h, mockServiceMgr := createTestServerManager()
+	mockServiceMgr.On("SetToolPolicies", mock.Anything, workspaces.NameArg{Workspace: "team-a"}, mock.MatchedBy(func(rules []config.ToolPolicyRule) bool {
+		return len(rules) == 1 && rules[0].Name == "no-drop"
+	})).Once()
+
+	rec := putToolPolicies(t, h, `{"rules":[{"name":"no-drop","tool":"db_query","when":[{"path":"$.sql","op":"matches","value":"(?i)drop"}],"effect":"deny"}]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, h.cfg.ToolPolicies["team-a"], 1)
+	mockServiceMgr.AssertExpectations(t)
+}
+
+func TestHandleV1PutToolPoliciesRejectsInvalidRules(t *testing.T) {
+	h, mockServiceMgr := createTestServerManager()
+
+	rec := putToolPolicies(t, h, `{"rules":[{"name":"bad","when":[{"path":"sql","op":"matches","value":"x"}],"effect":"deny"}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = putToolPolicies(t, h, `{"rules":[{"name":"dup","effect":"deny"},{"name":"dup","effect":"allow"}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, h.cfg.ToolPolicies["team-a"])
+	mockServiceMgr.AssertNotCalled(t, "SetToolPolicies", mock.Anything, mock.Anything, mock.Anything)
+}
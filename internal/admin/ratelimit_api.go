@@ -0,0 +1,18 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// handleV1RateLimits 返回生效的限流规则与未补满的令牌桶。
+func (h *Handler) handleV1RateLimits(c echo.Context) error {
+	if h.authMode() == "saas" && !h.currentPrincipal(c).IsSystemAdmin {
+		return respondError(c, http.StatusForbidden, "FORBIDDEN", "rate limits require system admin", nil)
+	}
+	return respondOK(c, map[string]interface{}{
+		"rules":   h.limits.Rules(),
+		"buckets": h.limits.Snapshot(),
+	})
+}
@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+)
+
+// handleV1Usage 返回某个月份的用量记录、合计与适用的配额。
+// 系统管理员可以查询任意账号与 workspace；其他用户只能查询自己的账号，
+// 或自己至少具有 viewer 角色的 workspace。
+func (h *Handler) handleV1Usage(c echo.Context) error {
+	if h.meter == nil {
+		return respondError(c, http.StatusNotFound, "USAGE_DISABLED", "usage metering is only available in saas mode", nil)
+	}
+	filter := identity.UsageFilter{
+		Period:      c.QueryParam("period"),
+		AccountID:   c.QueryParam("account_id"),
+		WorkspaceID: c.QueryParam("workspace_id"),
+	}
+	if filter.Period == "" {
+		filter.Period = usage.Period(time.Now())
+	} else if _, err := time.Parse("2006-01", filter.Period); err != nil {
+		return respondError(c, http.StatusBadRequest, "INVALID_ARGUMENT", "period must be formatted as YYYY-MM", nil)
+	}
+
+	principal := h.currentPrincipal(c)
+	if !principal.IsSystemAdmin {
+		if filter.WorkspaceID != "" {
+			if err := h.requireWorkspaceRole(c, filter.WorkspaceID, identity.RoleWorkspaceViewer); err != nil {
+				return err
+			}
+		} else if filter.AccountID == "" {
+			filter.AccountID = principal.AccountID
+		}
+		if filter.AccountID != "" && filter.AccountID != principal.AccountID && filter.WorkspaceID == "" {
+			return respondError(c, http.StatusForbidden, "FORBIDDEN", "usage of other accounts requires system admin", nil)
+		}
+	}
+
+	records, err := h.meter.Usage(c.Request().Context(), filter)
+	if err != nil {
+		return respondError(c, http.StatusInternalServerError, "INTERNAL", err.Error(), nil)
+	}
+	totals := identity.UsageRecord{Period: filter.Period, AccountID: filter.AccountID, WorkspaceID: filter.WorkspaceID}
+	for _, record := range records {
+		totals.ToolCalls += record.ToolCalls
+		totals.RequestBytes += record.RequestBytes
+		totals.ResponseBytes += record.ResponseBytes
+		totals.SessionSeconds += record.SessionSeconds
+	}
+	quotas := map[string]interface{}{}
+	if filter.AccountID != "" {
+		quotas[usage.ScopeAccount] = h.cfg.UsageQuotas.AccountQuota(filter.AccountID)
+	}
+	if filter.WorkspaceID != "" {
+		quotas[usage.ScopeWorkspace] = h.cfg.UsageQuotas.WorkspaceQuota(filter.WorkspaceID)
+	}
+	return respondOK(c, map[string]interface{}{
+		"period":  filter.Period,
+		"records": records,
+		"totals":  totals,
+		"quotas":  quotas,
+	})
+}
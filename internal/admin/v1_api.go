@@ -79,6 +79,7 @@ func (h *Handler) registerV1Routes(e *echo.Echo) {
 
 	v1.GET("/workspaces/:ws/services", h.handleV1ListServices)
 	v1.POST("/workspaces/:ws/services", h.handleV1CreateService)
+	v1.POST("/workspaces/:ws/federation/discover", h.handleV1DiscoverGateway)
 	v1.POST("/workspaces/:ws/services:batch", h.handleV1BatchCreateServices)
 	v1.POST("/workspaces/:ws/services:from-installed", h.handleV1CreateServiceFromInstalled)
 	v1.PUT("/workspaces/:ws/services/:name", h.handleV1UpdateService)
@@ -113,10 +114,22 @@ func (h *Handler) registerV1Routes(e *echo.Echo) {
 	v1.DELETE("/market/packages/:id", h.handleV1DeleteMarketPackage)
 	v1.POST("/market/packages/:id/install", h.handleV1InstallMarketPackage)
 	v1.GET("/system/config", h.handleV1SystemConfig)
+	v1.GET("/rate-limits", h.handleV1RateLimits)
+	v1.GET("/usage", h.handleV1Usage)
 	v1.PUT("/system/config", h.handleV1UpdateSystemConfig)
 	v1.GET("/system/api-key", h.handleV1GetSystemAPIKey)
 	v1.POST("/system/api-key/rotate", h.handleV1RotateSystemAPIKey)
 	v1.GET("/workspaces/:ws/logs", h.handleV1WorkspaceLogs)
+	v1.GET("/workspaces/:ws/policies", h.handleV1GetToolPolicies)
+	v1.PUT("/workspaces/:ws/policies", h.handleV1PutToolPolicies)
+	v1.GET("/workspaces/:ws/approvals", h.handleV1ListApprovals)
+	v1.POST("/workspaces/:ws/approvals", h.handleV1DecideApproval)
+	v1.GET("/webhooks", h.handleV1ListWebhooks)
+	v1.POST("/webhooks", h.handleV1CreateWebhook)
+	v1.GET("/webhooks/:id", h.handleV1GetWebhook)
+	v1.PUT("/webhooks/:id", h.handleV1UpdateWebhook)
+	v1.DELETE("/webhooks/:id", h.handleV1DeleteWebhook)
+	v1.GET("/webhooks/:id/deliveries", h.handleV1WebhookDeliveries)
 }
 
 func (h *Handler) v1AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
@@ -225,13 +238,16 @@ func (h *Handler) visibleWorkspaceMap(c echo.Context) (map[string]bool, error) {
 }
 
 func (h *Handler) appendAudit(c echo.Context, action, resourceType, resourceID, workspaceID string, detail map[string]interface{}) {
-	principal := h.currentPrincipal(c)
-	if h.auth == nil {
-		h.appendOperation(c.Request().Context(), principal, oplog.LevelInfo, action, resourceType, resourceID, workspaceID, "", readableOperationMessage(action, resourceID), "", detail)
-		return
+	h.appendAuditAs(c.Request().Context(), h.currentPrincipal(c), action, resourceType, resourceID, workspaceID, detail)
+}
+
+// appendAuditAs 以指定身份写入审计日志与操作日志，用于登录、注册等尚未建立身份的请求。
+// 操作日志同时驱动 webhook 投递。
+func (h *Handler) appendAuditAs(ctx context.Context, principal *identity.Principal, action, resourceType, resourceID, workspaceID string, detail map[string]interface{}) {
+	if h.auth != nil {
+		h.auth.AppendAuditLog(ctx, principal, action, resourceType, resourceID, workspaceID, detail)
 	}
-	h.auth.AppendAuditLog(c.Request().Context(), principal, action, resourceType, resourceID, workspaceID, detail)
-	h.appendOperation(c.Request().Context(), principal, oplog.LevelInfo, action, resourceType, resourceID, workspaceID, "", readableOperationMessage(action, resourceID), "", detail)
+	h.appendOperation(ctx, principal, oplog.LevelInfo, action, resourceType, resourceID, workspaceID, "", readableOperationMessage(action, resourceID), "", detail)
 }
 
 func (h *Handler) appendOperation(ctx context.Context, principal *identity.Principal, level oplog.Level, action, resourceType, resourceID, workspaceID, sessionID, message, errText string, detail map[string]interface{}) {
@@ -295,6 +311,11 @@ func readableOperationMessage(action, resourceID string) string {
 		"installed.oauth_complete":      "Installed package OAuth completed",
 		"auth.login":                    "User logged in",
 		"auth.register":                 "User registered",
+		"approval.approve":              "Tool call approved",
+		"approval.deny":                 "Tool call denied",
+		"webhook.create":                "Webhook created",
+		"webhook.update":                "Webhook updated",
+		"webhook.delete":                "Webhook deleted",
 	}
 	msg, ok := names[action]
 	if !ok {
@@ -417,7 +438,7 @@ func (h *Handler) handleV1AuthLogin(c echo.Context) error {
 			if err != nil {
 				return respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "invalid api key", nil)
 			}
-			h.auth.AppendAuditLog(c.Request().Context(), principal, "auth.login", "api_key", "", principal.WorkspaceID, map[string]interface{}{"mode": "saas"})
+			h.appendAuditAs(c.Request().Context(), principal, "auth.login", "api_key", "", principal.WorkspaceID, map[string]interface{}{"mode": "saas"})
 			return respondOK(c, map[string]interface{}{
 				"mode":       h.authMode(),
 				"token_type": "Bearer",
@@ -439,7 +460,7 @@ func (h *Handler) handleV1AuthLogin(c echo.Context) error {
 		if err != nil {
 			return respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "invalid email or password", nil)
 		}
-		h.auth.AppendAuditLog(c.Request().Context(), nil, "auth.login", "account", strings.ToLower(strings.TrimSpace(req.Email)), "", map[string]interface{}{"mode": "saas"})
+		h.appendAuditAs(c.Request().Context(), nil, "auth.login", "account", strings.ToLower(strings.TrimSpace(req.Email)), "", map[string]interface{}{"mode": "saas"})
 		return respondOK(c, resp)
 	}
 }
@@ -496,7 +517,7 @@ func (h *Handler) handleV1AuthRegister(c echo.Context) error {
 		})
 	}
 
-	h.auth.AppendAuditLog(c.Request().Context(), nil, "auth.register", "account", strings.ToLower(strings.TrimSpace(req.Email)), "", map[string]interface{}{"mode": "saas"})
+	h.appendAuditAs(c.Request().Context(), nil, "auth.register", "account", strings.ToLower(strings.TrimSpace(req.Email)), "", map[string]interface{}{"mode": "saas"})
 	return respondOK(c, resp)
 }
 
@@ -580,12 +601,18 @@ func (h *Handler) handleV1StatsOverview(c echo.Context) error {
 		recent = append(recent, row)
 	}
 
+	delivery := sessions.GlobalDeliveryStats()
+	toolCache := sessions.GlobalToolCacheStats()
 	return respondOK(c, map[string]interface{}{
-		"workspaces_count": len(workspacesList),
-		"running_mcps":     running,
-		"failed_mcps_24h":  0,
-		"active_sessions":  activeSessions,
-		"recent_activity":  recent,
+		"workspaces_count":     len(workspacesList),
+		"running_mcps":         running,
+		"failed_mcps_24h":      0,
+		"active_sessions":      activeSessions,
+		"dropped_events":       delivery.DroppedEvents,
+		"overflow_disconnects": delivery.OverflowDisconnects,
+		"tool_cache_hits":      toolCache.Hits,
+		"tool_cache_misses":    toolCache.Misses,
+		"recent_activity":      recent,
 	})
 }
 
@@ -832,6 +859,8 @@ type serviceView struct {
 	Env             map[string]string `json:"env,omitempty"`
 	URL             string            `json:"url,omitempty"`
 	GatewayProtocol string            `json:"gateway_protocol,omitempty"`
+	RemoteWorkspace string            `json:"remote_workspace,omitempty"`
+	RemoteServices  []string          `json:"remote_services,omitempty"`
 	AuthStatus      string            `json:"auth_status,omitempty"`
 	Status          string            `json:"status"`
 	Port            int               `json:"port,omitempty"`
@@ -839,12 +868,27 @@ type serviceView struct {
 	LastError       string            `json:"last_error,omitempty"`
 	RetryCount      int               `json:"retry_count"`
 	CreatedAt       string            `json:"created_at"`
+	// Pool 为共享下游连接的统计，未启用连接复用或服务为有状态时为空
+	Pool *servicePoolView `json:"pool,omitempty"`
+	// Cache 为工具结果缓存的命中统计，服务未开启缓存时为空
+	Cache *sessions.ToolCacheStats `json:"cache,omitempty"`
+}
+
+type servicePoolView struct {
+	Connections int `json:"connections"`
+	Sessions    int `json:"sessions"`
 }
 
 func (h *Handler) buildServiceViews(workspaceID string) []serviceView {
 	h.seedStateFromRuntime()
 	services := h.services.GetMcpServices(nilLogger{}, workspaces.NameArg{Workspace: workspaceID})
 	items := make([]serviceView, 0, len(services))
+	var poolStats map[string]map[string]int
+	var cacheStats map[string]sessions.ToolCacheStats
+	if len(services) > 0 {
+		poolStats = h.services.PoolStats(nilLogger{}, workspaces.NameArg{Workspace: workspaceID})
+		cacheStats = h.services.ToolCacheStats(nilLogger{}, workspaces.NameArg{Workspace: workspaceID})
+	}
 
 	// 获取内存中所有服务元数据
 	allMeta := h.state.listServices(workspaceID)
@@ -868,10 +912,12 @@ func (h *Handler) buildServiceViews(workspaceID string) []serviceView {
 			if !meta.CreatedAt.IsZero() {
 				createdAt = meta.CreatedAt
 			}
+		} else if info.Config.IsGateway() {
+			sourceType = config.ServiceTypeGateway
 		} else if info.Config.URL != "" {
 			sourceType = "url"
 		}
-		items = append(items, serviceView{
+		view := serviceView{
 			Name:            name,
 			WorkspaceID:     workspaceID,
 			SourceType:      sourceType,
@@ -888,7 +934,20 @@ func (h *Handler) buildServiceViews(workspaceID string) []serviceView {
 			LastError:       info.LastError,
 			RetryCount:      info.RetryCount,
 			CreatedAt:       createdAt.UTC().Format(time.RFC3339),
-		})
+		}
+		if stat, ok := poolStats[name]; ok {
+			view.Pool = &servicePoolView{Connections: stat["connections"], Sessions: stat["sessions"]}
+		}
+		if stat, ok := cacheStats[name]; ok {
+			view.Cache = &stat
+		}
+		if info.Config.IsGateway() {
+			view.RemoteWorkspace = info.Config.GetRemoteWorkspace()
+			if info.Remote != nil {
+				view.RemoteServices = info.Remote.Services
+			}
+		}
+		items = append(items, view)
 		delete(metaMap, name)
 	}
 
@@ -1322,6 +1381,23 @@ type sessionView struct {
 	BoundMCPNames   []string `json:"bound_mcp_names"`
 	CreatedAt       string   `json:"created_at"`
 	LastReceiveTime string   `json:"last_receive_time"`
+	// Degraded 为 true 表示部分下游服务订阅失败，网关正在后台重试
+	Degraded      bool                           `json:"degraded"`
+	Subscriptions []sessions.ServiceSubscription `json:"subscriptions"`
+	Reconnects    int                            `json:"reconnects"`
+	Delivery      sessions.DeliveryStats         `json:"delivery"`
+	// ProtocolVersion 为与 client 协商出的 MCP 协议版本，尚未 initialize 时为空
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+	// ResourceSubscriptions 为 client 通过 resources/subscribe 订阅的资源 URI
+	ResourceSubscriptions []string `json:"resource_subscriptions"`
+}
+
+// sessionStatus 根据下游订阅情况返回 session 状态。
+func sessionStatus(sess *sessions.Session) string {
+	if sess.IsDegraded() {
+		return "degraded"
+	}
+	return "active"
 }
 
 func (h *Handler) buildSessionViews(wsID string) []sessionView {
@@ -1334,14 +1410,20 @@ func (h *Handler) buildSessionViews(wsID string) []sessionView {
 	sort.Strings(serviceNames)
 	for _, sess := range sessionsList {
 		views = append(views, sessionView{
-			ID:              sess.GetId(),
-			WorkspaceID:     wsID,
-			Status:          "active",
-			IsReady:         sess.IsToolsListReady(),
-			ToolsCount:      len(sess.GetAllTools()),
-			BoundMCPNames:   serviceNames,
-			CreatedAt:       sess.CreatedAt.UTC().Format(time.RFC3339),
-			LastReceiveTime: sess.LastReceiveTime.UTC().Format(time.RFC3339),
+			ID:                    sess.GetId(),
+			WorkspaceID:           wsID,
+			Status:                sessionStatus(sess),
+			IsReady:               sess.IsToolsListReady(),
+			ToolsCount:            len(sess.GetAllTools()),
+			BoundMCPNames:         serviceNames,
+			CreatedAt:             sess.CreatedAt.UTC().Format(time.RFC3339),
+			LastReceiveTime:       sess.LastReceiveTime.UTC().Format(time.RFC3339),
+			Degraded:              sess.IsDegraded(),
+			Subscriptions:         sess.Subscriptions(),
+			Reconnects:            sess.ReconnectCount(),
+			Delivery:              sess.DeliveryStats(),
+			ProtocolVersion:       sess.ProtocolVersion(),
+			ResourceSubscriptions: sess.ResourceSubscriptions(),
 		})
 	}
 	sort.Slice(views, func(i, j int) bool { return views[i].CreatedAt > views[j].CreatedAt })
@@ -1387,14 +1469,20 @@ func (h *Handler) handleV1CreateSession(c echo.Context) error {
 	})
 	h.appendAudit(c, "session.create", "session", sess.GetId(), wsID, nil)
 	return respondCreated(c, sessionView{
-		ID:              sess.GetId(),
-		WorkspaceID:     wsID,
-		Status:          "active",
-		IsReady:         sess.IsToolsListReady(),
-		ToolsCount:      len(sess.GetAllTools()),
-		BoundMCPNames:   h.listServiceNames(wsID),
-		CreatedAt:       sess.CreatedAt.UTC().Format(time.RFC3339),
-		LastReceiveTime: sess.LastReceiveTime.UTC().Format(time.RFC3339),
+		ID:                    sess.GetId(),
+		WorkspaceID:           wsID,
+		Status:                sessionStatus(sess),
+		IsReady:               sess.IsToolsListReady(),
+		ToolsCount:            len(sess.GetAllTools()),
+		BoundMCPNames:         h.listServiceNames(wsID),
+		CreatedAt:             sess.CreatedAt.UTC().Format(time.RFC3339),
+		LastReceiveTime:       sess.LastReceiveTime.UTC().Format(time.RFC3339),
+		Degraded:              sess.IsDegraded(),
+		Subscriptions:         sess.Subscriptions(),
+		Reconnects:            sess.ReconnectCount(),
+		Delivery:              sess.DeliveryStats(),
+		ProtocolVersion:       sess.ProtocolVersion(),
+		ResourceSubscriptions: sess.ResourceSubscriptions(),
 	})
 }
 
@@ -1420,12 +1508,16 @@ func (h *Handler) handleV1GetSession(c echo.Context) error {
 		return respondOK(c, map[string]interface{}{
 			"id":                sess.GetId(),
 			"workspace_id":      wsID,
-			"status":            "active",
+			"status":            sessionStatus(sess),
 			"is_ready":          sess.IsToolsListReady(),
 			"tools_count":       len(sess.GetAllTools()),
 			"bound_mcp_names":   h.listServiceNames(wsID),
 			"created_at":        sess.CreatedAt.UTC().Format(time.RFC3339),
 			"last_receive_time": sess.LastReceiveTime.UTC().Format(time.RFC3339),
+			"degraded":          sess.IsDegraded(),
+			"subscriptions":     sess.Subscriptions(),
+			"reconnects":        sess.ReconnectCount(),
+			"delivery":          sess.DeliveryStats(),
 			"recent_messages":   []interface{}{},
 		})
 	}
@@ -1926,6 +2018,10 @@ func serviceConfigToMap(cfg config.MCPServerConfig) map[string]interface{} {
 		"args":             append([]string(nil), cfg.Args...),
 		"env":              copyStringMap(cfg.Env),
 		"gateway_protocol": cfg.GatewayProtocol,
+		"stateful":         cfg.Stateful,
+		"cache":            cfg.Cache.ToMap(),
+		"type":             cfg.Type,
+		"remote_workspace": cfg.RemoteWorkspace,
 	}
 }
 
@@ -1949,6 +2045,10 @@ func serviceConfigFromMap(raw map[string]interface{}, workspaceID string) config
 	cfg.Args = asStringSlice(raw["args"])
 	cfg.Env = asStringMap(raw["env"])
 	cfg.GatewayProtocol = asString(raw["gateway_protocol"])
+	cfg.Stateful, _ = raw["stateful"].(bool)
+	cfg.Cache = config.ToolCacheConfigFromMap(raw["cache"])
+	cfg.Type = asString(raw["type"])
+	cfg.RemoteWorkspace = asString(raw["remote_workspace"])
 	if cfg.Env == nil {
 		cfg.Env = map[string]string{}
 	}
@@ -2475,10 +2575,19 @@ func (h *Handler) parseServiceRequest(ctx context.Context, workspaceID string, r
 		return name, cfg, meta, nil
 	}
 
+	if asString(raw["type"]) == config.ServiceTypeGateway {
+		if err := parseGatewayServiceRequest(raw, &cfg, &meta); err != nil {
+			return "", config.MCPServerConfig{}, serviceMeta{}, err
+		}
+		return name, cfg, meta, nil
+	}
+
 	if url := asString(raw["url"]); url != "" {
 		meta.SourceType = "url"
 		cfg.URL = url
 		cfg.GatewayProtocol = asGatewayProtocol(raw["gateway_protocol"])
+		cfg.Stateful, _ = raw["stateful"].(bool)
+		cfg.Cache = config.ToolCacheConfigFromMap(raw["cache"])
 		if err := h.applyRequestOAuth(ctx, raw["auth"], &cfg); err != nil {
 			return "", config.MCPServerConfig{}, serviceMeta{}, err
 		}
@@ -2494,6 +2603,8 @@ func (h *Handler) parseServiceRequest(ctx context.Context, workspaceID string, r
 	cfg.Args = asStringSlice(raw["args"])
 	cfg.Env = asStringMap(raw["env"])
 	cfg.GatewayProtocol = asGatewayProtocol(raw["gateway_protocol"])
+	cfg.Stateful, _ = raw["stateful"].(bool)
+	cfg.Cache = config.ToolCacheConfigFromMap(raw["cache"])
 	return name, cfg, meta, nil
 }
 
@@ -294,3 +294,29 @@ func TestHandleV1DeleteWorkspaceDoesNotRecreateEmptyRuntimeWorkspace(t *testing.
 	data := resp.Data.(map[string]interface{})
 	assert.Empty(t, data["items"])
 }
+
+func TestBuildServiceViewsIncludesPoolAndCacheStats(t *testing.T) {
+	h, mockServiceMgr := createTestServerManager()
+	arg := workspaces.NameArg{Workspace: "team-a"}
+	services := map[string]runtime.ExportMcpService{
+		"github": runtime.NewMcpService("github", config.MCPServerConfig{URL: "http://github.invalid/sse"}, runtime.NewPortManager()),
+		"notes":  runtime.NewMcpService("notes", config.MCPServerConfig{URL: "http://notes.invalid/sse", Stateful: true}, runtime.NewPortManager()),
+	}
+	mockServiceMgr.On("GetMcpServices", nilLogger{}, arg).Return(services)
+	mockServiceMgr.On("GetWorkspaceSessions", nilLogger{}, arg).Return([]*sessions.Session{})
+	mockServiceMgr.On("PoolStats", nilLogger{}, arg).Return(map[string]map[string]int{"github": {"connections": 2, "sessions": 5}})
+	mockServiceMgr.On("ToolCacheStats", nilLogger{}, arg).Return(map[string]sessions.ToolCacheStats{"notes": {Hits: 7, Misses: 3, Entries: 2}})
+
+	views := make(map[string]serviceView)
+	for _, view := range h.buildServiceViews("team-a") {
+		views[view.Name] = view
+	}
+	if assert.NotNil(t, views["github"].Pool) {
+		assert.Equal(t, servicePoolView{Connections: 2, Sessions: 5}, *views["github"].Pool)
+	}
+	assert.Nil(t, views["notes"].Pool)
+	if assert.NotNil(t, views["notes"].Cache) {
+		assert.Equal(t, sessions.ToolCacheStats{Hits: 7, Misses: 3, Entries: 2}, *views["notes"].Cache)
+	}
+	assert.Nil(t, views["github"].Cache)
+}
@@ -0,0 +1,231 @@
+package admin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+)
+
+// webhookView 是返回给 API 的 webhook，secret 只在创建时返回一次。
+type webhookView struct {
+	config.WebhookConfig
+	Secret    string `json:"secret,omitempty"`
+	HasSecret bool   `json:"hasSecret"`
+}
+
+type webhookRequest struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Secret 为空时创建会生成新的 secret，更新会保留原 secret
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	Workspaces  []string `json:"workspaces"`
+	Disabled    bool     `json:"disabled"`
+	MaxAttempts int      `json:"maxAttempts"`
+}
+
+func newWebhookView(hook config.WebhookConfig, withSecret bool) webhookView {
+	view := webhookView{WebhookConfig: hook, HasSecret: hook.Secret != ""}
+	if withSecret {
+		view.Secret = hook.Secret
+	}
+	return view
+}
+
+func (h *Handler) requireSystemAdmin(c echo.Context) error {
+	if !h.currentPrincipal(c).IsSystemAdmin {
+		return respondError(c, http.StatusForbidden, "FORBIDDEN", "webhooks require system admin", nil)
+	}
+	return nil
+}
+
+// handleV1ListWebhooks 返回配置的 webhook。
+func (h *Handler) handleV1ListWebhooks(c echo.Context) error {
+	if err := h.requireSystemAdmin(c); err != nil {
+		return err
+	}
+	h.mu.RLock()
+	views := make([]webhookView, 0, len(h.cfg.Webhooks))
+	for _, hook := range h.cfg.Webhooks {
+		views = append(views, newWebhookView(hook, false))
+	}
+	h.mu.RUnlock()
+	return respondOK(c, map[string]interface{}{"webhooks": views})
+}
+
+// handleV1GetWebhook 返回单个 webhook。
+func (h *Handler) handleV1GetWebhook(c echo.Context) error {
+	if err := h.requireSystemAdmin(c); err != nil {
+		return err
+	}
+	hook, ok := h.findWebhook(c.Param("id"))
+	if !ok {
+		return respondError(c, http.StatusNotFound, "NOT_FOUND", "webhook not found", nil)
+	}
+	return respondOK(c, newWebhookView(hook, false))
+}
+
+// handleV1CreateWebhook 新增 webhook，未提供 secret 时生成一个，响应中包含 secret。
+func (h *Handler) handleV1CreateWebhook(c echo.Context) error {
+	if err := h.requireSystemAdmin(c); err != nil {
+		return err
+	}
+	var req webhookRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	hook := req.config(uuid.NewString())
+	if hook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+		}
+		hook.Secret = secret
+	}
+	if err := hook.Validate(); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+
+	h.mu.Lock()
+	hooks := append(append([]config.WebhookConfig(nil), h.cfg.Webhooks...), hook)
+	if err := h.saveWebhooksLocked(hooks); err != nil {
+		h.mu.Unlock()
+		return respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+	}
+	h.mu.Unlock()
+
+	h.appendAudit(c, "webhook.create", "webhook", hook.ID, "", map[string]interface{}{"url": hook.URL, "events": hook.Events})
+	return respondCreated(c, newWebhookView(hook, true))
+}
+
+// handleV1UpdateWebhook 整体替换 webhook 的配置。
+func (h *Handler) handleV1UpdateWebhook(c echo.Context) error {
+	if err := h.requireSystemAdmin(c); err != nil {
+		return err
+	}
+	var req webhookRequest
+	if err := c.Bind(&req); err != nil {
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	id := c.Param("id")
+
+	h.mu.Lock()
+	hooks := append([]config.WebhookConfig(nil), h.cfg.Webhooks...)
+	index := -1
+	for i := range hooks {
+		if hooks[i].ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		h.mu.Unlock()
+		return respondError(c, http.StatusNotFound, "NOT_FOUND", "webhook not found", nil)
+	}
+	hook := req.config(id)
+	if hook.Secret == "" {
+		hook.Secret = hooks[index].Secret
+	}
+	if err := hook.Validate(); err != nil {
+		h.mu.Unlock()
+		return respondError(c, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+	}
+	hooks[index] = hook
+	if err := h.saveWebhooksLocked(hooks); err != nil {
+		h.mu.Unlock()
+		return respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+	}
+	h.mu.Unlock()
+
+	h.appendAudit(c, "webhook.update", "webhook", id, "", map[string]interface{}{"url": hook.URL, "events": hook.Events, "disabled": hook.Disabled})
+	return respondOK(c, newWebhookView(hook, false))
+}
+
+// handleV1DeleteWebhook 删除 webhook，已入队的投递不受影响。
+func (h *Handler) handleV1DeleteWebhook(c echo.Context) error {
+	if err := h.requireSystemAdmin(c); err != nil {
+		return err
+	}
+	id := c.Param("id")
+
+	h.mu.Lock()
+	hooks := make([]config.WebhookConfig, 0, len(h.cfg.Webhooks))
+	for _, hook := range h.cfg.Webhooks {
+		if hook.ID != id {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == len(h.cfg.Webhooks) {
+		h.mu.Unlock()
+		return respondError(c, http.StatusNotFound, "NOT_FOUND", "webhook not found", nil)
+	}
+	if err := h.saveWebhooksLocked(hooks); err != nil {
+		h.mu.Unlock()
+		return respondError(c, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error(), nil)
+	}
+	h.mu.Unlock()
+
+	h.appendAudit(c, "webhook.delete", "webhook", id, "", nil)
+	return respondOK(c, map[string]interface{}{"id": id, "deleted": true})
+}
+
+// handleV1WebhookDeliveries 返回 webhook 最近的投递记录。
+func (h *Handler) handleV1WebhookDeliveries(c echo.Context) error {
+	if err := h.requireSystemAdmin(c); err != nil {
+		return err
+	}
+	id := c.Param("id")
+	if _, ok := h.findWebhook(id); !ok {
+		return respondError(c, http.StatusNotFound, "NOT_FOUND", "webhook not found", nil)
+	}
+	return respondOK(c, map[string]interface{}{"deliveries": h.hooks.Deliveries(id)})
+}
+
+func (h *Handler) findWebhook(id string) (config.WebhookConfig, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, hook := range h.cfg.Webhooks {
+		if hook.ID == id {
+			return hook, true
+		}
+	}
+	return config.WebhookConfig{}, false
+}
+
+// saveWebhooksLocked 写回配置文件并让新的 webhook 列表立即生效，调用方需持有 h.mu。
+func (h *Handler) saveWebhooksLocked(hooks []config.WebhookConfig) error {
+	previous := h.cfg.Webhooks
+	h.cfg.Webhooks = hooks
+	if err := h.cfg.SaveConfig(); err != nil && h.cfg.CfgPath() != "" {
+		h.cfg.Webhooks = previous
+		return err
+	}
+	h.hooks.SetWebhooks(hooks)
+	return nil
+}
+
+func (r webhookRequest) config(id string) config.WebhookConfig {
+	return config.WebhookConfig{
+		ID:          id,
+		Name:        r.Name,
+		URL:         r.URL,
+		Secret:      r.Secret,
+		Events:      r.Events,
+		Workspaces:  r.Workspaces,
+		Disabled:    r.Disabled,
+		MaxAttempts: r.MaxAttempts,
+	}
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/webhook"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callWebhookAPI(t *testing.T, handler echo.HandlerFunc, method, id, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1/webhooks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if id != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+	assert.NoError(t, handler(c))
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp.Data
+}
+
+func TestWebhookCRUD(t *testing.T) {
+	h, _ := createTestServerManager()
+	h.hooks = webhook.New(xlog.NewLogger("test-webhook"), nil)
+	defer h.hooks.Close()
+
+	rec, created := callWebhookAPI(t, h.handleV1CreateWebhook, http.MethodPost, "", `{"name":"oncall","url":"https://hooks.example/ops","events":["service.*","tool.call_denied"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	id, _ := created["id"].(string)
+	secret, _ := created["secret"].(string)
+	require.NotEmpty(t, id)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"), "create should return the generated secret")
+	require.Len(t, h.cfg.Webhooks, 1)
+
+	// 之后的响应不再包含 secret
+	rec, listed := callWebhookAPI(t, h.handleV1ListWebhooks, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), secret)
+	assert.Len(t, listed["webhooks"], 1)
+
+	rec, updated := callWebhookAPI(t, h.handleV1UpdateWebhook, http.MethodPut, id, `{"name":"oncall","url":"https://hooks.example/v2","disabled":true}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, true, updated["hasSecret"])
+	assert.Equal(t, secret, h.cfg.Webhooks[0].Secret, "update without secret keeps the existing one")
+	assert.Equal(t, "https://hooks.example/v2", h.cfg.Webhooks[0].URL)
+
+	rec, _ = callWebhookAPI(t, h.handleV1UpdateWebhook, http.MethodPut, id, `{"url":"ftp://hooks.example"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, deliveries := callWebhookAPI(t, h.handleV1WebhookDeliveries, http.MethodGet, id, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, deliveries["deliveries"])
+
+	rec, _ = callWebhookAPI(t, h.handleV1DeleteWebhook, http.MethodDelete, id, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, h.cfg.Webhooks)
+	rec, _ = callWebhookAPI(t, h.handleV1GetWebhook, http.MethodGet, id, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestWebhooksRequireSystemAdmin(t *testing.T) {
+	h, _ := createTestServerManager()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/webhooks", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("auth.principal", &identity.Principal{AccountID: "acc-1", Role: identity.RoleWorkspaceOwner})
+	assert.NoError(t, h.handleV1ListWebhooks(c))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
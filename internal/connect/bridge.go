@@ -0,0 +1,500 @@
+// Package connect 实现 `mcp-gateway connect`：在本地以 stdio MCP server 的形式运行，
+// 把每条消息转发到远端网关的 Streamable HTTP 入口（/stream），供只支持 stdio 的桌面 client 使用。
+package connect
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	headerMcpSessionID       = "Mcp-Session-Id"
+	headerMcpProtocolVersion = "Mcp-Protocol-Version"
+	headerLastEventID        = "Last-Event-ID"
+	headerWorkspaceID        = "X-Workspace-Id"
+
+	methodNotificationsInitialized = "notifications/initialized"
+
+	// GET 事件流断开后的重连退避
+	streamRetryMin = time.Second
+	streamRetryMax = 30 * time.Second
+)
+
+// Options 配置桥接的远端网关。
+type Options struct {
+	// URL 为远端网关的 /stream 地址，例如 https://gateway.example.com/stream
+	URL string
+	// Workspace 为空时使用网关的默认 workspace
+	Workspace string
+	// Token 为网关 API key 或 OAuth access token，以 Bearer 方式发送
+	Token string
+	// HTTPClient 为空时使用不设超时的默认 client（GET 事件流是长连接）
+	HTTPClient *http.Client
+}
+
+// Bridge 把 stdio 上按行分隔的 JSON-RPC 消息转发到远端网关，并把响应与服务端推送写回 stdout。
+// 会话失效（网关返回 404）时用 client 最初的 initialize 请求重新握手，对 stdio client 透明。
+type Bridge struct {
+	opts   Options
+	client *http.Client
+	xl     xlog.Logger
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+	initRequest     []byte
+	initialized     bool
+	lastEventID     string
+	streamStarted   bool
+
+	// reinitMu 保证并发请求同时遇到会话失效时只重新握手一次
+	reinitMu sync.Mutex
+}
+
+// New 创建一个 Bridge。
+func New(opts Options) (*Bridge, error) {
+	if strings.TrimSpace(opts.URL) == "" {
+		return nil, errors.New("gateway url is required")
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Bridge{opts: opts, client: client, xl: xlog.NewLogger("CONNECT")}, nil
+}
+
+// Run 从 in 读取 client 消息直到 EOF 或 ctx 结束，结束时删除远端 session。
+// initialize 与通知按顺序同步转发，带 id 的请求并发转发，响应按完成顺序写回。
+func (b *Bridge) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	b.out = out
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var inflight sync.WaitGroup
+	var err error
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case err = <-readErr:
+			break loop
+		case line := <-lines:
+			peek := peekMessage(line)
+			if peek.Method == string(mcp.MethodInitialize) || peek.isNotification() {
+				b.forward(ctx, line, peek)
+				continue
+			}
+			inflight.Add(1)
+			go func() {
+				defer inflight.Done()
+				b.forward(ctx, line, peek)
+			}()
+		}
+	}
+	inflight.Wait()
+	cancel()
+	b.closeSession()
+	return err
+}
+
+// forward 转发一条 client 消息并把结果写回 stdout；请求失败时给带 id 的请求回写 JSON-RPC 错误。
+func (b *Bridge) forward(ctx context.Context, body []byte, peek messagePeek) {
+	if peek.Method == string(mcp.MethodInitialize) {
+		b.mu.Lock()
+		b.initRequest = append([]byte(nil), body...)
+		b.mu.Unlock()
+	}
+	resp, err := b.post(ctx, body, peek)
+	if err != nil {
+		b.xl.Errorf("forward %s failed: %v", peek.describe(), err)
+		b.writeError(peek.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if peek.Method == string(mcp.MethodInitialize) && resp.StatusCode == http.StatusOK {
+		body, err := b.startSession(resp)
+		if err != nil {
+			b.xl.Errorf("initialize failed: %v", err)
+			b.writeError(peek.ID, err)
+			return
+		}
+		b.writeLine(body)
+		return
+	}
+	if err := b.relay(resp); err != nil {
+		b.xl.Errorf("relay response of %s failed: %v", peek.describe(), err)
+		b.writeError(peek.ID, err)
+		return
+	}
+	if peek.Method == methodNotificationsInitialized {
+		b.mu.Lock()
+		b.initialized = true
+		start := !b.streamStarted
+		b.streamStarted = true
+		b.mu.Unlock()
+		if start {
+			go b.listen(ctx)
+		}
+	}
+}
+
+// post 发送一条消息；session 已失效时重新握手并重试一次。
+func (b *Bridge) post(ctx context.Context, body []byte, peek messagePeek) (*http.Response, error) {
+	b.mu.Lock()
+	sessionID := b.sessionID
+	b.mu.Unlock()
+	resp, err := b.do(ctx, http.MethodPost, body, sessionID, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNotFound || sessionID == "" || peek.Method == string(mcp.MethodInitialize) {
+		return resp, nil
+	}
+	resp.Body.Close()
+	b.xl.Warnf("gateway session %s expired, re-initializing", sessionID)
+	if err := b.reinitialize(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	sessionID = b.sessionID
+	b.mu.Unlock()
+	return b.do(ctx, http.MethodPost, body, sessionID, "")
+}
+
+// reinitialize 用 client 最初的 initialize 请求建立新 session；stale 已被其它请求替换时直接返回。
+func (b *Bridge) reinitialize(ctx context.Context, stale string) error {
+	b.reinitMu.Lock()
+	defer b.reinitMu.Unlock()
+	b.mu.Lock()
+	current, initRequest, initialized := b.sessionID, b.initRequest, b.initialized
+	b.mu.Unlock()
+	if current != stale {
+		return nil
+	}
+	if initRequest == nil {
+		return errors.New("gateway session expired before initialize")
+	}
+
+	resp, err := b.do(ctx, http.MethodPost, initRequest, "", "")
+	if err != nil {
+		return fmt.Errorf("re-initialize: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("re-initialize: gateway returned %s", resp.Status)
+	}
+	if _, err := b.startSession(resp); err != nil {
+		return fmt.Errorf("re-initialize: %w", err)
+	}
+	b.mu.Lock()
+	sessionID := b.sessionID
+	b.mu.Unlock()
+
+	if initialized {
+		notification := []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+		resp, err := b.do(ctx, http.MethodPost, notification, sessionID, "")
+		if err != nil {
+			return fmt.Errorf("re-initialize: %w", err)
+		}
+		resp.Body.Close()
+	}
+	b.xl.Infof("gateway session re-established: %s", sessionID)
+	return nil
+}
+
+// listen 维持 GET /stream 事件流，把服务端推送写回 stdout；断线后携带 Last-Event-ID 重连。
+func (b *Bridge) listen(ctx context.Context) {
+	backoff := streamRetryMin
+	for ctx.Err() == nil {
+		b.mu.Lock()
+		sessionID, lastEventID := b.sessionID, b.lastEventID
+		b.mu.Unlock()
+
+		resp, err := b.do(ctx, http.MethodGet, nil, sessionID, lastEventID)
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				b.xl.Warnf("event stream connect failed: %v", err)
+			}
+		case resp.StatusCode == http.StatusMethodNotAllowed:
+			// 网关不提供服务端推送流
+			resp.Body.Close()
+			return
+		case resp.StatusCode == http.StatusNotFound:
+			// 重新握手后同样退避，避免网关持续返回 404 时反复握手
+			resp.Body.Close()
+			if err := b.reinitialize(ctx, sessionID); err != nil {
+				b.xl.Warnf("event stream: %v", err)
+			}
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			b.xl.Warnf("event stream rejected: %s", resp.Status)
+		default:
+			received := false
+			err := readEvents(resp.Body, func(id, data string) {
+				received = true
+				if id != "" {
+					b.mu.Lock()
+					if b.sessionID == sessionID {
+						b.lastEventID = id
+					}
+					b.mu.Unlock()
+				}
+				b.writeLine([]byte(data))
+			})
+			resp.Body.Close()
+			if err != nil && ctx.Err() == nil {
+				b.xl.Infof("event stream interrupted: %v", err)
+			}
+			// 只有收到过事件的流才重置退避，连上即断开的流继续退避
+			if received {
+				backoff = streamRetryMin
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, streamRetryMax)
+	}
+}
+
+// relay 把网关对一条 POST 的应答写回 stdout：JSON 直接写出，SSE 逐个事件写出。
+func (b *Bridge) relay(resp *http.Response) error {
+	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	mediaType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(mediaType, "text/event-stream"):
+		return readEvents(resp.Body, func(_, data string) { b.writeLine([]byte(data)) })
+	case strings.HasPrefix(mediaType, "application/json"):
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if body = bytes.TrimSpace(body); len(body) > 0 {
+			b.writeLine(body)
+		}
+		return nil
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if msg := strings.TrimSpace(string(text)); msg != "" {
+			return fmt.Errorf("gateway returned %s: %s", resp.Status, msg)
+		}
+		return fmt.Errorf("gateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+// closeSession 在 stdin 关闭后删除远端 session。
+func (b *Bridge) closeSession() {
+	b.mu.Lock()
+	sessionID := b.sessionID
+	b.mu.Unlock()
+	if sessionID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := b.do(ctx, http.MethodDelete, nil, sessionID, "")
+	if err != nil {
+		b.xl.Warnf("delete gateway session %s failed: %v", sessionID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// do 构造并发送一个带鉴权、workspace 与会话头的 HTTP 请求。
+func (b *Bridge) do(ctx context.Context, method string, body []byte, sessionID, lastEventID string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.opts.URL, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json, text/event-stream")
+	}
+	if b.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.opts.Token)
+	}
+	if b.opts.Workspace != "" {
+		req.Header.Set(headerWorkspaceID, b.opts.Workspace)
+	}
+	if sessionID != "" {
+		req.Header.Set(headerMcpSessionID, sessionID)
+		b.mu.Lock()
+		version := b.protocolVersion
+		b.mu.Unlock()
+		if version != "" {
+			req.Header.Set(headerMcpProtocolVersion, version)
+		}
+	}
+	if lastEventID != "" {
+		req.Header.Set(headerLastEventID, lastEventID)
+	}
+	return b.client.Do(req)
+}
+
+// startSession 读取 initialize 的应答，记录 session id 与协商出的协议版本，返回响应体。
+func (b *Bridge) startSession(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode initialize response: %w", err)
+	}
+	b.mu.Lock()
+	b.sessionID = resp.Header.Get(headerMcpSessionID)
+	b.protocolVersion = result.Result.ProtocolVersion
+	// 新 session 的事件 id 与旧 session 无关
+	b.lastEventID = ""
+	b.mu.Unlock()
+	return bytes.TrimSpace(body), nil
+}
+
+// writeLine 写出一条消息到 stdout，每条消息占一行。
+func (b *Bridge) writeLine(data []byte) {
+	b.outMu.Lock()
+	defer b.outMu.Unlock()
+	line := append(bytes.TrimSpace(data), '\n')
+	if _, err := b.out.Write(line); err != nil {
+		b.xl.Errorf("write stdout failed: %v", err)
+	}
+}
+
+// writeError 给带 id 的请求回写 JSON-RPC 错误，通知无需应答。
+func (b *Bridge) writeError(id json.RawMessage, err error) {
+	if len(id) == 0 || bytes.Equal(id, []byte("null")) {
+		return
+	}
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"id":      id,
+		"error":   map[string]any{"code": mcp.INTERNAL_ERROR, "message": err.Error()},
+	})
+	b.writeLine(data)
+}
+
+// messagePeek 抽取转发需要的 JSON-RPC 字段；批量消息不做解析，按请求处理。
+type messagePeek struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	batch  bool
+}
+
+func peekMessage(body []byte) messagePeek {
+	var peek messagePeek
+	if bytes.HasPrefix(body, []byte("[")) {
+		peek.batch = true
+		return peek
+	}
+	_ = json.Unmarshal(body, &peek)
+	return peek
+}
+
+// isNotification 对通知以及 client 对服务端请求的应答返回 true，二者都不需要等待结果。
+func (p messagePeek) isNotification() bool {
+	if p.batch {
+		return false
+	}
+	id := bytes.TrimSpace(p.ID)
+	return len(id) == 0 || bytes.Equal(id, []byte("null")) || p.Method == ""
+}
+
+func (p messagePeek) describe() string {
+	switch {
+	case p.batch:
+		return "batch"
+	case p.Method != "":
+		return p.Method
+	default:
+		return "response " + string(p.ID)
+	}
+}
+
+// readEvents 解析 SSE 流，按事件回调 id 与 data（多行 data 以换行拼接）。
+func readEvents(r io.Reader, fn func(id, data string)) error {
+	reader := bufio.NewReader(r)
+	var id string
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "" && err == nil:
+			if len(data) > 0 {
+				fn(id, strings.Join(data, "\n"))
+			}
+			id, data = "", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package connect
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGateway 模拟网关 /stream：每次 initialize 建立一个新 session，expire 使当前 session 失效。
+type fakeGateway struct {
+	mu       sync.Mutex
+	sessions int
+	current  string
+	headers  []http.Header
+	methods  []string
+}
+
+func (g *fakeGateway) expire() {
+	g.mu.Lock()
+	g.current = ""
+	g.mu.Unlock()
+}
+
+func (g *fakeGateway) forwarded() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.methods)
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.headers = append(g.headers, r.Header.Clone())
+	switch r.Method {
+	case http.MethodGet:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(body, &msg)
+	g.methods = append(g.methods, msg.Method)
+
+	if msg.Method == "initialize" {
+		g.sessions++
+		g.current = fmt.Sprintf("s%d", g.sessions)
+		w.Header().Set(headerMcpSessionID, g.current)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-03-26"}}`, msg.ID)
+		return
+	}
+	if r.Header.Get(headerMcpSessionID) != g.current || g.current == "" {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if len(msg.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+	fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{}}\n\n", msg.ID)
+}
+
+func TestBridgeReinitializesExpiredSession(t *testing.T) {
+	gateway := &fakeGateway{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	bridge, err := New(Options{URL: server.URL, Workspace: "team", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- bridge.Run(context.Background(), stdinReader, stdoutWriter) }()
+	out := bufio.NewReader(stdoutReader)
+	readLine := func() string {
+		t.Helper()
+		line, err := out.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(line)
+	}
+	send := func(msg string) {
+		t.Helper()
+		if _, err := io.WriteString(stdinWriter, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	if line := readLine(); !strings.Contains(line, `"protocolVersion":"2025-03-26"`) {
+		t.Fatalf("unexpected initialize response: %s", line)
+	}
+	send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	for deadline := time.Now().Add(2 * time.Second); gateway.forwarded() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("notifications/initialized was not forwarded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	gateway.expire()
+	send(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if line := readLine(); !strings.Contains(line, `notifications/progress`) {
+		t.Fatalf("streamed notification should be relayed first, got %s", line)
+	}
+	if line := readLine(); line != `{"jsonrpc":"2.0","id":2,"result":{}}` {
+		t.Fatalf("request should succeed on the new session, got %s", line)
+	}
+
+	if err := stdinWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("bridge should exit after stdin is closed")
+	}
+
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+	if gateway.sessions != 2 {
+		t.Fatalf("expected one re-initialize, got %d sessions", gateway.sessions)
+	}
+	want := []string{"initialize", "notifications/initialized", "tools/list", "initialize", "notifications/initialized", "tools/list"}
+	if strings.Join(gateway.methods, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected forwarded methods: %v", gateway.methods)
+	}
+	last := gateway.headers[len(gateway.headers)-1]
+	if last.Get("Authorization") != "Bearer secret" || last.Get(headerWorkspaceID) != "team" || last.Get(headerMcpSessionID) != "s2" {
+		t.Fatalf("requests should carry auth, workspace and the new session id, got %v", last)
+	}
+}
+
+func TestBridgeListenBacksOffWhenStreamKeepsExpiring(t *testing.T) {
+	var mu sync.Mutex
+	gets, inits := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodGet {
+			gets++
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		inits++
+		w.Header().Set(headerMcpSessionID, fmt.Sprintf("s%d", inits+1))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26"}}`)
+	}))
+	defer server.Close()
+
+	bridge, err := New(Options{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge.sessionID = "s1"
+	bridge.initRequest = []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	ctx, cancel := context.WithTimeout(context.Background(), streamRetryMin/2)
+	defer cancel()
+	bridge.listen(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 重新握手成功后也应退避，而不是立即再次 GET
+	if gets != 1 || inits != 1 {
+		t.Fatalf("expected one GET and one re-initialize within the first backoff, got %d GETs and %d initializes", gets, inits)
+	}
+}
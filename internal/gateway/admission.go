@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// quotaExceededCode 是月度配额用尽时返回的 JSON-RPC 错误码，位于服务端自定义范围。
+const quotaExceededCode = -32030
+
+// toolCallRejection 描述 tools/call 在转发前被拒绝的原因。
+type toolCallRejection struct {
+	code    int
+	message string
+	data    map[string]any
+	// retryAfter 为 Retry-After 头的秒数
+	retryAfter int
+}
+
+func (r *toolCallRejection) Error() string {
+	return r.message
+}
+
+// admitToolCall 在转发 tools/call 前执行准入检查，其它请求总是放行。
+func (h *Handler) admitToolCall(ctx context.Context, principal *identity.Principal, workspace, sessionID string, body []byte, detail map[string]interface{}) *toolCallRejection {
+	tool := calledTool(body)
+	if tool == "" {
+		return nil
+	}
+	return h.admitTool(ctx, principal, workspace, sessionID, tool, detail)
+}
+
+// admitPassthrough 对单服务直通入口（/:service 与 /:service/...）转发的 tools/call 执行与 /stream
+// 相同的准入检查，工具名按聚合入口的 <service>_<tool> 计。直通入口不经过 session，无法改写参数或
+// 等待审批，因此 workspace 的策略或审批可能作用于该工具时直接拒绝，调用方需改用 /stream。
+// 返回 nil 时请求体已还原，可以照常转发；批量请求中含 tools/call 时整体拒绝。
+func (h *Handler) admitPassthrough(c echo.Context, xl xlog.Logger, workspace, service string) (json.RawMessage, *toolCallRejection) {
+	in := c.Request()
+	if in.Method != http.MethodPost || in.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, &toolCallRejection{code: mcp.PARSE_ERROR, message: "failed to read request body"}
+	}
+	in.Body = io.NopCloser(bytes.NewReader(body))
+
+	if isJSONRPCBatch(body) {
+		members, _ := splitJSONRPCBatch(body)
+		for _, member := range members {
+			if calledTool(member) != "" {
+				return nil, &toolCallRejection{code: mcp.INVALID_REQUEST, message: "batched tools/call is not supported on the single-service endpoint, use /stream"}
+			}
+		}
+		return nil, nil
+	}
+	name := calledTool(body)
+	if name == "" {
+		return nil, nil
+	}
+	peek, _ := peekJSONRPC(body)
+	tool := service + "_" + name
+	principal := gatewayPrincipal(c)
+	detail := rpcLogDetail(rpcLogInfoFromBody(body, ""), "passthrough")
+	if h.services.GuardsTool(xl, workspaces.NameArg{Workspace: workspace}, tool) {
+		message := "tool " + tool + " is subject to workspace policies or approval, call it through /stream"
+		h.appendOperation(in.Context(), principal, oplog.LevelWarn, "tool.call_denied", workspace, "", "Tool call denied on passthrough: "+tool, message, withDetail(detail, map[string]interface{}{
+			"tool":      tool,
+			"denied_by": "policy",
+		}))
+		return peek.ID, &toolCallRejection{code: sessions.PolicyDeniedCode, message: message, data: map[string]any{"tool": tool}}
+	}
+	return peek.ID, h.admitTool(in.Context(), principal, workspace, "", tool, detail)
+}
+
+// passthroughRejectionStatus 返回 SSE message 直通入口拒绝调用时的 HTTP 状态码，
+// 与 /message 一致，限流与配额返回 429。
+func passthroughRejectionStatus(r *toolCallRejection) int {
+	switch r.code {
+	case rateLimitedCode, quotaExceededCode:
+		return http.StatusTooManyRequests
+	case sessions.PolicyDeniedCode:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// compositeStepAdmitter 让组合工具的每个步骤像直接调用该工具一样经过准入检查，
+// 避免通过组合工具绕过按工具、按服务的限流。
+func (h *Handler) compositeStepAdmitter(principal *identity.Principal, workspace, sessionID string) sessions.ToolAdmitter {
+	return func(ctx context.Context, tool string) error {
+		if rejected := h.admitTool(ctx, principal, workspace, sessionID, tool, map[string]interface{}{"composite_step": true}); rejected != nil {
+			return rejected
+		}
+		return nil
+	}
+}
+
+// admitTool 依次检查月度配额与限流，放行时计入一次下游工具调用；拒绝时记录 tool.call_denied
+// 操作日志，denied_by 区分配额与限流。
+func (h *Handler) admitTool(ctx context.Context, principal *identity.Principal, workspace, sessionID, tool string, detail map[string]interface{}) *toolCallRejection {
+	account := usageAccount(principal)
+	if exceeded := h.meter.CheckQuota(ctx, account, workspace); exceeded != nil {
+		rejection := quotaRejection(exceeded)
+		h.appendOperation(ctx, principal, oplog.LevelWarn, "tool.call_denied", workspace, sessionID, "Tool call quota exceeded: "+tool, rejection.message, withDetail(detail, map[string]interface{}{
+			"tool":         tool,
+			"denied_by":    "quota",
+			"quota_scope":  exceeded.Scope,
+			"quota_metric": exceeded.Metric,
+			"quota_limit":  exceeded.Limit,
+		}))
+		return rejection
+	}
+	if decision := h.limits.Allow(ratelimit.Request{Principal: rateLimitPrincipal(principal), Workspace: workspace, Tool: tool}); !decision.Allowed {
+		h.appendOperation(ctx, principal, oplog.LevelWarn, "tool.call_denied", workspace, sessionID, "Tool call rate limited: "+tool, rateLimitMessage(decision), withDetail(detail, map[string]interface{}{
+			"tool":            tool,
+			"denied_by":       "rate_limit",
+			"rate_limit_rule": decision.Rule,
+			"retry_after_ms":  decision.RetryAfter.Milliseconds(),
+		}))
+		return &toolCallRejection{code: rateLimitedCode, message: rateLimitMessage(decision), data: rateLimitErrorData(decision), retryAfter: retryAfterSeconds(decision)}
+	}
+	// 网关自身的工具（组合工具、元工具）不计入调用次数，组合工具的每个步骤调用下游时各自计入，
+	// 按工具与服务的用量和配额与直接调用一致
+	if service, _ := splitGatewayToolName(tool); service != sessions.GatewayNamespace {
+		h.meter.RecordToolCall(account, workspace, service)
+	}
+	return nil
+}
+
+// quotaRejection 把配额错误转换为 JSON-RPC 错误，client 可据 data 判断何时重置。
+func quotaRejection(e *usage.QuotaExceededError) *toolCallRejection {
+	retryAfter := int(math.Max(1, math.Ceil(time.Until(e.ResetAt).Seconds())))
+	return &toolCallRejection{
+		code:    quotaExceededCode,
+		message: e.Error(),
+		data: map[string]any{
+			"scope":      e.Scope,
+			"metric":     e.Metric,
+			"limit":      e.Limit,
+			"used":       e.Used,
+			"period":     e.Period,
+			"resetAt":    e.ResetAt.Format(time.RFC3339),
+			"retryAfter": retryAfter,
+		},
+		retryAfter: retryAfter,
+	}
+}
+
+// withDetail 复制操作日志 detail 并追加字段。
+func withDetail(detail map[string]interface{}, extra map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(detail)+len(extra))
+	for k, v := range detail {
+		fields[k] = v
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	return fields
+}
+
+// setRetryAfter 在 HTTP 响应上设置 Retry-After 头。
+func setRetryAfter(c echo.Context, r *toolCallRejection) {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(r.retryAfter))
+}
@@ -0,0 +1,218 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// isJSONRPCBatch 判断请求体是否为 JSON-RPC 批量请求（顶层为数组）。
+func isJSONRPCBatch(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// splitJSONRPCBatch 拆分批量请求的成员，空数组按规范视为无效请求。
+func splitJSONRPCBatch(body []byte) ([]json.RawMessage, error) {
+	var members []json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("empty batch")
+	}
+	return members, nil
+}
+
+// batchUnsupportedMessage 在 session 协商的协议版本不允许批量请求时返回错误描述。
+func batchUnsupportedMessage(session *sessions.Session) string {
+	if session.SupportsBatching() {
+		return ""
+	}
+	version := session.ProtocolVersion()
+	if version == "" {
+		version = sessions.LatestProtocolVersion
+	}
+	return fmt.Sprintf("JSON-RPC batching is not supported by protocol version %s", version)
+}
+
+func batchLogDetail(info rpcLogInfo, transport string, index, size int) map[string]interface{} {
+	detail := rpcLogDetail(info, transport)
+	detail["batch_index"] = index
+	detail["batch_size"] = size
+	return detail
+}
+
+// handleMessageBatch 处理 SSE 传输 POST /message 上的批量请求，响应照常逐条通过 SSE 流下发。
+func (h *Handler) handleMessageBatch(c echo.Context, xl xlog.Logger, workspace string, session *sessions.Session, body []byte) error {
+	if msg := h.dispatchMessageBatch(c.Request().Context(), gatewayPrincipal(c), xl, workspace, session, body, "sse-message"); msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
+	return c.String(http.StatusOK, "Accepted")
+}
+
+// dispatchMessageBatch 把批量请求的各成员并发交给 session，响应由 session 事件流逐条下发，
+// 每个成员单独记录操作日志。整个批量请求无效时返回错误描述。
+func (h *Handler) dispatchMessageBatch(ctx context.Context, principal *identity.Principal, xl xlog.Logger, workspace string, session *sessions.Session, body []byte, transport string) string {
+	members, err := splitJSONRPCBatch(body)
+	if err != nil {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.message_failed", workspace, session.Id, "session message failed", err.Error(), map[string]interface{}{"transport": transport})
+		return fmt.Sprintf("invalid batch: %v", err)
+	}
+	if msg := batchUnsupportedMessage(session); msg != "" {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.message_failed", workspace, session.Id, "session message failed", msg, map[string]interface{}{"transport": transport})
+		return msg
+	}
+
+	// SSE 的 POST 在响应下发前就已返回，只有 WebSocket 的 ctx 代表 client 连接仍在
+	callCtx := context.Background()
+	if transport == "websocket" {
+		callCtx = ctx
+	}
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member json.RawMessage) {
+			defer wg.Done()
+			info := rpcLogInfoFromBody(member, "")
+			detail := batchLogDetail(info, transport, i, len(members))
+			if errMsg := batchMemberError(member); errMsg != "" {
+				h.appendOperation(ctx, principal, oplog.LevelError, info.Action+"_failed", workspace, session.Id, info.Message+" failed", errMsg, detail)
+				peek, _ := peekJSONRPC(member)
+				sendSessionError(session, peek.ID, mcp.INVALID_REQUEST, errMsg, nil)
+				return
+			}
+			if rejected := h.admitToolCall(ctx, principal, workspace, session.Id, member, detail); rejected != nil {
+				peek, _ := peekJSONRPC(member)
+				sendSessionError(session, peek.ID, rejected.code, rejected.message, rejected.data)
+				return
+			}
+			if err := session.SendMessageContext(callCtx, xl, member); err != nil {
+				h.appendOperation(ctx, principal, oplog.LevelError, info.Action+"_failed", workspace, session.Id, info.Message+" failed", err.Error(), detail)
+				return
+			}
+			h.appendOperation(ctx, principal, oplog.LevelInfo, info.Action, workspace, session.Id, info.Message, "", detail)
+		}(i, member)
+	}
+	wg.Wait()
+	return ""
+}
+
+// sendSessionError 通过 session 事件流下发一条 JSON-RPC 错误响应。
+func sendSessionError(session *sessions.Session, id json.RawMessage, code int, message string, data any) {
+	if data, err := json.Marshal(jsonRPCErrorPayload(id, code, message, data)); err == nil {
+		session.SendEvent(sessions.SessionMsg{Event: "message", Data: string(data)})
+	}
+}
+
+// batchMemberError 校验批量请求中的单个成员，initialize 不允许出现在批量请求中。
+func batchMemberError(member json.RawMessage) string {
+	peek, err := peekJSONRPC(member)
+	if err != nil || peek.Method == "" {
+		return "invalid request"
+	}
+	if peek.Method == string(mcp.MethodInitialize) {
+		return "initialize must not be part of a JSON-RPC batch"
+	}
+	return ""
+}
+
+// streamHTTPHandleBatch 处理 POST /stream 上的批量请求：各成员并发转发到对应服务，
+// request 的响应按成员顺序组成批量响应返回；全部为 notification 时返回 202。
+func (h *Handler) streamHTTPHandleBatch(c echo.Context, xl xlog.Logger, workspace string, body []byte) error {
+	ctx := c.Request().Context()
+	principal := gatewayPrincipal(c)
+	sessionID := c.Request().Header.Get(headerMcpSessionID)
+	members, err := splitJSONRPCBatch(body)
+	if err != nil {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.request_failed", workspace, sessionID, "Failed to parse MCP batch", err.Error(), map[string]interface{}{"transport": "streamhttp"})
+		return writeJSONRPCError(c, http.StatusBadRequest, nil, mcp.INVALID_REQUEST, "invalid batch", err.Error())
+	}
+	if sessionID == "" {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.request_failed", workspace, "", "MCP request rejected", fmt.Sprintf("missing %s header", headerMcpSessionID), map[string]interface{}{"transport": "streamhttp"})
+		return writeJSONRPCError(c, http.StatusBadRequest, nil, mcp.INVALID_REQUEST, fmt.Sprintf("missing %s header", headerMcpSessionID), nil)
+	}
+	session, ok := h.lookupSession(c, xl, workspace, sessionID)
+	if !ok {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.request_failed", workspace, sessionID, "MCP request rejected", "session not found", map[string]interface{}{"transport": "streamhttp"})
+		return c.String(http.StatusNotFound, "session not found")
+	}
+	msg := checkProtocolVersionHeader(c, session)
+	if msg == "" {
+		msg = batchUnsupportedMessage(session)
+	}
+	if msg != "" {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.request_failed", workspace, sessionID, "MCP request rejected", msg, map[string]interface{}{"transport": "streamhttp"})
+		return writeJSONRPCError(c, http.StatusBadRequest, nil, mcp.INVALID_REQUEST, msg, nil)
+	}
+
+	responses := make([]interface{}, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member json.RawMessage) {
+			defer wg.Done()
+			responses[i] = h.streamHTTPBatchMember(ctx, xl, principal, workspace, session, member, i, len(members))
+		}(i, member)
+	}
+	wg.Wait()
+
+	replies := make([]interface{}, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			replies = append(replies, resp)
+		}
+	}
+	if len(replies) == 0 {
+		return c.NoContent(http.StatusAccepted)
+	}
+	c.Response().Header().Set("Content-Type", "application/json")
+	return c.JSON(http.StatusOK, replies)
+}
+
+// streamHTTPBatchMember 处理批量请求中的单个成员并记录操作日志，返回该成员的响应；
+// notification 没有响应，返回 nil。
+func (h *Handler) streamHTTPBatchMember(ctx context.Context, xl xlog.Logger, principal *identity.Principal, workspace string, session *sessions.Session, member json.RawMessage, index, size int) interface{} {
+	info := rpcLogInfoFromBody(member, "")
+	detail := batchLogDetail(info, "streamhttp", index, size)
+	peek, _ := peekJSONRPC(member)
+	if errMsg := batchMemberError(member); errMsg != "" {
+		h.appendOperation(ctx, principal, oplog.LevelError, info.Action+"_failed", workspace, session.Id, info.Message+" failed", errMsg, detail)
+		return jsonRPCErrorPayload(peek.ID, mcp.INVALID_REQUEST, errMsg, nil)
+	}
+
+	if peek.IsNotification() {
+		if peek.Method != methodNotificationsInit {
+			if err := session.SendMessage(xl, member); err != nil {
+				h.appendOperation(ctx, principal, oplog.LevelError, info.Action+"_failed", workspace, session.Id, info.Message+" failed", err.Error(), detail)
+				return nil
+			}
+		}
+		h.appendOperation(ctx, principal, oplog.LevelInfo, info.Action, workspace, session.Id, info.Message, "", detail)
+		return nil
+	}
+
+	if rejected := h.admitToolCall(ctx, principal, workspace, session.Id, member, detail); rejected != nil {
+		return jsonRPCErrorPayload(peek.ID, rejected.code, rejected.message, rejected.data)
+	}
+
+	started := time.Now()
+	out := forwardAndAwait(ctx, xl, session, member, peek.ID)
+	level, action, message, errText := forwardLogFields(out, info, detail, started)
+	h.appendOperation(ctx, principal, level, action, workspace, session.Id, message, errText, detail)
+	if out.Failure != "" {
+		return jsonRPCErrorPayload(peek.ID, -32000, out.Message, out.ErrData)
+	}
+	return json.RawMessage(out.Data)
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func batchTestSession(t *testing.T, id, version string) *sessions.Session {
+	t.Helper()
+	sess := newTestSession(id)
+	t.Cleanup(sess.Close)
+	_, err := sess.Initialize(mcp.InitializeParams{ProtocolVersion: version})
+	assert.NoError(t, err)
+	return sess
+}
+
+// --- POST /stream 批量请求：按成员顺序返回批量响应，notification 不产生响应 ---
+func TestGlobalStreamHTTP_Batch_CollectsResponsesInOrder(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	sess := batchTestSession(t, "sess-batch", sessions.ProtocolVersion20250326)
+	mockMgr.On("GetProxySession", mock.Anything, mock.MatchedBy(func(n workspaces.NameArg) bool {
+		return n.Session == "sess-batch"
+	})).Return(sess, true).Once()
+
+	body := `[
+		{"jsonrpc":"2.0","id":1,"method":"ping"},
+		{"jsonrpc":"2.0","id":2,"method":"initialize","params":{}},
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":"x","method":"resources/read","params":{"uri":"gateway://results/missing"}}
+	]`
+	c, rec := buildStreamHTTPRequest(t, http.MethodPost, body, map[string]string{"Mcp-Session-Id": "sess-batch"})
+
+	assert.NoError(t, srv.handleGlobalStreamHTTP(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var replies []map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &replies))
+	if assert.Len(t, replies, 3) {
+		assert.EqualValues(t, 1, replies[0]["id"])
+		assert.EqualValues(t, 2, replies[1]["id"])
+		assert.EqualValues(t, mcp.INVALID_REQUEST, replies[1]["error"].(map[string]any)["code"])
+		assert.Equal(t, "x", replies[2]["id"])
+		assert.Contains(t, replies[2]["error"].(map[string]any)["message"], "not found")
+	}
+	mockMgr.AssertExpectations(t)
+}
+
+// --- 协商版本不支持批量请求时整体拒绝 ---
+func TestGlobalStreamHTTP_Batch_RejectedWhenVersionDisallows(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	sess := batchTestSession(t, "sess-nobatch", sessions.ProtocolVersion20250618)
+	mockMgr.On("GetProxySession", mock.Anything, mock.Anything).Return(sess, true).Once()
+
+	body := `[{"jsonrpc":"2.0","id":1,"method":"ping"}]`
+	c, rec := buildStreamHTTPRequest(t, http.MethodPost, body, map[string]string{"Mcp-Session-Id": "sess-nobatch"})
+
+	assert.NoError(t, srv.handleGlobalStreamHTTP(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "batching is not supported")
+}
+
+// --- POST /message 批量请求：响应逐条通过 SSE 流下发 ---
+func TestGlobalMessage_Batch_StreamsResponsesIndividually(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	sess := batchTestSession(t, "sess-sse-batch", sessions.ProtocolVersion20250326)
+	mockMgr.On("GetProxySession", mock.Anything, mock.Anything).Return(sess, true).Once()
+	events, closeEvents := sess.GetEventChanWithCloser()
+	defer closeEvents()
+
+	body := `[{"jsonrpc":"2.0","id":7,"method":"ping"},{"jsonrpc":"2.0","id":8,"method":"initialize","params":{}}]`
+	req := httptest.NewRequest(http.MethodPost, "/message?sessionId=sess-sse-batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	assert.NoError(t, srv.handleGlobalMessage(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	ids := map[float64]bool{}
+	for len(ids) < 2 {
+		select {
+		case evt := <-events:
+			var msg struct {
+				ID float64 `json:"id"`
+			}
+			assert.NoError(t, json.Unmarshal([]byte(evt.Data), &msg))
+			ids[msg.ID] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected one SSE event per batch member, got %v", ids)
+		}
+	}
+	assert.True(t, ids[7] && ids[8])
+}
@@ -1,13 +1,21 @@
 package gateway
 
-import "github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+import (
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+)
 
 const remoteOAuthAccessTokenEnv = "MCP_REMOTE_AUTH_ACCESS_TOKEN"
 
+// downstreamOAuthToken 返回转发给下游服务的 Bearer 凭证：远端网关使用服务凭证，
+// 其它远端服务使用 OAuth access token。
 func downstreamOAuthToken(instance runtime.ExportMcpService) string {
 	info := instance.Info()
 	if info.Config.Env == nil {
 		return ""
 	}
+	if info.Config.IsGateway() {
+		return info.Config.Env[config.GatewayAPIKeyEnv]
+	}
 	return info.Config.Env[remoteOAuthAccessTokenEnv]
 }
@@ -0,0 +1,113 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+)
+
+const headerRequestID = echo.HeaderXRequestID
+
+// downstreamHTTPClient 是单服务代理共享的 HTTP client，复用到下游服务的连接。
+// 不设整体超时：SSE 与 Streamable HTTP 响应可能是长连接，由请求 context 控制生命周期。
+var downstreamHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     false,
+		MaxIdleConns:          256,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+	// 重定向交给 client 自行处理，避免把请求转发到未配置的地址
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// hopByHopHeaders 只对单跳连接有效，代理时不能转发（RFC 9110 7.6.1）。
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// gatewayOnlyHeaders 是发给网关本身的凭证与路由信息，不能泄露给下游服务。
+var gatewayOnlyHeaders = []string{
+	echo.HeaderAuthorization,
+	"Cookie",
+	"X-Workspace-Id",
+}
+
+// newDownstreamRequest 构造转发给下游服务的请求：去掉 hop-by-hop 与网关凭证头，
+// 只注入该服务配置的下游凭证，并附加 X-Forwarded-* 与请求 ID。
+func (h *Handler) newDownstreamRequest(c echo.Context, instance runtime.ExportMcpService, targetURL string) (*http.Request, error) {
+	in := c.Request()
+	req, err := http.NewRequestWithContext(in.Context(), in.Method, targetURL, in.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = in.ContentLength
+	req.Header = in.Header.Clone()
+	removeHopByHopHeaders(req.Header)
+	for _, name := range gatewayOnlyHeaders {
+		req.Header.Del(name)
+	}
+	if token := downstreamOAuthToken(instance); token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+
+	if ip, _, err := net.SplitHostPort(in.RemoteAddr); err == nil {
+		if prior := in.Header.Get(echo.HeaderXForwardedFor); prior != "" {
+			ip = prior + ", " + ip
+		}
+		req.Header.Set(echo.HeaderXForwardedFor, ip)
+	}
+	req.Header.Set(echo.HeaderXForwardedProto, h.requestScheme(c))
+	req.Header.Set("X-Forwarded-Host", h.requestHost(c))
+
+	requestID := in.Header.Get(headerRequestID)
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	req.Header.Set(headerRequestID, requestID)
+	c.Response().Header().Set(headerRequestID, requestID)
+	return req, nil
+}
+
+// copyDownstreamResponseHeaders 把下游响应头复制给 client，跳过 hop-by-hop 头。
+func copyDownstreamResponseHeaders(dst, src http.Header) {
+	header := src.Clone()
+	removeHopByHopHeaders(header)
+	for k, v := range header {
+		dst[k] = v
+	}
+}
+
+// removeHopByHopHeaders 删除标准 hop-by-hop 头以及 Connection 中声明的头。
+func removeHopByHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
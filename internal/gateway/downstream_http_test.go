@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// --- 单服务代理：不向下游泄露网关凭证，只注入配置的下游 token ---
+func TestHandleStreamHTTP_StripsGatewayCredentials(t *testing.T) {
+	var got http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer downstream.Close()
+
+	svc := runtime.NewMcpService("remote", config.MCPServerConfig{
+		Workspace:       "default",
+		URL:             downstream.URL + "/mcp",
+		GatewayProtocol: "streamhttp",
+		Env:             map[string]string{remoteOAuthAccessTokenEnv: "downstream-token"},
+	}, runtime.NewPortManager())
+	svc.Status = runtime.Running
+
+	srv, mockMgr := createTestServerManager()
+	mockMgr.On("GetMcpService", mock.Anything, mock.Anything).Return(runtime.ExportMcpService(svc), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/remote", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.RemoteAddr = "10.0.0.7:5123"
+	req.Header.Set(echo.HeaderAuthorization, "Bearer gateway-key")
+	req.Header.Set("Cookie", "session=gateway")
+	req.Header.Set("X-Workspace-Id", "default")
+	req.Header.Set("Connection", "keep-alive, X-Hop")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.9")
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("service")
+	c.SetParamValues("remote")
+
+	require.NoError(t, srv.handleStreamHTTP(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, "Bearer downstream-token", got.Get(echo.HeaderAuthorization))
+	assert.Empty(t, got.Get("Cookie"))
+	assert.Empty(t, got.Get("X-Workspace-Id"))
+	assert.Empty(t, got.Get("X-Hop"))
+	assert.Equal(t, echo.MIMEApplicationJSON, got.Get(echo.HeaderContentType))
+	assert.Equal(t, "203.0.113.9, 10.0.0.7", got.Get(echo.HeaderXForwardedFor))
+	assert.Equal(t, "http", got.Get(echo.HeaderXForwardedProto))
+	assert.Equal(t, "example.com", got.Get("X-Forwarded-Host"))
+	assert.NotEmpty(t, got.Get(headerRequestID))
+	assert.Equal(t, got.Get(headerRequestID), rec.Header().Get(headerRequestID))
+	assert.Empty(t, rec.Header().Get("Connection"))
+}
+
+// --- 单服务代理：tools/call 与 /stream 一样经过策略与限流 ---
+func TestHandleStreamHTTP_AdmitsToolCalls(t *testing.T) {
+	forwarded := 0
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"content":[]}}`))
+	}))
+	defer downstream.Close()
+
+	svc := runtime.NewMcpService("github", config.MCPServerConfig{
+		Workspace:       "default",
+		URL:             downstream.URL + "/mcp",
+		GatewayProtocol: "streamhttp",
+	}, runtime.NewPortManager())
+	svc.Status = runtime.Running
+
+	srv, mockMgr := createTestServerManager()
+	srv.limits = ratelimit.New(xlog.NewLogger("test-ratelimit"), []config.RateLimitConfig{
+		{Name: "search", Scope: config.RateLimitScopeTool, Tool: "github_search", Rate: 0.1, Burst: 1},
+	})
+	mockMgr.On("GetMcpService", mock.Anything, mock.Anything).Return(runtime.ExportMcpService(svc), nil)
+	mockMgr.On("GuardsTool", mock.Anything, mock.Anything, "github_delete_repo").Return(true)
+	mockMgr.On("GuardsTool", mock.Anything, mock.Anything, "github_search").Return(false)
+
+	call := func(tool string) *httptest.ResponseRecorder {
+		body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"` + tool + `","arguments":{}}}`
+		req := httptest.NewRequest(http.MethodPost, "/github", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("service")
+		c.SetParamValues("github")
+		require.NoError(t, srv.handleStreamHTTP(c))
+		return rec
+	}
+
+	rec := call("delete_repo")
+	assert.Contains(t, rec.Body.String(), `"code":-32031`)
+	assert.Equal(t, 0, forwarded)
+
+	rec = call("search")
+	assert.Contains(t, rec.Body.String(), `"result"`)
+	assert.Equal(t, 1, forwarded)
+
+	rec = call("search")
+	assert.Contains(t, rec.Body.String(), `"code":-32029`)
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Equal(t, 1, forwarded)
+}
+
+// --- 通配代理：路径不能越出网关服务的 /w/{workspace} 范围 ---
+func TestProxyHandler_RejectsPathTraversal(t *testing.T) {
+	var paths []string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		_, _ = w.Write([]byte(`ok`))
+	}))
+	defer remote.Close()
+
+	svc := runtime.NewMcpService("eu", config.MCPServerConfig{
+		Type:            config.ServiceTypeGateway,
+		Workspace:       "default",
+		URL:             remote.URL,
+		RemoteWorkspace: "team-a",
+		Env:             map[string]string{config.GatewayAPIKeyEnv: "gw-key"},
+	}, runtime.NewPortManager())
+	svc.Status = runtime.Running
+
+	srv, mockMgr := createTestServerManager()
+	mockMgr.On("GetMcpService", mock.Anything, mock.Anything).Return(runtime.ExportMcpService(svc), nil)
+
+	get := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = path
+		rec := httptest.NewRecorder()
+		require.NoError(t, srv.proxyHandler()(echo.New().NewContext(req, rec)))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/eu/../../api/v1/workspaces"))
+	assert.Equal(t, http.StatusBadRequest, get("/eu/./x"))
+	assert.Empty(t, paths)
+
+	assert.Equal(t, http.StatusOK, get("/eu/tools/a?b"))
+	assert.Equal(t, []string{"/w/team-a/tools/a%3Fb"}, paths)
+}
@@ -8,6 +8,9 @@ import (
 
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
 )
 
@@ -18,21 +21,26 @@ type Handler struct {
 	cfg       *config.Config
 	auth      *identity.Service
 	oauth     *internalOAuthServer
+	limits    *ratelimit.Limiter
+	meter     *usage.Meter
 	restoreMu sync.Mutex
 }
 
-// NewHandler 构造一个 gateway Handler。
-func NewHandler(services workspaces.ServiceManagerI, cfg *config.Config, auth *identity.Service) *Handler {
-	return &Handler{services: services, cfg: cfg, auth: auth, oauth: newInternalOAuthServer()}
+// NewHandler 构造一个 gateway Handler，limits 为 nil 时不限流，meter 为 nil 时不计量用量。
+func NewHandler(services workspaces.ServiceManagerI, cfg *config.Config, auth *identity.Service, limits *ratelimit.Limiter, meter *usage.Meter) *Handler {
+	return &Handler{services: services, cfg: cfg, auth: auth, oauth: newInternalOAuthServer(), limits: limits, meter: meter}
 }
 
 // Register 向 Echo 注册 MCP 协议入口：
 //   - Streamable HTTP: POST/GET/DELETE /stream, GET/POST /:service
 //   - SSE:             GET /sse, POST /message
+//   - WebSocket:       GET /ws
+//   - 以上入口均可加 /w/:workspace 前缀，按路径选择 workspace
+//   - 虚拟服务器:      /v/:name/stream、/v/:name/sse 等
 //
 // proxyHandler 是 wildcard 路由（/*），需要通过 RegisterProxy 单独注册在所有其它路由之后。
 func (h *Handler) Register(e *echo.Echo) {
-	auth := h.mcpAuthMiddleware
+	auth := h.gatewayAuth
 	e.GET("/.well-known/oauth-protected-resource", h.handleProtectedResourceMetadata)
 	e.GET("/.well-known/oauth-protected-resource/*", h.handleProtectedResourceMetadata)
 	e.GET(authorizationServerMetadataPath, h.handleAuthorizationServerMetadata)
@@ -41,21 +49,48 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/oauth/register", h.handleOAuthRegister)
 	e.POST("/oauth/token", h.handleOAuthToken)
 
-	e.GET("/sse", auth(h.requireSSE(h.handleGlobalSSE)))
-	e.POST("/message", auth(h.requireSSE(h.handleGlobalMessage)))
-	e.POST("/stream", auth(h.requireStreamHTTP(h.handleGlobalStreamHTTP)))
-	e.GET("/stream", auth(h.requireStreamHTTP(h.handleGlobalStreamHTTP)))
-	e.DELETE("/stream", auth(h.requireStreamHTTP(h.handleGlobalStreamHTTP)))
+	h.registerSessionRoutes(e, "", auth)
 	e.GET("/:service", auth(h.requireStreamHTTPOrProxy(h.handleStreamHTTP)))
 	e.POST("/:service", auth(h.requireStreamHTTPOrProxy(h.handleStreamHTTP)))
+
+	// 路径作用域：workspace 由 URL 决定，适用于只能配置地址的 client
+	scoped := func(next echo.HandlerFunc) echo.HandlerFunc { return auth(h.workspaceFromPath(next)) }
+	h.registerSessionRoutes(e, "/w/:workspace", scoped)
+	e.GET("/w/:workspace/:service", scoped(h.requireStreamHTTPOrProxy(h.handleStreamHTTP)))
+	e.POST("/w/:workspace/:service", scoped(h.requireStreamHTTPOrProxy(h.handleStreamHTTP)))
+
+	// 虚拟服务器：workspace 内只暴露部分服务与工具的具名入口
+	h.registerSessionRoutes(e, "/v/:name", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return auth(h.virtualServerFromPath(next))
+	})
+	h.registerSessionRoutes(e, "/w/:workspace/v/:name", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return scoped(h.virtualServerFromPath(next))
+	})
+}
+
+// registerSessionRoutes 在 prefix 下注册聚合入口（/sse、/message、/stream、/ws），
+// wrap 负责鉴权以及 workspace、虚拟服务器的选择。
+func (h *Handler) registerSessionRoutes(e *echo.Echo, prefix string, wrap func(echo.HandlerFunc) echo.HandlerFunc) {
+	e.GET(prefix+"/sse", wrap(h.requireSSE(h.handleGlobalSSE)))
+	e.POST(prefix+"/message", wrap(h.requireSSE(h.handleGlobalMessage)))
+	e.POST(prefix+"/stream", wrap(h.requireStreamHTTP(h.handleGlobalStreamHTTP)))
+	e.GET(prefix+"/stream", wrap(h.requireStreamHTTP(h.handleGlobalStreamHTTP)))
+	e.DELETE(prefix+"/stream", wrap(h.requireStreamHTTP(h.handleGlobalStreamHTTP)))
+	e.GET(prefix+"/ws", wrap(h.requireStreamHTTP(h.handleGlobalWebSocket)))
 }
 
 // RegisterProxy 注册通配 /* 代理路由，必须在所有其它路由之后调用。
 func (h *Handler) RegisterProxy(e *echo.Echo) {
-	auth := h.mcpAuthMiddleware
+	auth := h.gatewayAuth
+	e.Any("/w/:workspace/*", auth(h.workspaceFromPath(h.proxyHandler())))
 	e.Any("/*", auth(h.proxyHandler()))
 }
 
+// gatewayAuth 为 MCP 入口先按子域名确定 workspace，再做鉴权，鉴权通过后计量用量。
+func (h *Handler) gatewayAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return h.workspaceFromHost(h.mcpAuthMiddleware(h.meterUsage(next)))
+}
+
 func (h *Handler) requireSSE(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		if h.cfg == nil || !h.cfg.SupportsSSE() {
@@ -82,3 +117,13 @@ func (h *Handler) requireStreamHTTPOrProxy(next echo.HandlerFunc) echo.HandlerFu
 		return next(c)
 	}
 }
+
+// applyPrincipalToolMode 按 API key 的 scope 覆盖 workspace 默认的工具列表模式。
+func applyPrincipalToolMode(session *sessions.Session, principal *identity.Principal) {
+	switch {
+	case principal.HasScope(identity.ScopeToolsLazy):
+		session.SetLazyTools(true)
+	case principal.HasScope(identity.ScopeToolsFull):
+		session.SetLazyTools(false)
+	}
+}
@@ -27,7 +27,7 @@ func TestRegisterProtocolRoutesRespectGatewayProtocol(t *testing.T) {
 			h := NewHandler(&MockServiceManager{}, &config.Config{
 				GatewayProtocol: tt.protocol,
 				Auth:            &config.AuthConfig{Enabled: false},
-			}, nil)
+			}, nil, nil, nil)
 			h.Register(e)
 
 			req := httptest.NewRequest(tt.method, tt.path, nil)
@@ -45,7 +45,7 @@ func TestRegisterProtocolRoutesDefaultAllowsStreamHTTP(t *testing.T) {
 	h := NewHandler(&MockServiceManager{}, &config.Config{
 		GatewayProtocol: "all",
 		Auth:            &config.AuthConfig{Enabled: false},
-	}, nil)
+	}, nil, nil, nil)
 	h.Register(e)
 
 	req := httptest.NewRequest(http.MethodPost, "/stream", nil)
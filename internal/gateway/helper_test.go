@@ -63,6 +63,37 @@ func (m *MockServiceManager) CloseProxySession(logger xlog.Logger, name workspac
 	m.Called(logger, name)
 }
 
+func (m *MockServiceManager) SetToolPolicies(logger xlog.Logger, name workspaces.NameArg, rules []config.ToolPolicyRule) {
+	m.Called(logger, name, rules)
+}
+
+func (m *MockServiceManager) ListApprovals(logger xlog.Logger, name workspaces.NameArg) []sessions.Approval {
+	args := m.Called(logger, name)
+	return args.Get(0).([]sessions.Approval)
+}
+
+func (m *MockServiceManager) GuardsTool(logger xlog.Logger, name workspaces.NameArg, tool string) bool {
+	args := m.Called(logger, name, tool)
+	return args.Bool(0)
+}
+
+func (m *MockServiceManager) PoolStats(logger xlog.Logger, name workspaces.NameArg) map[string]map[string]int {
+	args := m.Called(logger, name)
+	stats, _ := args.Get(0).(map[string]map[string]int)
+	return stats
+}
+
+func (m *MockServiceManager) ToolCacheStats(logger xlog.Logger, name workspaces.NameArg) map[string]sessions.ToolCacheStats {
+	args := m.Called(logger, name)
+	stats, _ := args.Get(0).(map[string]sessions.ToolCacheStats)
+	return stats
+}
+
+func (m *MockServiceManager) DecideApproval(logger xlog.Logger, name workspaces.NameArg, id string, decision sessions.ApprovalDecision) (sessions.Approval, error) {
+	args := m.Called(logger, name, id, decision)
+	return args.Get(0).(sessions.Approval), args.Error(1)
+}
+
 func (m *MockServiceManager) DeleteServer(logger xlog.Logger, name workspaces.NameArg) error {
 	args := m.Called(logger, name)
 	return args.Error(0)
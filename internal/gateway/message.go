@@ -23,10 +23,7 @@ func (h *Handler) handleGlobalMessage(c echo.Context) error {
 	}
 	workspace := httpx.GetWorkspace(c, workspaces.DefaultWorkspace)
 	// 获取session
-	session, exists := h.services.GetProxySession(xl, workspaces.NameArg{
-		Workspace: workspace,
-		Session:   sessionId,
-	})
+	session, exists := h.lookupSession(c, xl, workspace, sessionId)
 	if !exists {
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.message_failed", workspace, sessionId, "session message failed", "session not found", nil)
 		return c.String(http.StatusNotFound, "session not found")
@@ -37,8 +34,20 @@ func (h *Handler) handleGlobalMessage(c echo.Context) error {
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.message_failed", workspace, sessionId, "session message failed", err.Error(), nil)
 		return err
 	}
+	if isJSONRPCBatch(body) {
+		return h.handleMessageBatch(c, xl, workspace, session, body)
+	}
 	info := rpcLogInfoFromBody(body, "")
 	detail := rpcLogDetail(info, "sse-message")
+	if tool := calledTool(body); tool != "" {
+		service, _ := splitGatewayToolName(tool)
+		setUsageService(c, service)
+	}
+	if rejected := h.admitToolCall(c.Request().Context(), gatewayPrincipal(c), workspace, sessionId, body, detail); rejected != nil {
+		peek, _ := peekJSONRPC(body)
+		setRetryAfter(c, rejected)
+		return c.JSON(http.StatusTooManyRequests, jsonRPCErrorPayload(peek.ID, rejected.code, rejected.message, rejected.data))
+	}
 
 	// 记录发送的消息
 	if err := session.SendMessage(xl, []byte(body)); err != nil {
@@ -122,6 +122,12 @@ func (s *memoryIdentityStore) AppendAuditLog(context.Context, *identity.AuditLog
 func (s *memoryIdentityStore) ListAuditLogs(context.Context, string, int) ([]identity.AuditLog, error) {
 	return nil, nil
 }
+func (s *memoryIdentityStore) IncrementUsage(context.Context, []identity.UsageRecord) error {
+	return nil
+}
+func (s *memoryIdentityStore) ListUsage(context.Context, identity.UsageFilter) ([]identity.UsageRecord, error) {
+	return nil, nil
+}
 
 func TestOAuthTokenPasswordGrantUsesGatewayAccountPassword(t *testing.T) {
 	hash, err := identity.HashPassword("secret123")
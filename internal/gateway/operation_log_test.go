@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// operationWatchers 接收测试期间写入的操作日志；xlog 的 sink 无法注销，因此只注册一次。
+var (
+	operationSinkOnce sync.Once
+	operationMu       sync.Mutex
+	operationWatchers = map[chan oplog.Event]struct{}{}
+)
+
+type operationSink struct{}
+
+func (operationSink) Write(entry xlog.Entry) {
+	event, ok := oplog.EventFromEntry(entry)
+	if !ok {
+		return
+	}
+	operationMu.Lock()
+	defer operationMu.Unlock()
+	for ch := range operationWatchers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// watchOperations 返回之后写入的操作日志。
+func watchOperations(t *testing.T) <-chan oplog.Event {
+	t.Helper()
+	operationSinkOnce.Do(func() { xlog.RegisterSink(operationSink{}) })
+	ch := make(chan oplog.Event, 64)
+	operationMu.Lock()
+	operationWatchers[ch] = struct{}{}
+	operationMu.Unlock()
+	t.Cleanup(func() {
+		operationMu.Lock()
+		delete(operationWatchers, ch)
+		operationMu.Unlock()
+	})
+	return ch
+}
+
+// waitOperation 等待指定 session 的指定操作日志。
+func waitOperation(t *testing.T, events <-chan oplog.Event, action, sessionID string) oplog.Event {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Action == action && event.SessionID == sessionID {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("operation %s for session %s was not logged", action, sessionID)
+			return oplog.Event{}
+		}
+	}
+}
+
+func TestCreateSessionLogsSessionCreate(t *testing.T) {
+	events := watchOperations(t)
+	srv, mockMgr := createTestServerManager()
+	sess := newTestSession("sess-oplog")
+	t.Cleanup(sess.Close)
+	mockMgr.On("CreateProxySession", mock.Anything, mock.Anything).Return(sess, nil).Once()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"inspector","version":"0.1"}}}`
+	c, rec := buildStreamHTTPRequest(t, http.MethodPost, body, nil)
+	require.NoError(t, srv.handleGlobalStreamHTTP(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	event := waitOperation(t, events, "session.create", "sess-oplog")
+	assert.Equal(t, workspaces.DefaultWorkspace, event.WorkspaceID)
+}
+
+func TestAdmissionDenialsLogToolCallDenied(t *testing.T) {
+	events := watchOperations(t)
+	srv, _ := createTestServerManager()
+	srv.limits = ratelimit.New(xlog.NewLogger("test-ratelimit"), []config.RateLimitConfig{
+		{Name: "github", Scope: config.RateLimitScopeTool, Tool: "github_*", Rate: 0.1, Burst: 1},
+	})
+	admit := srv.compositeStepAdmitter(nil, workspaces.DefaultWorkspace, "sess-denied")
+	require.NoError(t, admit(context.Background(), "github_search"))
+	require.Error(t, admit(context.Background(), "github_search"))
+
+	event := waitOperation(t, events, "tool.call_denied", "sess-denied")
+	assert.Equal(t, "rate_limit", event.Detail["denied_by"])
+	assert.Equal(t, "github_search", event.Detail["tool"])
+}
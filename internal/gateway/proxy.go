@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -17,7 +18,8 @@ import (
 func (h *Handler) proxyHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		xl := xlog.NewLogger("PROXY")
-		path := c.Request().URL.Path
+		// /w/:workspace/... 路由下去掉路径作用域前缀
+		path := strings.TrimPrefix(c.Request().URL.Path, workspacePathPrefix(c))
 
 		// 从路径中提取服务名和路由
 		parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
@@ -27,7 +29,10 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 
 		serviceName := parts[0]
 		lastRoute := parts[len(parts)-1] // 获取最后一个路由部分
-		remainingPath := "/" + strings.Join(parts[1:], "/")
+		remainingPath, ok := downstreamSubPath(parts[1:])
+		if !ok {
+			return c.String(http.StatusBadRequest, "Invalid path")
+		}
 
 		// 获取workspace信息
 		workspace := httpx.GetWorkspace(c, workspaces.DefaultWorkspace)
@@ -46,6 +51,7 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 		if err != nil {
 			return c.String(http.StatusNotFound, "Service not found")
 		}
+		setUsageService(c, serviceName)
 
 		// 获取原始请求的查询参数
 		originalQuery := c.Request().URL.RawQuery
@@ -62,9 +68,9 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 			c.Logger().Infof("Message URL: %s", baseURL)
 		default:
 			// 对于其他路由，使用基础URL加上完整路径
-			if url := instance.GetUrl(); url != "" {
+			if serviceURL := instance.GetUrl(); serviceURL != "" {
 				// 移除URL末尾的斜杠，避免双斜杠
-				baseURL = strings.TrimRight(url, "/")
+				baseURL = strings.TrimRight(serviceURL, "/")
 				if remainingPath != "/" {
 					baseURL += remainingPath
 				}
@@ -73,6 +79,18 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 			}
 		}
 
+		if id, rejected := h.admitPassthrough(c, xl, workspace, serviceName); rejected != nil {
+			status := http.StatusOK
+			if lastRoute == "message" {
+				// SSE 的 message 端点只回 Accepted，拒绝需通过状态码告知
+				status = passthroughRejectionStatus(rejected)
+			}
+			if rejected.retryAfter > 0 {
+				setRetryAfter(c, rejected)
+			}
+			return writeJSONRPCError(c, status, id, rejected.code, rejected.message, rejected.data)
+		}
+
 		// 构建目标URL，保留原始查询参数
 		targetURL := baseURL
 		if originalQuery != "" {
@@ -87,36 +105,19 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 		c.Logger().Infof("Proxy request: %s, target URL: %s, lastRoute: %s, query: %s",
 			c.Request().URL, targetURL, lastRoute, originalQuery)
 
-		// 创建新的请求
-		req, err := http.NewRequest(c.Request().Method, targetURL, c.Request().Body)
+		// 创建转发请求，不携带网关凭证
+		req, err := h.newDownstreamRequest(c, instance, targetURL)
 		if err != nil {
 			return err
 		}
-
-		// 复制原始请求的 header
-		for k, v := range c.Request().Header {
-			req.Header[k] = v
-		}
-		if token := downstreamOAuthToken(instance); token != "" {
-			req.Header.Set("Authorization", "Bearer "+token)
-		}
-
-		// 发送请求
-		client := &http.Client{
-			Transport: &http.Transport{
-				ForceAttemptHTTP2: false,
-			},
-		}
-		resp, err := client.Do(req)
+		resp, err := downstreamHTTPClient.Do(req)
 		if err != nil {
 			return err
 		}
 		defer resp.Body.Close()
 
 		// 复制响应 header
-		for k, v := range resp.Header {
-			c.Response().Header()[k] = v
-		}
+		copyDownstreamResponseHeaders(c.Response().Header(), resp.Header)
 
 		// 对于 SSE 请求的特殊处理
 		if httpx.IsSSE(resp.Header) {
@@ -152,7 +153,7 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 
 					// 如果是endpoint事件，添加服务名前缀
 					if currentEvent == "endpoint" && strings.HasPrefix(data, "/message") {
-						data = fmt.Sprintf("/%s%s", serviceName, data)
+						data = fmt.Sprintf("%s/%s%s", workspacePathPrefix(c), serviceName, data)
 					}
 
 					fmt.Fprintf(c.Response(), "data: %s\n\n", data)
@@ -170,3 +171,17 @@ func (h *Handler) proxyHandler() echo.HandlerFunc {
 		return err
 	}
 }
+
+// downstreamSubPath 把服务名之后的路径段拼成追加到下游 URL 的路径。拒绝 . 与 .. 段，
+// 避免越出服务 URL 的路径范围（如网关服务的 /w/{workspace}），并逐段转义，
+// 解码后的 ?、# 不会改变目标 URL 的结构。
+func downstreamSubPath(segments []string) (string, bool) {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		if segment == "." || segment == ".." {
+			return "", false
+		}
+		escaped[i] = url.PathEscape(segment)
+	}
+	return "/" + strings.Join(escaped, "/"), true
+}
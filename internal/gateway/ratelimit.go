@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// rateLimitedCode 是 tools/call 被限流时返回的 JSON-RPC 错误码，位于服务端自定义范围。
+const rateLimitedCode = -32029
+
+// calledTool 返回请求实际调用的工具名，非 tools/call 请求返回空字符串。
+// lazy 模式的 gateway_call_tool 按被代为调用的工具计算，避免绕过按工具的限流与计量。
+func calledTool(body []byte) string {
+	var req struct {
+		Method string `json:"method"`
+		Params struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Name string `json:"name"`
+			} `json:"arguments"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Method != string(mcp.MethodToolsCall) {
+		return ""
+	}
+	if req.Params.Name == sessions.CallToolMetaTool && req.Params.Arguments.Name != "" {
+		return req.Params.Arguments.Name
+	}
+	return req.Params.Name
+}
+
+// rateLimitPrincipal 返回限流使用的调用方标识：API key 优先，其次为账号。
+func rateLimitPrincipal(principal *identity.Principal) string {
+	if principal == nil {
+		return ""
+	}
+	if principal.APIKeyID != "" {
+		return principal.APIKeyID
+	}
+	return principal.AccountID
+}
+
+// retryAfterSeconds 把等待时间向上取整为秒，用于 Retry-After 头。
+func retryAfterSeconds(d ratelimit.Decision) int {
+	return int(math.Max(1, math.Ceil(d.RetryAfter.Seconds())))
+}
+
+func rateLimitMessage(d ratelimit.Decision) string {
+	return fmt.Sprintf("rate limit %s exceeded, retry after %ds", d.Rule, retryAfterSeconds(d))
+}
+
+// rateLimitErrorData 是限流错误响应的 data，client 可据此退避重试。
+func rateLimitErrorData(d ratelimit.Decision) map[string]any {
+	return map[string]any{
+		"rule":         d.Rule,
+		"retryAfter":   retryAfterSeconds(d),
+		"retryAfterMs": d.RetryAfter.Milliseconds(),
+	}
+}
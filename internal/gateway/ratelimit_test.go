@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalledToolUnwrapsLazyCallTool(t *testing.T) {
+	assert.Equal(t, "github_search", calledTool([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"github_search"}}`)))
+	assert.Equal(t, "github_search", calledTool([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"gateway_call_tool","arguments":{"name":"github_search"}}}`)))
+	assert.Equal(t, "", calledTool([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)))
+}
+
+// newRateLimitedServer 返回令牌已耗尽的网关：github_* 工具每 10 秒只补充一个令牌。
+func newRateLimitedServer(t *testing.T) (*echo.Echo, *MockServiceManager) {
+	t.Helper()
+	srv, mockMgr := createTestServerManager()
+	srv.cfg.GatewayProtocol = "all"
+	srv.cfg.Auth = &config.AuthConfig{Enabled: false}
+	srv.limits = ratelimit.New(xlog.NewLogger("test-ratelimit"), []config.RateLimitConfig{
+		{Name: "github", Scope: config.RateLimitScopeTool, Tool: "github_*", Rate: 0.1, Burst: 1},
+	})
+	require.True(t, srv.limits.Allow(ratelimit.Request{Workspace: workspaces.DefaultWorkspace, Tool: "github_search"}).Allowed)
+
+	sess := newTestSession("sess-limited")
+	t.Cleanup(sess.Close)
+	mockMgr.On("GetProxySession", mock.Anything, workspaces.NameArg{Workspace: workspaces.DefaultWorkspace, Session: "sess-limited"}).Return(sess, true)
+	e := echo.New()
+	srv.Register(e)
+	return e, mockMgr
+}
+
+const limitedToolCall = `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"github_search","arguments":{}}}`
+
+// --- /stream：限流以 JSON-RPC error 返回，data 携带重试时间 ---
+func TestGlobalStreamHTTP_RateLimitedToolCall(t *testing.T) {
+	e, _ := newRateLimitedServer(t)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, postStream("/stream", "sess-limited", limitedToolCall))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get(echo.HeaderRetryAfter))
+	var resp struct {
+		ID    int `json:"id"`
+		Error struct {
+			Code int            `json:"code"`
+			Data map[string]any `json:"data"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 7, resp.ID)
+	assert.Equal(t, rateLimitedCode, resp.Error.Code)
+	assert.Equal(t, "github", resp.Error.Data["rule"])
+	assert.InDelta(t, 10000, resp.Error.Data["retryAfterMs"], 100)
+}
+
+// --- /message：响应走 SSE 流，POST 本身返回 429 ---
+func TestGlobalMessage_RateLimitedToolCall(t *testing.T) {
+	e, _ := newRateLimitedServer(t)
+	req := httptest.NewRequest(http.MethodPost, "/message?sessionId=sess-limited", strings.NewReader(limitedToolCall))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), `"code":-32029`)
+}
+
+// --- 组合工具的步骤与直接调用共用限流 ---
+func TestCompositeStepAdmitterAppliesRateLimit(t *testing.T) {
+	srv, _ := createTestServerManager()
+	srv.limits = ratelimit.New(xlog.NewLogger("test-ratelimit"), []config.RateLimitConfig{
+		{Name: "github", Scope: config.RateLimitScopeTool, Tool: "github_*", Rate: 0.1, Burst: 1},
+	})
+	admit := srv.compositeStepAdmitter(nil, workspaces.DefaultWorkspace, "sess-composite")
+
+	require.NoError(t, admit(context.Background(), "github_search"))
+	err := admit(context.Background(), "github_search")
+	var rejected *toolCallRejection
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, rateLimitedCode, rejected.code)
+	assert.NoError(t, admit(context.Background(), "fs_read_file"), "other tools are not limited")
+}
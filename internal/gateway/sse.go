@@ -3,6 +3,7 @@ package gateway
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/httpx"
@@ -29,31 +30,23 @@ func (h *Handler) handleGlobalSSE(c echo.Context) error {
 		}
 		// 没有sessionId，生成一个返回出
 		// create proxy session
-		session, err := h.services.CreateProxySession(xl, workspaces.NameArg{
-			Workspace: workspace,
-			Session:   querySessionId,
-		})
+		session, err := h.createSession(c, xl, workspace)
 		if err != nil {
 			h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.create_failed", workspace, "", "session create failed", err.Error(), nil)
-			return c.String(http.StatusInternalServerError, err.Error())
+			return c.String(sessionCreateStatus(err), err.Error())
 		}
+		applyPrincipalToolMode(session, gatewayPrincipal(c))
 		xl.Infof("Created new session: %s", session.Id)
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelInfo, "session.connect", workspace, session.Id, "SSE session connected", "", map[string]interface{}{"transport": "sse", "connection": "created"})
 		// 302重定向到 /sse?sessionId={session.Id}
-		if workspace != "" {
-			return c.Redirect(http.StatusFound, fmt.Sprintf("/sse?sessionId=%s&workspaceId=%s", session.Id, workspace))
-		}
-		return c.Redirect(http.StatusFound, fmt.Sprintf("/sse?sessionId=%s", session.Id))
+		return c.Redirect(http.StatusFound, h.sseSessionURL(c, "/sse", workspace, session.Id))
 	}
 	c.Response().Header().Set("Content-Type", "text/event-stream")
 	c.Response().Header().Set("Cache-Control", "no-cache")
 	c.Response().Header().Set("Connection", "keep-alive")
 
 	// get session by sessionId
-	session, exists := h.services.GetProxySession(xl, workspaces.NameArg{
-		Workspace: workspace,
-		Session:   querySessionId,
-	})
+	session, exists := h.lookupSession(c, xl, workspace, querySessionId)
 	if !exists {
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.stream_failed", workspace, querySessionId, "session stream failed", "session not found", map[string]interface{}{"transport": "sse"})
 		return c.String(http.StatusNotFound, "session not found")
@@ -68,15 +61,18 @@ func (h *Handler) handleGlobalSSE(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "flusher not supported")
 	}
 
-	if workspace != "" {
-		fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s&workspaceId=%s\r\n\r\n", session.Id, workspace)
-	} else {
-		fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\r\n\r\n", session.Id)
-	}
+	fmt.Fprintf(w, "event: endpoint\ndata: %s\r\n\r\n", h.sseSessionURL(c, "/message", workspace, session.Id))
 	flusher.Flush()
 
-	// 获取事件通道和关闭函数
-	eventChan, closeChan := session.GetEventChanWithCloser()
+	// 获取事件通道和关闭函数；携带 Last-Event-ID 时先补发断线期间的事件
+	eventChan, replay, closeChan := session.GetEventChanSince(lastEventIDFromRequest(c))
+	for _, event := range replay {
+		if err := writeSSEEvent(w, event); err != nil {
+			closeChan()
+			return nil
+		}
+	}
+	flusher.Flush()
 
 	// 转发所有SSE事件
 	for {
@@ -87,13 +83,28 @@ func (h *Handler) handleGlobalSSE(c echo.Context) error {
 			// 关闭当前客户端的事件通道
 			closeChan()
 			return nil
-		case event := <-eventChan:
+		case event, ok := <-eventChan:
+			if !ok {
+				// session 已关闭，或订阅者缓冲溢出被断开（客户端可携带 Last-Event-ID 重连）
+				xl.Infof("Event channel closed, sessionId: %s", querySessionId)
+				return nil
+			}
 			xl.Infof("to sse: %v", event)
-			//ev := fmt.Sprintf("event: message", event.Data)
-			fmt.Fprintf(w, "event: %s\n", event.Event)
-			flusher.Flush()
-			fmt.Fprintf(w, "data: %s\n\n", event.Data)
+			if err := writeSSEEvent(w, event); err != nil {
+				closeChan()
+				return nil
+			}
 			flusher.Flush()
 		}
 	}
 }
+
+// sseSessionURL 生成 SSE 重定向与 endpoint 事件中的地址：保留 client 使用的 scheme 与 host，
+// 路径作用域内的请求继续使用 /w/:workspace 前缀，否则通过 workspaceId 参数携带 workspace。
+func (h *Handler) sseSessionURL(c echo.Context, path, workspace, sessionID string) string {
+	query := url.Values{"sessionId": {sessionID}}
+	if workspacePathPrefix(c) == "" && workspace != "" {
+		query.Set("workspaceId", workspace)
+	}
+	return h.gatewayURL(c, path) + "?" + query.Encode()
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -20,13 +23,15 @@ import (
 
 const (
 	headerMcpSessionID       = "Mcp-Session-Id"
+	headerLastEventID        = "Last-Event-ID"
+	headerProtocolVersion    = "MCP-Protocol-Version"
 	streamHTTPWaitTimeout    = 30 * time.Second
 	streamHTTPKeepAliveEvery = 30 * time.Second
 	methodNotificationsInit  = "notifications/initialized"
 )
 
 // handleStreamHTTP 单服务 Streamable HTTP 反向代理。
-// 仅把请求体按原样转给下游 MCP 服务暴露的 message 端点。
+// 把请求体按原样转给下游 MCP 服务暴露的 message 端点，tools/call 先经过准入检查。
 func (h *Handler) handleStreamHTTP(c echo.Context) error {
 	xl := xlog.NewLogger("STREAMHTTP")
 	serviceName := c.Param("service")
@@ -49,28 +54,24 @@ func (h *Handler) handleStreamHTTP(c echo.Context) error {
 	if targetURL == "" {
 		return c.String(http.StatusServiceUnavailable, "Service not available")
 	}
+	if id, rejected := h.admitPassthrough(c, xl, workspace, serviceName); rejected != nil {
+		if rejected.retryAfter > 0 {
+			setRetryAfter(c, rejected)
+		}
+		return writeJSONRPCError(c, http.StatusOK, id, rejected.code, rejected.message, rejected.data)
+	}
 
-	req, err := http.NewRequest(c.Request().Method, targetURL, c.Request().Body)
+	req, err := h.newDownstreamRequest(c, instance, targetURL)
 	if err != nil {
 		return err
 	}
-	for k, v := range c.Request().Header {
-		req.Header[k] = v
-	}
-	if token := downstreamOAuthToken(instance); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := downstreamHTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	for k, v := range resp.Header {
-		c.Response().Header()[k] = v
-	}
+	copyDownstreamResponseHeaders(c.Response().Header(), resp.Header)
 	c.Response().WriteHeader(resp.StatusCode)
 	_, err = io.Copy(c.Response().Writer, resp.Body)
 	return err
@@ -102,12 +103,16 @@ func (h *Handler) handleGlobalStreamHTTP(c echo.Context) error {
 //   - `notifications/initialized` 通知：直接 202，无需转发。
 //   - notification（无 id）：异步转发后 202。
 //   - request（有 id）：订阅 session 事件通道，转发后等待 id 匹配的响应并同步返回。
+//   - 批量请求（顶层为数组）：见 streamHTTPHandleBatch。
 func (h *Handler) streamHTTPHandlePost(c echo.Context, xl xlog.Logger, workspace string) error {
 	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.request_failed", workspace, c.Request().Header.Get(headerMcpSessionID), "Failed to read MCP request", err.Error(), map[string]interface{}{"transport": "streamhttp"})
 		return writeJSONRPCError(c, http.StatusBadRequest, nil, -32700, "failed to read request body", err.Error())
 	}
+	if isJSONRPCBatch(body) {
+		return h.streamHTTPHandleBatch(c, xl, workspace, body)
+	}
 	info := rpcLogInfoFromBody(body, "")
 
 	peek, err := peekJSONRPC(body)
@@ -120,7 +125,7 @@ func (h *Handler) streamHTTPHandlePost(c echo.Context, xl xlog.Logger, workspace
 	sessionID := c.Request().Header.Get(headerMcpSessionID)
 	// initialize 之外均要求携带 session id
 	if peek.Method == string(mcp.MethodInitialize) {
-		return h.streamHTTPHandleInitialize(c, xl, workspace, peek)
+		return h.streamHTTPHandleInitialize(c, xl, workspace, body, peek)
 	}
 
 	if sessionID == "" {
@@ -129,16 +134,18 @@ func (h *Handler) streamHTTPHandlePost(c echo.Context, xl xlog.Logger, workspace
 		return writeJSONRPCError(c, http.StatusBadRequest, peek.ID, -32600, fmt.Sprintf("missing %s header", headerMcpSessionID), nil)
 	}
 
-	session, ok := h.services.GetProxySession(xl, workspaces.NameArg{
-		Workspace: workspace,
-		Session:   sessionID,
-	})
+	session, ok := h.lookupSession(c, xl, workspace, sessionID)
 	if !ok {
 		// 404 会驱动客户端重新 initialize，对齐官方 client 行为
 		detail := rpcLogDetail(info, "streamhttp")
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.request_failed", workspace, sessionID, "MCP request rejected", "session not found", detail)
 		return c.String(http.StatusNotFound, "session not found")
 	}
+	if msg := checkProtocolVersionHeader(c, session); msg != "" {
+		detail := rpcLogDetail(info, "streamhttp")
+		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.request_failed", workspace, sessionID, "MCP request rejected", msg, detail)
+		return writeJSONRPCError(c, http.StatusBadRequest, peek.ID, -32600, msg, nil)
+	}
 
 	// notifications/initialized 是 client → server 的通知，网关直接 ACK 即可
 	if peek.Method == methodNotificationsInit {
@@ -162,104 +169,218 @@ func (h *Handler) streamHTTPHandlePost(c echo.Context, xl xlog.Logger, workspace
 		return c.NoContent(http.StatusAccepted)
 	}
 
+	// tools/call 超出配额或被限流时以 JSON-RPC error 返回，HTTP 状态保持 200 以便 client 解析 data
+	if tool := calledTool(body); tool != "" {
+		service, _ := splitGatewayToolName(tool)
+		setUsageService(c, service)
+	}
+	if rejected := h.admitToolCall(c.Request().Context(), gatewayPrincipal(c), workspace, sessionID, body, rpcLogDetail(info, "streamhttp")); rejected != nil {
+		setRetryAfter(c, rejected)
+		return writeJSONRPCError(c, http.StatusOK, peek.ID, rejected.code, rejected.message, rejected.data)
+	}
+
 	// request：订阅后转发，等待 id 匹配的响应
 	return h.streamHTTPForwardAndWait(c, xl, workspace, session, body, peek, info)
 }
 
 // streamHTTPHandleInitialize 处理首次 initialize：创建 session，响应头带 session id，
 // body 返回聚合 InitializeResult（下游 MCP 的 initialize 已在 session 建立时完成）。
-func (h *Handler) streamHTTPHandleInitialize(c echo.Context, xl xlog.Logger, workspace string, peek jsonRPCPeek) error {
+func (h *Handler) streamHTTPHandleInitialize(c echo.Context, xl xlog.Logger, workspace string, body []byte, peek jsonRPCPeek) error {
+	var initReq mcp.InitializeRequest
+	if err := json.Unmarshal(body, &initReq); err != nil {
+		return writeJSONRPCError(c, http.StatusBadRequest, peek.ID, mcp.INVALID_PARAMS, "invalid initialize params", err.Error())
+	}
+	// 先协商协议版本，不支持时不创建 session
+	if _, err := sessions.NegotiateProtocolVersion(initReq.Params.ProtocolVersion); err != nil {
+		var unsupported *sessions.UnsupportedProtocolVersionError
+		if errors.As(err, &unsupported) {
+			h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.initialize_failed", workspace, "", "session initialize failed", err.Error(), map[string]interface{}{"requested_version": unsupported.Requested})
+			return writeJSONRPCError(c, http.StatusBadRequest, peek.ID, unsupported.RPCCode(), unsupported.Error(), unsupported.RPCData())
+		}
+		return writeJSONRPCError(c, http.StatusBadRequest, peek.ID, mcp.INVALID_PARAMS, "invalid initialize params", err.Error())
+	}
 	if err := h.ensureWorkspaceServicesRunning(c.Request().Context(), workspace, xl); err != nil {
 		xl.Errorf("restore workspace services failed: %v", err)
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.initialize_failed", workspace, "", "session initialize failed", err.Error(), nil)
 		return writeJSONRPCError(c, http.StatusInternalServerError, peek.ID, -32000, "failed to restore workspace services", err.Error())
 	}
-	session, err := h.services.CreateProxySession(xl, workspaces.NameArg{
-		Workspace: workspace,
-	})
+	session, err := h.createSession(c, xl, workspace)
 	if err != nil {
 		xl.Errorf("create session failed: %v", err)
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.initialize_failed", workspace, "", "session initialize failed", err.Error(), nil)
-		return writeJSONRPCError(c, http.StatusInternalServerError, peek.ID, -32000, "failed to create session", err.Error())
+		return writeJSONRPCError(c, sessionCreateStatus(err), peek.ID, -32000, "failed to create session", err.Error())
 	}
 
+	result, err := session.Initialize(initReq.Params)
+	if err != nil {
+		return writeJSONRPCError(c, http.StatusBadRequest, peek.ID, mcp.INVALID_PARAMS, "invalid initialize params", err.Error())
+	}
+	applyPrincipalToolMode(session, gatewayPrincipal(c))
 	c.Response().Header().Set(headerMcpSessionID, session.Id)
 	detail := rpcLogDetail(rpcLogInfo{Method: peek.Method, RequestID: rawIDString(peek.ID), Action: "session.initialize", Message: "MCP session initialized"}, "streamhttp")
 	h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelInfo, "session.initialize", workspace, session.Id, "MCP session initialized", "", detail)
-	return writeJSONRPCResult(c, peek.ID, buildGatewayInitializeResult(session))
+	return writeJSONRPCResult(c, peek.ID, result)
 }
 
-// streamHTTPForwardAndWait 在订阅 session 事件通道的前提下转发请求，并同步等待
-// 匹配 id 的响应消息。
+// streamHTTPForwardAndWait 转发单个 request 并把等到的响应作为 HTTP body 返回。
+// client 的 Accept 包含 text/event-stream 且请求产生了进度、日志等中间通知时改用
+// SSE 响应：先逐条推送这些通知，最后推送响应并结束流。
 func (h *Handler) streamHTTPForwardAndWait(c echo.Context, xl xlog.Logger, workspace string, session *sessions.Session, body []byte, peek jsonRPCPeek, info rpcLogInfo) error {
-	eventChan, closer := session.GetEventChanWithCloser()
-	defer closer()
 	started := time.Now()
 	detail := rpcLogDetail(info, "streamhttp")
+	streaming := false
+	var notify func(evt sessions.SessionMsg) error
+	if acceptsEventStream(c) {
+		notify = func(evt sessions.SessionMsg) error {
+			if !streaming {
+				streaming = true
+				c.Response().Header().Set("Content-Type", "text/event-stream")
+				c.Response().Header().Set("Cache-Control", "no-cache")
+				c.Response().WriteHeader(http.StatusOK)
+			}
+			return writeStreamedEvent(c, evt)
+		}
+	}
+	out := forwardAndStream(c.Request().Context(), xl, session, body, peek.ID, notify)
+	if streaming {
+		detail["response_mode"] = "sse"
+	}
+	level, action, message, errText := forwardLogFields(out, info, detail, started)
+	h.appendOperation(c.Request().Context(), gatewayPrincipal(c), level, action, workspace, session.GetId(), message, errText, detail)
+
+	if streaming {
+		data := out.Data
+		if out.Failure != "" {
+			payload, err := json.Marshal(jsonRPCErrorPayload(peek.ID, -32000, out.Message, out.ErrData))
+			if err != nil {
+				return nil
+			}
+			data = string(payload)
+		}
+		// 响应头已经发出，客户端断开时无需再返回错误
+		_ = writeStreamedEvent(c, sessions.SessionMsg{Event: "message", Data: data})
+		return nil
+	}
+	if out.Failure != "" {
+		return writeJSONRPCError(c, out.Status, peek.ID, -32000, out.Message, out.ErrData)
+	}
+	c.Response().Header().Set("Content-Type", "application/json")
+	c.Response().WriteHeader(http.StatusOK)
+	_, err := c.Response().Write([]byte(out.Data))
+	return err
+}
+
+// acceptsEventStream 判断 client 是否接受 SSE 形式的 POST 响应。
+func acceptsEventStream(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream")
+}
+
+// writeStreamedEvent 在 POST 响应的 SSE 流上写出一条事件并立即刷新。
+func writeStreamedEvent(c echo.Context, evt sessions.SessionMsg) error {
+	if err := writeSSEEvent(c.Response().Writer, evt); err != nil {
+		return err
+	}
+	c.Response().Flush()
+	return nil
+}
+
+// forwardOutcome 是转发单个 request 并等待响应的结果。
+type forwardOutcome struct {
+	// Data 为收到的 JSON-RPC 响应，Failure 非空时为空
+	Data string
+	// Failure 为 "failed" 或 "timeout"，表示未拿到响应
+	Failure string
+	Status  int
+	Message string
+	ErrData any
+	// LogText 为写入操作日志的失败详情
+	LogText string
+}
+
+// forwardAndAwait 先按请求 id 登记等待，再转发请求并同步等待 session
+// 直接投递的响应消息，无需扫描整个广播流。
+func forwardAndAwait(parent context.Context, xl xlog.Logger, session *sessions.Session, body []byte, id json.RawMessage) forwardOutcome {
+	return forwardAndStream(parent, xl, session, body, id, nil)
+}
+
+// forwardAndStream 与 forwardAndAwait 相同，notify 非空时还会把该请求的中间通知
+// 逐条交给 notify，为空时照常广播；每收到一条通知，等待超时重新计时，长时间运行
+// 但持续汇报进度、或在等待审批的请求不会被固定超时打断。
+func forwardAndStream(parent context.Context, xl xlog.Logger, session *sessions.Session, body []byte, id json.RawMessage, notify func(evt sessions.SessionMsg) error) forwardOutcome {
+	respChan, cancelWait := session.AwaitResponse(id)
+	defer cancelWait()
+	notifications, cancelWatch := session.WatchRequest(id)
+	defer cancelWatch()
+	if notify == nil {
+		notify = func(evt sessions.SessionMsg) error {
+			session.SendEvent(evt)
+			return nil
+		}
+	}
 
 	sendErrCh := make(chan error, 1)
 	go func() {
-		sendErrCh <- session.SendMessage(xl, body)
+		sendErrCh <- session.SendMessageContext(parent, xl, body)
 	}()
 
-	ctx, cancel := context.WithTimeout(c.Request().Context(), streamHTTPWaitTimeout)
-	defer cancel()
+	timer := time.NewTimer(streamHTTPWaitTimeout)
+	defer timer.Stop()
 
-	targetID := normalizeRawID(peek.ID)
 	var sendErr error
 	sendDone := false
 	for {
+		var expired bool
 		select {
-		case <-ctx.Done():
-			if !started.IsZero() {
-				detail["duration_ms"] = time.Since(started).Milliseconds()
-			}
-			if sendErr != nil {
-				h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, info.Action+"_failed", workspace, session.GetId(), info.Message+" failed", sendErr.Error(), detail)
-				return writeJSONRPCError(c, http.StatusBadGateway, peek.ID, -32000, "forward failed", sendErr.Error())
-			}
-			h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, info.Action+"_timeout", workspace, session.GetId(), info.Message+" timeout", "", detail)
-			return writeJSONRPCError(c, http.StatusGatewayTimeout, peek.ID, -32000, "timeout waiting for downstream response", nil)
+		case <-parent.Done():
+			expired = true
+		case <-timer.C:
+			expired = true
 		case err := <-sendErrCh:
 			sendErr = err
 			sendDone = true
 			if err != nil {
 				xl.Warnf("SendMessage returned error: %v", err)
 			}
-		case evt, ok := <-eventChan:
+		case evt, ok := <-notifications:
 			if !ok {
-				if !started.IsZero() {
-					detail["duration_ms"] = time.Since(started).Milliseconds()
-				}
-				if sendDone && sendErr != nil {
-					h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, info.Action+"_failed", workspace, session.GetId(), info.Message+" failed", sendErr.Error(), detail)
-					return writeJSONRPCError(c, http.StatusBadGateway, peek.ID, -32000, "forward failed", sendErr.Error())
-				}
-				h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, info.Action+"_failed", workspace, session.GetId(), info.Message+" failed", "session closed before response", detail)
-				return writeJSONRPCError(c, http.StatusInternalServerError, peek.ID, -32000, "session closed before response", nil)
-			}
-			if evt.Event != "" && evt.Event != "message" {
+				notifications = nil
 				continue
 			}
-			if !responseMatchesID(evt.Data, targetID) {
-				continue
+			if err := notify(evt); err != nil {
+				return forwardOutcome{Failure: "failed", Status: http.StatusInternalServerError, Message: "client disconnected", LogText: err.Error()}
 			}
-			c.Response().Header().Set("Content-Type", "application/json")
-			c.Response().WriteHeader(http.StatusOK)
-			level, errText := finishRPCLogDetail(detail, started, evt.Data)
-			action := info.Action
-			message := info.Message
-			if level == oplog.LevelError {
-				action += "_failed"
-				message += " failed"
+			timer.Reset(streamHTTPWaitTimeout)
+		case evt, ok := <-respChan:
+			if !ok {
+				if sendDone && sendErr != nil {
+					return forwardOutcome{Failure: "failed", Status: http.StatusBadGateway, Message: "forward failed", ErrData: sendErr.Error(), LogText: sendErr.Error()}
+				}
+				return forwardOutcome{Failure: "failed", Status: http.StatusInternalServerError, Message: "session closed before response", LogText: "session closed before response"}
 			}
-			h.appendOperation(c.Request().Context(), gatewayPrincipal(c), level, action, workspace, session.GetId(), message, errText, detail)
-			_, err := c.Response().Write([]byte(evt.Data))
-			return err
+			return forwardOutcome{Data: evt.Data}
+		}
+		if expired {
+			if sendErr != nil {
+				return forwardOutcome{Failure: "failed", Status: http.StatusBadGateway, Message: "forward failed", ErrData: sendErr.Error(), LogText: sendErr.Error()}
+			}
+			return forwardOutcome{Failure: "timeout", Status: http.StatusGatewayTimeout, Message: "timeout waiting for downstream response"}
 		}
 	}
 }
 
+// forwardLogFields 根据转发结果补全操作日志的 detail，并返回日志级别、action、message 与错误详情。
+func forwardLogFields(out forwardOutcome, info rpcLogInfo, detail map[string]interface{}, started time.Time) (oplog.Level, string, string, string) {
+	if out.Failure != "" {
+		detail["duration_ms"] = time.Since(started).Milliseconds()
+		return oplog.LevelError, info.Action + "_" + out.Failure, info.Message + " " + out.Failure, out.LogText
+	}
+	level, errText := finishRPCLogDetail(detail, started, out.Data)
+	if level == oplog.LevelError {
+		return level, info.Action + "_failed", info.Message + " failed", errText
+	}
+	return level, info.Action, info.Message, ""
+}
+
 // streamHTTPEventStream 处理 GET /stream：长连接 SSE 流，把 session 的事件广播
 // 给当前客户端，常用于 server → client 的主动推送。
 func (h *Handler) streamHTTPEventStream(c echo.Context, xl xlog.Logger, workspace string) error {
@@ -272,14 +393,15 @@ func (h *Handler) streamHTTPEventStream(c echo.Context, xl xlog.Logger, workspac
 		return c.String(http.StatusBadRequest, fmt.Sprintf("missing %s header", headerMcpSessionID))
 	}
 
-	session, ok := h.services.GetProxySession(xl, workspaces.NameArg{
-		Workspace: workspace,
-		Session:   sessionID,
-	})
+	session, ok := h.lookupSession(c, xl, workspace, sessionID)
 	if !ok {
 		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.stream_failed", workspace, sessionID, "session stream failed", "session not found", nil)
 		return c.String(http.StatusNotFound, "session not found")
 	}
+	if msg := checkProtocolVersionHeader(c, session); msg != "" {
+		h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelError, "session.stream_failed", workspace, sessionID, "session stream failed", msg, nil)
+		return c.String(http.StatusBadRequest, msg)
+	}
 	h.appendOperation(c.Request().Context(), gatewayPrincipal(c), oplog.LevelInfo, "session.connect", workspace, sessionID, "Streamable HTTP session connected", "", map[string]interface{}{"transport": "streamhttp", "connection": "event-stream"})
 
 	c.Response().Header().Set("Content-Type", "text/event-stream")
@@ -293,9 +415,21 @@ func (h *Handler) streamHTTPEventStream(c echo.Context, xl xlog.Logger, workspac
 		return c.String(http.StatusInternalServerError, "streaming not supported")
 	}
 
-	eventChan, closer := session.GetEventChanWithCloser()
+	// 规范 (Resumability and Redelivery): 客户端可通过 Last-Event-ID 恢复断开的流，
+	// 服务端补发该 ID 之后、原本应在此流上发送的消息。
+	eventChan, replay, closer := session.GetEventChanSince(lastEventIDFromRequest(c))
 	defer closer()
 
+	for _, evt := range replay {
+		if isJSONRPCResponse(evt.Data) {
+			continue
+		}
+		if err := writeSSEEvent(w, evt); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
 	ping := time.NewTicker(streamHTTPKeepAliveEvery)
 	defer ping.Stop()
 
@@ -318,11 +452,7 @@ func (h *Handler) streamHTTPEventStream(c echo.Context, xl xlog.Logger, workspac
 			if isJSONRPCResponse(evt.Data) {
 				continue
 			}
-			eventName := evt.Event
-			if eventName == "" {
-				eventName = "message"
-			}
-			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventName, evt.Data); err != nil {
+			if err := writeSSEEvent(w, evt); err != nil {
 				return nil
 			}
 			flusher.Flush()
@@ -372,26 +502,32 @@ func peekJSONRPC(body []byte) (jsonRPCPeek, error) {
 	return p, nil
 }
 
-// normalizeRawID 去除两侧空白后返回 id 的规范化字节切片。
-func normalizeRawID(raw json.RawMessage) []byte {
-	if len(raw) == 0 {
-		return nil
+// lastEventIDFromRequest 解析 Last-Event-ID 请求头，缺失或非法时返回 -1（不重放）。
+func lastEventIDFromRequest(c echo.Context) int64 {
+	raw := strings.TrimSpace(c.Request().Header.Get(headerLastEventID))
+	if raw == "" {
+		return -1
 	}
-	return bytes.TrimSpace(raw)
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return -1
+	}
+	return id
 }
 
-// responseMatchesID 解析响应 body，判断其 id 是否与目标一致。
-func responseMatchesID(data string, target []byte) bool {
-	if len(target) == 0 {
-		return false
-	}
-	var peek struct {
-		ID json.RawMessage `json:"id"`
+// writeSSEEvent 按 SSE 格式写出一条 session 事件，带上 `id:` 以便客户端断线续传。
+func writeSSEEvent(w io.Writer, evt sessions.SessionMsg) error {
+	eventName := evt.Event
+	if eventName == "" {
+		eventName = "message"
 	}
-	if err := json.Unmarshal([]byte(data), &peek); err != nil {
-		return false
+	if evt.ID > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", evt.ID); err != nil {
+			return err
+		}
 	}
-	return bytes.Equal(bytes.TrimSpace(peek.ID), target)
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventName, evt.Data)
+	return err
 }
 
 // isJSONRPCResponse 判断一条消息是否为 JSON-RPC response（含 result 或 error、
@@ -434,6 +570,12 @@ func writeJSONRPCResult(c echo.Context, id json.RawMessage, result any) error {
 // writeJSONRPCError 写入 JSON-RPC 错误响应。statusCode 用于 HTTP 层，
 // JSON body 内是符合 JSON-RPC 规范的错误结构。
 func writeJSONRPCError(c echo.Context, statusCode int, id json.RawMessage, code int, message string, data any) error {
+	c.Response().Header().Set("Content-Type", "application/json")
+	return c.JSON(statusCode, jsonRPCErrorPayload(id, code, message, data))
+}
+
+// jsonRPCErrorPayload 构造 JSON-RPC error response。
+func jsonRPCErrorPayload(id json.RawMessage, code int, message string, data any) map[string]any {
 	errObj := map[string]any{
 		"code":    code,
 		"message": message,
@@ -450,21 +592,21 @@ func writeJSONRPCError(c echo.Context, statusCode int, id json.RawMessage, code
 	} else {
 		payload["id"] = nil
 	}
-	c.Response().Header().Set("Content-Type", "application/json")
-	return c.JSON(statusCode, payload)
+	return payload
 }
 
-// buildGatewayInitializeResult 构造网关层聚合的 InitializeResult。
-// capabilities 由 session 从已在线下游 MCP 的 initialize 结果 OR 合并而来，
-// 确保网关只向 client 声明至少有一个下游真的支持的能力。
-func buildGatewayInitializeResult(session *sessions.Session) *mcp.InitializeResult {
-	return &mcp.InitializeResult{
-		ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
-		ServerInfo: mcp.Implementation{
-			Name:    "mcp-gateway",
-			Version: "1.0.0",
-		},
-		Capabilities: session.AggregateCapabilities(),
-		Instructions: "MCP Gateway aggregates multiple MCP servers. Tools are namespaced as <serverName>_<toolName>.",
+// checkProtocolVersionHeader 校验 MCP-Protocol-Version 请求头：缺省时沿用 session 协商的版本；
+// 版本不受支持或与协商结果不一致时返回错误描述，规范要求以 400 响应。
+func checkProtocolVersionHeader(c echo.Context, session *sessions.Session) string {
+	version := strings.TrimSpace(c.Request().Header.Get(headerProtocolVersion))
+	if version == "" {
+		return ""
+	}
+	if !sessions.IsSupportedProtocolVersion(version) {
+		return fmt.Sprintf("unsupported %s: %s", headerProtocolVersion, version)
+	}
+	if negotiated := session.ProtocolVersion(); negotiated != "" && negotiated != version {
+		return fmt.Sprintf("%s %s does not match negotiated version %s", headerProtocolVersion, version, negotiated)
 	}
+	return ""
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -77,6 +78,48 @@ func TestGlobalStreamHTTP_Initialize_CreatesSessionAndReturnsResult(t *testing.T
 	mockMgr.AssertExpectations(t)
 }
 
+// --- Initialize: 不支持的协议版本按规范返回 -32602，且不创建 session ---
+func TestGlobalStreamHTTP_Initialize_UnsupportedVersionRejected(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2023-01-01","capabilities":{},"clientInfo":{"name":"old","version":"0.1"}}}`
+	c, rec := buildStreamHTTPRequest(t, http.MethodPost, body, nil)
+
+	assert.NoError(t, srv.handleGlobalStreamHTTP(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, rec.Header().Get("Mcp-Session-Id"))
+
+	var resp map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	errObj, ok := resp["error"].(map[string]any)
+	assert.True(t, ok)
+	assert.EqualValues(t, -32602, errObj["code"])
+	assert.Equal(t, "Unsupported protocol version", errObj["message"])
+	data, _ := errObj["data"].(map[string]any)
+	assert.Equal(t, "2023-01-01", data["requested"])
+	assert.Contains(t, data["supported"], "2024-11-05")
+
+	mockMgr.AssertNotCalled(t, "CreateProxySession", mock.Anything, mock.Anything)
+}
+
+// --- MCP-Protocol-Version 与协商结果不一致时返回 400 ---
+func TestGlobalStreamHTTP_ProtocolVersionHeaderMismatchReturns400(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	sess := newTestSession("sess-v")
+	_, err := sess.Initialize(mcp.InitializeParams{ProtocolVersion: "2024-11-05"})
+	assert.NoError(t, err)
+	mockMgr.On("GetProxySession", mock.Anything, mock.MatchedBy(func(n workspaces.NameArg) bool {
+		return n.Session == "sess-v"
+	})).Return(sess, true).Once()
+
+	body := `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+	c, rec := buildStreamHTTPRequest(t, http.MethodPost, body, map[string]string{"Mcp-Session-Id": "sess-v", "MCP-Protocol-Version": "2025-06-18"})
+
+	assert.NoError(t, srv.handleGlobalStreamHTTP(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockMgr.AssertExpectations(t)
+}
+
 // --- 非 initialize 必须带 Mcp-Session-Id ---
 func TestGlobalStreamHTTP_NonInitialize_MissingSessionReturns400(t *testing.T) {
 	srv, _ := createTestServerManager()
@@ -269,3 +312,39 @@ func TestGlobalStreamHTTP_Request_ForwardAndWait(t *testing.T) {
 
 	mockMgr.AssertExpectations(t)
 }
+
+// --- GET /stream 携带 Last-Event-ID 时补发断线期间的事件，并带 id 字段 ---
+func TestGlobalStreamHTTP_EventStream_ReplaysAfterLastEventID(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	sess := newTestSession("sess-replay")
+	defer sess.Close()
+	mockMgr.On("GetProxySession", mock.Anything, mock.MatchedBy(func(n workspaces.NameArg) bool {
+		return n.Session == "sess-replay"
+	})).Return(sess, true).Once()
+
+	sess.SendEvent(sessions.SessionMsg{Event: "message", Data: `{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"first"}}`})
+	sess.SendEvent(sessions.SessionMsg{Event: "message", Data: `{"jsonrpc":"2.0","id":7,"result":{}}`})
+	sess.SendEvent(sessions.SessionMsg{Event: "message", Data: `{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"third"}}`})
+
+	c, rec := buildStreamHTTPRequest(t, http.MethodGet, "", map[string]string{
+		"Mcp-Session-Id": "sess-replay",
+		"Last-Event-ID":  "1",
+	})
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	c.SetRequest(c.Request().WithContext(ctx))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	err := srv.handleGlobalStreamHTTP(c)
+	assert.NoError(t, err)
+
+	body := rec.Body.String()
+	assert.NotContains(t, body, `"first"`)
+	assert.NotContains(t, body, `"id":7`)
+	assert.Contains(t, body, "id: 3\nevent: message\n")
+	assert.Contains(t, body, `"third"`)
+
+	mockMgr.AssertExpectations(t)
+}
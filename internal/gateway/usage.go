@@ -0,0 +1,188 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/httpx"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+)
+
+const usageCounterKey = "usage.counter"
+
+// usageAccount 返回用量计入的账号，匿名调用为空。
+func usageAccount(principal *identity.Principal) string {
+	if principal == nil {
+		return ""
+	}
+	return principal.AccountID
+}
+
+// meterUsage 统计 MCP 入口收到与发出的字节数，WebSocket 按 hijack 后连接上的读写计算。
+// 未启用计量（非 SaaS 模式）时直接调用 next。
+func (h *Handler) meterUsage(next echo.HandlerFunc) echo.HandlerFunc {
+	if h.meter == nil {
+		return next
+	}
+	return func(c echo.Context) error {
+		counter := &usageCounter{meter: h.meter, c: c}
+		c.Set(usageCounterKey, counter)
+		if req := c.Request(); req.Body != nil {
+			req.Body = &countingBody{ReadCloser: req.Body, counter: counter}
+		}
+		c.Response().Writer = &meteredWriter{ResponseWriter: c.Response().Writer, counter: counter}
+		defer counter.finish()
+		return next(c)
+	}
+}
+
+// setUsageService 把本次请求的字节数计入 service，在确定调用的服务后由 handler 设置。
+func setUsageService(c echo.Context, service string) {
+	if counter, ok := c.Get(usageCounterKey).(*usageCounter); ok {
+		counter.mu.Lock()
+		counter.service = service
+		counter.mu.Unlock()
+	}
+}
+
+// usageCounter 汇总一次请求的字节数。请求体通常在确定服务前就已读取，
+// 因此先暂存，首次写响应或请求结束时再连同服务一起计入。
+type usageCounter struct {
+	meter *usage.Meter
+	c     echo.Context
+
+	mu                 sync.Mutex
+	resolved           bool
+	account, workspace string
+	service            string
+	pending            int
+	released           bool
+}
+
+func (u *usageCounter) addRequest(n int) {
+	if n <= 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.released {
+		u.pending += n
+		return
+	}
+	u.recordLocked(n, 0)
+}
+
+func (u *usageCounter) addResponse(n int) {
+	if n <= 0 {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.releaseLocked()
+	u.recordLocked(0, n)
+}
+
+func (u *usageCounter) finish() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.releaseLocked()
+}
+
+func (u *usageCounter) releaseLocked() {
+	if u.released {
+		return
+	}
+	u.released = true
+	u.recordLocked(u.pending, 0)
+	u.pending = 0
+}
+
+// recordLocked 计入字节数。账号与 workspace 在首次计入时解析，此时鉴权与 workspace 选择均已完成。
+func (u *usageCounter) recordLocked(requestBytes, responseBytes int) {
+	if !u.resolved {
+		u.resolved = true
+		u.account = usageAccount(gatewayPrincipal(u.c))
+		u.workspace = httpx.GetWorkspace(u.c, workspaces.DefaultWorkspace)
+	}
+	u.meter.RecordBytes(u.account, u.workspace, u.service, requestBytes, responseBytes)
+}
+
+// countingBody 统计读取的请求体字节数。
+type countingBody struct {
+	io.ReadCloser
+	counter *usageCounter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.counter.addRequest(n)
+	return n, err
+}
+
+// meteredWriter 统计写出的响应字节数，并保留 Flush、Hijack 以支持 SSE 与 WebSocket。
+type meteredWriter struct {
+	http.ResponseWriter
+	counter *usageCounter
+}
+
+func (w *meteredWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.counter.addResponse(n)
+	return n, err
+}
+
+func (w *meteredWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *meteredWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	raw, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := &countingConn{Conn: raw, counter: w.counter}
+	// 握手时已读入缓冲的数据不再经过 conn，需要保留在新的 reader 前面
+	var source io.Reader = conn
+	if n := rw.Reader.Buffered(); n > 0 {
+		buffered, _ := rw.Reader.Peek(n)
+		source = io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), conn)
+	}
+	return conn, bufio.NewReadWriter(bufio.NewReader(source), bufio.NewWriter(conn)), nil
+}
+
+func (w *meteredWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingConn 统计 hijack 后连接上读写的字节数。
+type countingConn struct {
+	net.Conn
+	counter *usageCounter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.counter.addRequest(n)
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.counter.addResponse(n)
+	return n, err
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryUsageStore 只保存写入的增量，测试通过 Meter.Usage 读取合并后的用量。
+type memoryUsageStore struct {
+	mu      sync.Mutex
+	records []identity.UsageRecord
+}
+
+func (s *memoryUsageStore) IncrementUsage(_ context.Context, records []identity.UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memoryUsageStore) ListUsage(context.Context, identity.UsageFilter) ([]identity.UsageRecord, error) {
+	return []identity.UsageRecord{}, nil
+}
+
+// newQuotaExhaustedServer 返回 default workspace 本月工具调用配额已用尽的网关。
+func newQuotaExhaustedServer(t *testing.T) (*echo.Echo, *usage.Meter) {
+	t.Helper()
+	srv, mockMgr := createTestServerManager()
+	srv.cfg.GatewayProtocol = "all"
+	srv.cfg.Auth = &config.AuthConfig{Enabled: false}
+	srv.meter = usage.NewMeter(&memoryUsageStore{}, config.UsageQuotaConfig{
+		Workspaces: map[string]config.UsageQuota{workspaces.DefaultWorkspace: {ToolCalls: 1}},
+	})
+	t.Cleanup(func() { _ = srv.meter.Close(context.Background()) })
+	srv.meter.RecordToolCall("", workspaces.DefaultWorkspace, "github")
+
+	sess := newTestSession("sess-quota")
+	t.Cleanup(sess.Close)
+	mockMgr.On("GetProxySession", mock.Anything, workspaces.NameArg{Workspace: workspaces.DefaultWorkspace, Session: "sess-quota"}).Return(sess, true)
+	e := echo.New()
+	srv.Register(e)
+	return e, srv.meter
+}
+
+// --- /stream：配额用尽以 JSON-RPC error 返回，请求与响应字节计入被调用的服务 ---
+func TestGlobalStreamHTTP_QuotaExceededToolCall(t *testing.T) {
+	e, meter := newQuotaExhaustedServer(t)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, postStream("/stream", "sess-quota", limitedToolCall))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderRetryAfter))
+	var resp struct {
+		Error struct {
+			Code int            `json:"code"`
+			Data map[string]any `json:"data"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, quotaExceededCode, resp.Error.Code)
+	assert.Equal(t, usage.ScopeWorkspace, resp.Error.Data["scope"])
+	assert.Equal(t, "tool_calls", resp.Error.Data["metric"])
+	assert.EqualValues(t, 1, resp.Error.Data["limit"])
+
+	records, err := meter.Usage(context.Background(), identity.UsageFilter{WorkspaceID: workspaces.DefaultWorkspace})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "github", records[0].Service)
+	assert.EqualValues(t, 1, records[0].ToolCalls, "rejected calls must not be counted")
+	assert.EqualValues(t, len(limitedToolCall), records[0].RequestBytes)
+	assert.EqualValues(t, rec.Body.Len(), records[0].ResponseBytes)
+}
+
+// --- /message：配额用尽时 POST 本身返回 429 ---
+func TestGlobalMessage_QuotaExceededToolCall(t *testing.T) {
+	e, _ := newQuotaExhaustedServer(t)
+	req := httptest.NewRequest(http.MethodPost, "/message?sessionId=sess-quota", strings.NewReader(limitedToolCall))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":-32030`)
+}
+
+// --- 组合工具按步骤计量：每个步骤计入所调用的服务，外层 gateway_<name> 不重复计入 ---
+func TestCompositeToolUsageIsRecordedPerStep(t *testing.T) {
+	srv, _ := createTestServerManager()
+	srv.meter = usage.NewMeter(&memoryUsageStore{}, config.UsageQuotaConfig{})
+	t.Cleanup(func() { _ = srv.meter.Close(context.Background()) })
+	principal := &identity.Principal{AccountID: "acc-1"}
+
+	outer := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"gateway_triage","arguments":{}}}`
+	require.Nil(t, srv.admitToolCall(context.Background(), principal, workspaces.DefaultWorkspace, "sess-composite", []byte(outer), nil))
+	admit := srv.compositeStepAdmitter(principal, workspaces.DefaultWorkspace, "sess-composite")
+	for _, tool := range []string{"github_search_issues", "github_get_issue", "github_get_issue", "fs_write_file"} {
+		require.NoError(t, admit(context.Background(), tool))
+	}
+
+	records, err := srv.meter.Usage(context.Background(), identity.UsageFilter{WorkspaceID: workspaces.DefaultWorkspace})
+	require.NoError(t, err)
+	calls := map[string]int64{}
+	for _, record := range records {
+		calls[record.Service] += record.ToolCalls
+	}
+	assert.Equal(t, map[string]int64{"github": 3, "fs": 1}, calls)
+}
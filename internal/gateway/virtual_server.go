@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+)
+
+// virtualServerKey 保存 /v/:name 路由选中的虚拟服务器名。
+const virtualServerKey = "gateway.virtual_server"
+
+// virtualServerFromPath 处理 /v/:name/... 路由：新建的 session 只订阅该虚拟服务器所列的服务。
+func (h *Handler) virtualServerFromPath(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		if name == "" {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		c.Set(virtualServerKey, name)
+		return next(c)
+	}
+}
+
+// virtualServerName 返回当前请求所在的虚拟服务器，非 /v/:name 路由时为空。
+func virtualServerName(c echo.Context) string {
+	name, _ := c.Get(virtualServerKey).(string)
+	return name
+}
+
+// virtualServerPathPrefix 返回 /v/:name 路径前缀，非虚拟服务器路由时为空。
+func virtualServerPathPrefix(c echo.Context) string {
+	if name := virtualServerName(c); name != "" {
+		return "/v/" + name
+	}
+	return ""
+}
+
+// createSession 为当前入口（含 /v/:name 虚拟服务器）创建 session。
+func (h *Handler) createSession(c echo.Context, xl xlog.Logger, workspace string) (*sessions.Session, error) {
+	session, err := h.services.CreateProxySession(xl, workspaces.NameArg{Workspace: workspace, VirtualServer: virtualServerName(c)})
+	if err != nil {
+		return nil, err
+	}
+	principal := gatewayPrincipal(c)
+	// 调用方用于匹配工具参数策略中的 principals
+	caller := sessions.Caller{Workspace: workspace}
+	if principal != nil {
+		caller.AccountID, caller.APIKeyID = principal.AccountID, principal.APIKeyID
+	}
+	session.SetCaller(caller)
+	// 组合工具的每个步骤按调用方单独准入
+	session.SetToolAdmitter(h.compositeStepAdmitter(principal, workspace, session.Id))
+	if h.meter != nil {
+		// 会话时长从创建起计算，直到 session 关闭
+		h.meter.TrackSession(usageAccount(principal), workspace, session.Done())
+	}
+	detail := map[string]interface{}{"path": c.Path()}
+	if name := virtualServerName(c); name != "" {
+		detail["virtual_server"] = name
+	}
+	h.appendOperation(c.Request().Context(), principal, oplog.LevelInfo, "session.create", workspace, session.Id, "MCP session created", "", detail)
+	return session, nil
+}
+
+// lookupSession 查找当前入口上的 session；session 不属于该入口的虚拟服务器时视为不存在，
+// 避免通过其它入口访问到更大的工具集。
+func (h *Handler) lookupSession(c echo.Context, xl xlog.Logger, workspace, sessionID string) (*sessions.Session, bool) {
+	session, ok := h.services.GetProxySession(xl, workspaces.NameArg{Workspace: workspace, Session: sessionID})
+	if !ok || session.VirtualServer() != virtualServerName(c) {
+		return nil, false
+	}
+	return session, true
+}
+
+// sessionCreateStatus 返回创建 session 失败时的 HTTP 状态码。
+func sessionCreateStatus(err error) int {
+	if errors.Is(err, sessions.ErrVirtualServerNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const virtualInitializeBody = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+
+func postStream(path, sessionID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(headerMcpSessionID, sessionID)
+	}
+	return req
+}
+
+// --- /w/:workspace/v/:name/stream：initialize 创建虚拟服务器 session，未声明的名字返回 404 ---
+func TestVirtualServerRouting_CreatesVirtualSession(t *testing.T) {
+	e, mockMgr := newWorkspaceRoutingServer()
+	sess := newTestSession("sess-virtual")
+	defer sess.Close()
+	mockMgr.On("CreateProxySession", mock.Anything, workspaces.NameArg{Workspace: "team-a", VirtualServer: "triage"}).Return(sess, nil).Once()
+	mockMgr.On("CreateProxySession", mock.Anything, workspaces.NameArg{Workspace: "team-a", VirtualServer: "missing"}).
+		Return((*sessions.Session)(nil), fmt.Errorf("%w: missing", sessions.ErrVirtualServerNotFound)).Once()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, postStream("/w/team-a/v/triage/stream", "", virtualInitializeBody))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "sess-virtual", rec.Header().Get(headerMcpSessionID))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, postStream("/w/team-a/v/missing/stream", "", virtualInitializeBody))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	mockMgr.AssertExpectations(t)
+}
+
+// --- 普通 session 不能通过虚拟服务器入口访问，反之亦然 ---
+func TestVirtualServerRouting_RejectsSessionFromOtherEntry(t *testing.T) {
+	e, mockMgr := newWorkspaceRoutingServer()
+	sess := newTestSession("sess-plain")
+	defer sess.Close()
+	mockMgr.On("GetProxySession", mock.Anything, workspaces.NameArg{Workspace: workspaces.DefaultWorkspace, Session: "sess-plain"}).Return(sess, true)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, postStream("/v/triage/stream", "sess-plain", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,241 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/httpx"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/mark3labs/mcp-go/mcp"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// 服务端发送 ping 的间隔
+	wsPingInterval = 30 * time.Second
+	// 超过该时长没有收到任何数据（含 pong）视为连接已断开
+	wsPongWait     = 2*wsPingInterval + 10*time.Second
+	wsWriteTimeout = 10 * time.Second
+	// 单条 JSON-RPC 消息的大小上限
+	wsMaxMessageBytes = 8 << 20
+)
+
+// wsPing 发送一个 WebSocket ping 控制帧，client 按协议自动回复 pong。
+var wsPing = websocket.Codec{Marshal: func(interface{}) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+// handleGlobalWebSocket 处理 GET /ws：在 WebSocket 文本帧上承载 MCP JSON-RPC。
+// 每条连接对应一个 session，连接关闭时 session 随之关闭；client 的消息并发分发，
+// 响应与通知都通过同一条连接下发。
+func (h *Handler) handleGlobalWebSocket(c echo.Context) error {
+	xl := xlog.NewLogger("GLOBAL-WS")
+	workspace := httpx.GetWorkspace(c, workspaces.DefaultWorkspace)
+	ctx := c.Request().Context()
+	principal := gatewayPrincipal(c)
+
+	if !strings.EqualFold(c.Request().Header.Get(echo.HeaderUpgrade), "websocket") {
+		return c.String(http.StatusBadRequest, "websocket upgrade required")
+	}
+	if _, ok := c.Response().Writer.(http.Hijacker); !ok {
+		return c.String(http.StatusInternalServerError, "websocket not supported")
+	}
+	if err := h.ensureWorkspaceServicesRunning(ctx, workspace, xl); err != nil {
+		xl.Errorf("restore workspace services failed: %v", err)
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.create_failed", workspace, "", "session create failed", err.Error(), map[string]interface{}{"transport": "websocket"})
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	session, err := h.createSession(c, xl, workspace)
+	if err != nil {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.create_failed", workspace, "", "session create failed", err.Error(), map[string]interface{}{"transport": "websocket"})
+		return c.String(sessionCreateStatus(err), err.Error())
+	}
+	applyPrincipalToolMode(session, principal)
+
+	served := false
+	writer := &activityResponseWriter{ResponseWriter: c.Response()}
+	server := websocket.Server{
+		// 鉴权已由 mcpAuthMiddleware 完成，非浏览器 client 通常不带 Origin，这里不做校验
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			served = true
+			h.appendOperation(ctx, principal, oplog.LevelInfo, "session.connect", workspace, session.Id, "WebSocket session connected", "", map[string]interface{}{"transport": "websocket", "connection": "created"})
+			h.serveWebSocket(ctx, xl, principal, workspace, session, conn, writer.conn)
+		},
+	}
+	server.ServeHTTP(writer, c.Request())
+
+	h.services.CloseProxySession(xl, workspaces.NameArg{Workspace: workspace, Session: session.Id})
+	if served {
+		h.appendOperation(ctx, principal, oplog.LevelInfo, "session.delete", workspace, session.Id, "MCP session deleted", "", map[string]interface{}{"transport": "websocket", "reason": "connection closed"})
+	}
+	return nil
+}
+
+// serveWebSocket 在连接存续期间收发消息：读循环分发 client 消息，写协程下发 session
+// 事件并定期发送 ping，超过 wsPongWait 没有读到数据时断开连接。
+func (h *Handler) serveWebSocket(ctx context.Context, xl xlog.Logger, principal *identity.Principal, workspace string, session *sessions.Session, conn *websocket.Conn, activity *activityConn) {
+	conn.MaxPayloadBytes = wsMaxMessageBytes
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		// 写协程退出时关闭连接，让读循环随之结束
+		defer conn.Close()
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-done:
+				// 读循环已结束，尽量把已产生的响应写完再退出
+				for {
+					select {
+					case evt, ok := <-events:
+						if !ok {
+							return
+						}
+						_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+						if err := websocket.Message.Send(conn, evt.Data); err != nil {
+							return
+						}
+					default:
+						return
+					}
+				}
+			case evt, ok := <-events:
+				if !ok {
+					xl.Infof("Event channel closed, sessionId: %s", session.Id)
+					return
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := websocket.Message.Send(conn, evt.Data); err != nil {
+					xl.Warnf("write websocket message failed: %v", err)
+					return
+				}
+			case <-ping.C:
+				if activity != nil && activity.idle() > wsPongWait {
+					xl.Warnf("no data from websocket client for %s, closing, sessionId: %s", wsPongWait, session.Id)
+					return
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := wsPing.Send(conn, nil); err != nil {
+					xl.Warnf("write websocket ping failed: %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	var inflight sync.WaitGroup
+	for {
+		var body []byte
+		if err := websocket.Message.Receive(conn, &body); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				xl.Infof("websocket read ended: %v, sessionId: %s", err, session.Id)
+			}
+			break
+		}
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			h.handleWebSocketMessage(ctx, xl, principal, workspace, session, body)
+		}()
+	}
+	// 等待进行中的请求结束，保证其响应在连接仍可写时下发
+	inflight.Wait()
+	close(done)
+	<-writerDone
+}
+
+// handleWebSocketMessage 处理 client 发来的一条消息（单个 JSON-RPC 消息或批量请求）。
+func (h *Handler) handleWebSocketMessage(ctx context.Context, xl xlog.Logger, principal *identity.Principal, workspace string, session *sessions.Session, body []byte) {
+	if isJSONRPCBatch(body) {
+		if msg := h.dispatchMessageBatch(ctx, principal, xl, workspace, session, body, "websocket"); msg != "" {
+			sendSessionError(session, nil, mcp.INVALID_REQUEST, msg, nil)
+		}
+		return
+	}
+	info := rpcLogInfoFromBody(body, "")
+	detail := rpcLogDetail(info, "websocket")
+	peek, err := peekJSONRPC(body)
+	if err != nil {
+		h.appendOperation(ctx, principal, oplog.LevelError, "session.request_failed", workspace, session.Id, "Failed to parse MCP request", err.Error(), detail)
+		sendSessionError(session, nil, mcp.PARSE_ERROR, "parse error", nil)
+		return
+	}
+	if rejected := h.admitToolCall(ctx, principal, workspace, session.Id, body, detail); rejected != nil {
+		sendSessionError(session, peek.ID, rejected.code, rejected.message, rejected.data)
+		return
+	}
+	if err := session.SendMessageContext(ctx, xl, body); err != nil {
+		h.appendOperation(ctx, principal, oplog.LevelError, info.Action+"_failed", workspace, session.Id, info.Message+" failed", err.Error(), detail)
+		return
+	}
+	h.appendOperation(ctx, principal, oplog.LevelInfo, info.Action, workspace, session.Id, info.Message, "", detail)
+}
+
+// activityResponseWriter 在 WebSocket 握手 hijack 连接时包装底层连接，记录读取活动。
+// x/net/websocket 在内部消化 pong 帧，只能通过底层连接上的读取判断对端是否存活。
+type activityResponseWriter struct {
+	http.ResponseWriter
+	conn *activityConn
+}
+
+func (w *activityResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	raw, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &activityConn{Conn: raw}
+	w.conn.touch()
+	// 握手时已读入缓冲的数据需要保留在新的 reader 前面
+	var source io.Reader = w.conn
+	if n := rw.Reader.Buffered(); n > 0 {
+		buffered, _ := rw.Reader.Peek(n)
+		source = io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), w.conn)
+	}
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(source), rw.Writer), nil
+}
+
+// activityConn 记录最近一次从连接读到数据的时间。
+type activityConn struct {
+	net.Conn
+	lastRead atomic.Int64
+}
+
+func (c *activityConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *activityConn) touch() {
+	c.lastRead.Store(time.Now().UnixNano())
+}
+
+func (c *activityConn) idle() time.Duration {
+	return time.Since(time.Unix(0, c.lastRead.Load()))
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// --- /ws：一条连接对应一个 session，JSON-RPC 消息按帧收发，断开后关闭 session ---
+func TestGlobalWebSocket_ServesSessionOverFrames(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	sess := newTestSession("sess-ws")
+	mockMgr.On("CreateProxySession", mock.Anything, mock.MatchedBy(func(n workspaces.NameArg) bool {
+		return n.Workspace == workspaces.DefaultWorkspace
+	})).Return(sess, nil).Once()
+	closed := make(chan struct{})
+	mockMgr.On("CloseProxySession", mock.Anything, workspaces.NameArg{Workspace: workspaces.DefaultWorkspace, Session: "sess-ws"}).
+		Run(func(mock.Arguments) {
+			sess.Close()
+			close(closed)
+		}).Once()
+
+	e := echo.New()
+	e.GET("/ws", srv.handleGlobalWebSocket)
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", "", ts.URL)
+	require.NoError(t, err)
+
+	receive := func() map[string]any {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		var frame string
+		require.NoError(t, websocket.Message.Receive(conn, &frame))
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal([]byte(frame), &msg))
+		return msg
+	}
+
+	require.NoError(t, websocket.Message.Send(conn, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`))
+	initResp := receive()
+	assert.EqualValues(t, 1, initResp["id"])
+	assert.Equal(t, "2025-03-26", initResp["result"].(map[string]any)["protocolVersion"])
+	assert.Equal(t, "2025-03-26", sess.ProtocolVersion())
+
+	require.NoError(t, websocket.Message.Send(conn, `not json`))
+	parseErr := receive()
+	assert.EqualValues(t, -32700, parseErr["error"].(map[string]any)["code"])
+
+	require.NoError(t, conn.Close())
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("session should be closed when the websocket disconnects")
+	}
+	mockMgr.AssertExpectations(t)
+}
+
+// --- 非 WebSocket 请求直接拒绝，不创建 session ---
+func TestGlobalWebSocket_RequiresUpgrade(t *testing.T) {
+	srv, mockMgr := createTestServerManager()
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	rec := httptest.NewRecorder()
+
+	assert.NoError(t, srv.handleGlobalWebSocket(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockMgr.AssertNotCalled(t, "CreateProxySession", mock.Anything, mock.Anything)
+}
@@ -62,6 +62,8 @@ func serviceConfigFromMap(raw map[string]interface{}, workspaceID string) config
 	cfg.Args = asStringSlice(raw["args"])
 	cfg.Env = asStringMap(raw["env"])
 	cfg.GatewayProtocol = asString(raw["gateway_protocol"])
+	cfg.Stateful, _ = raw["stateful"].(bool)
+	cfg.Cache = config.ToolCacheConfigFromMap(raw["cache"])
 	if cfg.Env == nil {
 		cfg.Env = map[string]string{}
 	}
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/httpx"
+)
+
+// workspacePathPrefixKey 保存路径作用域的前缀（/w/:workspace），生成 endpoint 与重定向地址时保留它。
+const workspacePathPrefixKey = "gateway.workspace_path_prefix"
+
+// workspaceFromPath 处理 /w/:workspace/... 路由：路径中的 workspace 优先于子域名、header 与 query。
+// 只能配置 URL 的 client 可以直接把 workspace 写进地址。
+func (h *Handler) workspaceFromPath(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		workspace := c.Param("workspace")
+		if workspace == "" {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		httpx.SetWorkspace(c, workspace)
+		c.Set(workspacePathPrefixKey, "/w/"+workspace)
+		return next(c)
+	}
+}
+
+// workspaceFromHost 在配置了 WorkspaceHostSuffix 时按子域名选择 workspace，
+// 例如 team-a.gateway.example 选择 team-a；不匹配的 host 保持原有的选择方式。
+func (h *Handler) workspaceFromHost(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if h.cfg != nil && h.cfg.WorkspaceHostSuffix != "" {
+			if workspace := workspaceFromHostname(h.requestHost(c), h.cfg.WorkspaceHostSuffix); workspace != "" {
+				httpx.SetWorkspace(c, workspace)
+			}
+		}
+		return next(c)
+	}
+}
+
+// workspaceFromHostname 返回 host 在 suffix 之下的单级子域名，不匹配时返回空。
+func workspaceFromHostname(host, suffix string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	suffix = "." + strings.ToLower(strings.Trim(suffix, "."))
+	label, ok := strings.CutSuffix(host, suffix)
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// workspacePathPrefix 返回当前请求的路径作用域前缀，非 /w/:workspace 路由时为空。
+func workspacePathPrefix(c echo.Context) string {
+	prefix, _ := c.Get(workspacePathPrefixKey).(string)
+	return prefix
+}
+
+// gatewayURL 生成指向网关自身的绝对地址，保留 client 使用的 scheme、host、路径作用域
+// 与虚拟服务器前缀。
+func (h *Handler) gatewayURL(c echo.Context, path string) string {
+	return h.absoluteURL(c, workspacePathPrefix(c)+virtualServerPathPrefix(c)+path)
+}
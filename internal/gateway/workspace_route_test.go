@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWorkspaceFromHostname(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{host: "team-a.gateway.example", expected: "team-a"},
+		{host: "Team-A.Gateway.Example:8443", expected: "team-a"},
+		{host: "gateway.example", expected: ""},
+		{host: "a.b.gateway.example", expected: ""},
+		{host: "team-a.other.example", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.expected, workspaceFromHostname(tt.host, "gateway.example"))
+		})
+	}
+}
+
+func newWorkspaceRoutingServer() (*echo.Echo, *MockServiceManager) {
+	srv, mockMgr := createTestServerManager()
+	srv.cfg.GatewayProtocol = "all"
+	srv.cfg.WorkspaceHostSuffix = "gateway.example"
+	srv.cfg.Auth = &config.AuthConfig{Enabled: false}
+	e := echo.New()
+	srv.Register(e)
+	srv.RegisterProxy(e)
+	return e, mockMgr
+}
+
+// --- /w/:workspace/sse：路径决定 workspace，重定向保留前缀与 client 使用的 scheme ---
+func TestWorkspacePathRouting_SSERedirectKeepsScope(t *testing.T) {
+	e, mockMgr := newWorkspaceRoutingServer()
+	sess := newTestSession("sess-scoped")
+	defer sess.Close()
+	mockMgr.On("CreateProxySession", mock.Anything, mock.MatchedBy(func(n workspaces.NameArg) bool {
+		return n.Workspace == "team-b"
+	})).Return(sess, nil).Once()
+
+	// 路径优先于子域名与 header
+	req := httptest.NewRequest(http.MethodGet, "/w/team-b/sse", nil)
+	req.Host = "team-a.gateway.example"
+	req.Header.Set("X-Workspace-Id", "other")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://team-a.gateway.example/w/team-b/sse?sessionId=sess-scoped", rec.Header().Get(echo.HeaderLocation))
+	mockMgr.AssertExpectations(t)
+}
+
+// --- 子域名选择 workspace，/w/:workspace/stream 的请求落到路径指定的 workspace ---
+func TestWorkspaceRouting_HostAndPathSelectWorkspace(t *testing.T) {
+	e, mockMgr := newWorkspaceRoutingServer()
+	mockMgr.On("CloseProxySession", mock.Anything, workspaces.NameArg{Workspace: "team-a", Session: "s1"}).Once()
+	mockMgr.On("CloseProxySession", mock.Anything, workspaces.NameArg{Workspace: "team-c", Session: "s2"}).Once()
+
+	req := httptest.NewRequest(http.MethodDelete, "/stream", nil)
+	req.Host = "team-a.gateway.example"
+	req.Header.Set(headerMcpSessionID, "s1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/w/team-c/stream", strings.NewReader(""))
+	req.Header.Set(headerMcpSessionID, "s2")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockMgr.AssertExpectations(t)
+}
@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+const defaultApprovalTimeout = 10 * time.Minute
+
+// ApprovalConfig 控制 workspace 内哪些 tools/call 需要人工审批后才转发。
+// 除此之外，effect 为 approve 的工具策略命中的调用也需要审批。
+type ApprovalConfig struct {
+	// Destructive 为 true 时，annotations.destructiveHint 为 true 的工具调用需要审批
+	Destructive bool `json:"destructive,omitempty"`
+	// TimeoutSeconds 为等待审批的最长时间，超时后调用失败，<=0 时使用 10 分钟
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// Timeout 返回等待审批的最长时间。
+func (c ApprovalConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return defaultApprovalTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	defaultToolCacheMaxEntries    = 256
+	defaultToolCacheMaxEntryBytes = 1024 * 1024
+)
+
+// ToolCacheConfig 配置单个下游服务的 tools/call 结果缓存。
+// 默认只缓存 annotations.readOnlyHint 为 true 的工具，Tools 中列出的工具无论注解如何都会缓存。
+type ToolCacheConfig struct {
+	// TTLSeconds 为缓存有效期，<=0 表示不缓存
+	TTLSeconds int `json:"ttlSeconds"`
+	// MaxEntries 为该服务最多缓存的结果数，超出时淘汰最久未使用的结果
+	MaxEntries int `json:"maxEntries,omitempty"`
+	// MaxEntryBytes 为单个结果的最大字节数，更大的结果不缓存
+	MaxEntryBytes int      `json:"maxEntryBytes,omitempty"`
+	Tools         []string `json:"tools,omitempty"`
+}
+
+// Enabled 返回是否开启缓存。
+func (c *ToolCacheConfig) Enabled() bool {
+	return c != nil && c.TTLSeconds > 0
+}
+
+// TTL 返回缓存有效期。
+func (c *ToolCacheConfig) TTL() time.Duration {
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+// WithDefaults 返回补全默认值后的配置。
+func (c ToolCacheConfig) WithDefaults() ToolCacheConfig {
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = defaultToolCacheMaxEntries
+	}
+	if c.MaxEntryBytes <= 0 {
+		c.MaxEntryBytes = defaultToolCacheMaxEntryBytes
+	}
+	return c
+}
+
+// ToMap 转换为服务配置持久化使用的 map 形式。
+func (c *ToolCacheConfig) ToMap() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"ttlSeconds":    c.TTLSeconds,
+		"maxEntries":    c.MaxEntries,
+		"maxEntryBytes": c.MaxEntryBytes,
+		"tools":         append([]string(nil), c.Tools...),
+	}
+}
+
+// ToolCacheConfigFromMap 解析请求或持久化数据中的缓存配置，无效或缺失时返回 nil。
+func ToolCacheConfigFromMap(raw interface{}) *ToolCacheConfig {
+	if raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var cfg ToolCacheConfig
+	if err := json.Unmarshal(data, &cfg); err != nil || !cfg.Enabled() {
+		return nil
+	}
+	return &cfg
+}
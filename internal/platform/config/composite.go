@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompositeToolConfig 声明一个由多个下游工具调用按顺序串联而成的组合工具，
+// 在网关侧执行，对 client 以 gateway_<Name> 的形式暴露。
+type CompositeToolConfig struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	Steps       []CompositeStepConfig  `json:"steps"`
+	// Output 为最终结果模板，为空时返回最后一个步骤的结果
+	Output string `json:"output,omitempty"`
+}
+
+// CompositeStepConfig 是组合工具中的一个步骤。
+//
+// Arguments 中的字符串值支持 {{ path }} 模板，path 可以引用 input.<参数>、
+// steps.<步骤ID>.<字段> 以及 ForEach 中的 item / index；数组下标写作 .0。
+// 整个字符串恰好是一个模板时保留原始类型，否则按文本拼接。
+type CompositeStepConfig struct {
+	ID string `json:"id"`
+	// Tool 为带服务前缀的工具名，例如 github_search_issues
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	// ForEach 为指向数组的模板路径，设置后对数组中的每个元素执行一次该步骤
+	ForEach string `json:"forEach,omitempty"`
+}
+
+// Validate 检查组合工具声明是否完整。
+func (c CompositeToolConfig) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("composite tool name is required")
+	}
+	if len(c.Steps) == 0 {
+		return fmt.Errorf("composite tool %s has no steps", c.Name)
+	}
+	seen := make(map[string]bool, len(c.Steps))
+	for i, step := range c.Steps {
+		if step.ID == "" {
+			return fmt.Errorf("composite tool %s step #%d: id is required", c.Name, i+1)
+		}
+		if seen[step.ID] {
+			return fmt.Errorf("composite tool %s: duplicate step id %s", c.Name, step.ID)
+		}
+		seen[step.ID] = true
+		if !strings.Contains(step.Tool, "_") {
+			return fmt.Errorf("composite tool %s step %s: tool must be <service>_<tool>, got %q", c.Name, step.ID, step.Tool)
+		}
+	}
+	return nil
+}
@@ -19,6 +19,34 @@ type Config struct {
 	ProxySessionTimeout time.Duration // Proxy Session 超时时间
 	McpServiceMgrConfig McpServiceMgrConfig
 	GatewayProtocol     string // "all" | "sse" | "streamhttp"
+	DownstreamPoolSize  int    // 每个下游 MCP 服务在 workspace 内共享的连接数，0 使用默认值，<0 关闭连接复用
+	SessionEventBuffer  int    // 每个 session 事件订阅者的缓冲大小
+	SessionOverflow     string // 订阅者缓冲写满时的策略："drop_notification" | "disconnect"
+	// WorkspaceHostSuffix 非空时按子域名选择 workspace，例如 "gateway.example" 使
+	// team-a.gateway.example 上的 MCP 请求进入 team-a
+	WorkspaceHostSuffix string
+	// CompositeTools 按 workspace id 声明的组合工具
+	CompositeTools map[string][]CompositeToolConfig
+	// LazyTools 按 workspace id 开启 lazy 工具模式：tools/list 只返回网关元工具
+	LazyTools map[string]bool
+	// OutputPolicies 按 workspace id 限制工具结果大小
+	OutputPolicies map[string]OutputPolicyConfig
+	// VirtualServers 按 workspace id 声明的虚拟服务器
+	VirtualServers map[string][]VirtualServerConfig
+	// ToolPolicies 按 workspace id 声明的工具参数策略
+	ToolPolicies map[string][]ToolPolicyRule
+	// Approvals 按 workspace id 配置需要人工审批的工具调用
+	Approvals map[string]ApprovalConfig
+	// Redaction 为日志与操作日志的敏感信息遮盖
+	Redaction RedactionConfig
+	// Redactions 按 workspace id 声明的遮盖规则
+	Redactions map[string]WorkspaceRedactionConfig
+	// RateLimits 为 tools/call 的令牌桶限流规则
+	RateLimits []RateLimitConfig
+	// UsageQuotas 为 SaaS 模式下按月计算的用量配额
+	UsageQuotas UsageQuotaConfig
+	// Webhooks 为接收操作日志与审计事件的外部地址
+	Webhooks []WebhookConfig
 
 	cfgPath string `json:"-"` // 加载时使用的配置文件路径，SaveConfig 将回写到此
 }
@@ -110,6 +138,15 @@ func (c *Config) Default() {
 	if c.McpServiceMgrConfig.McpServiceRetryCount == 0 {
 		c.McpServiceMgrConfig.McpServiceRetryCount = 3
 	}
+	if c.DownstreamPoolSize == 0 {
+		c.DownstreamPoolSize = 4
+	}
+	if c.SessionEventBuffer <= 0 {
+		c.SessionEventBuffer = 100
+	}
+	if c.SessionOverflow != "disconnect" {
+		c.SessionOverflow = "drop_notification"
+	}
 	c.GatewayProtocol = normalizeGatewayExposureProtocol(c.GatewayProtocol)
 	if c.WorkspacePath == "" {
 		c.WorkspacePath = "./vm" // 默认在当前运行目录下的 vm 目录
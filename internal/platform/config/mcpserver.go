@@ -1,15 +1,36 @@
 package config
 
-import "sort"
+import (
+	"sort"
+	"strings"
+)
+
+// ServiceTypeGateway 表示下游是另一个 mcp-gateway：URL 为其根地址，网关通过其 admin API
+// 发现 workspace 与服务，并以服务凭证连接 RemoteWorkspace 的聚合入口。
+const ServiceTypeGateway = "gateway"
+
+// GatewayAPIKeyEnv 为连接远端网关使用的服务凭证（远端的 API key）所在的环境变量。
+const GatewayAPIKeyEnv = "MCP_GATEWAY_API_KEY"
+
+// defaultRemoteWorkspace 与远端网关的默认 workspace 一致
+const defaultRemoteWorkspace = "default"
 
 // MCPServerConfig 定义单个MCP服务器的配置
 type MCPServerConfig struct {
+	// Type 为服务类型，为空时按 Command 或 URL 区分本地进程与远端服务
+	Type            string            `json:"type,omitempty"`
 	Workspace       string            `json:"workspace,omitempty"`
 	URL             string            `json:"url,omitempty"`
 	Command         string            `json:"command,omitempty"`
 	Args            []string          `json:"args,omitempty"`
 	Env             map[string]string `json:"env,omitempty"`
 	GatewayProtocol string            `json:"gateway_protocol,omitempty"`
+	// Stateful 表示下游在连接上保存会话状态，网关需为每个 session 建立独占连接，不参与连接复用
+	Stateful bool `json:"stateful,omitempty"`
+	// Cache 配置只读工具调用结果的缓存，为空表示不缓存
+	Cache *ToolCacheConfig `json:"cache,omitempty"`
+	// RemoteWorkspace 为 gateway 类型服务聚合的远端 workspace，为空时使用 default
+	RemoteWorkspace string `json:"remote_workspace,omitempty"`
 
 	LogConfig
 	McpServiceMgrConfig
@@ -23,3 +44,16 @@ func (c *MCPServerConfig) GetEnvs() []string {
 	sort.Strings(list)
 	return list
 }
+
+// IsGateway 返回服务是否为远端 mcp-gateway。
+func (c *MCPServerConfig) IsGateway() bool {
+	return c.Type == ServiceTypeGateway
+}
+
+// GetRemoteWorkspace 返回 gateway 类型服务聚合的远端 workspace。
+func (c *MCPServerConfig) GetRemoteWorkspace() string {
+	if ws := strings.TrimSpace(c.RemoteWorkspace); ws != "" {
+		return ws
+	}
+	return defaultRemoteWorkspace
+}
@@ -0,0 +1,43 @@
+package config
+
+// 工具结果超出大小限制时的处理方式
+const (
+	OutputModeTruncate = "truncate" // 截断文本并附加提示
+	OutputModeResource = "resource" // 完整结果保存在网关，返回 resource_link 供分页读取
+)
+
+const (
+	defaultOutputPageBytes = 64 * 1024
+	defaultOutputMaxStored = 16
+)
+
+// OutputPolicyConfig 限制单次 tools/call 返回给 client 的内容大小。
+type OutputPolicyConfig struct {
+	// MaxBytes 为结果 content 允许的最大字节数，<=0 表示不限制
+	MaxBytes int `json:"maxBytes,omitempty"`
+	// Mode 为 "truncate" 或 "resource"，为空时按 truncate 处理
+	Mode string `json:"mode,omitempty"`
+	// PageBytes 为 resource 模式下 resources/read 每页返回的字节数
+	PageBytes int `json:"pageBytes,omitempty"`
+	// MaxStored 为 resource 模式下每个 session 保留的结果数，超出时淘汰最早的结果
+	MaxStored int `json:"maxStored,omitempty"`
+}
+
+// Enabled 返回是否配置了大小限制。
+func (c OutputPolicyConfig) Enabled() bool {
+	return c.MaxBytes > 0
+}
+
+// WithDefaults 返回补全默认值后的配置。
+func (c OutputPolicyConfig) WithDefaults() OutputPolicyConfig {
+	if c.Mode != OutputModeResource {
+		c.Mode = OutputModeTruncate
+	}
+	if c.PageBytes <= 0 {
+		c.PageBytes = defaultOutputPageBytes
+	}
+	if c.MaxStored <= 0 {
+		c.MaxStored = defaultOutputMaxStored
+	}
+	return c
+}
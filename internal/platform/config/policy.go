@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// 策略规则匹配后的处理方式
+const (
+	PolicyEffectAllow   = "allow"
+	PolicyEffectDeny    = "deny"
+	PolicyEffectRewrite = "rewrite"
+	// PolicyEffectApprove 停止求值，调用需人工审批后才转发
+	PolicyEffectApprove = "approve"
+)
+
+// 参数条件的比较方式
+const (
+	// PolicyOpExists 参数存在且不为 null
+	PolicyOpExists = "exists"
+	// PolicyOpEquals 参数与 value 的 JSON 值相等
+	PolicyOpEquals = "equals"
+	// PolicyOpPrefix 字符串参数以 value 开头
+	PolicyOpPrefix = "prefix"
+	// PolicyOpPathUnder 字符串参数规范化（path.Clean）后等于 value 或位于 value 目录下
+	PolicyOpPathUnder = "path_under"
+	// PolicyOpMatches 字符串参数匹配正则表达式 value
+	PolicyOpMatches = "matches"
+	// PolicyOpContains 字符串参数包含子串 value，或数组参数包含元素 value
+	PolicyOpContains = "contains"
+)
+
+// policyPathSyntax 是参数路径的语法：以 $ 开头，由 .key 与 [index] 组成，例如 $.options.files[0]。
+var policyPathSyntax = regexp.MustCompile(`^\$(\.[A-Za-z0-9_-]+|\[[0-9]+\])*$`)
+
+// PolicyCondition 是对一个工具参数的判断。参数不存在时除 exists 外的比较均不成立。
+type PolicyCondition struct {
+	// Path 为参数的 JSON path，例如 $.path、$.options.mode
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	// Value 为比较值，字符串中的 {workspace}、{principal} 替换为当前 workspace 与账号 id
+	Value interface{} `json:"value,omitempty"`
+	// Not 为 true 时对比较结果取反
+	Not bool `json:"not,omitempty"`
+}
+
+// Validate 检查条件的路径与比较方式。
+func (c PolicyCondition) Validate() error {
+	if !policyPathSyntax.MatchString(c.Path) {
+		return fmt.Errorf("invalid argument path %q", c.Path)
+	}
+	switch c.Op {
+	case PolicyOpExists, PolicyOpEquals, PolicyOpContains:
+	case PolicyOpPrefix, PolicyOpPathUnder:
+		if _, ok := c.Value.(string); !ok {
+			return fmt.Errorf("%s %s requires a string value", c.Path, c.Op)
+		}
+	case PolicyOpMatches:
+		pattern, ok := c.Value.(string)
+		if !ok {
+			return fmt.Errorf("%s %s requires a string value", c.Path, c.Op)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s %s: %w", c.Path, c.Op, err)
+		}
+	default:
+		return fmt.Errorf("unsupported op %q", c.Op)
+	}
+	return nil
+}
+
+// ToolPolicyRule 是 workspace 内对 tools/call 参数的一条策略。规则按顺序求值：
+// 服务、工具、调用方与全部条件都匹配时生效，allow 直接放行，deny 拒绝调用，
+// rewrite 改写参数后继续求值后续规则，approve 使调用等待人工审批。没有规则命中时放行。
+type ToolPolicyRule struct {
+	Name string `json:"name"`
+	// Service 为服务名模式（path.Match 语法），为空时匹配全部服务
+	Service string `json:"service,omitempty"`
+	// Tool 为带服务前缀的工具名模式（path.Match 语法），例如 filesystem_*，为空时匹配全部工具
+	Tool string `json:"tool,omitempty"`
+	// Principals 为适用的账号 id 或 API key id，为空时匹配所有调用方
+	Principals []string          `json:"principals,omitempty"`
+	When       []PolicyCondition `json:"when,omitempty"`
+	Effect     string            `json:"effect"`
+	// Set 为 rewrite 写入的参数，key 为 JSON path，字符串值支持 {workspace}、{principal}
+	Set map[string]interface{} `json:"set,omitempty"`
+	// Remove 为 rewrite 删除的参数路径
+	Remove []string `json:"remove,omitempty"`
+	// Message 为 deny 返回给 client 的说明
+	Message string `json:"message,omitempty"`
+}
+
+// Validate 检查规则是否完整。
+func (r ToolPolicyRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("tool policy name is required")
+	}
+	for _, pattern := range []string{r.Service, r.Tool} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("tool policy %s: invalid pattern %q", r.Name, pattern)
+		}
+	}
+	for _, cond := range r.When {
+		if err := cond.Validate(); err != nil {
+			return fmt.Errorf("tool policy %s: %w", r.Name, err)
+		}
+	}
+	switch r.Effect {
+	case PolicyEffectAllow, PolicyEffectDeny, PolicyEffectApprove:
+		if len(r.Set) > 0 || len(r.Remove) > 0 {
+			return fmt.Errorf("tool policy %s: set and remove require effect %s", r.Name, PolicyEffectRewrite)
+		}
+	case PolicyEffectRewrite:
+		if len(r.Set) == 0 && len(r.Remove) == 0 {
+			return fmt.Errorf("tool policy %s: rewrite requires set or remove", r.Name)
+		}
+		for p := range r.Set {
+			if !policyPathSyntax.MatchString(p) || p == "$" {
+				return fmt.Errorf("tool policy %s: invalid set path %q", r.Name, p)
+			}
+		}
+		for _, p := range r.Remove {
+			if !policyPathSyntax.MatchString(p) || p == "$" {
+				return fmt.Errorf("tool policy %s: invalid remove path %q", r.Name, p)
+			}
+		}
+	default:
+		return fmt.Errorf("tool policy %s: unsupported effect %q", r.Name, r.Effect)
+	}
+	return nil
+}
+
+// ValidateToolPolicies 检查一组规则，规则名在 workspace 内必须唯一。
+func ValidateToolPolicies(rules []ToolPolicyRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("duplicate tool policy %s", rule.Name)
+		}
+		seen[rule.Name] = true
+	}
+	return nil
+}
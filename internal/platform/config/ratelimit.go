@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// 限流规则的计数维度
+const (
+	RateLimitScopePrincipal = "principal"
+	RateLimitScopeWorkspace = "workspace"
+	RateLimitScopeTool      = "tool"
+)
+
+// RateLimitConfig 声明一条 tools/call 令牌桶限流规则。每条规则按 Scope 为每个
+// 调用方、workspace 或工具各维护一个桶，请求需要所有匹配规则都有令牌才会放行。
+type RateLimitConfig struct {
+	Name string `json:"name"`
+	// Scope 为 "principal"（每个 API key 或账号一个桶）、"workspace"（每个 workspace 一个桶）
+	// 或 "tool"（每个 workspace 内每个工具一个桶）
+	Scope string `json:"scope"`
+	// Workspace 限定规则生效的 workspace，为空时对所有 workspace 生效
+	Workspace string `json:"workspace,omitempty"`
+	// Principal 限定规则生效的调用方（API key id 或账号 id），为空时对所有调用方生效
+	Principal string `json:"principal,omitempty"`
+	// Tool 为带服务前缀的工具名模式（path.Match 语法），例如 github_*；为空时匹配所有工具
+	Tool string `json:"tool,omitempty"`
+	// Rate 为每秒补充的令牌数
+	Rate float64 `json:"rate"`
+	// Burst 为桶容量，0 时取不小于 Rate 的最小整数
+	Burst int `json:"burst,omitempty"`
+}
+
+// Validate 检查限流规则是否完整。
+func (r RateLimitConfig) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("rate limit name is required")
+	}
+	switch r.Scope {
+	case RateLimitScopePrincipal, RateLimitScopeWorkspace, RateLimitScopeTool:
+	default:
+		return fmt.Errorf("rate limit %s: scope must be principal, workspace or tool, got %q", r.Name, r.Scope)
+	}
+	if r.Rate <= 0 {
+		return fmt.Errorf("rate limit %s: rate must be positive", r.Name)
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("rate limit %s: burst must not be negative", r.Name)
+	}
+	if _, err := path.Match(r.Tool, ""); err != nil {
+		return fmt.Errorf("rate limit %s: invalid tool pattern %q: %w", r.Name, r.Tool, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// 内置的敏感信息检测器
+const (
+	RedactBearerToken = "bearer_token"
+	RedactAWSKey      = "aws_key"
+	RedactEmail       = "email"
+	RedactCreditCard  = "credit_card"
+)
+
+// RedactionDetectors 为全部内置检测器。
+var RedactionDetectors = []string{RedactBearerToken, RedactAWSKey, RedactEmail, RedactCreditCard}
+
+// RedactionConfig 控制日志与操作日志中密钥、个人信息的遮盖。
+type RedactionConfig struct {
+	// Disabled 为 true 时关闭遮盖，包括 workspace 声明的规则
+	Disabled bool `json:"disabled,omitempty"`
+	// Detectors 为启用的内置检测器，为空时启用全部
+	Detectors []string `json:"detectors,omitempty"`
+	// Patterns 为作用于所有 workspace 的自定义规则
+	Patterns []RedactionPattern `json:"patterns,omitempty"`
+}
+
+// WorkspaceRedactionConfig 是 workspace 自定义的遮盖规则。
+type WorkspaceRedactionConfig struct {
+	// Patterns 只作用于该 workspace 的日志与工具结果
+	Patterns []RedactionPattern `json:"patterns,omitempty"`
+	// ToolResults 为 true 时同时遮盖返回给 client 的工具结果
+	ToolResults bool `json:"toolResults,omitempty"`
+}
+
+// RedactionPattern 是一条按正则匹配的遮盖规则。
+type RedactionPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+	// Replacement 为替换文本，为空时使用 [REDACTED:<name>]
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Validate 检查规则名与正则表达式。
+func (p RedactionPattern) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("redaction pattern name is required")
+	}
+	if p.Regex == "" {
+		return fmt.Errorf("redaction pattern %s: regex is required", p.Name)
+	}
+	if _, err := regexp.Compile(p.Regex); err != nil {
+		return fmt.Errorf("redaction pattern %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// Validate 检查内置检测器名称。
+func (c RedactionConfig) Validate() error {
+	for _, name := range c.Detectors {
+		known := false
+		for _, detector := range RedactionDetectors {
+			known = known || detector == name
+		}
+		if !known {
+			return fmt.Errorf("unknown redaction detector %q", name)
+		}
+	}
+	return nil
+}
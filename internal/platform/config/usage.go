@@ -0,0 +1,37 @@
+package config
+
+// UsageQuota 是一个月内允许的用量，0 表示不限制。
+type UsageQuota struct {
+	ToolCalls      int64 `json:"toolCalls,omitempty"`
+	RequestBytes   int64 `json:"requestBytes,omitempty"`
+	ResponseBytes  int64 `json:"responseBytes,omitempty"`
+	SessionMinutes int64 `json:"sessionMinutes,omitempty"`
+}
+
+// UsageQuotaConfig 是 SaaS 模式下按自然月（UTC）计算的配额，在 tools/call 时检查。
+type UsageQuotaConfig struct {
+	// Account 为每个账号的默认配额，按账号在所有 workspace 的用量合计
+	Account UsageQuota `json:"account"`
+	// Workspace 为每个 workspace 的默认配额，按所有账号在该 workspace 的用量合计
+	Workspace UsageQuota `json:"workspace"`
+	// Accounts 按账号 id 覆盖 Account
+	Accounts map[string]UsageQuota `json:"accounts,omitempty"`
+	// Workspaces 按 workspace id 覆盖 Workspace
+	Workspaces map[string]UsageQuota `json:"workspaces,omitempty"`
+}
+
+// AccountQuota 返回账号生效的配额。
+func (c UsageQuotaConfig) AccountQuota(accountID string) UsageQuota {
+	if quota, ok := c.Accounts[accountID]; ok {
+		return quota
+	}
+	return c.Account
+}
+
+// WorkspaceQuota 返回 workspace 生效的配额。
+func (c UsageQuotaConfig) WorkspaceQuota(workspaceID string) UsageQuota {
+	if quota, ok := c.Workspaces[workspaceID]; ok {
+		return quota
+	}
+	return c.Workspace
+}
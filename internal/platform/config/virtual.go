@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var virtualServerName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// VirtualServerConfig 声明 workspace 内的一个虚拟服务器：一个具名入口，只暴露所列服务
+// 及工具的子集，通过 /v/<Name>/stream 与 /v/<Name>/sse 访问，不额外启动进程。
+type VirtualServerConfig struct {
+	Name string `json:"name"`
+	// Services 为该入口订阅的下游服务名
+	Services []string `json:"services"`
+	// Tools 为允许的带服务前缀的工具名，例如 github_search_issues；为空时暴露所列服务的全部工具
+	Tools []string `json:"tools,omitempty"`
+	// Instructions 为 initialize 返回给 client 的说明，为空时使用网关默认说明
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// Validate 检查虚拟服务器声明是否完整。
+func (v VirtualServerConfig) Validate() error {
+	if !virtualServerName.MatchString(v.Name) {
+		return fmt.Errorf("virtual server name %q must contain only letters, digits, '-' and '_'", v.Name)
+	}
+	if len(v.Services) == 0 {
+		return fmt.Errorf("virtual server %s has no services", v.Name)
+	}
+	for _, tool := range v.Tools {
+		service, _, ok := strings.Cut(tool, "_")
+		if !ok {
+			return fmt.Errorf("virtual server %s: tool must be <service>_<tool>, got %q", v.Name, tool)
+		}
+		if !slices.Contains(v.Services, service) {
+			return fmt.Errorf("virtual server %s: tool %s belongs to service %s which is not listed", v.Name, tool, service)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// WebhookConfig 是一个接收操作日志与审计事件的外部地址。
+type WebhookConfig struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+	// Secret 用于计算 X-Webhook-Signature 的 HMAC-SHA256 签名
+	Secret string `json:"secret,omitempty"`
+	// Events 为订阅的事件 action，支持 path.Match 通配符（如 "service.*"），为空时订阅全部
+	Events []string `json:"events,omitempty"`
+	// Workspaces 只投递这些 workspace 的事件，为空时不限制
+	Workspaces []string `json:"workspaces,omitempty"`
+	Disabled   bool     `json:"disabled,omitempty"`
+	// MaxAttempts 为每个事件的最大投递次数，<=0 时使用 5
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+// Validate 检查地址与事件过滤规则。
+func (c WebhookConfig) Validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("webhook id is required")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("webhook %s: invalid url: %w", c.ID, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %s: url must be an absolute http(s) url", c.ID)
+	}
+	for _, pattern := range c.Events {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("webhook %s: invalid event pattern %q: %w", c.ID, pattern, err)
+		}
+	}
+	return nil
+}
+
+// Matches 返回 webhook 是否订阅 workspace 内的 action 事件。
+func (c WebhookConfig) Matches(action, workspace string) bool {
+	if c.Disabled {
+		return false
+	}
+	if len(c.Workspaces) > 0 && !containsString(c.Workspaces, workspace) {
+		return false
+	}
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, pattern := range c.Events {
+		if ok, _ := path.Match(pattern, action); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
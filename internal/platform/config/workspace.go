@@ -5,6 +5,20 @@ type WorkspaceConfig struct {
 	McpServiceMgrConfig
 	LogConfig
 	CommandBase string `json:"commandBase"`
+	// DownstreamPoolSize 每个下游服务在 workspace 内共享的最大连接数，<=0 表示不复用连接
+	DownstreamPoolSize int `json:"downstreamPoolSize,omitempty"`
+	// CompositeTools 该 workspace 声明的组合工具
+	CompositeTools []CompositeToolConfig `json:"compositeTools,omitempty"`
+	// LazyTools 为 true 时该 workspace 的 session 默认只暴露网关元工具
+	LazyTools bool `json:"lazyTools,omitempty"`
+	// OutputPolicy 该 workspace 工具结果的大小限制
+	OutputPolicy OutputPolicyConfig `json:"outputPolicy,omitempty"`
+	// VirtualServers 该 workspace 声明的虚拟服务器
+	VirtualServers []VirtualServerConfig `json:"virtualServers,omitempty"`
+	// ToolPolicies 该 workspace 的工具参数策略
+	ToolPolicies []ToolPolicyRule `json:"toolPolicies,omitempty"`
+	// Approval 该 workspace 需要人工审批的工具调用
+	Approval ApprovalConfig `json:"approval,omitempty"`
 }
 
 type LogConfig struct {
@@ -0,0 +1,133 @@
+// Package federation 访问远端 mcp-gateway 的 admin API，发现其 workspace 与服务，
+// 供 gateway 类型的服务以服务凭证连接远端 workspace 的聚合入口。
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// requestTimeout 为单次 admin API 请求的超时
+	requestTimeout = 10 * time.Second
+	// pageSize 为分页读取 workspace 时每页的数量，与 admin API 的上限一致
+	pageSize = 100
+)
+
+// Workspace 是远端网关中服务凭证可见的 workspace。
+type Workspace struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	McpCount int    `json:"mcp_count"`
+}
+
+// Service 是远端 workspace 中的 MCP 服务。
+type Service struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	ToolsCount int    `json:"tools_count"`
+}
+
+// Client 调用远端网关的 admin API，可并发使用。
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// New 返回访问 baseURL（远端网关根地址）的 Client，apiKey 为远端的 API key。
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// WorkspaceURL 返回远端网关 workspace 的路径作用域地址（/w/:workspace）。
+func WorkspaceURL(baseURL, workspace string) string {
+	return strings.TrimRight(baseURL, "/") + "/w/" + url.PathEscape(workspace)
+}
+
+// StreamURL 返回远端网关 workspace 的 Streamable HTTP 聚合入口。
+func StreamURL(baseURL, workspace string) string {
+	return WorkspaceURL(baseURL, workspace) + "/stream"
+}
+
+// Workspaces 返回服务凭证可见的全部远端 workspace，按页读取。
+func (c *Client) Workspaces(ctx context.Context) ([]Workspace, error) {
+	var all []Workspace
+	for page := 1; ; page++ {
+		var data struct {
+			Items []Workspace `json:"items"`
+			Total int         `json:"total"`
+		}
+		if err := c.get(ctx, fmt.Sprintf("/api/v1/workspaces?page=%d&page_size=%d", page, pageSize), &data); err != nil {
+			return nil, err
+		}
+		all = append(all, data.Items...)
+		if len(data.Items) == 0 || len(all) >= data.Total {
+			return all, nil
+		}
+	}
+}
+
+// Services 返回远端 workspace 中的 MCP 服务。
+func (c *Client) Services(ctx context.Context, workspace string) ([]Service, error) {
+	var data struct {
+		Items []Service `json:"items"`
+	}
+	if err := c.get(ctx, "/api/v1/workspaces/"+url.PathEscape(workspace)+"/services", &data); err != nil {
+		return nil, err
+	}
+	return data.Items, nil
+}
+
+// get 请求 admin API 并解出响应信封中的 data。
+func (c *Client) get(ctx context.Context, path string, data interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("remote gateway %s: %w", c.baseURL, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote gateway %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("remote gateway %s: read %s: %w", c.baseURL, path, err)
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("remote gateway %s: %s returned %s, not an mcp-gateway admin API response", c.baseURL, path, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || !envelope.Success {
+		if envelope.Error != nil && envelope.Error.Message != "" {
+			return fmt.Errorf("remote gateway %s: %s: %s", c.baseURL, path, envelope.Error.Message)
+		}
+		return fmt.Errorf("remote gateway %s: %s returned %s", c.baseURL, path, resp.Status)
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		return fmt.Errorf("remote gateway %s: decode %s: %w", c.baseURL, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func respond(w http.ResponseWriter, status int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestClientPagesWorkspacesAndListsServices(t *testing.T) {
+	const total = 150
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key-1" {
+			respond(w, http.StatusUnauthorized, map[string]interface{}{"success": false, "error": map[string]string{"code": "UNAUTHORIZED", "message": "invalid api key"}})
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v1/workspaces":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+			items := []map[string]interface{}{}
+			for i := (page - 1) * size; i < page*size && i < total; i++ {
+				items = append(items, map[string]interface{}{"id": fmt.Sprintf("ws-%d", i), "name": fmt.Sprintf("WS %d", i)})
+			}
+			respond(w, http.StatusOK, map[string]interface{}{"success": true, "data": map[string]interface{}{"items": items, "total": total}})
+		case r.URL.Path == "/api/v1/workspaces/ws-1/services":
+			respond(w, http.StatusOK, map[string]interface{}{"success": true, "data": map[string]interface{}{
+				"items": []map[string]interface{}{{"name": "github", "status": "running", "tools_count": 12}},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := New(server.URL+"/", "key-1")
+	workspaces, err := client.Workspaces(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workspaces) != total || workspaces[total-1].ID != "ws-149" {
+		t.Fatalf("expected %d workspaces across pages, got %d", total, len(workspaces))
+	}
+	services, err := client.Services(context.Background(), "ws-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Name != "github" || services[0].ToolsCount != 12 {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+
+	_, err = New(server.URL, "wrong").Workspaces(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Fatalf("expected remote error message, got %v", err)
+	}
+	_, err = client.Services(context.Background(), "missing")
+	if err == nil || !strings.Contains(err.Error(), "not an mcp-gateway admin API response") {
+		t.Fatalf("expected non-envelope error, got %v", err)
+	}
+}
+
+func TestStreamURL(t *testing.T) {
+	if got := StreamURL("https://eu.gw/", "team a"); got != "https://eu.gw/w/team%20a/stream" {
+		t.Fatalf("unexpected stream url: %s", got)
+	}
+}
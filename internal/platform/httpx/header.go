@@ -13,8 +13,19 @@ func IsSSE(header http.Header) bool {
 	return strings.Contains(header.Get("Content-Type"), "text/event-stream")
 }
 
-// GetWorkspace 获取 workspace, 优先从 header 中获取，如果没有则从 query 中获取
+// workspaceContextKey 保存由路由（路径或子域名）确定的 workspace。
+const workspaceContextKey = "httpx.workspace"
+
+// SetWorkspace 记录由路由确定的 workspace，GetWorkspace 优先使用它。
+func SetWorkspace(c echo.Context, workspace string) {
+	c.Set(workspaceContextKey, workspace)
+}
+
+// GetWorkspace 获取 workspace：优先使用路由确定的 workspace，其次 header，最后 query
 func GetWorkspace(c echo.Context, defaultWorkspace ...string) string {
+	if workspace, _ := c.Get(workspaceContextKey).(string); workspace != "" {
+		return workspace
+	}
 	workspace := c.Request().Header.Get("X-Workspace-Id")
 	if workspace == "" {
 		workspace = c.QueryParam("workspaceId")
@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/qiniu/qmgo"
+	qmgoopts "github.com/qiniu/qmgo/options"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoStore struct {
@@ -20,6 +22,7 @@ type MongoStore struct {
 	workspaces    *qmgo.Collection
 	mcpServers    *qmgo.Collection
 	installedPkgs *qmgo.Collection
+	usage         *qmgo.Collection
 }
 
 func OpenMongoStore(ctx context.Context, uri, dbName string) (*MongoStore, error) {
@@ -39,6 +42,7 @@ func OpenMongoStore(ctx context.Context, uri, dbName string) (*MongoStore, error
 		workspaces:    db.Collection("workspaces"),
 		mcpServers:    db.Collection("mcp_servers"),
 		installedPkgs: db.Collection("installed_packages"),
+		usage:         db.Collection("usage"),
 	}, nil
 }
 
@@ -340,3 +344,44 @@ func (s *MongoStore) ListAuditLogs(ctx context.Context, workspaceID string, limi
 	err := s.auditLogs.Find(ctx, query).Sort("-created_at").Limit(int64(limit)).All(&items)
 	return items, err
 }
+
+func (s *MongoStore) IncrementUsage(ctx context.Context, records []UsageRecord) error {
+	upsert := qmgoopts.UpdateOptions{UpdateOptions: options.Update().SetUpsert(true)}
+	for _, record := range records {
+		filter := bson.M{
+			"period":       record.Period,
+			"account_id":   record.AccountID,
+			"workspace_id": record.WorkspaceID,
+			"service":      record.Service,
+		}
+		err := s.usage.UpdateOne(ctx, filter, bson.M{
+			"$inc": bson.M{
+				"tool_calls":      record.ToolCalls,
+				"request_bytes":   record.RequestBytes,
+				"response_bytes":  record.ResponseBytes,
+				"session_seconds": record.SessionSeconds,
+			},
+			"$set": bson.M{"updated_at": record.UpdatedAt},
+		}, upsert)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MongoStore) ListUsage(ctx context.Context, filter UsageFilter) ([]UsageRecord, error) {
+	query := bson.M{}
+	if filter.Period != "" {
+		query["period"] = filter.Period
+	}
+	if filter.AccountID != "" {
+		query["account_id"] = filter.AccountID
+	}
+	if filter.WorkspaceID != "" {
+		query["workspace_id"] = filter.WorkspaceID
+	}
+	var items []UsageRecord
+	err := s.usage.Find(ctx, query).Sort("workspace_id", "account_id", "service").All(&items)
+	return items, err
+}
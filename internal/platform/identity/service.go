@@ -40,6 +40,29 @@ type Principal struct {
 	IsSystemAdmin bool
 	WorkspaceID   string
 	TokenType     string
+	// APIKeyID 为通过 API key 认证时的 key id，其他认证方式为空
+	APIKeyID string
+	// Scope 为 API key 声明的 scope，其他认证方式为空
+	Scope []string
+}
+
+// API key scope：覆盖 workspace 默认的工具列表模式
+const (
+	ScopeToolsLazy = "tools:lazy"
+	ScopeToolsFull = "tools:full"
+)
+
+// HasScope 判断 principal 是否带有指定 scope。
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	for _, s := range p.Scope {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 type Account struct {
@@ -111,6 +134,27 @@ type APIKey struct {
 	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
 }
 
+// UsageRecord 是某个月内一个账号在一个 workspace、一个服务上的用量累计。
+// 会话时长不归属具体服务，Service 为空。
+type UsageRecord struct {
+	Period         string    `bson:"period" json:"period"`
+	AccountID      string    `bson:"account_id" json:"account_id"`
+	WorkspaceID    string    `bson:"workspace_id" json:"workspace_id"`
+	Service        string    `bson:"service" json:"service"`
+	ToolCalls      int64     `bson:"tool_calls" json:"tool_calls"`
+	RequestBytes   int64     `bson:"request_bytes" json:"request_bytes"`
+	ResponseBytes  int64     `bson:"response_bytes" json:"response_bytes"`
+	SessionSeconds int64     `bson:"session_seconds" json:"session_seconds"`
+	UpdatedAt      time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// UsageFilter 筛选用量记录，空字段不参与筛选。
+type UsageFilter struct {
+	Period      string
+	AccountID   string
+	WorkspaceID string
+}
+
 type RefreshToken struct {
 	ID         string    `bson:"id"`
 	AccountID  string    `bson:"account_id"`
@@ -164,6 +208,8 @@ type Store interface {
 	DeleteRefreshToken(context.Context, string) error
 	AppendAuditLog(context.Context, *AuditLog) error
 	ListAuditLogs(context.Context, string, int) ([]AuditLog, error)
+	IncrementUsage(context.Context, []UsageRecord) error
+	ListUsage(context.Context, UsageFilter) ([]UsageRecord, error)
 }
 
 type Service struct {
@@ -448,6 +494,8 @@ func (s *Service) ValidateBearer(ctx context.Context, token string) (*Principal,
 			IsSystemAdmin: isSystemAdmin,
 			WorkspaceID:   apiKey.WorkspaceID,
 			TokenType:     "api_key",
+			APIKeyID:      apiKey.ID,
+			Scope:         append([]string(nil), apiKey.Scope...),
 		}, nil
 	}
 	return nil, ErrUnauthorized
@@ -755,6 +803,21 @@ func (s *Service) ListAuditLogs(ctx context.Context, workspaceID string, limit i
 	return s.store.ListAuditLogs(ctx, workspaceID, limit)
 }
 
+// IncrementUsage 把各记录的用量累加到对应月份、账号、workspace 与服务的汇总上。
+func (s *Service) IncrementUsage(ctx context.Context, records []UsageRecord) error {
+	if !s.IsSaaS() || s.store == nil || len(records) == 0 {
+		return nil
+	}
+	return s.store.IncrementUsage(ctx, records)
+}
+
+func (s *Service) ListUsage(ctx context.Context, filter UsageFilter) ([]UsageRecord, error) {
+	if !s.IsSaaS() || s.store == nil {
+		return nil, nil
+	}
+	return s.store.ListUsage(ctx, filter)
+}
+
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(hash), err
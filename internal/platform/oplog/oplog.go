@@ -69,8 +69,15 @@ func (s *XLogSink) Write(entry xlog.Entry) {
 	if s == nil || s.recorder == nil {
 		return
 	}
+	if event, ok := EventFromEntry(entry); ok {
+		s.recorder.record(context.Background(), event)
+	}
+}
+
+// EventFromEntry 把带 log_type=operation 字段的日志转换为操作日志事件，其它日志返回 false。
+func EventFromEntry(entry xlog.Entry) (Event, bool) {
 	if asString(entry.Fields["log_type"]) != "operation" {
-		return
+		return Event{}, false
 	}
 	event := Event{
 		ID:           asString(entry.Fields["event_id"]),
@@ -90,7 +97,7 @@ func (s *XLogSink) Write(entry xlog.Entry) {
 	if event.ID == "" {
 		event.ID = asString(entry.Fields["id"])
 	}
-	s.recorder.record(context.Background(), event)
+	return event, true
 }
 
 type NoopStore struct{}
@@ -0,0 +1,215 @@
+// Package ratelimit 实现 tools/call 的令牌桶限流：规则来自 config.RateLimits，
+// 按调用方、workspace 或工具分别计数，由 gateway 在转发前扣减，admin API 读取当前状态。
+package ratelimit
+
+import (
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+// sweepInterval 是清理已补满令牌桶的最小间隔，补满的桶与不存在等价。
+const sweepInterval = time.Minute
+
+// Request 描述一次待限流的工具调用。
+type Request struct {
+	// Principal 为调用方标识（API key id 或账号 id），匿名调用为空
+	Principal string
+	Workspace string
+	// Tool 为带服务前缀的工具名
+	Tool string
+}
+
+// Decision 是一次限流判断的结果。
+type Decision struct {
+	Allowed bool
+	// Rule 为拒绝该请求、等待时间最长的规则名
+	Rule string
+	// RetryAfter 为令牌补足前需要等待的时间
+	RetryAfter time.Duration
+}
+
+// BucketState 是一个令牌桶的当前状态。
+type BucketState struct {
+	Rule   string  `json:"rule"`
+	Scope  string  `json:"scope"`
+	Key    string  `json:"key"`
+	Tokens float64 `json:"tokens"`
+	Burst  int     `json:"burst"`
+	Rate   float64 `json:"rate"`
+}
+
+type bucketKey struct {
+	rule int
+	key  string
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter 按规则维护令牌桶，可并发使用；nil Limiter 放行所有请求。
+type Limiter struct {
+	mu        sync.Mutex
+	rules     []config.RateLimitConfig
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New 按规则构造 Limiter，无效的规则会被跳过。
+func New(xl xlog.Logger, rules []config.RateLimitConfig) *Limiter {
+	valid := make([]config.RateLimitConfig, 0, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			xl.Warnf("skip invalid rate limit: %v", err)
+			continue
+		}
+		if rule.Burst == 0 {
+			rule.Burst = int(math.Max(1, math.Ceil(rule.Rate)))
+		}
+		valid = append(valid, rule)
+	}
+	return &Limiter{rules: valid, buckets: make(map[bucketKey]*bucket), now: time.Now}
+}
+
+// Rules 返回生效的限流规则。
+func (l *Limiter) Rules() []config.RateLimitConfig {
+	if l == nil {
+		return []config.RateLimitConfig{}
+	}
+	return append(make([]config.RateLimitConfig, 0, len(l.rules)), l.rules...)
+}
+
+// Allow 判断请求是否放行。所有匹配规则的桶都至少有一个令牌时各扣减一个令牌，
+// 否则不扣减任何桶，返回需要等待的时间。
+func (l *Limiter) Allow(req Request) Decision {
+	if l == nil || len(l.rules) == 0 {
+		return Decision{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	matched := make([]*bucket, 0, len(l.rules))
+	var denied Decision
+	for i, rule := range l.rules {
+		key, ok := bucketKeyFor(rule, req)
+		if !ok {
+			continue
+		}
+		b := l.bucket(bucketKey{rule: i, key: key}, rule, now)
+		if b.tokens < 1 {
+			wait := time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+			if wait > denied.RetryAfter || denied.Rule == "" {
+				denied = Decision{Rule: rule.Name, RetryAfter: wait}
+			}
+			continue
+		}
+		matched = append(matched, b)
+	}
+	if denied.Rule != "" {
+		return denied
+	}
+	for _, b := range matched {
+		b.tokens--
+	}
+	return Decision{Allowed: true}
+}
+
+// Snapshot 返回所有未补满的令牌桶，按规则与 key 排序。
+func (l *Limiter) Snapshot() []BucketState {
+	if l == nil {
+		return []BucketState{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	states := make([]BucketState, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		rule := l.rules[key.rule]
+		refill(b, rule, now)
+		if b.tokens >= float64(rule.Burst) {
+			continue
+		}
+		states = append(states, BucketState{
+			Rule:   rule.Name,
+			Scope:  rule.Scope,
+			Key:    key.key,
+			Tokens: math.Round(b.tokens*100) / 100,
+			Burst:  rule.Burst,
+			Rate:   rule.Rate,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Rule != states[j].Rule {
+			return states[i].Rule < states[j].Rule
+		}
+		return states[i].Key < states[j].Key
+	})
+	return states
+}
+
+// bucket 返回补充到 now 的令牌桶，不存在时创建一个满桶。
+func (l *Limiter) bucket(key bucketKey, rule config.RateLimitConfig, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), updated: now}
+		l.buckets[key] = b
+		return b
+	}
+	refill(b, rule, now)
+	return b
+}
+
+// sweep 定期删除已补满的令牌桶，避免调用方与工具数量增长时桶无限累积。
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		rule := l.rules[key.rule]
+		refill(b, rule, now)
+		if b.tokens >= float64(rule.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func refill(b *bucket, rule config.RateLimitConfig, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed.Seconds()*rule.Rate)
+		b.updated = now
+	}
+}
+
+// bucketKeyFor 返回请求在规则下的计数 key，规则不匹配时返回 false。
+func bucketKeyFor(rule config.RateLimitConfig, req Request) (string, bool) {
+	if rule.Workspace != "" && rule.Workspace != req.Workspace {
+		return "", false
+	}
+	if rule.Principal != "" && rule.Principal != req.Principal {
+		return "", false
+	}
+	if rule.Tool != "" {
+		if ok, _ := path.Match(rule.Tool, req.Tool); !ok {
+			return "", false
+		}
+	}
+	switch rule.Scope {
+	case config.RateLimitScopePrincipal:
+		return req.Principal, true
+	case config.RateLimitScopeWorkspace:
+		return req.Workspace, true
+	default:
+		return req.Workspace + "/" + req.Tool, true
+	}
+}
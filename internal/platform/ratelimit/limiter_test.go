@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+func newTestLimiter(rules ...config.RateLimitConfig) (*Limiter, *time.Time) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	l := New(xlog.NewLogger("test-ratelimit"), rules)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiterRefillsPerToolBucket(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimitConfig{Name: "github", Scope: config.RateLimitScopeTool, Tool: "github_*", Rate: 2, Burst: 2})
+	search := Request{Principal: "key-1", Workspace: "default", Tool: "github_search"}
+
+	for i := 0; i < 2; i++ {
+		if d := l.Allow(search); !d.Allowed {
+			t.Fatalf("call %d should be allowed within burst", i)
+		}
+	}
+	d := l.Allow(search)
+	if d.Allowed || d.Rule != "github" || d.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("unexpected decision after burst: %+v", d)
+	}
+	// 其它工具与不匹配模式的工具各自计数
+	if !l.Allow(Request{Workspace: "default", Tool: "github_get"}).Allowed || !l.Allow(Request{Workspace: "default", Tool: "fs_read"}).Allowed {
+		t.Fatal("other tools should have their own buckets")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if !l.Allow(search).Allowed {
+		t.Fatal("token should be refilled after retry-after")
+	}
+}
+
+func TestLimiterDeniedCallConsumesNoTokens(t *testing.T) {
+	l, _ := newTestLimiter(
+		config.RateLimitConfig{Name: "per-key", Scope: config.RateLimitScopePrincipal, Rate: 10, Burst: 5},
+		config.RateLimitConfig{Name: "slow-tool", Scope: config.RateLimitScopeTool, Tool: "slow_*", Rate: 1, Burst: 1},
+	)
+	if !l.Allow(Request{Principal: "key-1", Workspace: "default", Tool: "slow_run"}).Allowed {
+		t.Fatal("first call should be allowed")
+	}
+	if d := l.Allow(Request{Principal: "key-1", Workspace: "default", Tool: "slow_run"}); d.Allowed || d.Rule != "slow-tool" {
+		t.Fatalf("second call should hit the tool limit: %+v", d)
+	}
+
+	states := l.Snapshot()
+	if len(states) != 2 || states[0].Rule != "per-key" || states[0].Key != "key-1" || states[0].Tokens != 4 {
+		t.Fatalf("denied call must not consume principal tokens: %+v", states)
+	}
+}
+
+func TestNewSkipsInvalidRules(t *testing.T) {
+	l, _ := newTestLimiter(
+		config.RateLimitConfig{Name: "no-rate", Scope: config.RateLimitScopeWorkspace},
+		config.RateLimitConfig{Name: "bad-scope", Scope: "global", Rate: 1},
+		config.RateLimitConfig{Name: "ok", Scope: config.RateLimitScopeWorkspace, Rate: 0.5},
+	)
+	rules := l.Rules()
+	if len(rules) != 1 || rules[0].Name != "ok" || rules[0].Burst != 1 {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+}
@@ -0,0 +1,176 @@
+// Package redact 遮盖日志、操作日志与工具结果中的密钥和个人信息：内置检测器与全局规则
+// 作用于所有内容，workspace 声明的规则只作用于该 workspace 的内容。
+package redact
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+type rule struct {
+	re          *regexp.Regexp
+	replacement string
+	// valid 非空时只替换通过校验的匹配，用于排除误报
+	valid func(match string) bool
+}
+
+func (r rule) apply(s string) string {
+	return r.re.ReplaceAllStringFunc(s, func(match string) string {
+		if r.valid != nil && !r.valid(match) {
+			return match
+		}
+		return r.replacement
+	})
+}
+
+var detectors = map[string]rule{
+	config.RedactBearerToken: {
+		re:          regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]{8,}=*`),
+		replacement: "Bearer [REDACTED:" + config.RedactBearerToken + "]",
+	},
+	config.RedactAWSKey: {
+		re:          regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b|(?i:aws_?secret_?access_?key)["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`),
+		replacement: "[REDACTED:" + config.RedactAWSKey + "]",
+	},
+	config.RedactEmail: {
+		re:          regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		replacement: "[REDACTED:" + config.RedactEmail + "]",
+	},
+	config.RedactCreditCard: {
+		re:          regexp.MustCompile(`\b[2-6](?:[ -]?\d){12,18}\b`),
+		replacement: "[REDACTED:" + config.RedactCreditCard + "]",
+		valid:       luhnValid,
+	},
+}
+
+// Redactor 按规则遮盖文本，可并发使用；nil Redactor 原样返回内容。
+type Redactor struct {
+	global     []rule
+	workspaces map[string][]rule
+	results    map[string]bool
+}
+
+// New 按配置构造 Redactor，无效的规则会被跳过；关闭遮盖时返回 nil。
+func New(xl xlog.Logger, cfg config.RedactionConfig, workspaces map[string]config.WorkspaceRedactionConfig) *Redactor {
+	if cfg.Disabled {
+		return nil
+	}
+	if err := cfg.Validate(); err != nil {
+		xl.Warnf("ignore redaction detectors: %v", err)
+		cfg.Detectors = nil
+	}
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = config.RedactionDetectors
+	}
+	r := &Redactor{workspaces: make(map[string][]rule), results: make(map[string]bool)}
+	for _, name := range names {
+		r.global = append(r.global, detectors[name])
+	}
+	r.global = append(r.global, compilePatterns(xl, cfg.Patterns)...)
+	for workspace, wcfg := range workspaces {
+		if rules := compilePatterns(xl, wcfg.Patterns); len(rules) > 0 {
+			r.workspaces[workspace] = rules
+		}
+		r.results[workspace] = wcfg.ToolResults
+	}
+	return r
+}
+
+func compilePatterns(xl xlog.Logger, patterns []config.RedactionPattern) []rule {
+	rules := make([]rule, 0, len(patterns))
+	for _, p := range patterns {
+		if err := p.Validate(); err != nil {
+			xl.Warnf("skip invalid redaction pattern: %v", err)
+			continue
+		}
+		replacement := p.Replacement
+		if replacement == "" {
+			replacement = "[REDACTED:" + p.Name + "]"
+		}
+		rules = append(rules, rule{re: regexp.MustCompile(p.Regex), replacement: replacement})
+	}
+	return rules
+}
+
+// ToolResults 返回 workspace 是否要求遮盖返回给 client 的工具结果。
+func (r *Redactor) ToolResults(workspace string) bool {
+	return r != nil && r.results[workspace]
+}
+
+// RedactString 遮盖文本，workspace 为空时只应用全局规则。
+func (r *Redactor) RedactString(workspace, s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, rule := range r.global {
+		s = rule.apply(s)
+	}
+	for _, rule := range r.workspaces[workspace] {
+		s = rule.apply(s)
+	}
+	return s
+}
+
+// RedactValue 遮盖值中的字符串，map 与切片返回遮盖后的副本，其它类型原样返回。
+func (r *Redactor) RedactValue(workspace string, v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	switch value := v.(type) {
+	case string:
+		return r.RedactString(workspace, value)
+	case error:
+		return r.RedactString(workspace, value.Error())
+	case []string:
+		out := make([]string, len(value))
+		for i, item := range value {
+			out[i] = r.RedactString(workspace, item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = r.RedactValue(workspace, item)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(value))
+		for k, item := range value {
+			out[k] = r.RedactString(workspace, item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			out[k] = r.RedactValue(workspace, item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// luhnValid 校验卡号的 Luhn 校验位，排除普通的长数字。卡号首位为 2-6，
+// 因此以 1 开头的毫秒时间戳不会被当作卡号。
+func luhnValid(match string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
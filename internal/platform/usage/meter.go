@@ -0,0 +1,419 @@
+// Package usage 在 SaaS 模式下计量工具调用、请求与响应字节数以及会话时长，
+// 按月、账号、workspace 与服务在内存中汇总后定期写入 identity.Store，并在 tools/call 时检查月度配额。
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+const (
+	// flushInterval 是把内存中的用量增量写入 Store 的间隔
+	flushInterval = 30 * time.Second
+	// totalsTTL 是配额检查使用的月度合计的缓存时间，多个网关实例共用 Store 时以此为同步周期
+	totalsTTL = time.Minute
+)
+
+// 配额的归属维度
+const (
+	ScopeAccount   = "account"
+	ScopeWorkspace = "workspace"
+)
+
+// Store 持久化按月累计的用量，由 identity.Service 实现。
+type Store interface {
+	IncrementUsage(context.Context, []identity.UsageRecord) error
+	ListUsage(context.Context, identity.UsageFilter) ([]identity.UsageRecord, error)
+}
+
+// Period 返回 t 所在的计量月份（UTC），例如 2026-10。
+func Period(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// periodEnd 返回 t 所在计量月份结束、配额重置的时间。
+func periodEnd(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// QuotaExceededError 表示账号或 workspace 的某项月度用量已达到配额。
+type QuotaExceededError struct {
+	Scope   string
+	ID      string
+	Metric  string
+	Limit   int64
+	Used    int64
+	Period  string
+	ResetAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("monthly %s quota exceeded for %s %s: %d of %d used in %s", e.Metric, e.Scope, e.ID, e.Used, e.Limit, e.Period)
+}
+
+type recordKey struct {
+	period, account, workspace, service string
+}
+
+type totalsKey struct {
+	period, scope, id string
+}
+
+type cachedTotals struct {
+	usage    identity.UsageRecord
+	loadedAt time.Time
+}
+
+type liveSession struct {
+	account, workspace string
+	mark               time.Time
+}
+
+// Meter 汇总用量并检查配额，可并发使用；nil Meter 不计量也不限制。
+type Meter struct {
+	store  Store
+	quotas config.UsageQuotaConfig
+	xl     xlog.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[recordKey]*identity.UsageRecord
+	totals  map[totalsKey]*cachedTotals
+	live    map[*liveSession]struct{}
+
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMeter 构造 Meter 并启动定期写入。
+func NewMeter(store Store, quotas config.UsageQuotaConfig) *Meter {
+	m := &Meter{
+		store:   store,
+		quotas:  quotas,
+		xl:      xlog.NewLogger("USAGE"),
+		now:     time.Now,
+		pending: make(map[recordKey]*identity.UsageRecord),
+		totals:  make(map[totalsKey]*cachedTotals),
+		live:    make(map[*liveSession]struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+func (m *Meter) loop() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.flush(context.Background()); err != nil {
+				m.xl.Warnf("flush usage failed: %v", err)
+			}
+		}
+	}
+}
+
+// Close 停止定期写入并写出剩余的用量。
+func (m *Meter) Close(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		<-m.stopped
+	})
+	return m.flush(ctx)
+}
+
+// RecordToolCall 计入一次工具调用。
+func (m *Meter) RecordToolCall(account, workspace, service string) {
+	if m == nil {
+		return
+	}
+	m.add(identity.UsageRecord{AccountID: account, WorkspaceID: workspace, Service: service, ToolCalls: 1})
+}
+
+// RecordBytes 计入收到的请求字节数与发出的响应字节数。
+func (m *Meter) RecordBytes(account, workspace, service string, requestBytes, responseBytes int) {
+	if m == nil || requestBytes+responseBytes == 0 {
+		return
+	}
+	m.add(identity.UsageRecord{AccountID: account, WorkspaceID: workspace, Service: service, RequestBytes: int64(requestBytes), ResponseBytes: int64(responseBytes)})
+}
+
+// TrackSession 从现在起计量会话时长，done 关闭时停止。未结束的会话在每次写入时按已过去的时间计入。
+func (m *Meter) TrackSession(account, workspace string, done <-chan struct{}) {
+	if m == nil {
+		return
+	}
+	ls := &liveSession{account: account, workspace: workspace, mark: m.now()}
+	m.mu.Lock()
+	m.live[ls] = struct{}{}
+	m.mu.Unlock()
+	go func() {
+		select {
+		case <-done:
+		case <-m.stop:
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.live[ls]; ok {
+			m.accrueLocked(ls, m.now())
+			delete(m.live, ls)
+		}
+	}()
+}
+
+// CheckQuota 检查账号与 workspace 本月的用量是否已达到配额，未超出时返回 nil。
+// 读取 Store 失败时放行，避免存储故障阻断所有工具调用。
+func (m *Meter) CheckQuota(ctx context.Context, account, workspace string) *QuotaExceededError {
+	if m == nil {
+		return nil
+	}
+	now := m.now()
+	checks := []struct {
+		scope, id string
+		quota     config.UsageQuota
+	}{
+		{ScopeAccount, account, m.quotas.AccountQuota(account)},
+		{ScopeWorkspace, workspace, m.quotas.WorkspaceQuota(workspace)},
+	}
+	for _, check := range checks {
+		if check.id == "" || check.quota == (config.UsageQuota{}) {
+			continue
+		}
+		used, err := m.monthTotals(ctx, Period(now), check.scope, check.id)
+		if err != nil {
+			m.xl.Warnf("load %s %s usage failed: %v", check.scope, check.id, err)
+			continue
+		}
+		if metric, limit, value := exceededMetric(check.quota, used); metric != "" {
+			return &QuotaExceededError{
+				Scope:   check.scope,
+				ID:      check.id,
+				Metric:  metric,
+				Limit:   limit,
+				Used:    value,
+				Period:  Period(now),
+				ResetAt: periodEnd(now),
+			}
+		}
+	}
+	return nil
+}
+
+// Usage 返回符合条件的用量记录，包含尚未写入 Store 的增量。
+func (m *Meter) Usage(ctx context.Context, filter identity.UsageFilter) ([]identity.UsageRecord, error) {
+	if m == nil {
+		return []identity.UsageRecord{}, nil
+	}
+	stored, err := m.store.ListUsage(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[recordKey]*identity.UsageRecord, len(stored))
+	for i := range stored {
+		record := stored[i]
+		merged[keyOf(record)] = &record
+	}
+	m.mu.Lock()
+	for key, delta := range m.pending {
+		if !matches(filter, *delta) {
+			continue
+		}
+		if record, ok := merged[key]; ok {
+			addUsage(record, *delta)
+			if delta.UpdatedAt.After(record.UpdatedAt) {
+				record.UpdatedAt = delta.UpdatedAt
+			}
+			continue
+		}
+		record := *delta
+		merged[key] = &record
+	}
+	m.mu.Unlock()
+
+	records := make([]identity.UsageRecord, 0, len(merged))
+	for _, record := range merged {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.WorkspaceID != b.WorkspaceID {
+			return a.WorkspaceID < b.WorkspaceID
+		}
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		return a.Service < b.Service
+	})
+	return records, nil
+}
+
+func (m *Meter) add(delta identity.UsageRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addLocked(delta, m.now())
+}
+
+func (m *Meter) addLocked(delta identity.UsageRecord, now time.Time) {
+	delta.Period = Period(now)
+	delta.UpdatedAt = now.UTC()
+	key := keyOf(delta)
+	record, ok := m.pending[key]
+	if !ok {
+		record = &identity.UsageRecord{Period: key.period, AccountID: key.account, WorkspaceID: key.workspace, Service: key.service}
+		m.pending[key] = record
+	}
+	addUsage(record, delta)
+	record.UpdatedAt = delta.UpdatedAt
+
+	// 已缓存的月度合计同步累加，配额检查无需等待写入
+	for _, tk := range []totalsKey{{delta.Period, ScopeAccount, delta.AccountID}, {delta.Period, ScopeWorkspace, delta.WorkspaceID}} {
+		if cached, ok := m.totals[tk]; ok {
+			addUsage(&cached.usage, delta)
+		}
+	}
+}
+
+// accrueLocked 把会话自上次计入以来的整秒数计入用量。
+func (m *Meter) accrueLocked(ls *liveSession, now time.Time) {
+	seconds := int64(now.Sub(ls.mark) / time.Second)
+	if seconds <= 0 {
+		return
+	}
+	ls.mark = ls.mark.Add(time.Duration(seconds) * time.Second)
+	m.addLocked(identity.UsageRecord{AccountID: ls.account, WorkspaceID: ls.workspace, SessionSeconds: seconds}, now)
+}
+
+// flush 把进行中会话的时长与所有增量写入 Store，失败的增量留到下次重试。
+func (m *Meter) flush(ctx context.Context) error {
+	m.mu.Lock()
+	now := m.now()
+	for ls := range m.live {
+		m.accrueLocked(ls, now)
+	}
+	if len(m.pending) == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	batch := m.pending
+	m.pending = make(map[recordKey]*identity.UsageRecord)
+	m.mu.Unlock()
+
+	records := make([]identity.UsageRecord, 0, len(batch))
+	for _, record := range batch {
+		records = append(records, *record)
+	}
+	if err := m.store.IncrementUsage(ctx, records); err != nil {
+		m.mu.Lock()
+		for key, record := range batch {
+			if current, ok := m.pending[key]; ok {
+				addUsage(current, *record)
+				continue
+			}
+			m.pending[key] = record
+		}
+		m.mu.Unlock()
+		return fmt.Errorf("write %d usage records: %w", len(records), err)
+	}
+	return nil
+}
+
+// monthTotals 返回账号或 workspace 在 period 的用量合计，缓存 totalsTTL。
+func (m *Meter) monthTotals(ctx context.Context, period, scope, id string) (identity.UsageRecord, error) {
+	key := totalsKey{period: period, scope: scope, id: id}
+	m.mu.Lock()
+	if cached, ok := m.totals[key]; ok && m.now().Sub(cached.loadedAt) < totalsTTL {
+		usage := cached.usage
+		m.mu.Unlock()
+		return usage, nil
+	}
+	m.mu.Unlock()
+
+	filter := identity.UsageFilter{Period: period}
+	if scope == ScopeAccount {
+		filter.AccountID = id
+	} else {
+		filter.WorkspaceID = id
+	}
+	stored, err := m.store.ListUsage(ctx, filter)
+	if err != nil {
+		return identity.UsageRecord{}, err
+	}
+	var total identity.UsageRecord
+	for _, record := range stored {
+		addUsage(&total, record)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, delta := range m.pending {
+		if matches(filter, *delta) {
+			addUsage(&total, *delta)
+		}
+	}
+	// 旧月份的合计不再需要
+	for k := range m.totals {
+		if k.period != period {
+			delete(m.totals, k)
+		}
+	}
+	m.totals[key] = &cachedTotals{usage: total, loadedAt: m.now()}
+	return total, nil
+}
+
+// exceededMetric 返回第一项达到配额的指标名、配额与已用量，都未达到时返回空字符串。
+func exceededMetric(quota config.UsageQuota, used identity.UsageRecord) (string, int64, int64) {
+	metrics := []struct {
+		name  string
+		limit int64
+		used  int64
+	}{
+		{"tool_calls", quota.ToolCalls, used.ToolCalls},
+		{"request_bytes", quota.RequestBytes, used.RequestBytes},
+		{"response_bytes", quota.ResponseBytes, used.ResponseBytes},
+		{"session_minutes", quota.SessionMinutes, used.SessionSeconds / 60},
+	}
+	for _, metric := range metrics {
+		if metric.limit > 0 && metric.used >= metric.limit {
+			return metric.name, metric.limit, metric.used
+		}
+	}
+	return "", 0, 0
+}
+
+func keyOf(record identity.UsageRecord) recordKey {
+	return recordKey{period: record.Period, account: record.AccountID, workspace: record.WorkspaceID, service: record.Service}
+}
+
+func matches(filter identity.UsageFilter, record identity.UsageRecord) bool {
+	return (filter.Period == "" || filter.Period == record.Period) &&
+		(filter.AccountID == "" || filter.AccountID == record.AccountID) &&
+		(filter.WorkspaceID == "" || filter.WorkspaceID == record.WorkspaceID)
+}
+
+func addUsage(dst *identity.UsageRecord, delta identity.UsageRecord) {
+	dst.ToolCalls += delta.ToolCalls
+	dst.RequestBytes += delta.RequestBytes
+	dst.ResponseBytes += delta.ResponseBytes
+	dst.SessionSeconds += delta.SessionSeconds
+}
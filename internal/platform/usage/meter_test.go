@@ -0,0 +1,148 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
+)
+
+// memoryStore 按记录 key 累加用量，模拟 Mongo 的 $inc upsert。
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[recordKey]identity.UsageRecord
+	lists   int
+}
+
+func (s *memoryStore) IncrementUsage(_ context.Context, records []identity.UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, delta := range records {
+		record := s.records[keyOf(delta)]
+		record.Period, record.AccountID, record.WorkspaceID, record.Service = delta.Period, delta.AccountID, delta.WorkspaceID, delta.Service
+		addUsage(&record, delta)
+		s.records[keyOf(delta)] = record
+	}
+	return nil
+}
+
+func (s *memoryStore) ListUsage(_ context.Context, filter identity.UsageFilter) ([]identity.UsageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lists++
+	out := make([]identity.UsageRecord, 0)
+	for _, record := range s.records {
+		if matches(filter, record) {
+			out = append(out, record)
+		}
+	}
+	return out, nil
+}
+
+func newTestMeter(t *testing.T, quotas config.UsageQuotaConfig) (*Meter, *memoryStore, *time.Time) {
+	t.Helper()
+	store := &memoryStore{records: make(map[recordKey]identity.UsageRecord)}
+	m := NewMeter(store, quotas)
+	now := time.Date(2026, 10, 31, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	t.Cleanup(func() { _ = m.Close(context.Background()) })
+	return m, store, &now
+}
+
+func TestMeterAggregatesAndFlushes(t *testing.T) {
+	m, store, _ := newTestMeter(t, config.UsageQuotaConfig{})
+	m.RecordToolCall("acc-1", "team-a", "github")
+	m.RecordToolCall("acc-1", "team-a", "github")
+	m.RecordBytes("acc-1", "team-a", "github", 120, 4096)
+	m.RecordBytes("acc-2", "team-b", "", 0, 10)
+
+	if err := m.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.RecordToolCall("acc-1", "team-a", "github")
+
+	records, err := m.Usage(context.Background(), identity.UsageFilter{WorkspaceID: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	got := records[0]
+	if got.Period != "2026-10" || got.ToolCalls != 3 || got.RequestBytes != 120 || got.ResponseBytes != 4096 {
+		t.Fatalf("usage should merge stored and pending deltas: %+v", got)
+	}
+	if stored := store.records[recordKey{"2026-10", "acc-1", "team-a", "github"}]; stored.ToolCalls != 2 {
+		t.Fatalf("flush should persist aggregated deltas: %+v", stored)
+	}
+}
+
+func TestMeterEnforcesMonthlyQuota(t *testing.T) {
+	m, store, now := newTestMeter(t, config.UsageQuotaConfig{
+		Workspace:  config.UsageQuota{ToolCalls: 100},
+		Workspaces: map[string]config.UsageQuota{"team-a": {ToolCalls: 2}},
+	})
+	ctx := context.Background()
+	if err := m.CheckQuota(ctx, "acc-1", "team-a"); err != nil {
+		t.Fatalf("fresh workspace should be within quota: %v", err)
+	}
+	m.RecordToolCall("acc-1", "team-a", "github")
+	m.RecordToolCall("acc-2", "team-a", "fs")
+
+	err := m.CheckQuota(ctx, "acc-1", "team-a")
+	if err == nil || err.Scope != ScopeWorkspace || err.Metric != "tool_calls" || err.Used != 2 || err.Limit != 2 {
+		t.Fatalf("expected workspace tool call quota error, got %+v", err)
+	}
+	if want := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC); !err.ResetAt.Equal(want) {
+		t.Fatalf("quota should reset at %s, got %s", want, err.ResetAt)
+	}
+	if store.lists != 1 {
+		t.Fatalf("cached totals should be reused, listed %d times", store.lists)
+	}
+
+	// 新的月份重新计数
+	*now = now.Add(2 * time.Hour)
+	if err := m.CheckQuota(ctx, "acc-1", "team-a"); err != nil {
+		t.Fatalf("quota should reset in a new period: %v", err)
+	}
+}
+
+func TestMeterTracksSessionMinutes(t *testing.T) {
+	m, _, now := newTestMeter(t, config.UsageQuotaConfig{Account: config.UsageQuota{SessionMinutes: 2}})
+	done := make(chan struct{})
+	m.TrackSession("acc-1", "team-a", done)
+
+	*now = now.Add(90 * time.Second)
+	if err := m.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(45 * time.Second)
+	close(done)
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		remaining := len(m.live)
+		m.mu.Unlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session should stop being tracked once done is closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	records, err := m.Usage(context.Background(), identity.UsageFilter{AccountID: "acc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].SessionSeconds != 135 {
+		t.Fatalf("unexpected session usage: %+v", records)
+	}
+	if err := m.CheckQuota(context.Background(), "acc-1", "team-a"); err == nil || err.Metric != "session_minutes" {
+		t.Fatalf("expected session minute quota error, got %+v", err)
+	}
+}
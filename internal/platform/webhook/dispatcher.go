@@ -0,0 +1,325 @@
+// Package webhook 把操作日志与审计事件推送到 config.Webhooks 声明的外部地址：按事件 action
+// 与 workspace 过滤，使用 HMAC-SHA256 签名，失败时按指数退避重试，并在内存中保留最近的投递记录。
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+// 投递请求携带的 header
+const (
+	HeaderWebhookID = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// 投递状态
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const (
+	defaultMaxAttempts = 5
+	// queueSize 为等待投递的事件数上限，写满后新事件被丢弃并记为失败
+	queueSize = 1024
+	workers   = 4
+	// historySize 为内存中保留的投递记录数
+	historySize    = 200
+	requestTimeout = 10 * time.Second
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+)
+
+// Payload 是投递给 webhook 的请求体。
+type Payload struct {
+	// ID 为投递 id，重试时不变，接收方可用于去重
+	ID      string      `json:"id"`
+	Webhook string      `json:"webhook"`
+	Action  string      `json:"action"`
+	Event   oplog.Event `json:"event"`
+}
+
+// Delivery 是一次事件投递的记录。
+type Delivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId"`
+	EventID   string `json:"eventId"`
+	Action    string `json:"action"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	// StatusCode 为最后一次请求的 HTTP 状态码，请求未完成时为 0
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CompletedAt time.Time `json:"completedAt,omitempty"`
+}
+
+type job struct {
+	hook     config.WebhookConfig
+	delivery *Delivery
+	body     []byte
+}
+
+// Dispatcher 异步投递事件，实现 xlog.Sink 以接收 log_type=operation 的日志；可并发使用，
+// nil Dispatcher 不投递任何事件。
+type Dispatcher struct {
+	client *http.Client
+	xl     xlog.Logger
+	// backoff 返回第 attempt 次失败后的等待时间，测试中可替换
+	backoff func(attempt int) time.Duration
+
+	mu      sync.Mutex
+	hooks   []config.WebhookConfig
+	history []*Delivery
+
+	queue     chan job
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New 构造 Dispatcher 并启动投递协程，无效的 webhook 会被跳过。
+func New(xl xlog.Logger, hooks []config.WebhookConfig) *Dispatcher {
+	d := &Dispatcher{
+		client:  &http.Client{Timeout: requestTimeout},
+		xl:      xl,
+		backoff: exponentialBackoff,
+		queue:   make(chan job, queueSize),
+		stop:    make(chan struct{}),
+	}
+	d.SetWebhooks(hooks)
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.run()
+	}
+	return d
+}
+
+// SetWebhooks 替换 webhook 列表，只影响之后的事件。
+func (d *Dispatcher) SetWebhooks(hooks []config.WebhookConfig) {
+	if d == nil {
+		return
+	}
+	valid := make([]config.WebhookConfig, 0, len(hooks))
+	for _, hook := range hooks {
+		if err := hook.Validate(); err != nil {
+			d.xl.Warnf("skip invalid webhook: %v", err)
+			continue
+		}
+		valid = append(valid, hook)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks = valid
+}
+
+// Write 实现 xlog.Sink，把操作日志转换为事件后投递。
+func (d *Dispatcher) Write(entry xlog.Entry) {
+	if event, ok := oplog.EventFromEntry(entry); ok {
+		d.Publish(event)
+	}
+}
+
+// Publish 把事件投递给所有订阅它的 webhook，不等待投递完成。
+func (d *Dispatcher) Publish(event oplog.Event) {
+	if d == nil || event.Action == "" {
+		return
+	}
+	d.mu.Lock()
+	hooks := make([]config.WebhookConfig, 0, len(d.hooks))
+	for _, hook := range d.hooks {
+		if hook.Matches(event.Action, event.WorkspaceID) {
+			hooks = append(hooks, hook)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, hook := range hooks {
+		delivery := &Delivery{
+			ID:        uuid.NewString(),
+			WebhookID: hook.ID,
+			EventID:   event.ID,
+			Action:    event.Action,
+			Status:    StatusPending,
+			CreatedAt: time.Now().UTC(),
+		}
+		body, err := json.Marshal(Payload{ID: delivery.ID, Webhook: hook.ID, Action: event.Action, Event: event})
+		if err != nil {
+			d.record(delivery)
+			d.finish(delivery, StatusFailed, 0, fmt.Sprintf("encode payload: %v", err))
+			continue
+		}
+		d.record(delivery)
+		select {
+		case d.queue <- job{hook: hook, delivery: delivery, body: body}:
+		default:
+			d.xl.Warnf("webhook %s queue is full, drop event %s", hook.ID, event.Action)
+			d.finish(delivery, StatusFailed, 0, "delivery queue is full")
+		}
+	}
+}
+
+// Deliveries 返回 webhook 最近的投递记录，按时间倒序。
+func (d *Dispatcher) Deliveries(webhookID string) []Delivery {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]Delivery, 0)
+	for i := len(d.history) - 1; i >= 0; i-- {
+		if d.history[i].WebhookID == webhookID {
+			list = append(list, *d.history[i])
+		}
+	}
+	return list
+}
+
+// Close 停止投递，等待中的重试被放弃。
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.closeOnce.Do(func() {
+		close(d.stop)
+		d.wg.Wait()
+	})
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case j := <-d.queue:
+			d.deliver(j)
+		}
+	}
+}
+
+// deliver 投递一个事件，网络错误、408、429 与 5xx 按指数退避重试。
+func (d *Dispatcher) deliver(j job) {
+	maxAttempts := j.hook.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		d.mu.Lock()
+		j.delivery.Attempts = attempt
+		d.mu.Unlock()
+
+		code, err := d.send(j)
+		if err == nil {
+			d.finish(j.delivery, StatusSucceeded, code, "")
+			return
+		}
+		if !retryable(code) || attempt >= maxAttempts {
+			d.xl.Warnf("webhook %s delivery %s failed after %d attempts: %v", j.hook.ID, j.delivery.ID, attempt, err)
+			d.finish(j.delivery, StatusFailed, code, err.Error())
+			return
+		}
+		d.mu.Lock()
+		j.delivery.StatusCode = code
+		j.delivery.Error = err.Error()
+		d.mu.Unlock()
+
+		timer := time.NewTimer(d.backoff(attempt))
+		select {
+		case <-d.stop:
+			timer.Stop()
+			d.finish(j.delivery, StatusFailed, code, "dispatcher closed before retry")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (d *Dispatcher) send(j job) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.hook.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookID, j.hook.ID)
+	req.Header.Set(HeaderEvent, j.delivery.Action)
+	req.Header.Set(HeaderDelivery, j.delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if j.hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(j.hook.Secret, timestamp, j.body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(delivery *Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.history = append(d.history, delivery)
+	if len(d.history) > historySize {
+		d.history = append([]*Delivery(nil), d.history[len(d.history)-historySize:]...)
+	}
+}
+
+func (d *Dispatcher) finish(delivery *Delivery, status string, code int, errText string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery.Status = status
+	delivery.StatusCode = code
+	delivery.Error = errText
+	delivery.CompletedAt = time.Now().UTC()
+}
+
+// Sign 返回 X-Webhook-Signature 的值：sha256= 加上 HMAC-SHA256(secret, timestamp + "." + body) 的十六进制。
+// 接收方应使用相同方式计算并用 hmac.Equal 比较，同时拒绝时间戳过旧的请求。
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable 返回失败的请求是否值得重试，code 为 0 表示请求未完成。
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+func exponentialBackoff(attempt int) time.Duration {
+	wait := initialBackoff << (attempt - 1)
+	if wait <= 0 || wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
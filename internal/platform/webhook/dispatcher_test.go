@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+func newTestDispatcher(t *testing.T, hooks ...config.WebhookConfig) *Dispatcher {
+	t.Helper()
+	d := New(xlog.NewLogger("test-webhook"), hooks)
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	t.Cleanup(d.Close)
+	return d
+}
+
+// waitDelivery 等待 webhook 最近一次投递结束。
+func waitDelivery(t *testing.T, d *Dispatcher, webhookID string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if list := d.Deliveries(webhookID); len(list) > 0 && list[0].Status != StatusPending {
+			return list[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery to %s did not finish", webhookID)
+	return Delivery{}
+}
+
+func TestDispatcherSignsOperationEvents(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, config.WebhookConfig{ID: "ops", URL: server.URL, Secret: "s3cret", Events: []string{"service.*"}})
+	d.Write(xlog.Entry{
+		Timestamp: time.Now(),
+		Level:     "error",
+		Message:   "MCP service failed: fs",
+		Fields: map[string]interface{}{
+			"log_type":     "operation",
+			"event_id":     "evt-1",
+			"action":       "service.failed",
+			"workspace_id": "team-a",
+			"resource_id":  "fs",
+		},
+	})
+
+	r := <-received
+	body := <-bodies
+	if got, want := r.Header.Get(HeaderSignature), Sign("s3cret", r.Header.Get(HeaderTimestamp), body); got != want {
+		t.Fatalf("signature mismatch: got %s want %s", got, want)
+	}
+	if r.Header.Get(HeaderEvent) != "service.failed" || r.Header.Get(HeaderWebhookID) != "ops" {
+		t.Fatalf("unexpected headers: %v", r.Header)
+	}
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event.ID != "evt-1" || payload.Event.WorkspaceID != "team-a" || payload.ID != r.Header.Get(HeaderDelivery) {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	if delivery := waitDelivery(t, d, "ops"); delivery.Status != StatusSucceeded || delivery.Attempts != 1 || delivery.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestDispatcherFiltersEvents(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t,
+		config.WebhookConfig{ID: "denials", URL: server.URL, Events: []string{"tool.call_denied"}, Workspaces: []string{"team-a"}},
+		config.WebhookConfig{ID: "off", URL: server.URL, Disabled: true},
+	)
+	d.Write(xlog.Entry{Message: "plain log", Fields: map[string]interface{}{"action": "tool.call_denied"}})
+	d.Publish(oplog.Event{ID: "1", Action: "tool.call_denied", WorkspaceID: "team-b"})
+	d.Publish(oplog.Event{ID: "2", Action: "session.create", WorkspaceID: "team-a"})
+	d.Publish(oplog.Event{ID: "3", Action: "tool.call_denied", WorkspaceID: "team-a"})
+
+	delivery := waitDelivery(t, d, "denials")
+	if delivery.EventID != "3" || len(d.Deliveries("denials")) != 1 || len(d.Deliveries("off")) != 0 {
+		t.Fatalf("only the matching event should be delivered: %+v", d.Deliveries("denials"))
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 request, got %d", calls.Load())
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, config.WebhookConfig{ID: "flaky", URL: server.URL})
+	d.Publish(oplog.Event{ID: "1", Action: "service.max_retries_reached"})
+	if delivery := waitDelivery(t, d, "flaky"); delivery.Status != StatusSucceeded || delivery.Attempts != 3 {
+		t.Fatalf("delivery should succeed on the third attempt: %+v", delivery)
+	}
+
+	// 4xx 不重试，达到次数上限后失败
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	d.SetWebhooks([]config.WebhookConfig{{ID: "rejecting", URL: rejecting.URL}, {ID: "down", URL: down.URL, MaxAttempts: 2}})
+	d.Publish(oplog.Event{ID: "2", Action: "service.failed"})
+	if delivery := waitDelivery(t, d, "rejecting"); delivery.Status != StatusFailed || delivery.Attempts != 1 || delivery.StatusCode != http.StatusBadRequest {
+		t.Fatalf("4xx should fail without retry: %+v", delivery)
+	}
+	if delivery := waitDelivery(t, d, "down"); delivery.Status != StatusFailed || delivery.Attempts != 2 || delivery.StatusCode != http.StatusBadGateway {
+		t.Fatalf("5xx should be retried up to maxAttempts: %+v", delivery)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	if exponentialBackoff(1) != time.Second || exponentialBackoff(3) != 4*time.Second || exponentialBackoff(20) != time.Minute {
+		t.Fatalf("unexpected backoff: %v %v %v", exponentialBackoff(1), exponentialBackoff(3), exponentialBackoff(20))
+	}
+}
@@ -70,7 +70,7 @@ func initGlobalLogger() {
 	config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	config.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
 
-	logger, err := config.Build(zap.AddCallerSkip(1))
+	logger, err := config.Build(zap.AddCallerSkip(1), zap.WrapCore(newRedactingCore))
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize zap logger: %v", err))
 	}
@@ -221,11 +221,11 @@ func SetupFileLogging(baseDir, fileName string) error {
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
 
-	core := zapcore.NewCore(
+	core := newRedactingCore(zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
 		zapcore.AddSync(multiWriter),
 		zapcore.DebugLevel,
-	)
+	))
 
 	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 
@@ -280,13 +280,13 @@ func (l *zapLogger) emit(level, message string) {
 	if dispatcher == nil {
 		return
 	}
-	dispatcher.publish(Entry{
+	dispatcher.publish(redactEntry(Entry{
 		Timestamp: time.Now().UTC(),
 		Level:     level,
 		Logger:    l.name,
 		Message:   message,
 		Fields:    cloneFields(l.fields),
-	})
+	}))
 }
 
 func cloneFields(fields map[string]interface{}) map[string]interface{} {
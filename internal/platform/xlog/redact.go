@@ -0,0 +1,117 @@
+package xlog
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// workspaceField 为日志中标识 workspace 的字段名，用于选择 workspace 的遮盖规则。
+const workspaceField = "workspace_id"
+
+// Redactor 在日志写出前遮盖敏感内容，workspace 为日志 workspace_id 字段的值，可能为空。
+type Redactor interface {
+	RedactString(workspace, s string) string
+	RedactValue(workspace string, v interface{}) interface{}
+}
+
+type redactorHolder struct {
+	redactor Redactor
+}
+
+var globalRedactor atomic.Pointer[redactorHolder]
+
+// SetRedactor 设置日志的遮盖规则，对控制台、日志文件与 sink 均生效；r 为 nil 时关闭遮盖。
+func SetRedactor(r Redactor) {
+	globalRedactor.Store(&redactorHolder{redactor: r})
+}
+
+func currentRedactor() Redactor {
+	if holder := globalRedactor.Load(); holder != nil {
+		return holder.redactor
+	}
+	return nil
+}
+
+// redactEntry 遮盖发往 sink 的日志。
+func redactEntry(entry Entry) Entry {
+	r := currentRedactor()
+	if r == nil {
+		return entry
+	}
+	workspace, _ := entry.Fields[workspaceField].(string)
+	entry.Message = r.RedactString(workspace, entry.Message)
+	for k, v := range entry.Fields {
+		entry.Fields[k] = r.RedactValue(workspace, v)
+	}
+	return entry
+}
+
+// redactingCore 在 zap 写出日志前遮盖消息与字段。With 附加的字段在附加时遮盖，
+// 并记录其中的 workspace_id 供之后的日志使用。
+type redactingCore struct {
+	zapcore.Core
+	workspace string
+}
+
+func newRedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	workspace := fieldsWorkspace(fields, c.workspace)
+	return &redactingCore{Core: c.Core.With(redactFields(workspace, fields)), workspace: workspace}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	workspace := fieldsWorkspace(fields, c.workspace)
+	if r := currentRedactor(); r != nil {
+		entry.Message = r.RedactString(workspace, entry.Message)
+	}
+	return c.Core.Write(entry, redactFields(workspace, fields))
+}
+
+func fieldsWorkspace(fields []zapcore.Field, fallback string) string {
+	for _, f := range fields {
+		if f.Key == workspaceField && f.Type == zapcore.StringType {
+			return f.String
+		}
+	}
+	return fallback
+}
+
+// redactFields 返回遮盖后的字段副本，字符串、error、Stringer 与反射编码的值会被处理。
+func redactFields(workspace string, fields []zapcore.Field) []zapcore.Field {
+	r := currentRedactor()
+	if r == nil || len(fields) == 0 {
+		return fields
+	}
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = r.RedactString(workspace, f.String)
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				f = zap.String(f.Key, r.RedactString(workspace, err.Error()))
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+				f = zap.String(f.Key, r.RedactString(workspace, s.String()))
+			}
+		case zapcore.ReflectType:
+			f.Interface = r.RedactValue(workspace, f.Interface)
+		}
+		out[i] = f
+	}
+	return out
+}
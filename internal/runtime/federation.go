@@ -0,0 +1,74 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/federation"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+// RemoteGateway 是 gateway 类型服务启动时通过远端 admin API 发现的内容。
+type RemoteGateway struct {
+	Workspace string   `json:"workspace"`
+	Services  []string `json:"services"`
+}
+
+// startGateway 启动 gateway 类型服务。访问远端 admin API 时不持有 s.mutex，
+// 远端响应缓慢也不会阻塞 GetStatus、Info 等调用；得到结果后再加锁更新状态。
+func (s *McpService) startGateway(logger xlog.Logger) error {
+	s.mutex.Lock()
+	switch s.Status {
+	case Running:
+		s.mutex.Unlock()
+		return nil
+	case Starting:
+		s.mutex.Unlock()
+		return fmt.Errorf("服务 %s 正在启动", s.Name)
+	}
+	cfg := s.Config
+	s.Status = Starting
+	s.LastStartedAt = time.Now()
+	s.LastError = ""
+	s.FailureReason = ""
+	s.mutex.Unlock()
+
+	remote, err := discoverRemote(cfg)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Status != Starting {
+		// 发现期间服务被停止，保留停止后的状态
+		return fmt.Errorf("service %s was stopped while discovering the remote gateway", s.Name)
+	}
+	if err != nil {
+		s.Status = Failed
+		s.LastError = err.Error()
+		s.FailureReason = "Remote gateway discovery failed"
+		s.appendOperation("service.failed", "MCP service failed: "+s.Name, s.LastError, map[string]interface{}{"failure_reason": s.FailureReason})
+		return err
+	}
+	s.remote = remote
+	s.Status = Running
+	s.HealthCheckURL = cfg.URL
+	logger.Infof("Remote gateway %s workspace %s exposes %d services: %v", cfg.URL, remote.Workspace, len(remote.Services), remote.Services)
+	return nil
+}
+
+// discoverRemote 确认服务凭证可以访问远端 workspace 并返回其中的服务。
+func discoverRemote(cfg config.MCPServerConfig) (*RemoteGateway, error) {
+	workspace := cfg.GetRemoteWorkspace()
+	ctx, cancel := context.WithTimeout(context.Background(), bridgeInitTimeout)
+	defer cancel()
+	services, err := federation.New(cfg.URL, cfg.Env[config.GatewayAPIKeyEnv]).Services(ctx, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("discover remote gateway workspace %s: %w", workspace, err)
+	}
+	remote := &RemoteGateway{Workspace: workspace, Services: make([]string, 0, len(services))}
+	for _, svc := range services {
+		remote.Services = append(remote.Services, svc.Name)
+	}
+	return remote, nil
+}
@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+func TestGatewayStartDoesNotHoldLockDuringDiscovery(t *testing.T) {
+	release := make(chan struct{})
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if r.URL.Path != "/api/v1/workspaces/team-a/services" || r.Header.Get("Authorization") != "Bearer gw-key" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"success":false,"error":{"code":"FORBIDDEN","message":"workspace access denied"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true,"data":{"items":[{"name":"github"},{"name":"fs"}]}}`))
+	}))
+	defer remote.Close()
+
+	service := NewMcpService("eu", config.MCPServerConfig{
+		Type:            config.ServiceTypeGateway,
+		URL:             remote.URL,
+		RemoteWorkspace: "team-a",
+		Env:             map[string]string{config.GatewayAPIKeyEnv: "gw-key"},
+	}, mockPortMgr)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start(xlog.NewLogger("test-gateway")) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for service.GetStatus() != Starting {
+		if time.Now().After(deadline) {
+			t.Fatalf("service should be starting, got %s", service.GetStatus())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 远端尚未响应时 Info 不应被阻塞
+	info := make(chan McpServiceInfo, 1)
+	go func() { info <- service.Info() }()
+	select {
+	case <-info:
+	case <-time.After(time.Second):
+		t.Fatal("Info blocked while the remote gateway was being discovered")
+	}
+
+	close(release)
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	got := service.Info()
+	if got.Status != Running || got.Remote == nil || len(got.Remote.Services) != 2 {
+		t.Fatalf("unexpected service info after discovery: %+v", got)
+	}
+}
+
+func TestGatewayStartFailsWhenDiscoveryFails(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"success":false,"error":{"code":"UNAUTHORIZED","message":"invalid api key"}}`))
+	}))
+	defer remote.Close()
+
+	service := NewMcpService("eu", config.MCPServerConfig{Type: config.ServiceTypeGateway, URL: remote.URL}, mockPortMgr)
+	if err := service.Start(xlog.NewLogger("test-gateway")); err == nil {
+		t.Fatal("expected discovery error")
+	}
+	if service.GetStatus() != Failed || service.Info().FailureReason != "Remote gateway discovery failed" {
+		t.Fatalf("service should be failed, got %+v", service.Info())
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/federation"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime/bridge"
 	"github.com/mark3labs/mcp-go/client/transport"
@@ -65,6 +67,9 @@ type McpService struct {
 	bridge bridge.Bridge
 	isSSE  bool
 
+	// remote 为 gateway 类型服务最近一次启动时发现的远端内容
+	remote *RemoteGateway
+
 	// 状态详情
 	LastError      string    // 最后一次错误信息
 	FailureReason  string    // 失败原因
@@ -146,6 +151,9 @@ func (s *McpService) Stop(logger xlog.Logger) (err error) {
 
 // Start 启动服务
 func (s *McpService) Start(logger xlog.Logger) error {
+	if s.Config.IsGateway() {
+		return s.startGateway(logger)
+	}
 	if s.IsSSE() {
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
@@ -170,6 +178,12 @@ func (s *McpService) Start(logger xlog.Logger) error {
 	if s.Status == Failed {
 		return fmt.Errorf("服务 %s 已失败，无法启动", s.Name)
 	}
+	// 本次启动失败时记录操作日志，在释放锁之前执行
+	defer func() {
+		if s.Status == Failed {
+			s.appendOperation("service.failed", "MCP service failed: "+s.Name, s.LastError, map[string]interface{}{"failure_reason": s.FailureReason})
+		}
+	}()
 	if strings.TrimSpace(s.Config.Command) == "" {
 		s.LastError = "command is required"
 		s.FailureReason = "Invalid service configuration"
@@ -321,6 +335,7 @@ func (s *McpService) Restart(logger xlog.Logger) {
 		s.Status = Failed
 		s.FailureReason = "Max retry count reached"
 		s.LastError = "Service failed after maximum retry attempts"
+		s.appendOperation("service.max_retries_reached", "MCP service reached max retries: "+s.Name, s.LastError, map[string]interface{}{"retry_max": s.RetryMax})
 		s.mutex.Unlock()
 		return
 	}
@@ -352,11 +367,31 @@ func (s *McpService) Restart(logger xlog.Logger) {
 		} else {
 			s.Status = Failed
 			s.FailureReason = "All restart attempts failed"
+			s.appendOperation("service.max_retries_reached", "MCP service reached max retries: "+s.Name, s.LastError, map[string]interface{}{"retry_max": s.RetryMax})
 			s.mutex.Unlock()
 		}
 	}
 }
 
+// appendOperation 记录服务状态变化的操作日志，调用方需持有 s.mutex。
+func (s *McpService) appendOperation(action, message, errText string, detail map[string]interface{}) {
+	fields := map[string]interface{}{
+		"log_type":      "operation",
+		"event_id":      uuid.NewString(),
+		"action":        action,
+		"workspace_id":  s.Config.Workspace,
+		"resource_type": "service",
+		"resource_id":   s.Name,
+	}
+	for k, v := range detail {
+		fields[k] = v
+	}
+	if errText != "" {
+		fields["error"] = errText
+	}
+	xlog.NewLogger("runtime").WithFields(fields).Error(message)
+}
+
 // SetConfig 设置配置, 下次启动时生效
 func (s *McpService) SetConfig(cfg config.MCPServerConfig) error {
 	if s.Status != Stopped {
@@ -370,6 +405,10 @@ func (s *McpService) GetUrl() string {
 	if s.GetStatus() != Running {
 		return ""
 	}
+	if s.Config.IsGateway() {
+		// 只暴露远端 workspace 作用域下的 MCP 入口，避免经由服务凭证访问远端 admin API
+		return federation.WorkspaceURL(s.Config.URL, s.Config.GetRemoteWorkspace())
+	}
 	if s.Config.URL != "" {
 		return s.Config.URL
 	}
@@ -381,7 +420,7 @@ func (s *McpService) GetUrl() string {
 }
 
 func (s *McpService) GetSSEUrl() string {
-	if s.GetStatus() != Running {
+	if s.GetStatus() != Running || s.Config.IsGateway() {
 		return ""
 	}
 	if s.IsSSE() && s.Config.GatewayProtocol != "streamhttp" {
@@ -400,6 +439,9 @@ func (s *McpService) GetMessageUrl() string {
 	if s.GetStatus() != Running {
 		return ""
 	}
+	if s.Config.IsGateway() {
+		return federation.StreamURL(s.Config.URL, s.Config.GetRemoteWorkspace())
+	}
 	if s.IsSSE() && s.Config.GatewayProtocol == "streamhttp" {
 		return s.Config.URL
 	}
@@ -459,6 +501,8 @@ type McpServiceInfo struct {
 	RetryCount    int                    `json:"retry_count"`
 	RetryMax      int                    `json:"retry_max"`
 	URLs          ServiceURLs            `json:"urls"`
+	// Remote 为 gateway 类型服务发现的远端 workspace 与服务
+	Remote *RemoteGateway `json:"remote,omitempty"`
 }
 
 type ServiceURLs struct {
@@ -487,6 +531,7 @@ func (s *McpService) Info() McpServiceInfo {
 			SSEUrl:     s.GetSSEUrl(),
 			MessageUrl: s.GetMessageUrl(),
 		},
+		Remote: s.remote,
 	}
 }
 
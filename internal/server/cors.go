@@ -7,7 +7,10 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
-const HeaderMcpSessionID = "Mcp-Session-Id"
+const (
+	HeaderMcpSessionID       = "Mcp-Session-Id"
+	HeaderMcpProtocolVersion = "MCP-Protocol-Version"
+)
 
 func CORSConfig() middleware.CORSConfig {
 	return middleware.CORSConfig{
@@ -27,8 +30,10 @@ func CORSConfig() middleware.CORSConfig {
 			echo.HeaderAccept,
 			echo.HeaderAuthorization,
 			HeaderMcpSessionID,
+			HeaderMcpProtocolVersion,
 			"X-Workspace-Id",
+			echo.HeaderXRequestID,
 		},
-		ExposeHeaders: []string{HeaderMcpSessionID},
+		ExposeHeaders: []string{HeaderMcpSessionID, echo.HeaderXRequestID},
 	}
 }
@@ -14,6 +14,10 @@ import (
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/identity"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/oplog"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/persistence"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/ratelimit"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/redact"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/usage"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/webhook"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/workspaces"
@@ -25,6 +29,8 @@ type Server struct {
 	services workspaces.ServiceManagerI
 	auth     *identity.Service
 	opLogDB  interface{ Close(context.Context) error }
+	meter    *usage.Meter
+	hooks    *webhook.Dispatcher
 }
 
 // New 构造并返回一个 Server 实例，同时在给定的 Echo 上注册：
@@ -36,7 +42,13 @@ type Server struct {
 // 非 SaaS 模式下会异步触发一次从 mcp_servers.json 恢复 MCP 部署。
 func New(cfg config.Config, e *echo.Echo) *Server {
 	portMgr := runtime.NewPortManager()
+	// 遮盖规则在任何请求之前生效，日志、操作日志与工具结果共用
+	redactor := redact.New(xlog.NewLogger("REDACT"), cfg.Redaction, cfg.Redactions)
+	if redactor != nil {
+		xlog.SetRedactor(redactor)
+	}
 	services := workspaces.NewServiceMgr(cfg, portMgr)
+	services.SetRedactor(redactor)
 	var store identity.Store
 	if cfg.GetAuthConfig().GetMode() == "saas" {
 		if err := identity.ValidateSaaSConfig(&cfg); err != nil {
@@ -57,9 +69,19 @@ func New(cfg config.Config, e *echo.Echo) *Server {
 		panic(err)
 	}
 	xlog.RegisterSink(oplog.NewXLogSink(operationLogs))
+	// webhook 与操作日志接收同一批事件，由 admin API 增删
+	hooks := webhook.New(xlog.NewLogger("WEBHOOK"), cfg.Webhooks)
+	xlog.RegisterSink(hooks)
 
-	adminH := admin.NewHandler(services, &cfg, authSvc, operationLogs)
-	gatewayH := gateway.NewHandler(services, &cfg, authSvc)
+	// 限流状态由 gateway 写入、admin API 读取，两者共用一个 Limiter
+	limits := ratelimit.New(xlog.NewLogger("RATE-LIMIT"), cfg.RateLimits)
+	// 用量计量与月度配额只在 SaaS 模式下启用，用量记录保存在 Mongo
+	var meter *usage.Meter
+	if authSvc.IsSaaS() {
+		meter = usage.NewMeter(authSvc, cfg.UsageQuotas)
+	}
+	adminH := admin.NewHandler(services, &cfg, authSvc, limits, meter, hooks, operationLogs)
+	gatewayH := gateway.NewHandler(services, &cfg, authSvc, limits, meter)
 
 	// 先注册精确匹配的路由
 	adminH.Register(e)
@@ -80,12 +102,15 @@ func New(cfg config.Config, e *echo.Echo) *Server {
 		})
 	}
 
-	return &Server{services: services, auth: authSvc, opLogDB: opLogCloser}
+	return &Server{services: services, auth: authSvc, opLogDB: opLogCloser, meter: meter, hooks: hooks}
 }
 
 // Close 优雅关闭底层 service manager（会关闭所有 workspaces 及其 MCP 服务）。
 func (s *Server) Close() {
 	s.services.Close()
+	s.hooks.Close()
+	// 先写出剩余用量，再关闭 identity store
+	_ = s.meter.Close(context.Background())
 	if s.auth != nil {
 		_ = s.auth.Close(context.Background())
 	}
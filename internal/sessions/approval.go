@@ -0,0 +1,321 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ApprovalDeniedCode 是等待审批的 tools/call 被拒绝、超时或 session 关闭时返回的 JSON-RPC 错误码。
+const ApprovalDeniedCode = -32032
+
+// ApprovalRuleDestructive 是工具声明 destructiveHint 而需要审批时记录的规则名。
+const ApprovalRuleDestructive = "destructiveHint"
+
+// 等待审批的调用的结束状态
+const (
+	ApprovalStatusDenied    = "denied"
+	ApprovalStatusExpired   = "expired"
+	ApprovalStatusCancelled = "cancelled"
+)
+
+// 等待审批期间向请求方发送保活通知的间隔，需小于网关等待响应的超时
+const approvalKeepaliveInterval = 10 * time.Second
+
+// ErrApprovalNotFound 表示审批不存在，或调用已被处理、超时。
+var ErrApprovalNotFound = errors.New("approval not found")
+
+// Approval 是一条等待人工审批的工具调用。
+type Approval struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionId"`
+	// Tool 为带服务前缀的工具名
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	// Rule 为要求审批的策略名，工具声明 destructiveHint 时为 "destructiveHint"
+	Rule        string    `json:"rule"`
+	AccountID   string    `json:"accountId,omitempty"`
+	APIKeyID    string    `json:"apiKeyId,omitempty"`
+	RequestedAt time.Time `json:"requestedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// ApprovalDecision 是审批人对一条调用的决定。
+type ApprovalDecision struct {
+	Approve  bool
+	Approver string
+	Reason   string
+}
+
+// ApprovalError 表示等待审批的工具调用没有获得批准。
+type ApprovalError struct {
+	ID       string
+	Tool     string
+	Status   string
+	Approver string
+	Reason   string
+}
+
+func (e *ApprovalError) Error() string {
+	var msg string
+	switch e.Status {
+	case ApprovalStatusDenied:
+		msg = fmt.Sprintf("tool %s call denied by %s", e.Tool, e.Approver)
+	case ApprovalStatusExpired:
+		msg = fmt.Sprintf("tool %s call was not approved in time", e.Tool)
+	default:
+		msg = fmt.Sprintf("tool %s call cancelled while waiting for approval", e.Tool)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// RPCCode 实现 rpcError。
+func (e *ApprovalError) RPCCode() int {
+	return ApprovalDeniedCode
+}
+
+// RPCData 实现 rpcError。
+func (e *ApprovalError) RPCData() any {
+	data := map[string]any{"approval": e.ID, "tool": e.Tool, "status": e.Status}
+	if e.Approver != "" {
+		data["approver"] = e.Approver
+	}
+	if e.Reason != "" {
+		data["reason"] = e.Reason
+	}
+	return data
+}
+
+// ApprovalQueue 保存 workspace 内等待审批的工具调用，由 SessionManager 与其 session 共享。
+type ApprovalQueue struct {
+	mu      sync.Mutex
+	cfg     config.ApprovalConfig
+	pending map[string]*pendingApproval
+}
+
+type pendingApproval struct {
+	Approval
+	// decided 缓冲为 1，由从队列中取走该调用的一方写入
+	decided chan ApprovalDecision
+}
+
+func newApprovalQueue() *ApprovalQueue {
+	return &ApprovalQueue{pending: make(map[string]*pendingApproval)}
+}
+
+// SetApprovalConfig 设置 workspace 的审批配置，对已有 session 立即生效。
+func (m *SessionManager) SetApprovalConfig(cfg config.ApprovalConfig) {
+	m.approvals.mu.Lock()
+	defer m.approvals.mu.Unlock()
+	m.approvals.cfg = cfg
+}
+
+// Approvals 返回 workspace 的审批队列。
+func (m *SessionManager) Approvals() *ApprovalQueue {
+	return m.approvals
+}
+
+// List 返回等待审批的调用，按发起时间排序。
+func (q *ApprovalQueue) List() []Approval {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]Approval, 0, len(q.pending))
+	for _, p := range q.pending {
+		list = append(list, p.Approval)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RequestedAt.Before(list[j].RequestedAt) })
+	return list
+}
+
+// Decide 批准或拒绝一条等待中的调用，返回该调用；调用不存在或已结束时返回 ErrApprovalNotFound。
+func (q *ApprovalQueue) Decide(id string, decision ApprovalDecision) (Approval, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.pending[id]
+	if !ok {
+		return Approval{}, ErrApprovalNotFound
+	}
+	delete(q.pending, id)
+	p.decided <- decision
+	return p.Approval, nil
+}
+
+func (q *ApprovalQueue) config() config.ApprovalConfig {
+	if q == nil {
+		return config.ApprovalConfig{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cfg
+}
+
+func (q *ApprovalQueue) hold(a Approval) *pendingApproval {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	a.ID = uuid.NewString()
+	a.RequestedAt = time.Now()
+	a.ExpiresAt = a.RequestedAt.Add(q.cfg.Timeout())
+	p := &pendingApproval{Approval: a, decided: make(chan ApprovalDecision, 1)}
+	q.pending[a.ID] = p
+	return p
+}
+
+// withdraw 把调用移出队列，返回是否移出；返回 false 时审批人已做出决定，决定会写入 decided。
+func (q *ApprovalQueue) withdraw(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[id]; !ok {
+		return false
+	}
+	delete(q.pending, id)
+	return true
+}
+
+// approvalRule 返回调用需要审批的原因：approve 策略的规则名，或在 workspace 开启 destructive
+// 审批时工具声明了 destructiveHint。不需要审批时返回空字符串。
+func (s *Session) approvalRule(xl xlog.Logger, tool, policyRule string) string {
+	if policyRule != "" {
+		return policyRule
+	}
+	if !s.approvals.config().Destructive {
+		return ""
+	}
+	service, name, ok := strings.Cut(tool, "_")
+	if !ok || service == GatewayNamespace {
+		return ""
+	}
+	definition, known := s.GetMcpTool(service, name)
+	if !known {
+		// client 可能未先调用 tools/list，此时向下游补齐工具定义
+		if err := s.sendToolsListToMcp(xl, service); err != nil {
+			xl.Warnf("failed to load annotations of %s: %v", tool, err)
+		}
+		definition, known = s.GetMcpTool(service, name)
+	}
+	if known && definition.Annotations.DestructiveHint != nil && *definition.Annotations.DestructiveHint {
+		return ApprovalRuleDestructive
+	}
+	return ""
+}
+
+// waitForApproval 把调用放入审批队列并等待决定。等待期间定期向请求方发送 notifications/message，
+// 使网关的等待超时重新计时；批准时返回 nil，拒绝、超时、session 关闭或 ctx 结束（请求方断开）时
+// 返回 *ApprovalError，未决定的调用同时移出队列。
+func (s *Session) waitForApproval(ctx context.Context, xl xlog.Logger, requestID mcp.RequestId, tool, rule string, args map[string]interface{}) error {
+	if s.approvals == nil {
+		return &ApprovalError{Tool: tool, Status: ApprovalStatusCancelled, Reason: "approvals are not available"}
+	}
+	caller := s.Caller()
+	p := s.approvals.hold(Approval{
+		SessionID: s.Id,
+		Tool:      tool,
+		Arguments: args,
+		Rule:      rule,
+		AccountID: caller.AccountID,
+		APIKeyID:  caller.APIKeyID,
+	})
+	xl.Infof("tool %s held for approval %s (%s)", tool, p.ID, rule)
+	s.appendPolicyOperation(caller, "tool.call_held", tool, fmt.Sprintf("Tool call held for approval: %s", tool), map[string]interface{}{
+		"approval_id":   p.ID,
+		"approval_rule": rule,
+	})
+
+	requestKey := ""
+	if !requestID.IsNil() {
+		if raw, err := json.Marshal(requestID); err == nil {
+			requestKey = string(bytes.TrimSpace(raw))
+		}
+	}
+	s.sendApprovalNotice(requestKey, p)
+
+	expiry := time.NewTimer(time.Until(p.ExpiresAt))
+	defer expiry.Stop()
+	keepalive := time.NewTicker(approvalKeepaliveInterval)
+	defer keepalive.Stop()
+
+	status, reason := "", ""
+	for status == "" {
+		select {
+		case decision := <-p.decided:
+			return s.approvalResult(xl, p, decision)
+		case <-keepalive.C:
+			s.sendApprovalNotice(requestKey, p)
+		case <-expiry.C:
+			status = ApprovalStatusExpired
+		case <-s.doneChan:
+			status, reason = ApprovalStatusCancelled, "session closed"
+		case <-ctx.Done():
+			status, reason = ApprovalStatusCancelled, "client disconnected"
+		}
+	}
+	if !s.approvals.withdraw(p.ID) {
+		return s.approvalResult(xl, p, <-p.decided)
+	}
+	xl.Warnf("approval %s for tool %s %s", p.ID, tool, status)
+	detail := map[string]interface{}{
+		"approval_id":   p.ID,
+		"approval_rule": rule,
+	}
+	if status == ApprovalStatusExpired {
+		s.appendPolicyOperation(caller, "tool.call_approval_expired", tool, fmt.Sprintf("Tool call approval expired: %s", tool), detail)
+	} else {
+		detail["reason"] = reason
+		s.appendPolicyOperation(caller, "approval.cancelled", tool, fmt.Sprintf("Tool call approval cancelled: %s", tool), detail)
+	}
+	return &ApprovalError{ID: p.ID, Tool: tool, Status: status, Reason: reason}
+}
+
+func (s *Session) approvalResult(xl xlog.Logger, p *pendingApproval, decision ApprovalDecision) error {
+	if decision.Approve {
+		xl.Infof("approval %s for tool %s approved by %s", p.ID, p.Tool, decision.Approver)
+		return nil
+	}
+	xl.Infof("approval %s for tool %s denied by %s", p.ID, p.Tool, decision.Approver)
+	return &ApprovalError{ID: p.ID, Tool: p.Tool, Status: ApprovalStatusDenied, Approver: decision.Approver, Reason: decision.Reason}
+}
+
+// sendApprovalNotice 告知请求方调用正在等待审批。每次通知带上已等待的秒数，
+// 保证相邻的 keepalive 内容不同，不会被 SendEvent 当作重复事件丢弃。
+func (s *Session) sendApprovalNotice(requestKey string, p *pendingApproval) {
+	data, err := json.Marshal(mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: methodNotificationMessage,
+			Params: mcp.NotificationParams{AdditionalFields: map[string]any{
+				"level":  "info",
+				"logger": GatewayNamespace,
+				"data": map[string]any{
+					"message":        fmt.Sprintf("tool %s is waiting for approval", p.Tool),
+					"approval":       p.ID,
+					"tool":           p.Tool,
+					"rule":           p.Rule,
+					"expiresAt":      p.ExpiresAt.Format(time.RFC3339),
+					"elapsedSeconds": int(time.Since(p.RequestedAt).Seconds()),
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return
+	}
+	event := SessionMsg{Event: "message", Data: string(data)}
+	if requestKey == "" {
+		s.SendEvent(event)
+		return
+	}
+	s.sendRequestNotification(requestKey, event)
+}
@@ -0,0 +1,180 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func approvalSession(t *testing.T, cfg config.ApprovalConfig, rules ...config.ToolPolicyRule) (*SessionManager, *Session, map[string]*recordingClient, <-chan SessionMsg) {
+	t.Helper()
+	ok := func(mcp.CallToolRequest) *mcp.CallToolResult { return mcp.NewToolResultText("ok") }
+	clients := map[string]*recordingClient{
+		"fs": {tools: []mcp.Tool{
+			{Name: "delete_file", Annotations: mcp.ToolAnnotation{DestructiveHint: mcp.ToBoolPtr(true)}},
+			{Name: "read_file", Annotations: mcp.ToolAnnotation{ReadOnlyHint: mcp.ToBoolPtr(true)}},
+		}, respond: ok},
+		"db": {tools: []mcp.Tool{{Name: "query"}}, respond: ok},
+	}
+	services := []*runtime.McpService{runningRemoteService("fs"), runningRemoteService("db")}
+	manager := NewSessionManager(func() []*runtime.McpService { return services }, CleanupConfig{})
+	manager.dialDownstream = func(_ xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		return clients[spec.Name], &mcp.InitializeResult{}, nil
+	}
+	manager.SetToolPolicies(xlog.NewLogger("test-approval"), rules)
+	manager.SetApprovalConfig(cfg)
+	session, err := manager.CreateSession(xlog.NewLogger("test-approval"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(session.Close)
+	session.SetCaller(Caller{Workspace: "team-a", AccountID: "acc-1"})
+	events, closeEvents := session.GetEventChanWithCloser()
+	t.Cleanup(closeEvents)
+	return manager, session, clients, events
+}
+
+// waitApprovalNotice 读取调用进入审批队列后发出的通知，返回审批 id。
+func waitApprovalNotice(t *testing.T, events <-chan SessionMsg) string {
+	t.Helper()
+	var notice struct {
+		Method string `json:"method"`
+		Params struct {
+			Data struct {
+				Approval string `json:"approval"`
+			} `json:"data"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &notice); err != nil {
+		t.Fatal(err)
+	}
+	if notice.Method != methodNotificationMessage || notice.Params.Data.Approval == "" {
+		t.Fatalf("expected approval notice, got %+v", notice)
+	}
+	return notice.Params.Data.Approval
+}
+
+func decodeApprovalResponse(t *testing.T, events <-chan SessionMsg) (int, map[string]any) {
+	t.Helper()
+	var resp struct {
+		Error *struct {
+			Code int            `json:"code"`
+			Data map[string]any `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil {
+		return 0, nil
+	}
+	return resp.Error.Code, resp.Error.Data
+}
+
+func TestApprovalHoldsDestructiveToolUntilApproved(t *testing.T) {
+	manager, session, clients, events := approvalSession(t, config.ApprovalConfig{Destructive: true})
+
+	// 只读工具不需要审批
+	if code := callTool(t, session, events, 1, "fs_read_file", map[string]interface{}{"path": "/a"}); code != 0 {
+		t.Fatalf("read_file should be forwarded, got code %d", code)
+	}
+
+	sendRPC(t, session, 2, "tools/call", map[string]interface{}{"name": "fs_delete_file", "arguments": map[string]interface{}{"path": "/a"}})
+	id := waitApprovalNotice(t, events)
+	pending := manager.Approvals().List()
+	if len(pending) != 1 || pending[0].ID != id || pending[0].Tool != "fs_delete_file" || pending[0].Rule != ApprovalRuleDestructive || pending[0].AccountID != "acc-1" {
+		t.Fatalf("unexpected pending approvals: %+v", pending)
+	}
+	if len(clients["fs"].calls) != 1 {
+		t.Fatalf("held call must not reach downstream before approval")
+	}
+
+	if _, err := manager.Approvals().Decide(id, ApprovalDecision{Approve: true, Approver: "admin-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := decodeApprovalResponse(t, events); code != 0 {
+		t.Fatalf("approved call should succeed, got code %d", code)
+	}
+	if len(clients["fs"].calls) != 2 || clients["fs"].calls[1].Params.Name != "delete_file" {
+		t.Fatalf("approved call should be forwarded: %+v", clients["fs"].calls)
+	}
+	if _, err := manager.Approvals().Decide(id, ApprovalDecision{Approve: true}); err != ErrApprovalNotFound {
+		t.Fatalf("resolved approval should be gone, got %v", err)
+	}
+}
+
+func TestApprovalDeniedAndExpiredCallsFail(t *testing.T) {
+	manager, session, clients, events := approvalSession(t, config.ApprovalConfig{TimeoutSeconds: 1},
+		config.ToolPolicyRule{Name: "review-writes", Tool: "db_query", When: []config.PolicyCondition{{Path: "$.sql", Op: config.PolicyOpMatches, Value: `(?i)^update`}}, Effect: config.PolicyEffectApprove},
+	)
+
+	// destructive 未开启时只有 approve 规则要求审批
+	if code := callTool(t, session, events, 1, "fs_delete_file", map[string]interface{}{"path": "/a"}); code != 0 {
+		t.Fatalf("delete_file should be forwarded without destructive approvals, got code %d", code)
+	}
+
+	sendRPC(t, session, 2, "tools/call", map[string]interface{}{"name": "db_query", "arguments": map[string]interface{}{"sql": "update users set admin = true"}})
+	id := waitApprovalNotice(t, events)
+	if _, err := manager.Approvals().Decide(id, ApprovalDecision{Approver: "admin-1", Reason: "not during the freeze"}); err != nil {
+		t.Fatal(err)
+	}
+	code, data := decodeApprovalResponse(t, events)
+	if code != ApprovalDeniedCode || data["status"] != ApprovalStatusDenied || data["reason"] != "not during the freeze" || data["approver"] != "admin-1" {
+		t.Fatalf("denied call should fail with the reason, got code %d data %+v", code, data)
+	}
+
+	sendRPC(t, session, 3, "tools/call", map[string]interface{}{"name": "db_query", "arguments": map[string]interface{}{"sql": "update users set admin = false"}})
+	waitApprovalNotice(t, events)
+	code, data = decodeApprovalResponse(t, events)
+	if code != ApprovalDeniedCode || data["status"] != ApprovalStatusExpired {
+		t.Fatalf("unanswered call should expire, got code %d data %+v", code, data)
+	}
+	if len(manager.Approvals().List()) != 0 || len(clients["db"].calls) != 0 {
+		t.Fatalf("denied and expired calls must not reach downstream")
+	}
+}
+
+func TestApprovalWithdrawnWhenClientDisconnects(t *testing.T) {
+	manager, session, clients, events := approvalSession(t, config.ApprovalConfig{Destructive: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	raw, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": map[string]interface{}{"name": "fs_delete_file", "arguments": map[string]interface{}{"path": "/a"}}})
+	if err := session.SendMessageContext(ctx, xlog.NewLogger("test-approval-cancel"), raw); err != nil {
+		t.Fatal(err)
+	}
+	id := waitApprovalNotice(t, events)
+	cancel()
+
+	code, data := decodeApprovalResponse(t, events)
+	if code != ApprovalDeniedCode || data["status"] != ApprovalStatusCancelled || data["reason"] != "client disconnected" {
+		t.Fatalf("disconnected call should be cancelled, got code %d data %+v", code, data)
+	}
+	if len(manager.Approvals().List()) != 0 {
+		t.Fatalf("cancelled call should be removed from the queue")
+	}
+	if _, err := manager.Approvals().Decide(id, ApprovalDecision{Approve: true, Approver: "admin-1"}); err != ErrApprovalNotFound {
+		t.Fatalf("cancelled call should no longer be approvable, got %v", err)
+	}
+	if len(clients["fs"].calls) != 0 {
+		t.Fatalf("cancelled call must not reach downstream")
+	}
+}
+
+func TestApprovalKeepaliveNoticesAreNotDeduplicated(t *testing.T) {
+	_, session, _, events := approvalSession(t, config.ApprovalConfig{Destructive: true})
+	p := session.approvals.hold(Approval{SessionID: session.Id, Tool: "fs_delete_file", Rule: ApprovalRuleDestructive})
+
+	session.sendApprovalNotice("", p)
+	first := waitApprovalNotice(t, events)
+	p.RequestedAt = p.RequestedAt.Add(-approvalKeepaliveInterval)
+	session.sendApprovalNotice("", p)
+	if second := waitApprovalNotice(t, events); second != first {
+		t.Fatalf("keepalive should refer to the same approval: %s != %s", second, first)
+	}
+}
@@ -0,0 +1,221 @@
+package sessions
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// CacheBypassMetaKey 为 tools/call 请求 _meta 中的字段，值为 true 时跳过缓存读取，
+// 下游返回的新结果仍会写入缓存。
+const CacheBypassMetaKey = "gateway/noCache"
+
+var (
+	globalCacheHits   atomic.Int64
+	globalCacheMisses atomic.Int64
+)
+
+// ToolCacheStats 是工具结果缓存的命中统计。
+type ToolCacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// GlobalToolCacheStats 返回进程内所有 workspace 的缓存命中统计。
+func GlobalToolCacheStats() ToolCacheStats {
+	return ToolCacheStats{Hits: globalCacheHits.Load(), Misses: globalCacheMisses.Load()}
+}
+
+// ToolCache 在 workspace 内跨 session 缓存下游服务的 tools/call 结果，
+// 按 (服务, 工具, 规范化 JSON 参数) 索引，每个服务独立配置 TTL 与容量。
+type ToolCache struct {
+	mu       sync.Mutex
+	services map[McpName]*serviceCache
+}
+
+type serviceCache struct {
+	cfg     config.ToolCacheConfig
+	tools   map[McpToolName]bool
+	entries map[string]*list.Element
+	lru     *list.List
+	hits    int64
+	misses  int64
+}
+
+type cacheEntry struct {
+	key       string
+	result    *mcp.CallToolResult
+	expiresAt time.Time
+}
+
+// NewToolCache 创建一个空的工具结果缓存。
+func NewToolCache() *ToolCache {
+	return &ToolCache{services: make(map[McpName]*serviceCache)}
+}
+
+// Configure 设置服务的缓存配置，配置变化时清空该服务已有的缓存；cfg 未开启时移除该服务。
+func (c *ToolCache) Configure(name McpName, cfg *config.ToolCacheConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !cfg.Enabled() {
+		delete(c.services, name)
+		return
+	}
+	next := cfg.WithDefaults()
+	if sc, ok := c.services[name]; ok && sameCacheConfig(sc.cfg, next) {
+		return
+	}
+	tools := make(map[McpToolName]bool, len(next.Tools))
+	for _, tool := range next.Tools {
+		tools[tool] = true
+	}
+	c.services[name] = &serviceCache{
+		cfg:     next,
+		tools:   tools,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Invalidate 丢弃服务的全部缓存结果，保留配置与统计，在服务重启、停止或删除后调用。
+func (c *ToolCache) Invalidate(name McpName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sc, ok := c.services[name]; ok {
+		sc.entries = make(map[string]*list.Element)
+		sc.lru.Init()
+	}
+}
+
+// Stats 返回每个已配置缓存的服务的统计信息。
+func (c *ToolCache) Stats() map[McpName]ToolCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[McpName]ToolCacheStats, len(c.services))
+	for name, sc := range c.services {
+		stats[name] = ToolCacheStats{Hits: sc.hits, Misses: sc.misses, Entries: len(sc.entries)}
+	}
+	return stats
+}
+
+// cacheable 判断工具调用是否走缓存：服务开启了缓存，且工具声明了 readOnlyHint 或在配置的工具列表中。
+func (c *ToolCache) cacheable(name McpName, tool mcp.Tool, known bool, toolName McpToolName) bool {
+	c.mu.Lock()
+	sc, ok := c.services[name]
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if sc.tools[toolName] {
+		return true
+	}
+	return known && tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint
+}
+
+func (c *ToolCache) get(name McpName, key string) (*mcp.CallToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sc, ok := c.services[name]
+	if !ok {
+		return nil, false
+	}
+	if elem, ok := sc.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			sc.lru.MoveToFront(elem)
+			sc.hits++
+			globalCacheHits.Add(1)
+			return entry.result, true
+		}
+		sc.lru.Remove(elem)
+		delete(sc.entries, key)
+	}
+	sc.misses++
+	globalCacheMisses.Add(1)
+	return nil, false
+}
+
+func (c *ToolCache) put(name McpName, key string, result *mcp.CallToolResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sc, ok := c.services[name]
+	if !ok || result == nil || result.IsError || contentSize(result.Content) > sc.cfg.MaxEntryBytes {
+		return
+	}
+	entry := &cacheEntry{key: key, result: result, expiresAt: time.Now().Add(sc.cfg.TTL())}
+	if elem, ok := sc.entries[key]; ok {
+		elem.Value = entry
+		sc.lru.MoveToFront(elem)
+		return
+	}
+	sc.entries[key] = sc.lru.PushFront(entry)
+	for sc.lru.Len() > sc.cfg.MaxEntries {
+		oldest := sc.lru.Back()
+		sc.lru.Remove(oldest)
+		delete(sc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func sameCacheConfig(a, b config.ToolCacheConfig) bool {
+	if a.TTLSeconds != b.TTLSeconds || a.MaxEntries != b.MaxEntries || a.MaxEntryBytes != b.MaxEntryBytes || len(a.Tools) != len(b.Tools) {
+		return false
+	}
+	for i := range a.Tools {
+		if a.Tools[i] != b.Tools[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// toolCacheKey 由工具名与规范化的参数 JSON 组成；encoding/json 对 map 的键排序，
+// 因此参数顺序不同的相同调用得到相同的键。
+func toolCacheKey(toolName McpToolName, args interface{}) (string, bool) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return toolName + "\x00" + string(data), true
+}
+
+func cacheBypassed(request mcp.CallToolRequest) bool {
+	if request.Params.Meta == nil {
+		return false
+	}
+	bypass, _ := request.Params.Meta.AdditionalFields[CacheBypassMetaKey].(bool)
+	return bypass
+}
+
+// callToolCached 调用下游工具，命中缓存时不访问下游。
+func (s *Session) callToolCached(ctx context.Context, cli client.MCPClient, mcpName McpName, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	cache := s.toolCache
+	if cache == nil {
+		return cli.CallTool(ctx, request)
+	}
+	tool, known := s.GetMcpTool(mcpName, request.Params.Name)
+	if !cache.cacheable(mcpName, tool, known, request.Params.Name) {
+		return cli.CallTool(ctx, request)
+	}
+	key, ok := toolCacheKey(request.Params.Name, request.Params.Arguments)
+	if !ok {
+		return cli.CallTool(ctx, request)
+	}
+	if !cacheBypassed(request) {
+		if result, hit := cache.get(mcpName, key); hit {
+			return result, nil
+		}
+	}
+	result, err := cli.CallTool(ctx, request)
+	if err == nil {
+		cache.put(mcpName, key, result)
+	}
+	return result, err
+}
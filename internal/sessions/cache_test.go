@@ -0,0 +1,87 @@
+package sessions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestReadOnlyToolResultsAreCached(t *testing.T) {
+	docs := &recordingClient{respond: func(req mcp.CallToolRequest) *mcp.CallToolResult {
+		return mcp.NewToolResultText("result of " + req.Params.Name)
+	}}
+	cache := NewToolCache()
+	cache.Configure("docs", &config.ToolCacheConfig{TTLSeconds: 60})
+
+	session := NewSession("cache-test")
+	defer session.Close()
+	session.toolCache = cache
+	session.attachClient("docs", docs, &mcp.InitializeResult{})
+	session.updateToolsMap("docs", &mcp.ListToolsResult{Tools: []mcp.Tool{
+		mcp.NewTool("lookup", mcp.WithReadOnlyHintAnnotation(true)),
+		mcp.NewTool("update"),
+	}})
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	call := func(id int, tool string, args string, meta map[string]interface{}) {
+		t.Helper()
+		params := map[string]interface{}{"name": "docs_" + tool, "arguments": json.RawMessage(args)}
+		if meta != nil {
+			params["_meta"] = meta
+		}
+		raw, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": "tools/call", "params": params})
+		if err := session.SendMessage(xlog.NewLogger("test-cache"), raw); err != nil {
+			t.Fatalf("call %d failed: %v", id, err)
+		}
+		waitEvent(t, events)
+	}
+
+	call(1, "lookup", `{"q":"go","page":1}`, nil)
+	call(2, "lookup", `{"page":1,"q":"go"}`, nil)
+	if len(docs.calls) != 1 {
+		t.Fatalf("identical arguments in a different order should hit the cache, got %d downstream calls", len(docs.calls))
+	}
+
+	call(3, "lookup", `{"q":"go","page":1}`, map[string]interface{}{CacheBypassMetaKey: true})
+	if len(docs.calls) != 2 {
+		t.Fatalf("the _meta bypass flag should skip the cache, got %d downstream calls", len(docs.calls))
+	}
+
+	cache.Invalidate("docs")
+	call(4, "lookup", `{"q":"go","page":1}`, nil)
+	call(5, "update", `{"q":"go"}`, nil)
+	call(6, "update", `{"q":"go"}`, nil)
+	if len(docs.calls) != 5 {
+		t.Fatalf("expected a miss after invalidation and no caching for non read-only tools, got %d downstream calls", len(docs.calls))
+	}
+
+	stats := cache.Stats()["docs"]
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Fatalf("unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestToolCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewToolCache()
+	cache.Configure("docs", &config.ToolCacheConfig{TTLSeconds: 60, MaxEntries: 2})
+	for _, key := range []string{"a", "b"} {
+		cache.put("docs", key, mcp.NewToolResultText(key))
+	}
+	cache.get("docs", "a")
+	cache.put("docs", "c", mcp.NewToolResultText("c"))
+
+	if _, ok := cache.get("docs", "b"); ok {
+		t.Fatal("least recently used entry should have been evicted")
+	}
+	if _, ok := cache.get("docs", "a"); !ok {
+		t.Fatal("recently used entry should be kept")
+	}
+	cache.put("docs", "err", mcp.NewToolResultError("boom"))
+	if _, ok := cache.get("docs", "err"); ok {
+		t.Fatal("error results must not be cached")
+	}
+}
@@ -0,0 +1,291 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// GatewayNamespace 是网关自身提供的工具（组合工具等）使用的名称前缀，
+// 与下游服务的 <服务名>_<工具名> 命名方式一致。
+const GatewayNamespace = "gateway"
+
+const (
+	// 组合工具中单个步骤调用的超时
+	compositeStepTimeout = 30 * time.Second
+	// ForEach 展开的最大次数，防止模板引用到意外的大数组
+	compositeMaxIterations = 50
+)
+
+var templateExpr = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// SetCompositeTools 设置 workspace 声明的组合工具，无效的声明会被跳过。
+func (m *SessionManager) SetCompositeTools(xl xlog.Logger, tools []config.CompositeToolConfig) {
+	valid := make(map[string]config.CompositeToolConfig, len(tools))
+	for _, tool := range tools {
+		if err := tool.Validate(); err != nil {
+			xl.Warnf("skip invalid composite tool: %v", err)
+			continue
+		}
+		valid[tool.Name] = tool
+	}
+	m.compositeTools = valid
+}
+
+// ToolAdmitter 在组合工具的步骤调用下游工具前执行与直接 tools/call 相同的准入检查，
+// 返回非 nil error 时该步骤失败且不调用下游。
+type ToolAdmitter func(ctx context.Context, tool string) error
+
+// SetToolAdmitter 设置组合工具步骤的准入检查，在 session 交给 client 之前调用；未设置时不检查。
+func (s *Session) SetToolAdmitter(admit ToolAdmitter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.admitTool = admit
+}
+
+// compositeToolDefs 返回组合工具在 tools/list 中的定义。
+func (s *Session) compositeToolDefs() []mcp.Tool {
+	tools := make([]mcp.Tool, 0, len(s.compositeTools))
+	for _, composite := range s.compositeTools {
+		if !s.toolAllowed(GatewayNamespace + "_" + composite.Name) {
+			continue
+		}
+		schema := composite.InputSchema
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		raw, err := json.Marshal(schema)
+		if err != nil {
+			continue
+		}
+		tools = append(tools, mcp.Tool{
+			Name:           GatewayNamespace + "_" + composite.Name,
+			Description:    fmt.Sprintf("[%s] %s", GatewayNamespace, composite.Description),
+			RawInputSchema: raw,
+		})
+	}
+	return tools
+}
+
+// runCompositeTool 在网关侧依次执行组合工具的各个步骤。步骤失败时返回 isError 结果，
+// 而不是 JSON-RPC 错误，与下游工具失败的表现保持一致。需要审批的步骤以 requestID
+// 向请求方发送等待通知。
+func (s *Session) runCompositeTool(ctx context.Context, xl xlog.Logger, requestID mcp.RequestId, name string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	composite, ok := s.compositeTools[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown gateway tool: %s", name)
+	}
+	xl = xlog.WithChildName("composite-"+name, xl)
+
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	steps := make(map[string]interface{}, len(composite.Steps))
+	scope := map[string]interface{}{"input": args, "steps": steps}
+
+	var last interface{}
+	for _, step := range composite.Steps {
+		value, err := s.runCompositeStep(ctx, xl, requestID, step, scope)
+		if err != nil {
+			xl.Warnf("composite step %s failed: %v", step.ID, err)
+			return mcp.NewToolResultError(fmt.Sprintf("step %s failed: %v", step.ID, err)), nil
+		}
+		steps[step.ID] = value
+		last = value
+	}
+
+	if composite.Output != "" {
+		last = renderTemplate(composite.Output, scope)
+	}
+	return mcp.NewToolResultText(stringifyValue(last)), nil
+}
+
+func (s *Session) runCompositeStep(ctx context.Context, xl xlog.Logger, requestID mcp.RequestId, step config.CompositeStepConfig, scope map[string]interface{}) (interface{}, error) {
+	if step.ForEach == "" {
+		return s.callCompositeStep(ctx, xl, requestID, step, renderArguments(step.Arguments, scope))
+	}
+
+	items, ok := lookupPath(scope, strings.Trim(strings.TrimSpace(step.ForEach), "{} "))
+	if !ok {
+		return nil, fmt.Errorf("forEach path %q not found", step.ForEach)
+	}
+	list, ok := items.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("forEach path %q is not an array", step.ForEach)
+	}
+	if len(list) > compositeMaxIterations {
+		return nil, fmt.Errorf("forEach path %q has %d items, limit is %d", step.ForEach, len(list), compositeMaxIterations)
+	}
+
+	results := make([]interface{}, 0, len(list))
+	for i, item := range list {
+		iterScope := make(map[string]interface{}, len(scope)+2)
+		for k, v := range scope {
+			iterScope[k] = v
+		}
+		iterScope["item"] = item
+		iterScope["index"] = i
+		value, err := s.callCompositeStep(ctx, xl, requestID, step, renderArguments(step.Arguments, iterScope))
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		results = append(results, value)
+	}
+	return results, nil
+}
+
+// callCompositeStep 调用步骤对应的下游工具，并把结果转换成可供后续模板引用的值。
+// 需要审批的步骤在此等待决定；每个步骤都单独经过准入检查，与直接调用该工具一致。
+func (s *Session) callCompositeStep(ctx context.Context, xl xlog.Logger, requestID mcp.RequestId, step config.CompositeStepConfig, args map[string]interface{}) (interface{}, error) {
+	rewritten, rule, err := s.applyToolPolicies(xl, step.Tool, args)
+	if err != nil {
+		return nil, err
+	}
+	if rewritten != nil {
+		args = rewritten
+	}
+	if approval := s.approvalRule(xl, step.Tool, rule); approval != "" {
+		if err := s.waitForApproval(ctx, xl, requestID, step.Tool, approval, args); err != nil {
+			return nil, err
+		}
+	}
+	names := strings.SplitN(step.Tool, "_", 2)
+	mcpName, toolName := names[0], names[1]
+
+	request := mcp.CallToolRequest{}
+	request.Method = string(mcp.MethodToolsCall)
+	request.Params.Name = toolName
+	request.Params.Arguments = args
+
+	ctx, cancel := context.WithTimeout(ctx, compositeStepTimeout)
+	defer cancel()
+
+	s.mu.RLock()
+	admit := s.admitTool
+	s.mu.RUnlock()
+	if admit != nil {
+		if err := admit(ctx, step.Tool); err != nil {
+			return nil, err
+		}
+	}
+
+	tool, known := s.GetMcpTool(mcpName, toolName)
+	retrySafe := known && tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint
+	raw, err := s.callWithReconnect(ctx, xl, mcpName, retrySafe, func(ctx context.Context, cli client.MCPClient) (interface{}, error) {
+		return s.callToolCached(ctx, cli, mcpName, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := raw.(*mcp.CallToolResult)
+	value := toolResultValue(result)
+	if result.IsError {
+		return nil, fmt.Errorf("%s returned error: %s", step.Tool, stringifyValue(value))
+	}
+	return value, nil
+}
+
+// toolResultValue 提取工具结果中的文本；文本是 JSON 时解析为结构化值，便于模板取字段。
+func toolResultValue(result *mcp.CallToolResult) interface{} {
+	texts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	joined := strings.Join(texts, "\n")
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(joined), &parsed); err == nil {
+		return parsed
+	}
+	return joined
+}
+
+func renderArguments(args map[string]interface{}, scope map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		out[k] = renderValue(v, scope)
+	}
+	return out
+}
+
+func renderValue(v interface{}, scope map[string]interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return renderTemplate(val, scope)
+	case map[string]interface{}:
+		return renderArguments(val, scope)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = renderValue(item, scope)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// renderTemplate 替换字符串中的 {{ path }}。整个字符串就是一个模板时返回原始值。
+func renderTemplate(tpl string, scope map[string]interface{}) interface{} {
+	if m := templateExpr.FindStringSubmatch(tpl); m != nil && m[0] == strings.TrimSpace(tpl) {
+		value, _ := lookupPath(scope, m[1])
+		return value
+	}
+	return templateExpr.ReplaceAllStringFunc(tpl, func(expr string) string {
+		path := templateExpr.FindStringSubmatch(expr)[1]
+		value, ok := lookupPath(scope, path)
+		if !ok {
+			return ""
+		}
+		return stringifyValue(value)
+	})
+}
+
+// lookupPath 按点分路径在 scope 中取值，数字段作为数组下标。
+func lookupPath(scope map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = scope
+	for _, part := range strings.Split(strings.TrimSpace(path), ".") {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			cur = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func stringifyValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
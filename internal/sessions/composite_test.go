@@ -0,0 +1,165 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// recordingClient 记录收到的工具调用，并按工具名返回预设结果。
+type recordingClient struct {
+	client.MCPClient
+	mu      sync.Mutex
+	calls   []mcp.CallToolRequest
+	respond func(req mcp.CallToolRequest) *mcp.CallToolResult
+	tools   []mcp.Tool
+}
+
+func (c *recordingClient) ListTools(context.Context, mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	return &mcp.ListToolsResult{Tools: c.tools}, nil
+}
+
+func (c *recordingClient) CallTool(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, req)
+	c.mu.Unlock()
+	return c.respond(req), nil
+}
+
+func (c *recordingClient) Close() error { return nil }
+
+func issuesComposite() config.CompositeToolConfig {
+	return config.CompositeToolConfig{
+		Name:        "triage",
+		Description: "search, fetch and save issues",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"query": map[string]interface{}{"type": "string"}},
+		},
+		Steps: []config.CompositeStepConfig{
+			{ID: "search", Tool: "github_search_issues", Arguments: map[string]interface{}{"q": "{{ input.query }}", "limit": 2}},
+			{ID: "fetch", Tool: "github_get_issue", ForEach: "steps.search.items", Arguments: map[string]interface{}{"number": "{{item.number}}"}},
+			{ID: "save", Tool: "fs_write_file", Arguments: map[string]interface{}{
+				"path":    "triage-{{ input.query }}.md",
+				"content": "first={{steps.fetch.0.title}} all={{steps.fetch}}",
+			}},
+		},
+		Output: "saved {{steps.save}}",
+	}
+}
+
+func TestSessionRunsCompositeToolAcrossServices(t *testing.T) {
+	github := &recordingClient{respond: func(req mcp.CallToolRequest) *mcp.CallToolResult {
+		if req.Params.Name == "search_issues" {
+			return mcp.NewToolResultText(`{"items":[{"number":1},{"number":2}]}`)
+		}
+		args := req.GetArguments()
+		return mcp.NewToolResultText(fmt.Sprintf(`{"title":"issue-%v"}`, args["number"]))
+	}}
+	fs := &recordingClient{respond: func(req mcp.CallToolRequest) *mcp.CallToolResult {
+		return mcp.NewToolResultText("ok")
+	}}
+
+	manager := NewSessionManager(nil, CleanupConfig{})
+	manager.SetCompositeTools(xlog.NewLogger("test-composite"), []config.CompositeToolConfig{
+		issuesComposite(),
+		{Name: "broken"},
+	})
+	session := NewSession("composite-test")
+	defer session.Close()
+	session.compositeTools = manager.compositeTools
+	session.attachClient("github", github, &mcp.InitializeResult{})
+	session.attachClient("fs", fs, &mcp.InitializeResult{})
+
+	defs := session.compositeToolDefs()
+	if len(defs) != 1 || defs[0].Name != "gateway_triage" {
+		t.Fatalf("invalid composite tools should be skipped, got %+v", defs)
+	}
+
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+	raw, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "id": 1, "method": "tools/call",
+		"params": map[string]interface{}{"name": "gateway_triage", "arguments": map[string]interface{}{"query": "bug"}},
+	})
+	if err := session.SendMessage(xlog.NewLogger("test-composite"), raw); err != nil {
+		t.Fatalf("composite call failed: %v", err)
+	}
+
+	evt := waitEvent(t, events)
+	if !strings.Contains(evt.Data, "saved ok") {
+		t.Fatalf("unexpected composite result: %s", evt.Data)
+	}
+	if len(github.calls) != 3 {
+		t.Fatalf("expected search + 2 fetches, got %d calls", len(github.calls))
+	}
+	if got := github.calls[0].GetArguments(); got["q"] != "bug" || got["limit"] != 2 {
+		t.Fatalf("unexpected search arguments: %+v", got)
+	}
+	if got := github.calls[2].GetArguments()["number"]; got != float64(2) {
+		t.Fatalf("forEach should keep the item's value type, got %#v", got)
+	}
+	saved := fs.calls[0].GetArguments()
+	if saved["path"] != "triage-bug.md" || saved["content"] != `first=issue-1 all=[{"title":"issue-1"},{"title":"issue-2"}]` {
+		t.Fatalf("unexpected templated arguments: %+v", saved)
+	}
+}
+
+func TestSessionCompositeToolStopsOnStepError(t *testing.T) {
+	github := &recordingClient{respond: func(req mcp.CallToolRequest) *mcp.CallToolResult {
+		return mcp.NewToolResultError("rate limited")
+	}}
+	session := NewSession("composite-error-test")
+	defer session.Close()
+	session.compositeTools = map[string]config.CompositeToolConfig{"triage": issuesComposite()}
+	session.attachClient("github", github, &mcp.InitializeResult{})
+
+	result, err := session.runCompositeTool(context.Background(), xlog.NewLogger("test-composite-error"), mcp.NewRequestId(nil), "triage", map[string]interface{}{"query": "bug"})
+	if err != nil {
+		t.Fatalf("step failures should be reported as tool errors: %v", err)
+	}
+	if !result.IsError || len(github.calls) != 1 {
+		t.Fatalf("expected isError after first step, result=%+v calls=%d", result, len(github.calls))
+	}
+}
+
+func TestSessionCompositeStepsAreAdmittedIndividually(t *testing.T) {
+	github := &recordingClient{respond: func(req mcp.CallToolRequest) *mcp.CallToolResult {
+		if req.Params.Name == "search_issues" {
+			return mcp.NewToolResultText(`{"items":[{"number":1},{"number":2}]}`)
+		}
+		return mcp.NewToolResultText(`{"title":"issue"}`)
+	}}
+	session := NewSession("composite-admit-test")
+	defer session.Close()
+	session.compositeTools = map[string]config.CompositeToolConfig{"triage": issuesComposite()}
+	session.attachClient("github", github, &mcp.InitializeResult{})
+
+	var admitted []string
+	session.SetToolAdmitter(func(_ context.Context, tool string) error {
+		admitted = append(admitted, tool)
+		if len(admitted) > 2 {
+			return fmt.Errorf("rate limit github exceeded")
+		}
+		return nil
+	})
+
+	result, err := session.runCompositeTool(context.Background(), xlog.NewLogger("test-composite-admit"), mcp.NewRequestId(nil), "triage", map[string]interface{}{"query": "bug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "rate limit github exceeded") {
+		t.Fatalf("rejected step should fail the composite tool: %+v", result)
+	}
+	if strings.Join(admitted, ",") != "github_search_issues,github_get_issue,github_get_issue" || len(github.calls) != 2 {
+		t.Fatalf("every step call should be admitted before it is sent: admitted=%v calls=%d", admitted, len(github.calls))
+	}
+}
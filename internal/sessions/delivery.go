@@ -0,0 +1,242 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+// 订阅者缓冲区写满时的处理策略
+const (
+	// OverflowDropNotification 丢弃无需应答的通知；响应或 server→client 请求
+	// 无法送达时仍断开订阅者，由客户端携带 Last-Event-ID 重连补发
+	OverflowDropNotification = "drop_notification"
+	// OverflowDisconnect 任何事件溢出都直接断开订阅者
+	OverflowDisconnect = "disconnect"
+
+	// 每个订阅者的默认事件缓冲大小
+	defaultEventBufferSize = 100
+)
+
+// eventSubscriber 是一个 SSE / GET /stream 连接在 session 上的订阅。
+type eventSubscriber struct {
+	ch chan SessionMsg
+}
+
+// DeliveryStats 描述 session 的事件投递情况。
+type DeliveryStats struct {
+	Subscribers         int   `json:"subscribers"`
+	PendingResponses    int   `json:"pending_responses"`
+	DroppedEvents       int64 `json:"dropped_events"`
+	OverflowDisconnects int64 `json:"overflow_disconnects"`
+}
+
+// 全局投递计数，跨 session 累计
+var (
+	totalDroppedEvents       atomic.Int64
+	totalOverflowDisconnects atomic.Int64
+)
+
+// GlobalDeliveryStats 返回进程内所有 session 累计的丢弃事件数与溢出断开数。
+func GlobalDeliveryStats() DeliveryStats {
+	return DeliveryStats{
+		DroppedEvents:       totalDroppedEvents.Load(),
+		OverflowDisconnects: totalOverflowDisconnects.Load(),
+	}
+}
+
+// DeliveryStats 返回当前 session 的订阅者数量与丢弃统计。
+func (s *Session) DeliveryStats() DeliveryStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pending := 0
+	for _, waiters := range s.pendingResponses {
+		pending += len(waiters)
+	}
+	return DeliveryStats{
+		Subscribers:         len(s.eventChans),
+		PendingResponses:    pending,
+		DroppedEvents:       s.droppedEvents.Load(),
+		OverflowDisconnects: s.overflowDisconnects.Load(),
+	}
+}
+
+// AwaitResponse 登记一个等待指定 JSON-RPC id 响应的请求方。匹配的响应直接投递到
+// 返回的通道，不再进入广播流；session 关闭时通道被关闭。调用方结束等待后需调用 cancel。
+func (s *Session) AwaitResponse(id json.RawMessage) (<-chan SessionMsg, func()) {
+	key := string(bytes.TrimSpace(id))
+	ch := make(chan SessionMsg, 1)
+
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	s.pendingResponses[key] = append(s.pendingResponses[key], ch)
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.removeWaiterLocked(key, ch)
+	}
+	return ch, cancel
+}
+
+// WatchRequest 登记一个接收指定 JSON-RPC id 请求中间通知（进度、日志）的请求方，
+// 登记后这些通知不再进入广播流；session 关闭时通道被关闭。调用方结束后需调用 cancel。
+func (s *Session) WatchRequest(id json.RawMessage) (<-chan SessionMsg, func()) {
+	key := string(bytes.TrimSpace(id))
+	ch := make(chan SessionMsg, requestStreamBufferSize)
+
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	s.requestStreams[key] = ch
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.requestStreams[key] == ch {
+			delete(s.requestStreams, key)
+		}
+	}
+	return ch, cancel
+}
+
+// sendRequestNotification 把属于某个请求的中间通知投递给 WatchRequest 登记的请求方，
+// 没有登记时照常广播。请求方缓冲已满时丢弃通知，不阻塞下游。
+func (s *Session) sendRequestNotification(requestKey string, event SessionMsg) {
+	s.mu.Lock()
+	if ch, ok := s.requestStreams[requestKey]; ok {
+		select {
+		case ch <- event:
+		default:
+			s.droppedEvents.Add(1)
+			totalDroppedEvents.Add(1)
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.SendEvent(event)
+}
+
+// routeResponseLocked 把 JSON-RPC 响应直接交给等待该 id 的请求方，返回是否已投递。
+// 同一 id 有多个等待者时按登记顺序投递。调用方需持有写锁。
+func (s *Session) routeResponseLocked(event SessionMsg) bool {
+	if len(s.pendingResponses) == 0 || (event.Event != "" && event.Event != "message") {
+		return false
+	}
+	key, ok := responseKey(event.Data)
+	if !ok {
+		return false
+	}
+	waiters := s.pendingResponses[key]
+	if len(waiters) == 0 {
+		return false
+	}
+	ch := waiters[0]
+	s.removeWaiterLocked(key, ch)
+	// 缓冲为 1 且每个等待者只会收到一条响应，不会阻塞
+	ch <- event
+	return true
+}
+
+func (s *Session) removeWaiterLocked(key string, ch chan SessionMsg) {
+	waiters := s.pendingResponses[key]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(s.pendingResponses, key)
+		return
+	}
+	s.pendingResponses[key] = waiters
+}
+
+// broadcastEventLocked 把事件写入每个订阅者的有界缓冲。缓冲已满时按溢出策略
+// 丢弃事件或断开订阅者，不会阻塞发送方。调用方需持有写锁。
+func (s *Session) broadcastEventLocked(event SessionMsg, xl xlog.Logger) int {
+	sentCount := 0
+	// 仅在发生溢出时才解析消息，避免每个订阅者重复解析
+	droppable, classified := false, false
+
+	alive := s.eventChans[:0]
+	for _, sub := range s.eventChans {
+		select {
+		case sub.ch <- event:
+			sentCount++
+			alive = append(alive, sub)
+			continue
+		default:
+		}
+
+		s.droppedEvents.Add(1)
+		totalDroppedEvents.Add(1)
+		if !classified {
+			droppable, classified = isDroppableEvent(event), true
+		}
+		if s.cleanupConfig.OverflowPolicy == OverflowDropNotification && droppable {
+			xl.Warnf("Subscriber buffer full, dropping notification event %d", event.ID)
+			alive = append(alive, sub)
+			continue
+		}
+
+		// 断开慢订阅者，客户端可以用 Last-Event-ID 重连并从重放缓冲补发
+		xl.Warnf("Subscriber buffer full, disconnecting slow subscriber at event %d", event.ID)
+		s.overflowDisconnects.Add(1)
+		totalOverflowDisconnects.Add(1)
+		close(sub.ch)
+	}
+	// 清理被截断部分的引用，便于 GC
+	for i := len(alive); i < len(s.eventChans); i++ {
+		s.eventChans[i] = nil
+	}
+	s.eventChans = alive
+	return sentCount
+}
+
+// responseKey 解析 JSON-RPC 响应的 id，非响应消息返回 false。
+func responseKey(data string) (string, bool) {
+	var peek struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &peek); err != nil {
+		return "", false
+	}
+	id := bytes.TrimSpace(peek.ID)
+	if peek.Method != "" || len(id) == 0 || bytes.Equal(id, []byte("null")) {
+		return "", false
+	}
+	if len(peek.Result) == 0 && len(peek.Error) == 0 {
+		return "", false
+	}
+	return string(id), true
+}
+
+// isDroppableEvent 判断事件是否为可丢弃的通知（有 method 且没有 id）。
+func isDroppableEvent(event SessionMsg) bool {
+	var peek struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal([]byte(event.Data), &peek); err != nil {
+		return false
+	}
+	id := bytes.TrimSpace(peek.ID)
+	return peek.Method != "" && (len(id) == 0 || bytes.Equal(id, []byte("null")))
+}
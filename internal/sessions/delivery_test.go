@@ -0,0 +1,103 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSessionRoutesResponseToWaiterWithoutBroadcast(t *testing.T) {
+	session := NewSession("routing-test")
+	defer session.Close()
+
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+	resp, cancel := session.AwaitResponse(json.RawMessage(` "req-1" `))
+	defer cancel()
+
+	session.sendSuccessResponse("req-1", map[string]string{"ok": "true"})
+
+	select {
+	case evt := <-resp:
+		if key, _ := responseKey(evt.Data); key != `"req-1"` {
+			t.Fatalf("unexpected routed response: %s", evt.Data)
+		}
+	default:
+		t.Fatal("response should be delivered to the waiter")
+	}
+	select {
+	case evt := <-events:
+		t.Fatalf("routed response must not be broadcast, got %s", evt.Data)
+	default:
+	}
+
+	// 没有等待者的响应照常广播（例如 /sse + /message）
+	session.sendSuccessResponse(2, map[string]string{"ok": "true"})
+	select {
+	case <-events:
+	default:
+		t.Fatal("unrouted response should be broadcast")
+	}
+}
+
+func TestSessionAwaitResponseClosedOnSessionClose(t *testing.T) {
+	session := NewSession("routing-close-test")
+	resp, cancel := session.AwaitResponse(json.RawMessage(`1`))
+	defer cancel()
+
+	session.Close()
+	if _, ok := <-resp; ok {
+		t.Fatal("waiter channel should be closed with the session")
+	}
+}
+
+func fillSubscriber(t *testing.T, session *Session, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		session.SendEvent(SessionMsg{Event: "message", Data: fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"n":%d}}`, i)})
+	}
+}
+
+func TestSessionOverflowDropsNotifications(t *testing.T) {
+	session := newSession("overflow-drop", CleanupConfig{EventBufferSize: 2})
+	defer session.Close()
+	events := session.GetEventChan()
+
+	fillSubscriber(t, session, 3)
+	stats := session.DeliveryStats()
+	if stats.DroppedEvents != 1 || stats.OverflowDisconnects != 0 || stats.Subscribers != 1 {
+		t.Fatalf("unexpected stats after notification overflow: %+v", stats)
+	}
+
+	// 响应无法丢弃：缓冲已满时断开订阅者
+	session.sendSuccessResponse(9, map[string]string{"ok": "true"})
+	stats = session.DeliveryStats()
+	if stats.OverflowDisconnects != 1 || stats.Subscribers != 0 {
+		t.Fatalf("expected slow subscriber to be disconnected: %+v", stats)
+	}
+	for range events {
+	}
+}
+
+func TestSessionOverflowDisconnectPolicy(t *testing.T) {
+	session := newSession("overflow-disconnect", CleanupConfig{EventBufferSize: 1, OverflowPolicy: OverflowDisconnect})
+	defer session.Close()
+	slow := session.GetEventChan()
+	fast, closeFast := session.GetEventChanWithCloser()
+	defer closeFast()
+
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"jsonrpc":"2.0","method":"notifications/a"}`})
+	<-fast
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"jsonrpc":"2.0","method":"notifications/b"}`})
+
+	if stats := session.DeliveryStats(); stats.OverflowDisconnects != 1 || stats.Subscribers != 1 {
+		t.Fatalf("expected only the slow subscriber to be disconnected: %+v", stats)
+	}
+	<-slow
+	if _, ok := <-slow; ok {
+		t.Fatal("slow subscriber channel should be closed")
+	}
+	if evt := <-fast; evt.ID != 2 {
+		t.Fatalf("fast subscriber should still receive events, got %+v", evt)
+	}
+}
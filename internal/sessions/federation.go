@@ -0,0 +1,30 @@
+package sessions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GatewayServerName 是网关在 initialize 结果中返回的 serverInfo.name，虚拟服务器为
+// "mcp-gateway/<name>"；下游返回该名称时说明它是另一个网关。
+const GatewayServerName = "mcp-gateway"
+
+// isFederatedLocked 返回下游是否为另一个 mcp-gateway，调用方需持有 s.mu。
+func (s *Session) isFederatedLocked(mcpName McpName) bool {
+	result := s.mcpinitializeResults[mcpName]
+	if result == nil {
+		return false
+	}
+	name := result.ServerInfo.Name
+	return name == GatewayServerName || strings.HasPrefix(name, GatewayServerName+"/")
+}
+
+// namespacedDescriptionLocked 返回聚合后带服务标签的工具描述。远端网关的工具名已带远端服务前缀，
+// 描述以 "[远端服务] " 开头，这里合并为 "[服务/远端服务] "，而不是再叠加一层标签。
+// 调用方需持有 s.mu。
+func (s *Session) namespacedDescriptionLocked(mcpName McpName, description string) string {
+	if s.isFederatedLocked(mcpName) && strings.HasPrefix(description, "[") && strings.Contains(description, "] ") {
+		return "[" + mcpName + "/" + description[1:]
+	}
+	return fmt.Sprintf("[%s] %s", mcpName, description)
+}
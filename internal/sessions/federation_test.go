@@ -0,0 +1,48 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+)
+
+func TestNamespacedDescriptionMergesFederatedTags(t *testing.T) {
+	s := NewSession("federation-test")
+	defer s.Close()
+	s.setInitializeResultForTest("eu", &mcp.InitializeResult{ServerInfo: mcp.Implementation{Name: GatewayServerName}})
+	s.setInitializeResultForTest("fs", &mcp.InitializeResult{ServerInfo: mcp.Implementation{Name: "filesystem"}})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if got := s.namespacedDescriptionLocked("eu", "[github] Create an issue"); got != "[eu/github] Create an issue" {
+		t.Fatalf("federated description: %q", got)
+	}
+	if got := s.namespacedDescriptionLocked("eu", "plain"); got != "[eu] plain" {
+		t.Fatalf("untagged federated description: %q", got)
+	}
+	if got := s.namespacedDescriptionLocked("fs", "[note] Read a file"); got != "[fs] [note] Read a file" {
+		t.Fatalf("non-gateway description should keep its text: %q", got)
+	}
+}
+
+func TestDownstreamSpecForGatewayService(t *testing.T) {
+	svc := runtime.NewMcpService("eu", config.MCPServerConfig{
+		Type:            config.ServiceTypeGateway,
+		Workspace:       "default",
+		URL:             "https://eu.gw.example/",
+		RemoteWorkspace: "team a",
+		Env:             map[string]string{config.GatewayAPIKeyEnv: "gw-key"},
+	}, runtime.NewPortManager())
+	svc.Status = runtime.Running
+
+	spec := downstreamSpecFor(svc)
+	if spec.Protocol != downstreamProtocolStreamHTTP || spec.URL != "https://eu.gw.example/w/team%20a/stream" {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	if spec.Headers["Authorization"] != "Bearer gw-key" {
+		t.Fatalf("gateway api key should be sent as bearer token: %v", spec.Headers)
+	}
+}
@@ -3,13 +3,23 @@ package sessions
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
 
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
 )
 
+const (
+	// 订阅失败服务的首次重试间隔与退避上限
+	subscriptionRetryInterval    = 2 * time.Second
+	subscriptionMaxRetryInterval = 1 * time.Minute
+)
+
 // ServiceLister 是 SessionManager 向外查询"当前可用 MCP 服务"的回调。
 // 由调用方（通常是 workspaces.WorkSpace）提供，用于在会话建立时迭代
 // 订阅每个下游 MCP 的 SSE 事件流。采用回调解耦是为了避免 sessions 包
@@ -22,6 +32,29 @@ type SessionManager struct {
 	sessionsMutex sync.RWMutex
 	listServices  ServiceLister
 	sessionConfig CleanupConfig
+	// 订阅失败服务的后台重试间隔（指数退避）
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	// pool 为 nil 时每个 session 独占下游连接
+	pool *ClientPool
+	// toolCache 在 workspace 内跨 session 缓存只读工具的调用结果
+	toolCache *ToolCache
+	// compositeTools workspace 声明的组合工具，按名称索引
+	compositeTools map[string]config.CompositeToolConfig
+	// lazyTools 新建 session 默认启用 lazy 工具模式
+	lazyTools bool
+	// outputPolicy 新建 session 的工具结果大小限制
+	outputPolicy config.OutputPolicyConfig
+	// virtualServers workspace 声明的虚拟服务器，按名称索引
+	virtualServers map[string]*virtualServer
+	// policies workspace 的工具参数策略，与 session 共享
+	policies *toolPolicySet
+	// approvals workspace 内等待审批的工具调用，与 session 共享
+	approvals *ApprovalQueue
+	// redactResult 非空时遮盖返回给 client 的工具结果文本
+	redactResult func(string) string
+	// dialDownstream 建立独占下游连接，测试中可替换
+	dialDownstream func(xl xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error)
 }
 
 // NewSessionManager 构造一个 SessionManager。
@@ -32,6 +65,49 @@ func NewSessionManager(listServices ServiceLister, cleanupConfig CleanupConfig)
 		listServices:  listServices,
 		sessions:      make(map[string]*Session),
 		sessionConfig: normalizeCleanupConfig(cleanupConfig),
+		toolCache:     NewToolCache(),
+		policies:      &toolPolicySet{},
+		approvals:     newApprovalQueue(),
+
+		retryInterval:    subscriptionRetryInterval,
+		maxRetryInterval: subscriptionMaxRetryInterval,
+		dialDownstream:   dialDownstream,
+	}
+}
+
+// SetClientPool 启用下游连接复用。声明为有状态（Stateful）的服务不受影响。
+func (m *SessionManager) SetClientPool(pool *ClientPool) {
+	m.pool = pool
+}
+
+// InvalidateService 丢弃某个服务的共享连接与缓存结果，在服务重启、停止或删除后调用。
+func (m *SessionManager) InvalidateService(name McpName) {
+	if m.pool != nil {
+		m.pool.Invalidate(name)
+	}
+	m.toolCache.Invalidate(name)
+}
+
+// ToolCacheStats 返回每个开启了结果缓存的服务的命中统计。
+func (m *SessionManager) ToolCacheStats() map[McpName]ToolCacheStats {
+	return m.toolCache.Stats()
+}
+
+// PoolStats 返回共享连接的统计信息；未启用连接复用时返回 nil。
+func (m *SessionManager) PoolStats() map[McpName]map[string]int {
+	if m.pool == nil {
+		return nil
+	}
+	return m.pool.Stats()
+}
+
+// Close 关闭所有 session 以及共享连接池。
+func (m *SessionManager) Close(xl xlog.Logger) {
+	for _, session := range m.GetAllSessions(xl) {
+		_ = m.CloseSession(xl, session.Id)
+	}
+	if m.pool != nil {
+		m.pool.Close()
 	}
 }
 
@@ -48,6 +124,11 @@ func (m *SessionManager) GetSession(_ xlog.Logger, sessionId string) (*Session,
 
 // CreateSession creates a new session and subscribes it to every running MCP service.
 func (m *SessionManager) CreateSession(xl xlog.Logger) (*Session, error) {
+	return m.createSession(xl, nil)
+}
+
+// createSession 创建 session 并订阅运行中的服务；virtual 非空时只订阅其所列的服务。
+func (m *SessionManager) createSession(xl xlog.Logger, virtual *virtualServer) (*Session, error) {
 	session := newSession(uuid.New().String(), m.sessionConfig)
 	if m.existsSession(session.Id) {
 		xl.Errorf("session %s already exists", session.Id)
@@ -60,28 +141,45 @@ func (m *SessionManager) CreateSession(xl xlog.Logger) (*Session, error) {
 		m.CloseSession(xl, sessionId)
 	})
 
-	runningServices := 0
+	session.SetRedialer(m.redial)
+	session.retryFailed = func(xl xlog.Logger) { m.startRetry(xl, session) }
+	session.compositeTools = m.compositeTools
+	session.SetLazyTools(m.lazyTools)
+	session.outputPolicy = m.outputPolicy
+	session.toolCache = m.toolCache
+	session.virtual = virtual
+	session.policies = m.policies
+	session.approvals = m.approvals
+	session.redactResult = m.redactResult
+
+	// 单个下游订阅失败不影响整个 session：记录失败状态并在后台重试，
+	// 只有所有运行中的服务都失败时才认为创建失败。
+	runningServices, connected := 0, 0
 	for _, mcpService := range m.listServices() {
+		if virtual != nil && !virtual.includesService(mcpService.Name) {
+			continue
+		}
 		if mcpService.GetStatus() != runtime.Running {
 			xl.Warnf("service %s is not running", mcpService.Name)
 			continue
 		}
 		runningServices++
 
-		headers := downstreamAuthHeaders(mcpService)
-		var err error
-		if mcpService.IsSSE() && mcpService.Config.GatewayProtocol != "streamhttp" {
-			err = session.SubscribeSSE(xl, mcpService.Name, mcpService.GetSSEUrl(), headers)
-		} else {
-			err = session.SubscribeStreamHTTP(xl, mcpService.Name, mcpService.GetMessageUrl(), headers)
-		}
+		err := m.connect(xl, session, mcpService)
+		session.recordSubscription(mcpService.Name, err)
 		if err != nil {
 			xl.Errorf("failed to subscribe to service %s: %v", mcpService.Name, err)
-			return nil, fmt.Errorf("failed to subscribe mcpServer[%s]", mcpService.Name)
+			continue
 		}
+		connected++
 	}
-	if runningServices > 0 && !session.IsReady() {
-		return nil, fmt.Errorf("create session %s failed", session.Id)
+	if runningServices > 0 && connected == 0 {
+		session.Close()
+		return nil, fmt.Errorf("create session %s failed: no service could be subscribed", session.Id)
+	}
+	if session.IsDegraded() {
+		xl.Warnf("session %s created in degraded mode, failed services: %v", session.Id, session.FailedSubscriptions())
+		m.startRetry(xl, session)
 	}
 	m.sessionsMutex.Lock()
 	m.sessions[session.Id] = session
@@ -89,11 +187,120 @@ func (m *SessionManager) CreateSession(xl xlog.Logger) (*Session, error) {
 	return session, nil
 }
 
+// connect 为 session 建立到单个下游服务的连接，优先复用连接池。
+func (m *SessionManager) connect(xl xlog.Logger, session *Session, mcpService *runtime.McpService) error {
+	cli, result, err := m.dial(xl, mcpService)
+	if err != nil {
+		return err
+	}
+	session.attachClient(mcpService.Name, cli, result)
+	return nil
+}
+
+func (m *SessionManager) dial(xl xlog.Logger, mcpService *runtime.McpService) (client.MCPClient, *mcp.InitializeResult, error) {
+	m.toolCache.Configure(mcpService.Name, mcpService.Config.Cache)
+	spec := downstreamSpecFor(mcpService)
+	if m.pool != nil && !mcpService.Config.Stateful {
+		return m.pool.Acquire(xl, spec)
+	}
+	return m.dialDownstream(xl, spec)
+}
+
+// redial 是 session 的 Redialer：按服务名查找最新的服务配置（重启后端口可能变化）重新建连。
+func (m *SessionManager) redial(xl xlog.Logger, mcpName McpName, dead client.MCPClient) (client.MCPClient, *mcp.InitializeResult, error) {
+	evictClient(dead)
+	for _, mcpService := range m.listServices() {
+		if mcpService.Name != mcpName {
+			continue
+		}
+		if mcpService.GetStatus() != runtime.Running {
+			return nil, nil, fmt.Errorf("service %s is not running", mcpName)
+		}
+		return m.dial(xl, mcpService)
+	}
+	return nil, nil, fmt.Errorf("service %s not found", mcpName)
+}
+
+// startRetry 启动 session 的后台重试，同一 session 同时只有一个重试协程。
+func (m *SessionManager) startRetry(xl xlog.Logger, session *Session) {
+	if session.retrying.CompareAndSwap(false, true) {
+		go m.retryFailedSubscriptions(xl, session)
+	}
+}
+
+// retryFailedSubscriptions 以指数退避在后台重试订阅失败的服务，直到全部成功或 session 关闭。
+func (m *SessionManager) retryFailedSubscriptions(xl xlog.Logger, session *Session) {
+	defer session.retrying.Store(false)
+	interval := m.retryInterval
+	for {
+		select {
+		case <-session.doneChan:
+			return
+		case <-time.After(interval):
+		}
+
+		failed := session.FailedSubscriptions()
+		if len(failed) == 0 {
+			return
+		}
+		services := make(map[McpName]*runtime.McpService)
+		for _, mcpService := range m.listServices() {
+			services[mcpService.Name] = mcpService
+		}
+		for _, name := range failed {
+			mcpService, ok := services[name]
+			if !ok || mcpService.GetStatus() != runtime.Running {
+				// 服务已删除或停止，不再为该 session 重试
+				session.forgetSubscription(name)
+				continue
+			}
+			cli, result, err := m.dial(xl, mcpService)
+			if err != nil {
+				session.recordSubscription(name, err)
+				xl.Warnf("retry subscribing session %s to service %s failed: %v", session.Id, name, err)
+				continue
+			}
+			if !session.attachLateClient(xl, name, cli, result) {
+				return
+			}
+			xl.Infof("Service %s joined session %s after retry", name, session.Id)
+		}
+
+		interval *= 2
+		if interval > m.maxRetryInterval {
+			interval = m.maxRetryInterval
+		}
+	}
+}
+
+// downstreamSpecFor 根据服务配置决定网关连接下游时使用的协议与地址。
+func downstreamSpecFor(mcpService *runtime.McpService) downstreamSpec {
+	spec := downstreamSpec{
+		Name:    mcpService.Name,
+		Headers: downstreamAuthHeaders(mcpService),
+	}
+	if mcpService.Config.IsGateway() {
+		// 远端网关只通过 workspace 作用域的 Streamable HTTP 入口聚合
+		spec.Protocol = downstreamProtocolStreamHTTP
+		spec.URL = mcpService.GetMessageUrl()
+	} else if mcpService.IsSSE() && mcpService.Config.GatewayProtocol != "streamhttp" {
+		spec.Protocol = downstreamProtocolSSE
+		spec.URL = mcpService.GetSSEUrl()
+	} else {
+		spec.Protocol = downstreamProtocolStreamHTTP
+		spec.URL = mcpService.GetMessageUrl()
+	}
+	return spec
+}
+
 func downstreamAuthHeaders(mcpService *runtime.McpService) map[string]string {
 	if mcpService == nil || mcpService.Config.Env == nil {
 		return nil
 	}
 	token := mcpService.Config.Env[remoteOAuthAccessTokenEnv]
+	if mcpService.Config.IsGateway() {
+		token = mcpService.Config.Env[config.GatewayAPIKeyEnv]
+	}
 	if token == "" {
 		return nil
 	}
@@ -0,0 +1,267 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// lazy 模式下暴露给 client 的网关元工具
+const (
+	metaToolSearch   = "search_tools"
+	metaToolDescribe = "describe_tool"
+	metaToolCall     = "call_tool"
+)
+
+// CallToolMetaTool 是 lazy 模式下代为调用其它工具的网关元工具全名。
+const CallToolMetaTool = GatewayNamespace + "_" + metaToolCall
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+
+	// BM25 参数
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// 工具名中的词比描述更能说明用途，按该权重重复计入
+	bm25NameWeight = 3
+)
+
+// SetLazyTools 设置该 workspace 新建 session 的默认工具列表模式。
+func (m *SessionManager) SetLazyTools(lazy bool) {
+	m.lazyTools = lazy
+}
+
+// SetLazyTools 切换 session 的工具列表模式。lazy 模式下 tools/list 只返回网关元工具，
+// 完整工具目录通过 gateway_search_tools / gateway_describe_tool 按需获取。
+func (s *Session) SetLazyTools(lazy bool) {
+	s.lazyTools.Store(lazy)
+}
+
+// LazyTools 返回 session 是否处于 lazy 工具模式。
+func (s *Session) LazyTools() bool {
+	return s.lazyTools.Load()
+}
+
+func isMetaTool(name string) bool {
+	switch name {
+	case metaToolSearch, metaToolDescribe, metaToolCall:
+		return true
+	default:
+		return false
+	}
+}
+
+// metaToolDefs 返回 lazy 模式下 tools/list 的内容。
+func metaToolDefs() []mcp.Tool {
+	return []mcp.Tool{
+		mcp.NewTool(GatewayNamespace+"_"+metaToolSearch,
+			mcp.WithDescription("Search all tools available in this workspace by keyword. Returns the best matching tool names and descriptions."),
+			mcp.WithString("query", mcp.Required(), mcp.Description("Keywords describing what you want to do")),
+			mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Maximum number of results (default %d, max %d)", defaultSearchLimit, maxSearchLimit))),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		mcp.NewTool(GatewayNamespace+"_"+metaToolDescribe,
+			mcp.WithDescription("Get the full definition, including the input schema, of a tool returned by gateway_search_tools."),
+			mcp.WithString("name", mcp.Required(), mcp.Description("Tool name, e.g. github_search_issues")),
+			mcp.WithReadOnlyHintAnnotation(true),
+		),
+		mcp.NewTool(GatewayNamespace+"_"+metaToolCall,
+			mcp.WithDescription("Call any tool in this workspace by name."),
+			mcp.WithString("name", mcp.Required(), mcp.Description("Tool name, e.g. github_search_issues")),
+			mcp.WithObject("arguments", mcp.Description("Arguments for the tool, matching its input schema")),
+		),
+	}
+}
+
+// handleMetaTool 执行网关元工具。gateway_call_tool 会把请求改写成对目标工具的
+// tools/call 并沿用原请求 id，响应由目标工具的调用路径返回。
+func (s *Session) handleMetaTool(ctx context.Context, xl xlog.Logger, request mcp.JSONRPCRequest, name string, args map[string]interface{}) error {
+	switch name {
+	case metaToolSearch:
+		query, _ := args["query"].(string)
+		limit := defaultSearchLimit
+		if v, ok := args["limit"].(float64); ok && v > 0 {
+			limit = int(v)
+		}
+		if limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		matches := searchTools(s.toolCatalog(xl), query, limit)
+		s.sendSuccessResponse(request.ID, jsonToolResult(map[string]interface{}{"tools": matches}))
+		return nil
+
+	case metaToolDescribe:
+		target, _ := args["name"].(string)
+		for _, tool := range s.toolCatalog(xl) {
+			if tool.Name == target {
+				s.sendSuccessResponse(request.ID, jsonToolResult(tool))
+				return nil
+			}
+		}
+		s.sendSuccessResponse(request.ID, mcp.NewToolResultError(fmt.Sprintf("tool %s not found", target)))
+		return nil
+
+	case metaToolCall:
+		target, _ := args["name"].(string)
+		if target == "" || target == GatewayNamespace+"_"+metaToolCall {
+			s.sendSuccessResponse(request.ID, mcp.NewToolResultError("invalid tool name"))
+			return nil
+		}
+		arguments, _ := args["arguments"].(map[string]interface{})
+		raw, err := json.Marshal(map[string]interface{}{
+			"jsonrpc": mcp.JSONRPC_VERSION,
+			"id":      request.ID,
+			"method":  mcp.MethodToolsCall,
+			"params":  map[string]interface{}{"name": target, "arguments": arguments},
+		})
+		if err != nil {
+			s.sendErrorResponse(request.ID, err)
+			return err
+		}
+		return s.SendMessageContext(ctx, xl, raw)
+
+	default:
+		return fmt.Errorf("unknown gateway tool: %s", name)
+	}
+}
+
+// toolCatalog 返回带服务前缀、保留完整 schema 与 annotations 的可见工具，按名称排序。
+// 虚拟服务器隐藏的工具不会出现；工具列表尚未拉取时会同步刷新一次。
+func (s *Session) toolCatalog(xl xlog.Logger) []mcp.Tool {
+	if !s.IsToolsListReady() {
+		s.refreshToolCatalog(xl)
+	}
+
+	s.mu.RLock()
+	catalog := make([]mcp.Tool, 0, len(s.aggregatedTools))
+	for mcpName, tools := range s.mcpToolsMap {
+		for _, tool := range tools {
+			if !s.toolAllowed(mcpName + "_" + tool.Name) {
+				continue
+			}
+			tool.Name = mcpName + "_" + tool.Name
+			tool.Description = s.namespacedDescriptionLocked(mcpName, tool.Description)
+			catalog = append(catalog, tool)
+		}
+	}
+	s.mu.RUnlock()
+
+	catalog = append(catalog, s.compositeToolDefs()...)
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+	return catalog
+}
+
+// toolMatch 是 gateway_search_tools 的单条结果。
+type toolMatch struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Score       float64 `json:"score"`
+}
+
+// searchTools 用 BM25 对工具名、描述与 input schema 做关键字检索。
+func searchTools(catalog []mcp.Tool, query string, limit int) []toolMatch {
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 || len(catalog) == 0 {
+		return []toolMatch{}
+	}
+
+	docs := make([]map[string]int, len(catalog))
+	docLens := make([]int, len(catalog))
+	docFreq := make(map[string]int)
+	totalLen := 0
+	for i, tool := range catalog {
+		tf := make(map[string]int)
+		for _, term := range tokenize(tool.Name) {
+			tf[term] += bm25NameWeight
+			docLens[i] += bm25NameWeight
+		}
+		schema, _ := json.Marshal(tool.InputSchema)
+		if tool.RawInputSchema != nil {
+			schema = tool.RawInputSchema
+		}
+		for _, term := range tokenize(tool.Description + " " + string(schema)) {
+			tf[term]++
+			docLens[i]++
+		}
+		for term := range tf {
+			docFreq[term]++
+		}
+		docs[i] = tf
+		totalLen += docLens[i]
+	}
+	avgLen := float64(totalLen) / float64(len(catalog))
+	n := float64(len(catalog))
+
+	matches := make([]toolMatch, 0)
+	for i, tf := range docs {
+		score := 0.0
+		for _, term := range queryTerms {
+			freq := float64(tf[term])
+			if freq == 0 {
+				continue
+			}
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*float64(docLens[i])/avgLen))
+		}
+		if score > 0 {
+			matches = append(matches, toolMatch{
+				Name:        catalog[i].Name,
+				Description: catalog[i].Description,
+				Score:       math.Round(score*1000) / 1000,
+			})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// tokenize 按非字母数字字符与驼峰边界切词并转小写，简单归一英文复数（issues → issue）。
+func tokenize(text string) []string {
+	terms := make([]string, 0)
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			term := strings.ToLower(string(cur))
+			if len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+				term = term[:len(term)-1]
+			}
+			terms = append(terms, term)
+			cur = cur[:0]
+		}
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				flush()
+			}
+			cur = append(cur, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return terms
+}
+
+func jsonToolResult(v interface{}) *mcp.CallToolResult {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	return mcp.NewToolResultText(string(data))
+}
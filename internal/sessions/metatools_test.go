@@ -0,0 +1,137 @@
+package sessions
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestTokenizeSplitsIdentifiers(t *testing.T) {
+	got := tokenize("github_searchIssues: Find-PRs v2")
+	want := []string{"github", "search", "issue", "find", "prs", "v2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestSearchToolsRanksByBM25(t *testing.T) {
+	catalog := []mcp.Tool{
+		mcp.NewTool("fs_read_file", mcp.WithDescription("Read a file from disk")),
+		mcp.NewTool("github_search_issues", mcp.WithDescription("Search GitHub issues"), mcp.WithString("query")),
+		mcp.NewTool("github_get_issue", mcp.WithDescription("Fetch a single issue")),
+	}
+	matches := searchTools(catalog, "search issues", 2)
+	if len(matches) != 2 || matches[0].Name != "github_search_issues" || matches[1].Name != "github_get_issue" {
+		t.Fatalf("unexpected ranking: %+v", matches)
+	}
+	if got := searchTools(catalog, "kubernetes", 5); len(got) != 0 {
+		t.Fatalf("unrelated query should not match, got %+v", got)
+	}
+}
+
+func lazySession(t *testing.T) (*Session, *recordingClient, <-chan SessionMsg) {
+	t.Helper()
+	github := &recordingClient{
+		tools: []mcp.Tool{
+			mcp.NewTool("search_issues", mcp.WithDescription("Search issues"), mcp.WithString("query", mcp.Required())),
+			mcp.NewTool("create_issue", mcp.WithDescription("Create an issue")),
+		},
+		respond: func(req mcp.CallToolRequest) *mcp.CallToolResult {
+			return mcp.NewToolResultText("called " + req.Params.Name)
+		},
+	}
+	session := NewSession("lazy-test")
+	t.Cleanup(session.Close)
+	session.attachClient("github", github, &mcp.InitializeResult{})
+	session.SetLazyTools(true)
+
+	events, closeEvents := session.GetEventChanWithCloser()
+	t.Cleanup(closeEvents)
+	return session, github, events
+}
+
+func sendRPC(t *testing.T, session *Session, id int, method string, params map[string]interface{}) {
+	t.Helper()
+	raw, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err := session.SendMessage(xlog.NewLogger("test-lazy"), raw); err != nil {
+		t.Fatalf("%s failed: %v", method, err)
+	}
+}
+
+func TestLazySessionListsOnlyMetaTools(t *testing.T) {
+	session, _, events := lazySession(t)
+
+	sendRPC(t, session, 1, "tools/list", map[string]interface{}{})
+	var resp struct {
+		Result mcp.ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, tool := range resp.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "gateway_search_tools,gateway_describe_tool,gateway_call_tool" {
+		t.Fatalf("unexpected lazy tools list: %v", names)
+	}
+}
+
+func TestLazySessionSearchDescribeAndCall(t *testing.T) {
+	session, github, events := lazySession(t)
+
+	sendRPC(t, session, 2, "tools/call", map[string]interface{}{
+		"name": "gateway_search_tools", "arguments": map[string]interface{}{"query": "search"},
+	})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, `github_search_issues`) || strings.Contains(evt.Data, "create_issue") {
+		t.Fatalf("unexpected search result: %s", evt.Data)
+	}
+
+	sendRPC(t, session, 3, "tools/call", map[string]interface{}{
+		"name": "gateway_describe_tool", "arguments": map[string]interface{}{"name": "github_search_issues"},
+	})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, `required`) || !strings.Contains(evt.Data, `query`) {
+		t.Fatalf("describe should include the input schema: %s", evt.Data)
+	}
+
+	sendRPC(t, session, 4, "tools/call", map[string]interface{}{
+		"name": "gateway_call_tool", "arguments": map[string]interface{}{
+			"name": "github_create_issue", "arguments": map[string]interface{}{"title": "bug"},
+		},
+	})
+	evt := waitEvent(t, events)
+	if !strings.Contains(evt.Data, `"id":4`) || !strings.Contains(evt.Data, "called create_issue") {
+		t.Fatalf("call_tool should proxy with the original id: %s", evt.Data)
+	}
+	if got := github.calls[0].GetArguments()["title"]; got != "bug" {
+		t.Fatalf("arguments were not forwarded: %+v", github.calls[0].GetArguments())
+	}
+}
+
+func TestLazyVirtualSessionHidesFilteredTools(t *testing.T) {
+	session, _, events := lazySession(t)
+	session.virtual = newVirtualServer(config.VirtualServerConfig{
+		Name:     "triage",
+		Services: []string{"github"},
+		Tools:    []string{"github_search_issues"},
+	})
+
+	sendRPC(t, session, 2, "tools/call", map[string]interface{}{
+		"name": "gateway_search_tools", "arguments": map[string]interface{}{"query": "issue"},
+	})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, "github_search_issues") || strings.Contains(evt.Data, "github_create_issue") {
+		t.Fatalf("search should only return tools of the virtual server: %s", evt.Data)
+	}
+
+	sendRPC(t, session, 3, "tools/call", map[string]interface{}{
+		"name": "gateway_describe_tool", "arguments": map[string]interface{}{"name": "github_create_issue"},
+	})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, "tool github_create_issue not found") {
+		t.Fatalf("describe should not reveal hidden tools: %s", evt.Data)
+	}
+}
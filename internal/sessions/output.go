@@ -0,0 +1,245 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resource 模式下保存的完整工具结果使用网关自有的 URI，分页写作 ?page=N（从 1 开始）
+const storedResultURIPrefix = GatewayNamespace + "://results/"
+
+// storedResult 是 resource 模式下保存在 session 内的完整工具结果。
+type storedResult struct {
+	tool      string
+	text      string
+	createdAt time.Time
+}
+
+// resourceLink 是 MCP 的 resource_link 内容块，当前 mcp-go 版本没有对应类型。
+type resourceLink struct {
+	Type        string `json:"type"`
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mimeType,omitempty"`
+	Size        int    `json:"size,omitempty"`
+}
+
+// linkedToolResult 与 mcp.CallToolResult 的结构一致，content 中可以包含 resource_link。
+type linkedToolResult struct {
+	mcp.Result
+	Content           []interface{} `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+// SetOutputPolicy 设置该 workspace 新建 session 的工具结果大小限制。
+func (m *SessionManager) SetOutputPolicy(policy config.OutputPolicyConfig) {
+	m.outputPolicy = policy
+}
+
+// limitToolResult 按 outputPolicy 处理超出大小限制的工具结果，未超出时原样返回。
+func (s *Session) limitToolResult(xl xlog.Logger, tool string, result *mcp.CallToolResult) interface{} {
+	if result == nil || !s.outputPolicy.Enabled() {
+		return result
+	}
+	size := contentSize(result.Content) + structuredContentSize(result)
+	if size <= s.outputPolicy.MaxBytes {
+		return result
+	}
+	// 超出限制的结果只保留文本，结构化内容无法按大小截断
+	result = withoutStructuredContent(result)
+
+	policy := s.outputPolicy.WithDefaults()
+	xl.Infof("tool %s returned %d bytes, over the %d byte limit, mode: %s", tool, size, policy.MaxBytes, policy.Mode)
+	if policy.Mode == config.OutputModeResource {
+		if linked, ok := s.storeToolResult(tool, result, policy); ok {
+			return linked
+		}
+	}
+	return truncateToolResult(result, size, policy.MaxBytes)
+}
+
+// truncateToolResult 按顺序保留不超过 maxBytes 的内容，文本在字符边界处截断，
+// 放不下的非文本内容直接丢弃，最后附加一段截断提示。
+func truncateToolResult(result *mcp.CallToolResult, size, maxBytes int) *mcp.CallToolResult {
+	budget := maxBytes
+	content := make([]mcp.Content, 0, len(result.Content)+1)
+	for _, c := range result.Content {
+		if text, ok := mcp.AsTextContent(c); ok {
+			if budget <= 0 {
+				continue
+			}
+			if len(text.Text) > budget {
+				truncated := *text
+				truncated.Text = cutUTF8(text.Text, budget)
+				c = truncated
+			}
+			content = append(content, c)
+			budget -= len(text.Text)
+			continue
+		}
+		if n := contentSize([]mcp.Content{c}); n <= budget {
+			content = append(content, c)
+			budget -= n
+		}
+	}
+	kept := contentSize(content)
+	content = append(content, mcp.NewTextContent(fmt.Sprintf("\n[%s] output truncated: %d of %d bytes omitted", GatewayNamespace, size-kept, size)))
+	return &mcp.CallToolResult{Result: result.Result, Content: content, IsError: result.IsError}
+}
+
+// storeToolResult 把结果中的文本保存在 session 内，返回预览与指向完整结果的 resource_link。
+// 结果中没有文本时返回 false，由调用方退回截断处理。
+func (s *Session) storeToolResult(tool string, result *mcp.CallToolResult, policy config.OutputPolicyConfig) (*linkedToolResult, bool) {
+	texts := make([]string, 0, len(result.Content))
+	omitted := 0
+	for _, c := range result.Content {
+		if text, ok := mcp.AsTextContent(c); ok {
+			texts = append(texts, text.Text)
+		} else {
+			omitted++
+		}
+	}
+	if len(texts) == 0 {
+		return nil, false
+	}
+	full := strings.Join(texts, "\n")
+
+	id := uuid.NewString()
+	s.mu.Lock()
+	if s.storedResults == nil {
+		s.storedResults = make(map[string]*storedResult)
+	}
+	s.storedResults[id] = &storedResult{tool: tool, text: full, createdAt: time.Now()}
+	s.storedOrder = append(s.storedOrder, id)
+	for len(s.storedOrder) > policy.MaxStored {
+		delete(s.storedResults, s.storedOrder[0])
+		s.storedOrder = s.storedOrder[1:]
+	}
+	s.mu.Unlock()
+
+	uri := storedResultURIPrefix + id
+	pages := len(paginate(full, policy.PageBytes))
+	note := fmt.Sprintf("\n[%s] output truncated: the full result (%d bytes, %d pages) is available via resources/read on %s", GatewayNamespace, len(full), pages, uri)
+	if omitted > 0 {
+		note += fmt.Sprintf("; %d non-text content blocks were omitted", omitted)
+	}
+	return &linkedToolResult{
+		Result:  result.Result,
+		IsError: result.IsError,
+		Content: []interface{}{
+			mcp.NewTextContent(cutUTF8(full, policy.MaxBytes/2)),
+			mcp.NewTextContent(note),
+			resourceLink{
+				Type:        "resource_link",
+				URI:         uri,
+				Name:        tool + " result",
+				Description: fmt.Sprintf("Full output of %s, %d pages", tool, pages),
+				MIMEType:    "text/plain",
+				Size:        len(full),
+			},
+		},
+	}, true
+}
+
+func isStoredResultURI(uri string) bool {
+	return strings.HasPrefix(uri, storedResultURIPrefix)
+}
+
+// readStoredResult 处理对 gateway://results/<id>?page=N 的 resources/read。
+func (s *Session) readStoredResult(requestId interface{}, uri string) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		s.sendErrorResponse(requestId, fmt.Errorf("invalid resource uri %s: %w", uri, err))
+		return
+	}
+	id := strings.TrimPrefix(u.Path, "/")
+	page := 1
+	if p := u.Query().Get("page"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil || page < 1 {
+			s.sendErrorResponse(requestId, fmt.Errorf("invalid page %q", p))
+			return
+		}
+	}
+
+	s.mu.RLock()
+	stored, ok := s.storedResults[id]
+	s.mu.RUnlock()
+	if !ok {
+		s.sendErrorResponse(requestId, fmt.Errorf("resource %s not found or expired", uri))
+		return
+	}
+
+	pages := paginate(stored.text, s.outputPolicy.WithDefaults().PageBytes)
+	if page > len(pages) {
+		s.sendErrorResponse(requestId, fmt.Errorf("page %d out of range, resource has %d pages", page, len(pages)))
+		return
+	}
+	meta := map[string]any{"page": page, "pages": len(pages)}
+	if page < len(pages) {
+		meta["nextUri"] = fmt.Sprintf("%s%s?page=%d", storedResultURIPrefix, id, page+1)
+	}
+	s.sendSuccessResponse(requestId, &mcp.ReadResourceResult{
+		Result: mcp.Result{Meta: meta},
+		Contents: []mcp.ResourceContents{mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "text/plain",
+			Text:     pages[page-1],
+		}},
+	})
+}
+
+// contentSize 估算 content 的字节数：文本按长度计算，其余内容按 JSON 编码后的长度计算。
+func contentSize(content []mcp.Content) int {
+	size := 0
+	for _, c := range content {
+		if text, ok := mcp.AsTextContent(c); ok {
+			size += len(text.Text)
+			continue
+		}
+		data, _ := json.Marshal(c)
+		size += len(data)
+	}
+	return size
+}
+
+// paginate 把文本按不超过 size 字节切分，不会切开多字节字符。
+func paginate(text string, size int) []string {
+	pages := make([]string, 0, len(text)/size+1)
+	for len(text) > size {
+		page := cutUTF8(text, size)
+		if page == "" {
+			// size 小于单个字符的长度时至少前进一个字符
+			_, n := utf8.DecodeRuneInString(text)
+			page = text[:n]
+		}
+		pages = append(pages, page)
+		text = text[len(page):]
+	}
+	return append(pages, text)
+}
+
+// cutUTF8 返回不超过 n 字节且不切开多字节字符的前缀。
+func cutUTF8(text string, n int) string {
+	if n >= len(text) {
+		return text
+	}
+	if n <= 0 {
+		return ""
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
@@ -0,0 +1,101 @@
+package sessions
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func outputSession(t *testing.T, policy config.OutputPolicyConfig, text string) (*Session, <-chan SessionMsg) {
+	t.Helper()
+	session := NewSession("output-test")
+	t.Cleanup(session.Close)
+	session.outputPolicy = policy
+	session.attachClient("web", &recordingClient{respond: func(mcp.CallToolRequest) *mcp.CallToolResult {
+		return mcp.NewToolResultText(text)
+	}}, &mcp.InitializeResult{})
+
+	events, closeEvents := session.GetEventChanWithCloser()
+	t.Cleanup(closeEvents)
+	return session, events
+}
+
+func TestToolResultTruncatedOverLimit(t *testing.T) {
+	session, events := outputSession(t, config.OutputPolicyConfig{MaxBytes: 10}, strings.Repeat("界", 10))
+
+	sendRPC(t, session, 1, "tools/call", map[string]interface{}{"name": "web_fetch"})
+	var resp struct {
+		Result struct {
+			Content []mcp.TextContent `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result.Content) != 2 {
+		t.Fatalf("expected truncated text and marker, got %+v", resp.Result.Content)
+	}
+	if got := resp.Result.Content[0].Text; got != strings.Repeat("界", 3) {
+		t.Fatalf("text should be cut on a character boundary, got %q", got)
+	}
+	if marker := resp.Result.Content[1].Text; !strings.Contains(marker, "21 of 30 bytes omitted") {
+		t.Fatalf("unexpected truncation marker: %q", marker)
+	}
+}
+
+func TestToolResultStoredAsPagedResource(t *testing.T) {
+	full := strings.Repeat("a", 40) + strings.Repeat("b", 40) + strings.Repeat("c", 20)
+	session, events := outputSession(t, config.OutputPolicyConfig{MaxBytes: 10, Mode: config.OutputModeResource, PageBytes: 40}, full)
+
+	sendRPC(t, session, 1, "tools/call", map[string]interface{}{"name": "web_fetch"})
+	var resp struct {
+		Result struct {
+			Content []map[string]interface{} `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	link := resp.Result.Content[len(resp.Result.Content)-1]
+	uri, _ := link["uri"].(string)
+	if link["type"] != "resource_link" || !strings.HasPrefix(uri, "gateway://results/") || link["size"] != float64(100) {
+		t.Fatalf("expected a resource_link to the stored result, got %+v", resp.Result.Content)
+	}
+	if preview := resp.Result.Content[0]["text"]; preview != "aaaaa" {
+		t.Fatalf("unexpected preview: %v", preview)
+	}
+
+	sendRPC(t, session, 2, "resources/read", map[string]interface{}{"uri": uri + "?page=2"})
+	var page struct {
+		Result struct {
+			Meta     map[string]interface{}     `json:"_meta"`
+			Contents []mcp.TextResourceContents `json:"contents"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Result.Contents[0].Text != strings.Repeat("b", 40) {
+		t.Fatalf("unexpected page 2: %+v", page.Result.Contents)
+	}
+	if page.Result.Meta["pages"] != float64(3) || page.Result.Meta["nextUri"] != uri+"?page=3" {
+		t.Fatalf("unexpected page meta: %+v", page.Result.Meta)
+	}
+
+	sendRPC(t, session, 3, "resources/read", map[string]interface{}{"uri": "gateway://results/missing"})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, "not found") {
+		t.Fatalf("unknown stored result should be an error: %s", evt.Data)
+	}
+}
+
+func TestToolResultUnderLimitUnchanged(t *testing.T) {
+	session, events := outputSession(t, config.OutputPolicyConfig{MaxBytes: 100}, "small")
+
+	sendRPC(t, session, 1, "tools/call", map[string]interface{}{"name": "web_fetch"})
+	if evt := waitEvent(t, events); strings.Contains(evt.Data, "truncated") || !strings.Contains(evt.Data, `"small"`) {
+		t.Fatalf("small results should pass through: %s", evt.Data)
+	}
+}
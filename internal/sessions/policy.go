@@ -0,0 +1,464 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+// PolicyDeniedCode 是 tools/call 被工具参数策略拒绝时返回的 JSON-RPC 错误码。
+const PolicyDeniedCode = -32031
+
+// Caller 描述创建 session 的调用方，用于匹配策略规则的 principals，
+// 以及替换规则中的 {workspace}、{principal} 占位符。
+type Caller struct {
+	Workspace string
+	AccountID string
+	APIKeyID  string
+}
+
+// PolicyDeniedError 表示工具调用被 workspace 的策略拒绝。
+type PolicyDeniedError struct {
+	Rule    string
+	Tool    string
+	Message string
+}
+
+func (e *PolicyDeniedError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("tool %s denied by policy %s: %s", e.Tool, e.Rule, e.Message)
+	}
+	return fmt.Sprintf("tool %s denied by policy %s", e.Tool, e.Rule)
+}
+
+// RPCCode 实现 rpcError。
+func (e *PolicyDeniedError) RPCCode() int {
+	return PolicyDeniedCode
+}
+
+// RPCData 实现 rpcError。
+func (e *PolicyDeniedError) RPCData() any {
+	return map[string]any{"rule": e.Rule, "tool": e.Tool}
+}
+
+// toolPolicySet 保存 workspace 编译后的策略，由 SessionManager 与其 session 共享，更新后立即对已有 session 生效。
+type toolPolicySet struct {
+	rules atomic.Pointer[[]*toolPolicy]
+}
+
+func (p *toolPolicySet) load() []*toolPolicy {
+	if p == nil {
+		return nil
+	}
+	if rules := p.rules.Load(); rules != nil {
+		return *rules
+	}
+	return nil
+}
+
+type toolPolicy struct {
+	config.ToolPolicyRule
+	when   []policyCondition
+	set    []policyAssignment
+	remove []policyPath
+}
+
+type policyCondition struct {
+	config.PolicyCondition
+	path policyPath
+	re   *regexp.Regexp
+}
+
+type policyAssignment struct {
+	path  policyPath
+	value interface{}
+}
+
+// SetToolPolicies 替换 workspace 的工具参数策略，无效的规则会被跳过。
+func (m *SessionManager) SetToolPolicies(xl xlog.Logger, rules []config.ToolPolicyRule) {
+	compiled := make([]*toolPolicy, 0, len(rules))
+	for _, rule := range rules {
+		policy, err := compileToolPolicy(rule)
+		if err != nil {
+			xl.Warnf("skip invalid tool policy: %v", err)
+			continue
+		}
+		compiled = append(compiled, policy)
+	}
+	m.policies.rules.Store(&compiled)
+}
+
+// GuardsTool 返回 workspace 的策略或审批是否可能作用于该工具的调用。只按服务与工具名判断，
+// 开启 destructive 审批时所有工具都视为可能需要审批，供无法执行策略的单服务入口拒绝调用。
+func (m *SessionManager) GuardsTool(tool string) bool {
+	service, _, _ := strings.Cut(tool, "_")
+	for _, rule := range m.policies.load() {
+		if rule.matchesName(service, tool) {
+			return true
+		}
+	}
+	return m.approvals.config().Destructive
+}
+
+func compileToolPolicy(rule config.ToolPolicyRule) (*toolPolicy, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	policy := &toolPolicy{ToolPolicyRule: rule}
+	for _, cond := range rule.When {
+		compiled := policyCondition{PolicyCondition: cond, path: parsePolicyPath(cond.Path)}
+		if cond.Op == config.PolicyOpMatches {
+			compiled.re = regexp.MustCompile(cond.Value.(string))
+		}
+		policy.when = append(policy.when, compiled)
+	}
+	for p, value := range rule.Set {
+		policy.set = append(policy.set, policyAssignment{path: parsePolicyPath(p), value: value})
+	}
+	// 按路径排序，保证同一规则的改写结果稳定
+	sort.Slice(policy.set, func(i, j int) bool { return policy.set[i].path.String() < policy.set[j].path.String() })
+	for _, p := range rule.Remove {
+		policy.remove = append(policy.remove, parsePolicyPath(p))
+	}
+	return policy, nil
+}
+
+// SetCaller 记录创建 session 的调用方，在 session 交给 client 之前调用。
+func (s *Session) SetCaller(caller Caller) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caller = caller
+}
+
+// Caller 返回创建 session 的调用方。
+func (s *Session) Caller() Caller {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.caller
+}
+
+// applyToolPolicies 按顺序对带服务前缀的工具调用求值策略。参数被改写时返回新的 map，
+// 不修改 args，未改写时返回 nil；命中 approve 规则时同时返回规则名；
+// 被拒绝时返回 *PolicyDeniedError 并写入操作日志。
+func (s *Session) applyToolPolicies(xl xlog.Logger, tool string, args map[string]interface{}) (map[string]interface{}, string, error) {
+	rules := s.policies.load()
+	if len(rules) == 0 {
+		return nil, "", nil
+	}
+	caller := s.Caller()
+	service, _, _ := strings.Cut(tool, "_")
+	var applied []string
+	for _, rule := range rules {
+		if !rule.matches(caller, service, tool, args) {
+			continue
+		}
+		switch rule.Effect {
+		case config.PolicyEffectAllow:
+			return rewritten(args, applied), "", nil
+		case config.PolicyEffectApprove:
+			return rewritten(args, applied), rule.Name, nil
+		case config.PolicyEffectDeny:
+			denied := &PolicyDeniedError{Rule: rule.Name, Tool: tool, Message: renderPolicyValue(rule.Message, caller).(string)}
+			s.appendPolicyOperation(caller, "tool.call_denied", tool, denied.Error(), map[string]interface{}{"denied_by": "policy", "policy_rule": rule.Name, "policy_rewrites": applied})
+			return nil, "", denied
+		case config.PolicyEffectRewrite:
+			if applied == nil {
+				args = cloneArguments(args)
+			}
+			rule.rewrite(caller, args)
+			applied = append(applied, rule.Name)
+			xl.Infof("tool %s arguments rewritten by policy %s", tool, rule.Name)
+		}
+	}
+	return rewritten(args, applied), "", nil
+}
+
+func rewritten(args map[string]interface{}, applied []string) map[string]interface{} {
+	if len(applied) == 0 {
+		return nil
+	}
+	return args
+}
+
+// appendPolicyOperation 把策略结果写入操作日志，字段与 gateway 的操作日志一致。
+func (s *Session) appendPolicyOperation(caller Caller, action, tool, message string, detail map[string]interface{}) {
+	fields := map[string]interface{}{
+		"log_type":      "operation",
+		"event_id":      uuid.NewString(),
+		"action":        action,
+		"workspace_id":  caller.Workspace,
+		"session_id":    s.Id,
+		"resource_type": "session",
+		"resource_id":   s.Id,
+		"actor_id":      caller.AccountID,
+		"tool":          tool,
+	}
+	for k, v := range detail {
+		fields[k] = v
+	}
+	xlog.NewLogger("mcp-gateway").WithFields(fields).Warn(message)
+}
+
+func (p *toolPolicy) matches(caller Caller, service, tool string, args map[string]interface{}) bool {
+	if !p.matchesName(service, tool) {
+		return false
+	}
+	if len(p.Principals) > 0 && !containsAny(p.Principals, caller.AccountID, caller.APIKeyID) {
+		return false
+	}
+	for _, cond := range p.when {
+		if cond.holds(caller, args) == cond.Not {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesName 只按服务与工具名判断规则是否可能命中。
+func (p *toolPolicy) matchesName(service, tool string) bool {
+	if p.Service != "" {
+		if ok, _ := path.Match(p.Service, service); !ok {
+			return false
+		}
+	}
+	if p.Tool != "" {
+		if ok, _ := path.Match(p.Tool, tool); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *toolPolicy) rewrite(caller Caller, args map[string]interface{}) {
+	for _, target := range p.remove {
+		target.remove(args)
+	}
+	for _, assignment := range p.set {
+		assignment.path.set(args, renderPolicyValue(assignment.value, caller))
+	}
+}
+
+// holds 判断条件本身（取反前）是否成立。
+func (c policyCondition) holds(caller Caller, args map[string]interface{}) bool {
+	value, ok := c.path.get(args)
+	if !ok || value == nil {
+		return false
+	}
+	switch c.Op {
+	case config.PolicyOpExists:
+		return true
+	case config.PolicyOpEquals:
+		return jsonEqual(value, renderPolicyValue(c.Value, caller))
+	case config.PolicyOpContains:
+		want := renderPolicyValue(c.Value, caller)
+		switch v := value.(type) {
+		case string:
+			sub, ok := want.(string)
+			return ok && strings.Contains(v, sub)
+		case []interface{}:
+			for _, item := range v {
+				if jsonEqual(item, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case config.PolicyOpPrefix:
+		return strings.HasPrefix(str, renderPolicyValue(c.Value, caller).(string))
+	case config.PolicyOpPathUnder:
+		return pathUnder(str, renderPolicyValue(c.Value, caller).(string))
+	case config.PolicyOpMatches:
+		return c.re.MatchString(str)
+	}
+	return false
+}
+
+// pathUnder 判断 p 规范化后是否等于 dir 或位于 dir 之下，../ 无法借此越过 dir。
+func pathUnder(p, dir string) bool {
+	p, dir = path.Clean(p), path.Clean(dir)
+	if p == dir || dir == "/" && strings.HasPrefix(p, "/") {
+		return true
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
+
+// renderPolicyValue 替换字符串中的 {workspace}、{principal}，其它类型原样返回。
+func renderPolicyValue(value interface{}, caller Caller) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+	return strings.NewReplacer("{workspace}", caller.Workspace, "{principal}", caller.AccountID).Replace(str)
+}
+
+// jsonEqual 按 JSON 值比较，避免数字类型（int 与 float64）不同导致不相等。
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// cloneArguments 深拷贝工具参数，改写时不影响调用方持有的原始参数。
+func cloneArguments(args map[string]interface{}) map[string]interface{} {
+	out, _ := normalizeJSON(args).(map[string]interface{})
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+	return out
+}
+
+func containsAny(list []string, values ...string) bool {
+	for _, item := range list {
+		for _, v := range values {
+			if v != "" && item == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// policyPath 是解析后的参数路径，元素为对象 key（string）或数组下标（int）。
+type policyPath []interface{}
+
+// parsePolicyPath 解析已通过 config 校验的路径，例如 $.options.files[0]。
+func parsePolicyPath(p string) policyPath {
+	var out policyPath
+	rest := strings.TrimPrefix(p, "$")
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			index, _ := strconv.Atoi(rest[1:end])
+			out = append(out, index)
+			rest = rest[end+1:]
+			continue
+		}
+		rest = rest[1:]
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		out = append(out, rest[:end])
+		rest = rest[end:]
+	}
+	return out
+}
+
+func (p policyPath) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, step := range p {
+		if index, ok := step.(int); ok {
+			fmt.Fprintf(&b, "[%d]", index)
+			continue
+		}
+		b.WriteString("." + step.(string))
+	}
+	return b.String()
+}
+
+func (p policyPath) get(args map[string]interface{}) (interface{}, bool) {
+	var current interface{} = args
+	for _, step := range p {
+		switch key := step.(type) {
+		case string:
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[key]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := current.([]interface{})
+			if !ok || key >= len(arr) {
+				return nil, false
+			}
+			current = arr[key]
+		}
+	}
+	return current, true
+}
+
+// set 写入参数，缺失的中间对象会被创建；数组只能写入已存在的下标。
+func (p policyPath) set(args map[string]interface{}, value interface{}) {
+	parent, ok := p[:len(p)-1].container(args)
+	if !ok {
+		return
+	}
+	switch key := p[len(p)-1].(type) {
+	case string:
+		if obj, ok := parent.(map[string]interface{}); ok {
+			obj[key] = value
+		}
+	case int:
+		if arr, ok := parent.([]interface{}); ok && key < len(arr) {
+			arr[key] = value
+		}
+	}
+}
+
+func (p policyPath) remove(args map[string]interface{}) {
+	parent, ok := p[:len(p)-1].get(args)
+	if !ok {
+		return
+	}
+	if obj, ok := parent.(map[string]interface{}); ok {
+		if key, ok := p[len(p)-1].(string); ok {
+			delete(obj, key)
+		}
+	}
+}
+
+// container 返回路径指向的对象或数组，对象 key 缺失时创建空对象。
+func (p policyPath) container(args map[string]interface{}) (interface{}, bool) {
+	var current interface{} = args
+	for _, step := range p {
+		switch key := step.(type) {
+		case string:
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			next, exists := obj[key]
+			if !exists || next == nil {
+				next = make(map[string]interface{})
+				obj[key] = next
+			}
+			current = next
+		case int:
+			arr, ok := current.([]interface{})
+			if !ok || key >= len(arr) {
+				return nil, false
+			}
+			current = arr[key]
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,144 @@
+package sessions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func policySession(t *testing.T, rules ...config.ToolPolicyRule) (*SessionManager, *Session, map[string]*recordingClient) {
+	t.Helper()
+	ok := func(mcp.CallToolRequest) *mcp.CallToolResult { return mcp.NewToolResultText("ok") }
+	clients := map[string]*recordingClient{
+		"fs": {tools: []mcp.Tool{{Name: "write_file"}}, respond: ok},
+		"db": {tools: []mcp.Tool{{Name: "query"}}, respond: ok},
+	}
+	services := []*runtime.McpService{runningRemoteService("fs"), runningRemoteService("db")}
+	manager := NewSessionManager(func() []*runtime.McpService { return services }, CleanupConfig{})
+	manager.dialDownstream = func(_ xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		return clients[spec.Name], &mcp.InitializeResult{}, nil
+	}
+	manager.SetToolPolicies(xlog.NewLogger("test-policy"), rules)
+	session, err := manager.CreateSession(xlog.NewLogger("test-policy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(session.Close)
+	session.SetCaller(Caller{Workspace: "team-a", AccountID: "acc-1", APIKeyID: "key-1"})
+	return manager, session, clients
+}
+
+// callTool 发送一次 tools/call 并返回响应中的错误码，成功时为 0。
+func callTool(t *testing.T, session *Session, events <-chan SessionMsg, id int, name string, args map[string]interface{}) int {
+	t.Helper()
+	sendRPC(t, session, id, "tools/call", map[string]interface{}{"name": name, "arguments": args})
+	var resp struct {
+		Error *struct {
+			Code int            `json:"code"`
+			Data map[string]any `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil {
+		return 0
+	}
+	return resp.Error.Code
+}
+
+func TestToolPoliciesDenyAndRewriteArguments(t *testing.T) {
+	_, session, clients := policySession(t,
+		config.ToolPolicyRule{
+			Name:    "fs-sandbox",
+			Service: "fs",
+			When:    []config.PolicyCondition{{Path: "$.path", Op: config.PolicyOpPathUnder, Value: "/data/{workspace}", Not: true}},
+			Effect:  config.PolicyEffectDeny,
+			Message: "paths must stay under /data/{workspace}",
+		},
+		config.ToolPolicyRule{
+			Name:   "no-drop",
+			Tool:   "db_query",
+			When:   []config.PolicyCondition{{Path: "$.sql", Op: config.PolicyOpMatches, Value: `(?i)\bdrop\b`}},
+			Effect: config.PolicyEffectDeny,
+		},
+		config.ToolPolicyRule{
+			Name:   "read-only",
+			Tool:   "db_*",
+			Effect: config.PolicyEffectRewrite,
+			Set:    map[string]interface{}{"$.options.readOnly": true, "$.options.schema": "{workspace}"},
+			Remove: []string{"$.unsafe"},
+		},
+	)
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	if code := callTool(t, session, events, 1, "fs_write_file", map[string]interface{}{"path": "/data/team-a/../team-b/secret"}); code != PolicyDeniedCode {
+		t.Fatalf("path traversal should be denied, got code %d", code)
+	}
+	if code := callTool(t, session, events, 2, "fs_write_file", map[string]interface{}{"path": "/data/team-a/notes.txt"}); code != 0 {
+		t.Fatalf("path inside the sandbox should be forwarded, got code %d", code)
+	}
+	if code := callTool(t, session, events, 3, "db_query", map[string]interface{}{"sql": "DROP TABLE users"}); code != PolicyDeniedCode {
+		t.Fatalf("DROP should be denied, got code %d", code)
+	}
+	if code := callTool(t, session, events, 4, "db_query", map[string]interface{}{"sql": "select 1", "unsafe": true}); code != 0 {
+		t.Fatalf("query should be forwarded, got code %d", code)
+	}
+
+	if len(clients["fs"].calls) != 1 || len(clients["db"].calls) != 1 {
+		t.Fatalf("denied calls must not reach downstream: fs=%d db=%d", len(clients["fs"].calls), len(clients["db"].calls))
+	}
+	args := clients["db"].calls[0].GetArguments()
+	options, _ := args["options"].(map[string]interface{})
+	if options["readOnly"] != true || options["schema"] != "team-a" || args["unsafe"] != nil || args["sql"] != "select 1" {
+		t.Fatalf("arguments should be rewritten: %+v", args)
+	}
+}
+
+func TestToolPoliciesMatchPrincipalAndReload(t *testing.T) {
+	manager, session, clients := policySession(t,
+		config.ToolPolicyRule{Name: "ops", Principals: []string{"key-1"}, Effect: config.PolicyEffectAllow},
+		config.ToolPolicyRule{Name: "deny-all", Effect: config.PolicyEffectDeny},
+	)
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	if code := callTool(t, session, events, 1, "db_query", map[string]interface{}{"sql": "select 1"}); code != 0 {
+		t.Fatalf("allow rule should stop evaluation for key-1, got code %d", code)
+	}
+
+	// 替换策略后对已有 session 立即生效
+	manager.SetToolPolicies(xlog.NewLogger("test-policy"), []config.ToolPolicyRule{
+		{Name: "ops", Principals: []string{"acc-2"}, Effect: config.PolicyEffectAllow},
+		{Name: "deny-all", Effect: config.PolicyEffectDeny},
+	})
+	if code := callTool(t, session, events, 2, "db_query", map[string]interface{}{"sql": "select 1"}); code != PolicyDeniedCode {
+		t.Fatalf("reloaded policies should deny other principals, got code %d", code)
+	}
+	if len(clients["db"].calls) != 1 {
+		t.Fatalf("unexpected downstream calls: %d", len(clients["db"].calls))
+	}
+}
+
+func TestGuardsToolMatchesPolicyNamesAndDestructiveApproval(t *testing.T) {
+	manager := NewSessionManager(func() []*runtime.McpService { return nil }, CleanupConfig{})
+	if manager.GuardsTool("fs_write_file") {
+		t.Fatal("workspace without policies should not guard tools")
+	}
+	manager.SetToolPolicies(xlog.NewLogger("test-policy"), []config.ToolPolicyRule{
+		{Name: "fs-writes", Service: "fs", Tool: "fs_write_*", Effect: config.PolicyEffectDeny},
+	})
+	if !manager.GuardsTool("fs_write_file") || manager.GuardsTool("fs_read_file") || manager.GuardsTool("db_query") {
+		t.Fatal("policy guard should follow the rule's service and tool patterns")
+	}
+	manager.SetApprovalConfig(config.ApprovalConfig{Destructive: true})
+	if !manager.GuardsTool("db_query") {
+		t.Fatal("destructive approval should guard every tool")
+	}
+}
@@ -0,0 +1,284 @@
+package sessions
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// 共享连接在没有 session 引用后保留的时间，避免 session 频繁建立/释放时反复握手
+	poolIdleTTL = 1 * time.Minute
+)
+
+// ClientPool 在同一 workspace 的多个 session 之间复用下游 MCP 连接。
+//
+// 每个下游服务最多建立 size 条连接，新 session 优先分到引用数最少的连接上。
+// 下游 JSON-RPC id 由共享连接自己分配（mcp-go client 内部自增），响应按该 id
+// 路由回发起调用的 goroutine，session 再用 client 原始 id 重新封装响应，
+// 因此多个 session 共用一条连接时 id 不会冲突。
+type ClientPool struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*poolEntry
+
+	// dial 建立一条新的下游连接，测试中可替换
+	dial func(xl xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error)
+}
+
+// poolEntry 保存同一个下游服务（同 URL、同凭证）的所有共享连接。
+type poolEntry struct {
+	// mu 串行化同一服务的建连与引用计数，避免并发建立超过 size 条连接
+	mu    sync.Mutex
+	name  McpName
+	conns []*pooledConn
+}
+
+type pooledConn struct {
+	cli       client.MCPClient
+	init      *mcp.InitializeResult
+	refs      int
+	closed    bool
+	idleTimer *time.Timer
+}
+
+// NewClientPool 构造一个连接池，size 为每个下游服务允许的最大共享连接数。
+func NewClientPool(size int) *ClientPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &ClientPool{
+		size:    size,
+		entries: make(map[string]*poolEntry),
+		dial:    dialDownstream,
+	}
+}
+
+// Acquire 为 session 获取一条到 spec 所述服务的共享连接。返回的 client 调用
+// Close 时只释放引用，真正的下游连接由连接池在空闲超时或失效时关闭。
+func (p *ClientPool) Acquire(xl xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+	entry := p.entry(spec)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	entry.pruneLocked()
+	conn := entry.leastUsedLocked()
+	if conn == nil || (conn.refs > 0 && len(entry.conns) < p.size) {
+		cli, result, err := p.dial(xl, spec)
+		if err != nil {
+			if conn == nil {
+				return nil, nil, err
+			}
+			// 已有可用连接时，建新连接失败只记录日志，继续复用旧连接
+			xl.Warnf("failed to open extra pooled connection to %s, reusing existing one: %v", spec.Name, err)
+		} else {
+			conn = &pooledConn{cli: cli, init: result}
+			entry.conns = append(entry.conns, conn)
+			xl.Infof("Opened pooled connection #%d to %s", len(entry.conns), spec.Name)
+		}
+	}
+
+	conn.refs++
+	if conn.idleTimer != nil {
+		conn.idleTimer.Stop()
+		conn.idleTimer = nil
+	}
+	return &sharedClient{
+		MCPClient: conn.cli,
+		release:   func() { p.release(entry, conn) },
+		evict:     func() { entry.evict(conn) },
+	}, conn.init, nil
+}
+
+// Invalidate 关闭某个服务的所有共享连接，在服务重启、停止或删除时调用。
+// 仍持有旧连接的 session 会在下一次调用时收到错误。
+func (p *ClientPool) Invalidate(name McpName) {
+	p.mu.Lock()
+	victims := make([]*poolEntry, 0)
+	for key, entry := range p.entries {
+		if entry.name == name {
+			victims = append(victims, entry)
+			delete(p.entries, key)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, entry := range victims {
+		entry.closeAll()
+	}
+}
+
+// Close 关闭连接池中的所有连接。
+func (p *ClientPool) Close() {
+	p.mu.Lock()
+	entries := p.entries
+	p.entries = make(map[string]*poolEntry)
+	p.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.closeAll()
+	}
+}
+
+// Stats 返回每个服务当前的共享连接数与 session 引用数，用于诊断。
+func (p *ClientPool) Stats() map[McpName]map[string]int {
+	p.mu.Lock()
+	entries := make([]*poolEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		entries = append(entries, entry)
+	}
+	p.mu.Unlock()
+
+	out := make(map[McpName]map[string]int)
+	for _, entry := range entries {
+		entry.mu.Lock()
+		stat := out[entry.name]
+		if stat == nil {
+			stat = map[string]int{"connections": 0, "sessions": 0}
+			out[entry.name] = stat
+		}
+		for _, conn := range entry.conns {
+			if conn.closed {
+				continue
+			}
+			stat["connections"]++
+			stat["sessions"] += conn.refs
+		}
+		entry.mu.Unlock()
+	}
+	return out
+}
+
+func (p *ClientPool) entry(spec downstreamSpec) *poolEntry {
+	key := poolKey(spec)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key]
+	if !ok {
+		entry = &poolEntry{name: spec.Name}
+		p.entries[key] = entry
+	}
+	return entry
+}
+
+func (p *ClientPool) release(entry *poolEntry, conn *pooledConn) {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if conn.refs > 0 {
+		conn.refs--
+	}
+	if conn.refs > 0 || conn.closed {
+		return
+	}
+	conn.idleTimer = time.AfterFunc(poolIdleTTL, func() {
+		entry.mu.Lock()
+		defer entry.mu.Unlock()
+		if conn.refs == 0 && !conn.closed {
+			conn.closed = true
+			_ = conn.cli.Close()
+			entry.pruneLocked()
+		}
+	})
+}
+
+// pruneLocked 移除已关闭的连接。调用方需持有 entry.mu。
+func (e *poolEntry) pruneLocked() {
+	alive := e.conns[:0]
+	for _, conn := range e.conns {
+		if !conn.closed {
+			alive = append(alive, conn)
+		}
+	}
+	e.conns = alive
+}
+
+// leastUsedLocked 返回引用数最少的连接，没有连接时返回 nil。调用方需持有 entry.mu。
+func (e *poolEntry) leastUsedLocked() *pooledConn {
+	var best *pooledConn
+	for _, conn := range e.conns {
+		if best == nil || conn.refs < best.refs {
+			best = conn
+		}
+	}
+	return best
+}
+
+// evict 关闭一条已断开的共享连接，后续 Acquire 会重新建连。
+// 仍引用该连接的其他 session 会在下一次调用时各自重连。
+func (e *poolEntry) evict(conn *pooledConn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if conn.closed {
+		return
+	}
+	conn.closed = true
+	if conn.idleTimer != nil {
+		conn.idleTimer.Stop()
+	}
+	_ = conn.cli.Close()
+	e.pruneLocked()
+}
+
+func (e *poolEntry) closeAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, conn := range e.conns {
+		if conn.closed {
+			continue
+		}
+		conn.closed = true
+		if conn.idleTimer != nil {
+			conn.idleTimer.Stop()
+		}
+		_ = conn.cli.Close()
+	}
+	e.conns = nil
+}
+
+// poolKey 以服务名、协议、URL 与请求头区分连接，确保不同凭证不会共用同一条连接。
+func poolKey(spec downstreamSpec) string {
+	headerKeys := make([]string, 0, len(spec.Headers))
+	for k := range spec.Headers {
+		headerKeys = append(headerKeys, k)
+	}
+	sort.Strings(headerKeys)
+	var b strings.Builder
+	b.WriteString(spec.Name)
+	b.WriteString("|")
+	b.WriteString(spec.Protocol)
+	b.WriteString("|")
+	b.WriteString(spec.URL)
+	for _, k := range headerKeys {
+		b.WriteString("|")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(spec.Headers[k])
+	}
+	return b.String()
+}
+
+// sharedClient 是 session 持有的共享连接句柄，Close 只释放引用。
+type sharedClient struct {
+	client.MCPClient
+	once    sync.Once
+	release func()
+	evict   func()
+}
+
+// evictClient 在连接断开时把共享连接从连接池中剔除；非共享连接无需处理。
+func evictClient(cli client.MCPClient) {
+	if shared, ok := cli.(*sharedClient); ok && shared.evict != nil {
+		shared.evict()
+	}
+}
+
+func (c *sharedClient) Close() error {
+	c.once.Do(c.release)
+	return nil
+}
@@ -0,0 +1,124 @@
+package sessions
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type fakePoolClient struct {
+	client.MCPClient
+	closed atomic.Int32
+}
+
+func (c *fakePoolClient) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func newFakePool(size int) (*ClientPool, *[]*fakePoolClient) {
+	dialed := make([]*fakePoolClient, 0)
+	pool := NewClientPool(size)
+	pool.dial = func(_ xlog.Logger, _ downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		cli := &fakePoolClient{}
+		dialed = append(dialed, cli)
+		return cli, &mcp.InitializeResult{}, nil
+	}
+	return pool, &dialed
+}
+
+func TestClientPoolSharesConnectionsUpToSize(t *testing.T) {
+	pool, dialed := newFakePool(2)
+	xl := xlog.NewLogger("test-pool")
+	spec := downstreamSpec{Name: "svc", URL: "http://svc", Protocol: downstreamProtocolStreamHTTP}
+
+	clients := make([]client.MCPClient, 0, 5)
+	for i := 0; i < 5; i++ {
+		cli, _, err := pool.Acquire(xl, spec)
+		if err != nil {
+			t.Fatalf("Acquire #%d failed: %v", i, err)
+		}
+		clients = append(clients, cli)
+	}
+
+	if len(*dialed) != 2 {
+		t.Fatalf("expected 2 downstream connections, got %d", len(*dialed))
+	}
+	stats := pool.Stats()["svc"]
+	if stats["connections"] != 2 || stats["sessions"] != 5 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// 释放句柄不会关闭共享连接，重复 Close 也只释放一次引用
+	for _, cli := range clients {
+		_ = cli.Close()
+		_ = cli.Close()
+	}
+	for _, cli := range *dialed {
+		if cli.closed.Load() != 0 {
+			t.Fatal("shared connection should stay open while idle")
+		}
+	}
+	if stats := pool.Stats()["svc"]; stats["sessions"] != 0 {
+		t.Fatalf("expected all references released, got %+v", stats)
+	}
+}
+
+func TestClientPoolSeparatesCredentials(t *testing.T) {
+	pool, dialed := newFakePool(4)
+	xl := xlog.NewLogger("test-pool-creds")
+
+	_, _, _ = pool.Acquire(xl, downstreamSpec{Name: "svc", URL: "http://svc", Headers: map[string]string{"Authorization": "Bearer a"}})
+	_, _, _ = pool.Acquire(xl, downstreamSpec{Name: "svc", URL: "http://svc", Headers: map[string]string{"Authorization": "Bearer b"}})
+
+	if len(*dialed) != 2 {
+		t.Fatalf("different credentials must not share a connection, dialed %d", len(*dialed))
+	}
+}
+
+func TestClientPoolInvalidateClosesConnections(t *testing.T) {
+	pool, dialed := newFakePool(1)
+	xl := xlog.NewLogger("test-pool-invalidate")
+	spec := downstreamSpec{Name: "svc", URL: "http://svc"}
+
+	held, _, _ := pool.Acquire(xl, spec)
+	pool.Invalidate("svc")
+
+	if (*dialed)[0].closed.Load() != 1 {
+		t.Fatal("Invalidate should close the downstream connection")
+	}
+	// 失效后的旧句柄释放不应 panic，新的 Acquire 会重新建连
+	_ = held.Close()
+	if _, _, err := pool.Acquire(xl, spec); err != nil {
+		t.Fatalf("Acquire after invalidate failed: %v", err)
+	}
+	if len(*dialed) != 2 {
+		t.Fatalf("expected a fresh connection after invalidate, dialed %d", len(*dialed))
+	}
+}
+
+func TestClientPoolDialErrorFallsBackToExistingConnection(t *testing.T) {
+	pool, dialed := newFakePool(2)
+	xl := xlog.NewLogger("test-pool-fallback")
+	spec := downstreamSpec{Name: "svc", URL: "http://svc"}
+
+	if _, _, err := pool.Acquire(xl, spec); err != nil {
+		t.Fatalf("first Acquire failed: %v", err)
+	}
+	pool.dial = func(_ xlog.Logger, _ downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		return nil, nil, errors.New("boom")
+	}
+	if _, _, err := pool.Acquire(xl, spec); err != nil {
+		t.Fatalf("Acquire should reuse the existing connection: %v", err)
+	}
+	if stats := pool.Stats()["svc"]; stats["connections"] != 1 || stats["sessions"] != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(*dialed) != 1 {
+		t.Fatalf("unexpected dial count %d", len(*dialed))
+	}
+}
@@ -0,0 +1,181 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	methodNotificationProgress = "notifications/progress"
+	methodNotificationMessage  = "notifications/message"
+)
+
+// requestRoute 记录一个转发到下游、尚未完成的请求，用于把下游推送的中间通知
+// 交还给发起请求的 session。
+type requestRoute struct {
+	session    *Session
+	requestKey string
+	conn       client.MCPClient
+	// token 为 client 原始的 progressToken，gatewayToken 为发往下游的替换值
+	token        interface{}
+	gatewayToken string
+	// touch 在收到中间通知时重置下游调用的超时
+	touch func()
+}
+
+// requestHub 跟踪所有下游连接上进行中的请求。共享连接上不同 session 可能使用相同的
+// progressToken，因此转发前替换为网关内唯一的 token，通知回来时再换回原值。
+type requestHub struct {
+	mu      sync.Mutex
+	byToken map[string]*requestRoute
+	byConn  map[client.MCPClient]map[*requestRoute]struct{}
+}
+
+var inflightRequests = &requestHub{
+	byToken: make(map[string]*requestRoute),
+	byConn:  make(map[client.MCPClient]map[*requestRoute]struct{}),
+}
+
+var progressTokenSeq atomic.Int64
+
+func (h *requestHub) add(route *requestRoute) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if route.gatewayToken != "" {
+		h.byToken[route.gatewayToken] = route
+	}
+	if h.byConn[route.conn] == nil {
+		h.byConn[route.conn] = make(map[*requestRoute]struct{})
+	}
+	h.byConn[route.conn][route] = struct{}{}
+}
+
+func (h *requestHub) remove(route *requestRoute) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if route.gatewayToken != "" {
+		delete(h.byToken, route.gatewayToken)
+	}
+	delete(h.byConn[route.conn], route)
+	if len(h.byConn[route.conn]) == 0 {
+		delete(h.byConn, route.conn)
+	}
+}
+
+// dispatch 把下游推送的进度与日志通知交给对应的请求。进度通知按 progressToken 路由；
+// 日志通知不携带请求标识，只有连接上恰好一个进行中的请求时才能确定归属，否则丢弃。
+func (h *requestHub) dispatch(conn client.MCPClient, notification mcp.JSONRPCNotification) {
+	var route *requestRoute
+	params := notification.Params
+	switch notification.Method {
+	case methodNotificationProgress:
+		token := fmt.Sprint(params.AdditionalFields["progressToken"])
+		h.mu.Lock()
+		route = h.byToken[token]
+		h.mu.Unlock()
+		if route == nil {
+			return
+		}
+		fields := make(map[string]any, len(params.AdditionalFields))
+		for k, v := range params.AdditionalFields {
+			fields[k] = v
+		}
+		fields["progressToken"] = route.token
+		params.AdditionalFields = fields
+	case methodNotificationMessage:
+		h.mu.Lock()
+		if routes := h.byConn[conn]; len(routes) == 1 {
+			for r := range routes {
+				route = r
+			}
+		}
+		h.mu.Unlock()
+		if route == nil {
+			return
+		}
+	default:
+		return
+	}
+
+	data, err := json.Marshal(mcp.JSONRPCNotification{
+		JSONRPC:      mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{Method: notification.Method, Params: params},
+	})
+	if err != nil {
+		xlog.NewLogger("session-"+route.session.Id).Errorf("failed to marshal notification %s: %v", notification.Method, err)
+		return
+	}
+	route.touch()
+	route.session.sendRequestNotification(route.requestKey, SessionMsg{Event: "message", Data: string(data)})
+}
+
+// watchDownstreamNotifications 把下游连接推送的通知交给资源订阅与进行中请求分发。
+func watchDownstreamNotifications(cli *client.Client) {
+	cli.OnNotification(func(notification mcp.JSONRPCNotification) {
+		resourceSubscriptions.dispatch(cli, notification)
+		inflightRequests.dispatch(cli, notification)
+	})
+}
+
+// trackRequest 登记发往单个下游服务的请求，带 progressToken 时替换为网关唯一的 token。
+// 返回下游调用使用的 context（downstreamCallTimeout 内没有响应也没有中间通知时超时）、
+// 改写后的请求体，以及请求结束时调用的 done。
+func (s *Session) trackRequest(mcpName McpName, request mcp.JSONRPCRequest, content json.RawMessage) (context.Context, json.RawMessage, func()) {
+	ctx, touch, cancel := idleContext(downstreamCallTimeout)
+	if request.ID.IsNil() {
+		return ctx, content, cancel
+	}
+	s.mu.RLock()
+	cli := s.mcpClients[mcpName]
+	s.mu.RUnlock()
+	if cli == nil {
+		return ctx, content, cancel
+	}
+	idRaw, err := json.Marshal(request.ID)
+	if err != nil {
+		return ctx, content, cancel
+	}
+	route := &requestRoute{session: s, requestKey: string(bytes.TrimSpace(idRaw)), conn: downstreamConn(cli), touch: touch}
+
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err == nil {
+		params, _ := raw["params"].(map[string]interface{})
+		meta, _ := params["_meta"].(map[string]interface{})
+		if token, ok := meta["progressToken"]; ok && token != nil {
+			route.token = token
+			route.gatewayToken = fmt.Sprintf("%s-%d", s.Id, progressTokenSeq.Add(1))
+			meta["progressToken"] = route.gatewayToken
+			if updated, err := json.Marshal(raw); err == nil {
+				content = updated
+			}
+		}
+	}
+
+	inflightRequests.add(route)
+	return ctx, content, func() {
+		inflightRequests.remove(route)
+		cancel()
+	}
+}
+
+// idleContext 返回一个在 idle 时长内没有调用 touch 就被取消的 context。
+func idleContext(idle time.Duration) (context.Context, func(), context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	timer := time.AfterFunc(idle, func() { cancel(context.DeadlineExceeded) })
+	touch := func() { timer.Reset(idle) }
+	return ctx, touch, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
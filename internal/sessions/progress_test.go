@@ -0,0 +1,83 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// progressClient 在工具执行期间模拟下游推送进度与日志通知。
+type progressClient struct {
+	client.MCPClient
+	tokens []interface{}
+}
+
+func (c *progressClient) CallTool(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var token interface{}
+	if req.Params.Meta != nil {
+		token = req.Params.Meta.ProgressToken
+	}
+	c.tokens = append(c.tokens, token)
+	progress := mcp.JSONRPCNotification{JSONRPC: mcp.JSONRPC_VERSION}
+	progress.Method = methodNotificationProgress
+	progress.Params.AdditionalFields = map[string]interface{}{"progressToken": token, "progress": 1}
+	inflightRequests.dispatch(c, progress)
+
+	log := mcp.JSONRPCNotification{JSONRPC: mcp.JSONRPC_VERSION}
+	log.Method = methodNotificationMessage
+	log.Params.AdditionalFields = map[string]interface{}{"level": "info", "data": "working"}
+	inflightRequests.dispatch(c, log)
+	return mcp.NewToolResultText("done"), nil
+}
+
+func (c *progressClient) Close() error { return nil }
+
+func TestRequestNotificationsRoutedToWatcher(t *testing.T) {
+	downstream := &progressClient{}
+	session := NewSession("progress-test")
+	defer session.Close()
+	session.attachClient("svc", downstream, &mcp.InitializeResult{})
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	watched, cancelWatch := session.WatchRequest(json.RawMessage(`7`))
+	defer cancelWatch()
+	sendRPC(t, session, 7, "tools/call", map[string]interface{}{
+		"name":  "svc_long",
+		"_meta": map[string]interface{}{"progressToken": 42},
+	})
+
+	if token, ok := downstream.tokens[0].(string); !ok || token == "42" {
+		t.Fatalf("progress token should be replaced before reaching a shared connection, got %v", downstream.tokens[0])
+	}
+	var progress mcp.JSONRPCNotification
+	if err := json.Unmarshal([]byte(waitEvent(t, watched).Data), &progress); err != nil {
+		t.Fatal(err)
+	}
+	if progress.Method != methodNotificationProgress || progress.Params.AdditionalFields["progressToken"] != float64(42) {
+		t.Fatalf("progress should carry the client token, got %+v", progress)
+	}
+	if evt := waitEvent(t, watched); !strings.Contains(evt.Data, `"method":"notifications/message"`) {
+		t.Fatalf("log message from the only in-flight request should follow, got %s", evt.Data)
+	}
+	// 中间通知只进入请求的流，广播流上只有最终响应
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, `"result"`) {
+		t.Fatalf("expected the final response on the session stream, got %s", evt.Data)
+	}
+	if len(inflightRequests.byToken) != 0 || len(inflightRequests.byConn) != 0 {
+		t.Fatalf("finished requests must be removed from the hub")
+	}
+
+	// 未登记流的请求，进度通知照常广播
+	sendRPC(t, session, 8, "tools/call", map[string]interface{}{
+		"name":  "svc_long",
+		"_meta": map[string]interface{}{"progressToken": "p"},
+	})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, `"progressToken":"p"`) {
+		t.Fatalf("unwatched progress should be broadcast, got %s", evt.Data)
+	}
+}
@@ -0,0 +1,265 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 网关对 client 支持的 MCP 协议版本
+const (
+	ProtocolVersion20241105 = "2024-11-05"
+	ProtocolVersion20250326 = "2025-03-26"
+	ProtocolVersion20250618 = "2025-06-18"
+
+	// LatestProtocolVersion 是网关支持的最新版本，client 未声明版本时使用
+	LatestProtocolVersion = ProtocolVersion20250618
+)
+
+// SupportedProtocolVersions 按从新到旧排列。
+var SupportedProtocolVersions = []string{
+	ProtocolVersion20250618,
+	ProtocolVersion20250326,
+	ProtocolVersion20241105,
+}
+
+// protocolFeatures 记录各版本之间行为不同、网关需要按 client 版本转换的部分。
+type protocolFeatures struct {
+	// batching JSON-RPC 批量请求，仅 2025-03-26 支持（2025-06-18 又移除）
+	batching bool
+	// audioContent 工具结果中的 audio 内容，2025-03-26 起支持
+	audioContent bool
+	// resourceLinks 工具结果中的 resource_link 内容，2025-06-18 起支持
+	resourceLinks bool
+	// structuredContent 工具结果的 structuredContent 与工具定义的 outputSchema，2025-06-18 起支持
+	structuredContent bool
+}
+
+func featuresFor(version string) protocolFeatures {
+	switch version {
+	case ProtocolVersion20241105:
+		return protocolFeatures{}
+	case ProtocolVersion20250326:
+		return protocolFeatures{batching: true, audioContent: true}
+	default:
+		return protocolFeatures{audioContent: true, resourceLinks: true, structuredContent: true}
+	}
+}
+
+// UnsupportedProtocolVersionError 是 client 请求了网关不支持的协议版本时 initialize 返回的错误，
+// 按规范以 -32602 响应，并在 data 中给出支持的版本。
+type UnsupportedProtocolVersionError struct {
+	Requested string
+}
+
+func (e *UnsupportedProtocolVersionError) Error() string {
+	return "Unsupported protocol version"
+}
+
+// RPCCode 实现 rpcError。
+func (e *UnsupportedProtocolVersionError) RPCCode() int {
+	return mcp.INVALID_PARAMS
+}
+
+// RPCData 实现 rpcError。
+func (e *UnsupportedProtocolVersionError) RPCData() any {
+	return map[string]any{
+		"supported": SupportedProtocolVersions,
+		"requested": e.Requested,
+	}
+}
+
+// rpcError 是带 JSON-RPC 错误码的错误，sendResponse 会按其错误码与 data 响应。
+type rpcError interface {
+	error
+	RPCCode() int
+	RPCData() any
+}
+
+// NegotiateProtocolVersion 返回与 client 协商出的协议版本：支持 client 请求的版本时使用该版本，
+// 未声明版本时使用最新版本，否则返回 UnsupportedProtocolVersionError。
+func NegotiateProtocolVersion(requested string) (string, error) {
+	if requested == "" {
+		return LatestProtocolVersion, nil
+	}
+	if slices.Contains(SupportedProtocolVersions, requested) {
+		return requested, nil
+	}
+	return "", &UnsupportedProtocolVersionError{Requested: requested}
+}
+
+// IsSupportedProtocolVersion 返回网关是否支持该协议版本。
+func IsSupportedProtocolVersion(version string) bool {
+	return slices.Contains(SupportedProtocolVersions, version)
+}
+
+// Initialize 与 client 协商协议版本并记录在 session 上，返回网关聚合的 InitializeResult。
+// 下游 MCP 的 initialize 已在 session 建立时完成，这里不再转发。
+func (s *Session) Initialize(params mcp.InitializeParams) (*mcp.InitializeResult, error) {
+	version, err := NegotiateProtocolVersion(params.ProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.protocolVersion.Store(version)
+	result := &mcp.InitializeResult{
+		ProtocolVersion: version,
+		ServerInfo: mcp.Implementation{
+			Name:    GatewayServerName,
+			Version: "1.0.0",
+		},
+		Capabilities: s.AggregateCapabilities(),
+		Instructions: "MCP Gateway aggregates multiple MCP servers. Tools are namespaced as <serverName>_<toolName>.",
+	}
+	// 虚拟服务器以自己的名称与说明出现
+	if s.virtual != nil {
+		result.ServerInfo.Name = GatewayServerName + "/" + s.virtual.name
+		if s.virtual.instructions != "" {
+			result.Instructions = s.virtual.instructions
+		}
+	}
+	return result, nil
+}
+
+// ProtocolVersion 返回与 client 协商出的协议版本，尚未 initialize 时返回空字符串。
+func (s *Session) ProtocolVersion() string {
+	version, _ := s.protocolVersion.Load().(string)
+	return version
+}
+
+// SupportsBatching 返回 client 协商的协议版本是否允许 JSON-RPC 批量请求。
+func (s *Session) SupportsBatching() bool {
+	return featuresFor(s.clientProtocolVersion()).batching
+}
+
+// clientProtocolVersion 返回用于兼容转换的版本；未 initialize 的 session 按最新版本处理。
+func (s *Session) clientProtocolVersion() string {
+	if version := s.ProtocolVersion(); version != "" {
+		return version
+	}
+	return LatestProtocolVersion
+}
+
+// adaptResult 把响应转换成 client 协商版本支持的形式。
+func (s *Session) adaptResult(result interface{}) interface{} {
+	features := featuresFor(s.clientProtocolVersion())
+	switch r := result.(type) {
+	case *linkedToolResult:
+		// 保存在网关的结果只通过 resources/read 读取文本，不再携带下游的结构化内容
+		clean, _, _ := carriedFields(r.Result)
+		if features.resourceLinks {
+			return &linkedToolResult{Result: clean, Content: r.Content, IsError: r.IsError}
+		}
+		// 旧版本 client 不认识 resource_link，链接地址已包含在提示文本中
+		content := make([]interface{}, 0, len(r.Content))
+		for _, c := range r.Content {
+			if _, ok := c.(resourceLink); !ok {
+				content = append(content, c)
+			}
+		}
+		return &linkedToolResult{Result: clean, Content: content, IsError: r.IsError}
+	case *mcp.CallToolResult:
+		if r == nil {
+			return r
+		}
+		return s.adaptToolResult(r, features)
+	case *mcp.ListToolsResult:
+		if !features.structuredContent || r == nil {
+			return r
+		}
+		return s.withOutputSchemas(r)
+	default:
+		return result
+	}
+}
+
+// adaptToolResult 还原下游结果携带的 structuredContent 与 resource_link；不支持的版本中
+// resource_link 保留为提示文本，structuredContent 序列化为一段文本，audio 替换为说明。
+func (s *Session) adaptToolResult(r *mcp.CallToolResult, features protocolFeatures) interface{} {
+	clean, structured, links := carriedFields(r.Result)
+	changed := structured != nil || links != nil
+	if features.structuredContent && features.resourceLinks {
+		if !changed {
+			return r
+		}
+		content := make([]interface{}, len(r.Content))
+		for i, c := range r.Content {
+			content[i] = c
+			// 提示文本被遮盖或截断改动过时不再还原
+			if link, ok := links[i]; ok && isResourceLinkText(c, link) {
+				content[i] = link
+			}
+		}
+		return &linkedToolResult{Result: clean, Content: content, StructuredContent: structured, IsError: r.IsError}
+	}
+
+	content := r.Content
+	if !features.audioContent {
+		copied := false
+		for i, c := range r.Content {
+			audio, ok := mcp.AsAudioContent(c)
+			if !ok {
+				continue
+			}
+			if !copied {
+				content = append(make([]mcp.Content, 0, len(r.Content)), r.Content...)
+				copied, changed = true, true
+			}
+			content[i] = mcp.NewTextContent(fmt.Sprintf("[%s] %s audio content omitted: not supported by protocol version %s", GatewayNamespace, audio.MIMEType, s.clientProtocolVersion()))
+		}
+	}
+	// 规范建议同时在文本中返回序列化的结构化结果，下游已经这样做时不再重复
+	if structured != nil && !hasStructuredText(content, structured) {
+		data, err := json.Marshal(structured)
+		if err == nil {
+			content = append(append(make([]mcp.Content, 0, len(content)+1), content...), mcp.NewTextContent(string(data)))
+		}
+	}
+	if !changed {
+		return r
+	}
+	return &mcp.CallToolResult{Result: clean, Content: content, IsError: r.IsError}
+}
+
+// isResourceLinkText 返回 c 是否仍是 resource_link 被替换成的提示文本。
+func isResourceLinkText(c mcp.Content, raw json.RawMessage) bool {
+	var link resourceLink
+	if json.Unmarshal(raw, &link) != nil {
+		return false
+	}
+	text, ok := mcp.AsTextContent(c)
+	return ok && text.Text == resourceLinkText(link)
+}
+
+// hasStructuredText 返回 content 中是否已有与 structured 等价的 JSON 文本。
+func hasStructuredText(content []mcp.Content, structured any) bool {
+	for _, c := range content {
+		text, ok := mcp.AsTextContent(c)
+		if !ok {
+			continue
+		}
+		var parsed any
+		if json.Unmarshal([]byte(text.Text), &parsed) == nil && reflect.DeepEqual(parsed, structured) {
+			return true
+		}
+	}
+	return false
+}
+
+// withOutputSchemas 为声明了 outputSchema 的下游工具补回该字段。
+func (s *Session) withOutputSchemas(r *mcp.ListToolsResult) interface{} {
+	schemas := s.outputSchemas.Load()
+	if schemas == nil || len(*schemas) == 0 {
+		return r
+	}
+	tools := make([]interface{}, len(r.Tools))
+	for i, tool := range r.Tools {
+		tools[i] = tool
+		if schema, ok := (*schemas)[tool.Name]; ok {
+			tools[i] = schemaTool{Tool: tool, OutputSchema: schema}
+		}
+	}
+	return &toolListResult{PaginatedResult: r.PaginatedResult, Tools: tools}
+}
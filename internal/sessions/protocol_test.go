@@ -0,0 +1,178 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	cases := []struct {
+		requested string
+		want      string
+		err       bool
+	}{
+		{requested: "2024-11-05", want: "2024-11-05"},
+		{requested: "2025-03-26", want: "2025-03-26"},
+		{requested: "", want: LatestProtocolVersion},
+		{requested: "2099-01-01", err: true},
+	}
+	for _, tc := range cases {
+		got, err := NegotiateProtocolVersion(tc.requested)
+		var unsupported *UnsupportedProtocolVersionError
+		if tc.err != errors.As(err, &unsupported) || got != tc.want {
+			t.Fatalf("NegotiateProtocolVersion(%q) = %q, %v", tc.requested, got, err)
+		}
+	}
+}
+
+func TestSessionInitializeOverMessageEndpoint(t *testing.T) {
+	session := NewSession("protocol-test")
+	defer session.Close()
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	sendRPC(t, session, 1, "initialize", map[string]interface{}{"protocolVersion": "2023-01-01"})
+	var rejected struct {
+		Error struct {
+			Code int            `json:"code"`
+			Data map[string]any `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &rejected); err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Error.Code != mcp.INVALID_PARAMS || rejected.Error.Data["requested"] != "2023-01-01" {
+		t.Fatalf("unsupported version should be rejected with -32602, got %+v", rejected.Error)
+	}
+	if session.ProtocolVersion() != "" {
+		t.Fatalf("rejected initialize must not record a version, got %q", session.ProtocolVersion())
+	}
+
+	sendRPC(t, session, 2, "initialize", map[string]interface{}{"protocolVersion": "2024-11-05"})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, `"protocolVersion":"2024-11-05"`) {
+		t.Fatalf("client version should be honoured: %s", evt.Data)
+	}
+	if session.ProtocolVersion() != "2024-11-05" || session.SupportsBatching() {
+		t.Fatalf("unexpected negotiated state: %q batching=%v", session.ProtocolVersion(), session.SupportsBatching())
+	}
+}
+
+func TestAdaptResultForOlderClients(t *testing.T) {
+	session := NewSession("adapt-test")
+	defer session.Close()
+
+	linked := &linkedToolResult{Content: []interface{}{mcp.NewTextContent("preview"), resourceLink{Type: "resource_link", URI: "gateway://results/x"}}}
+	if got := session.adaptResult(linked).(*linkedToolResult); len(got.Content) != 2 {
+		t.Fatalf("latest clients should keep resource_link, got %+v", got.Content)
+	}
+
+	_, _ = session.Initialize(mcp.InitializeParams{ProtocolVersion: ProtocolVersion20250326})
+	if got := session.adaptResult(linked).(*linkedToolResult); len(got.Content) != 1 {
+		t.Fatalf("resource_link should be dropped for 2025-03-26 clients, got %+v", got.Content)
+	}
+
+	audio := &mcp.CallToolResult{Content: []mcp.Content{mcp.NewAudioContent("AAAA", "audio/wav")}}
+	if got := session.adaptResult(audio); got != audio {
+		t.Fatalf("2025-03-26 clients support audio content")
+	}
+	_, _ = session.Initialize(mcp.InitializeParams{ProtocolVersion: ProtocolVersion20241105})
+	got := session.adaptResult(audio).(*mcp.CallToolResult)
+	if text, ok := mcp.AsTextContent(got.Content[0]); !ok || !strings.Contains(text.Text, "audio/wav") {
+		t.Fatalf("audio should be replaced by a note for 2024-11-05 clients, got %+v", got.Content)
+	}
+}
+
+// cannedTransport 按方法返回固定的 JSON-RPC 结果。
+type cannedTransport struct {
+	results map[string]string
+}
+
+func (t *cannedTransport) Start(context.Context) error { return nil }
+
+func (t *cannedTransport) SendRequest(_ context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	return &transport.JSONRPCResponse{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(t.results[request.Method])}, nil
+}
+
+func (t *cannedTransport) SendNotification(context.Context, mcp.JSONRPCNotification) error {
+	return nil
+}
+
+func (t *cannedTransport) SetNotificationHandler(func(mcp.JSONRPCNotification)) {}
+
+func (t *cannedTransport) Close() error { return nil }
+
+func TestStructuredContentRoundTrip(t *testing.T) {
+	canned := &cannedTransport{results: map[string]string{
+		"initialize": `{"protocolVersion":"2025-06-18","capabilities":{"tools":{}},"serverInfo":{"name":"weather","version":"1"}}`,
+		"tools/list": `{"tools":[{"name":"forecast","inputSchema":{"type":"object"},"outputSchema":{"type":"object","properties":{"temp":{"type":"number"}}}}]}`,
+		"tools/call": `{"content":[{"type":"text","text":"sunny"},{"type":"resource_link","uri":"file:///report.txt","name":"report"}],"structuredContent":{"temp":21}}`,
+	}}
+	versioned := &versionedTransport{Interface: canned}
+	if got := versioned.headers(context.Background()); got != nil {
+		t.Fatalf("no version header before initialize, got %v", got)
+	}
+	cli := client.NewClient(versioned)
+	initResult, err := cli.Initialize(context.Background(), mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: LatestProtocolVersion}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versioned.headers(context.Background()); got["MCP-Protocol-Version"] != ProtocolVersion20250618 {
+		t.Fatalf("2025-06-18 downstreams need the version header, got %v", got)
+	}
+
+	call := func(version string) (tools, result map[string]any) {
+		session := NewSession("structured-" + version)
+		defer session.Close()
+		session.attachClient("weather", cli, initResult)
+		_, _ = session.Initialize(mcp.InitializeParams{ProtocolVersion: version})
+		events, closeEvents := session.GetEventChanWithCloser()
+		defer closeEvents()
+
+		sendRPC(t, session, 1, "tools/list", map[string]interface{}{})
+		var listed struct {
+			Result map[string]any `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &listed); err != nil {
+			t.Fatal(err)
+		}
+		sendRPC(t, session, 2, "tools/call", map[string]interface{}{"name": "weather_forecast", "arguments": map[string]interface{}{}})
+		var called struct {
+			Result map[string]any `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &called); err != nil {
+			t.Fatal(err)
+		}
+		return listed.Result["tools"].([]any)[0].(map[string]any), called.Result
+	}
+
+	tool, result := call(ProtocolVersion20250618)
+	if tool["outputSchema"] == nil {
+		t.Fatalf("2025-06-18 clients should see outputSchema: %v", tool)
+	}
+	if result["structuredContent"].(map[string]any)["temp"] != float64(21) {
+		t.Fatalf("structuredContent should be carried through: %v", result)
+	}
+	content := result["content"].([]any)
+	if len(content) != 2 || content[1].(map[string]any)["type"] != "resource_link" {
+		t.Fatalf("resource_link should be restored: %v", content)
+	}
+	if _, ok := result["_meta"]; ok {
+		t.Fatalf("carrier fields must not reach the client: %v", result)
+	}
+
+	tool, result = call(ProtocolVersion20250326)
+	if tool["outputSchema"] != nil || result["structuredContent"] != nil {
+		t.Fatalf("older clients must not see 2025-06-18 fields: %v %v", tool, result)
+	}
+	content = result["content"].([]any)
+	if len(content) != 3 || !strings.Contains(content[1].(map[string]any)["text"].(string), "file:///report.txt") || content[2].(map[string]any)["text"] != `{"temp":21}` {
+		t.Fatalf("older clients should get the link and the structured result as text: %v", content)
+	}
+}
@@ -0,0 +1,185 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// 判断连接是否断开时 ping 下游的超时
+	connectionProbeTimeout = 3 * time.Second
+	// 重连后重试请求的超时
+	retryRequestTimeout = 10 * time.Second
+)
+
+// Redialer 为 session 重新建立到某个下游服务的连接。dead 是已经断开的旧客户端，
+// 实现方可据此丢弃共享连接池中对应的连接。
+type Redialer func(xl xlog.Logger, mcpName McpName, dead client.MCPClient) (client.MCPClient, *mcp.InitializeResult, error)
+
+// SetRedialer 设置下游断线后的重连方式，未设置时 session 不会自动重连。
+func (s *Session) SetRedialer(redial Redialer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redial = redial
+}
+
+// ReconnectCount 返回 session 内所有下游服务累计的自动重连次数。
+func (s *Session) ReconnectCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	total := 0
+	for _, sub := range s.subscriptions {
+		total += sub.Reconnects
+	}
+	return total
+}
+
+// callWithReconnect 调用下游；若调用因连接断开失败，则重新订阅并 initialize，
+// 仅在 retrySafe 为 true（只读请求）时用新连接重试一次。
+func (s *Session) callWithReconnect(ctx context.Context, xl xlog.Logger, mcpName McpName, retrySafe bool, call func(ctx context.Context, cli client.MCPClient) (interface{}, error)) (interface{}, error) {
+	s.mu.RLock()
+	mCli, ok := s.mcpClients[mcpName]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to find mcpClient for %s", mcpName)
+	}
+
+	result, err := call(ctx, mCli)
+	if err == nil || !isConnectionLost(mCli, err) {
+		return result, err
+	}
+
+	xl.Warnf("connection to %s lost: %v, reconnecting", mcpName, err)
+	newCli, rerr := s.reconnect(xl, mcpName, mCli)
+	if rerr != nil {
+		xl.Errorf("failed to reconnect to %s: %v", mcpName, rerr)
+		return nil, err
+	}
+	if !retrySafe {
+		return nil, fmt.Errorf("connection to %s was lost and has been re-established, request was not retried: %w", mcpName, err)
+	}
+
+	retryCtx, cancel := context.WithTimeout(context.Background(), retryRequestTimeout)
+	defer cancel()
+	return call(retryCtx, newCli)
+}
+
+// reconnect 用 Redialer 替换已断开的客户端。并发请求同时发现断线时只会重连一次。
+func (s *Session) reconnect(xl xlog.Logger, mcpName McpName, dead client.MCPClient) (client.MCPClient, error) {
+	s.reconnectMu.Lock()
+	defer s.reconnectMu.Unlock()
+
+	s.mu.RLock()
+	current := s.mcpClients[mcpName]
+	redial := s.redial
+	s.mu.RUnlock()
+	if current != nil && current != dead {
+		// 其他请求已经完成重连
+		return current, nil
+	}
+	if redial == nil {
+		return nil, fmt.Errorf("reconnect is not configured for session %s", s.Id)
+	}
+
+	cli, result, err := redial(xl, mcpName, dead)
+	if err != nil {
+		// 记为订阅失败并交给后台按退避重试，session 不会一直停留在降级状态直到下一次调用
+		s.recordSubscription(mcpName, err)
+		if s.retryFailed != nil {
+			s.retryFailed(xl)
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		_ = cli.Close()
+		return nil, fmt.Errorf("session %s is closed", s.Id)
+	}
+	s.mcpClients[mcpName] = cli
+	s.mcpinitializeResults[mcpName] = result
+	if sub := s.subscriptions[mcpName]; sub != nil {
+		sub.Reconnects++
+	}
+	s.mu.Unlock()
+	_ = dead.Close()
+	s.resubscribeResources(xl, mcpName, dead, cli)
+
+	s.recordSubscription(mcpName, nil)
+	xl.Infof("Reconnected session %s to %s", s.Id, mcpName)
+	return cli, nil
+}
+
+// transportError 标记下游连接层面的失败，由 dialDownstream 建立的连接在 SendRequest 出错时返回，
+// 用于与下游返回的 JSON-RPC 错误区分。
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+
+func (e *transportError) Unwrap() error { return e.err }
+
+// isConnectionLost 判断调用失败是否由下游连接断开导致：连接被关闭、重置或拒绝时直接认定断线；
+// 其它传输层错误（超时、HTTP 错误状态等）再 ping 一次确认。下游返回的 JSON-RPC 错误不会触发重连。
+func isConnectionLost(cli client.MCPClient, err error) bool {
+	if err == nil {
+		return false
+	}
+	if isBrokenConnection(err) {
+		return true
+	}
+	var transportErr *transportError
+	if !errors.As(err, &transportErr) {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectionProbeTimeout)
+	defer cancel()
+	return cli.Ping(ctx) != nil
+}
+
+// isBrokenConnection 判断错误链中是否有连接已断开的网络错误；请求自身被取消或超时不算。
+func isBrokenConnection(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	for _, target := range []error{io.EOF, io.ErrUnexpectedEOF, io.ErrClosedPipe, net.ErrClosed, os.ErrClosed,
+		syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
+
+// isRetrySafe 判断请求在重连后能否安全重放：列表/读取类方法总是可以，
+// tools/call 仅当下游把该工具标记为 readOnlyHint 时才可以。
+func (s *Session) isRetrySafe(mcpName McpName, method string, reqRaw json.RawMessage) bool {
+	switch mcp.MCPMethod(method) {
+	case mcp.MethodPing, mcp.MethodToolsList, mcp.MethodResourcesList, mcp.MethodResourcesTemplatesList,
+		mcp.MethodResourcesRead, mcp.MethodPromptsList, mcp.MethodPromptsGet:
+		return true
+	case mcp.MethodToolsCall:
+		var request mcp.CallToolRequest
+		if err := json.Unmarshal(reqRaw, &request); err != nil {
+			return false
+		}
+		tool, ok := s.GetMcpTool(mcpName, request.Params.Name)
+		return ok && tool.Annotations.ReadOnlyHint != nil && *tool.Annotations.ReadOnlyHint
+	default:
+		return false
+	}
+}
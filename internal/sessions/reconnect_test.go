@@ -0,0 +1,200 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// flakyClient 模拟一条下游连接：dead 为 true 时所有调用都返回传输层错误。
+type flakyClient struct {
+	client.MCPClient
+	dead  bool
+	calls atomic.Int32
+}
+
+func (c *flakyClient) Ping(context.Context) error {
+	if c.dead {
+		return fmt.Errorf("transport error: %w", syscall.ECONNRESET)
+	}
+	return nil
+}
+
+func (c *flakyClient) CallTool(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c.calls.Add(1)
+	if c.dead {
+		return nil, fmt.Errorf("transport error: %w", syscall.ECONNRESET)
+	}
+	return mcp.NewToolResultText("ok:" + req.Params.Name), nil
+}
+
+func (c *flakyClient) Close() error { return nil }
+
+func newReconnectSession(t *testing.T) (*Session, *flakyClient, *atomic.Int32) {
+	t.Helper()
+	session := NewSession("reconnect-test")
+	t.Cleanup(session.Close)
+
+	dead := &flakyClient{dead: true}
+	session.attachClient("svc", dead, &mcp.InitializeResult{})
+	session.recordSubscription("svc", nil)
+	session.updateToolsMap("svc", &mcp.ListToolsResult{Tools: []mcp.Tool{
+		mcp.NewTool("read", mcp.WithReadOnlyHintAnnotation(true)),
+		mcp.NewTool("write"),
+	}})
+
+	var redials atomic.Int32
+	session.SetRedialer(func(_ xlog.Logger, _ McpName, _ client.MCPClient) (client.MCPClient, *mcp.InitializeResult, error) {
+		redials.Add(1)
+		return &flakyClient{}, &mcp.InitializeResult{}, nil
+	})
+	return session, dead, &redials
+}
+
+func callToolRaw(t *testing.T, id int, name string) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func waitEvent(t *testing.T, events <-chan SessionMsg) SessionMsg {
+	t.Helper()
+	select {
+	case evt := <-events:
+		return evt
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for session event")
+		return SessionMsg{}
+	}
+}
+
+func TestSessionReconnectsAndRetriesReadOnlyTool(t *testing.T) {
+	session, _, redials := newReconnectSession(t)
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	if err := session.SendMessage(xlog.NewLogger("test-reconnect"), callToolRaw(t, 1, "svc_read")); err != nil {
+		t.Fatalf("read-only call should succeed after reconnect: %v", err)
+	}
+	evt := waitEvent(t, events)
+	if !strings.Contains(evt.Data, "ok:read") {
+		t.Fatalf("expected retried result, got %s", evt.Data)
+	}
+	if redials.Load() != 1 || session.ReconnectCount() != 1 {
+		t.Fatalf("expected one reconnect, redials=%d count=%d", redials.Load(), session.ReconnectCount())
+	}
+}
+
+func TestSessionReconnectsButDoesNotRetryMutatingTool(t *testing.T) {
+	session, _, redials := newReconnectSession(t)
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	_ = session.SendMessage(xlog.NewLogger("test-reconnect-write"), callToolRaw(t, 2, "svc_write"))
+	evt := waitEvent(t, events)
+	if !strings.Contains(evt.Data, "not retried") {
+		t.Fatalf("expected error for non read-only tool, got %s", evt.Data)
+	}
+	if redials.Load() != 1 || session.ReconnectCount() != 1 {
+		t.Fatalf("connection should still be re-established, redials=%d", redials.Load())
+	}
+
+	// 重连后的调用走新连接
+	_ = session.SendMessage(xlog.NewLogger("test-reconnect-write"), callToolRaw(t, 3, "svc_write"))
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, "ok:write") {
+		t.Fatalf("expected call on new connection to succeed, got %s", evt.Data)
+	}
+}
+
+func TestIsConnectionLostUsesTypedErrors(t *testing.T) {
+	alive, dead := &flakyClient{}, &flakyClient{dead: true}
+	cases := []struct {
+		name string
+		cli  client.MCPClient
+		err  error
+		want bool
+	}{
+		{"connection reset", alive, fmt.Errorf("transport error: %w", syscall.ECONNRESET), true},
+		{"closed stream", alive, fmt.Errorf("transport error: %w", &transportError{err: io.ErrUnexpectedEOF}), true},
+		{"transport error confirmed by ping", dead, fmt.Errorf("transport error: %w", &transportError{err: errors.New("request failed with status 502")}), true},
+		{"slow request on live connection", alive, fmt.Errorf("transport error: %w", &transportError{err: context.DeadlineExceeded}), false},
+		{"json-rpc error", dead, errors.New("transport error: tool failed"), false},
+	}
+	for _, tc := range cases {
+		if got := isConnectionLost(tc.cli, tc.err); got != tc.want {
+			t.Errorf("%s: isConnectionLost = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSessionRetriesInBackgroundAfterFailedReconnect(t *testing.T) {
+	services := []*runtime.McpService{runningRemoteService("svc")}
+	manager := NewSessionManager(func() []*runtime.McpService { return services }, CleanupConfig{})
+	manager.retryInterval = 10 * time.Millisecond
+	manager.maxRetryInterval = 20 * time.Millisecond
+
+	var dials atomic.Int32
+	manager.dialDownstream = func(_ xlog.Logger, _ downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		switch dials.Add(1) {
+		case 1:
+			return &flakyClient{dead: true}, &mcp.InitializeResult{}, nil
+		case 2:
+			return nil, nil, errors.New("connection refused")
+		default:
+			return &flakyClient{}, &mcp.InitializeResult{}, nil
+		}
+	}
+	session, err := manager.CreateSession(xlog.NewLogger("test-reconnect-retry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	session.updateToolsMap("svc", &mcp.ListToolsResult{Tools: []mcp.Tool{mcp.NewTool("write")}})
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	_ = session.SendMessage(xlog.NewLogger("test-reconnect-retry"), callToolRaw(t, 1, "svc_write"))
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, "connection reset") {
+		t.Fatalf("expected the original transport error, got %s", evt.Data)
+	}
+	// 重连失败后由后台重试恢复，而不是等到下一次调用
+	for {
+		evt := waitEvent(t, events)
+		if strings.Contains(evt.Data, methodNotificationServiceJoined) {
+			break
+		}
+	}
+	if session.IsDegraded() || dials.Load() != 3 {
+		t.Fatalf("service should be re-attached by the background retry, degraded=%v dials=%d", session.IsDegraded(), dials.Load())
+	}
+	_ = session.SendMessage(xlog.NewLogger("test-reconnect-retry"), callToolRaw(t, 2, "svc_write"))
+	for {
+		evt := waitEvent(t, events)
+		if strings.Contains(evt.Data, `"id":2`) {
+			if !strings.Contains(evt.Data, "ok:write") {
+				t.Fatalf("expected call on the re-attached connection to succeed, got %s", evt.Data)
+			}
+			return
+		}
+	}
+}
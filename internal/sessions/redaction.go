@@ -0,0 +1,39 @@
+package sessions
+
+import "github.com/mark3labs/mcp-go/mcp"
+
+// SetResultRedactor 设置新建 session 遮盖工具结果文本的函数，nil 表示不遮盖。
+func (m *SessionManager) SetResultRedactor(redact func(string) string) {
+	m.redactResult = redact
+}
+
+// redactToolResult 遮盖工具结果中的文本、文本资源与结构化内容，返回副本，不修改 result。
+func (s *Session) redactToolResult(result *mcp.CallToolResult) *mcp.CallToolResult {
+	if s.redactResult == nil || result == nil {
+		return result
+	}
+	redacted := *result
+	if structured, ok := result.Meta[structuredContentMetaKey]; ok {
+		redacted.Meta = make(map[string]any, len(result.Meta))
+		for k, v := range result.Meta {
+			redacted.Meta[k] = v
+		}
+		redacted.Meta[structuredContentMetaKey] = redactValue(structured, s.redactResult)
+	}
+	redacted.Content = make([]mcp.Content, len(result.Content))
+	for i, content := range result.Content {
+		switch c := content.(type) {
+		case mcp.TextContent:
+			c.Text = s.redactResult(c.Text)
+			content = c
+		case mcp.EmbeddedResource:
+			if text, ok := c.Resource.(mcp.TextResourceContents); ok {
+				text.Text = s.redactResult(text.Text)
+				c.Resource = text
+			}
+			content = c
+		}
+		redacted.Content[i] = content
+	}
+	return &redacted
+}
@@ -0,0 +1,45 @@
+package sessions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRedactToolResultCopiesTextContent(t *testing.T) {
+	session := &Session{redactResult: func(s string) string { return strings.ReplaceAll(s, "s3cr3t", "[REDACTED]") }}
+	result := &mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewTextContent("token=s3cr3t"),
+		mcp.NewImageContent("s3cr3t", "image/png"),
+		mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///a", Text: "s3cr3t"}),
+	}}
+
+	redacted := session.redactToolResult(result)
+	if text, _ := mcp.AsTextContent(redacted.Content[0]); text.Text != "token=[REDACTED]" {
+		t.Fatalf("text content should be redacted: %q", text.Text)
+	}
+	if image, _ := mcp.AsImageContent(redacted.Content[1]); image.Data != "s3cr3t" {
+		t.Fatal("binary content is left unchanged")
+	}
+	if resource, _ := mcp.AsEmbeddedResource(redacted.Content[2]); resource.Resource.(mcp.TextResourceContents).Text != "[REDACTED]" {
+		t.Fatal("embedded text resources should be redacted")
+	}
+	if text, _ := mcp.AsTextContent(result.Content[0]); text.Text != "token=s3cr3t" {
+		t.Fatal("the original result must not be modified")
+	}
+	if (&Session{}).redactToolResult(result) != result {
+		t.Fatal("sessions without redaction return the result as is")
+	}
+}
+
+func TestEventSummaryOmitsPayload(t *testing.T) {
+	method, id := eventSummary(`{"jsonrpc":"2.0","id":7,"result":{"content":[{"type":"text","text":"password=hunter2"}]}}`)
+	if method != "" || id != "7" {
+		t.Fatalf("unexpected summary: method=%q id=%q", method, id)
+	}
+	method, id = eventSummary(`{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"secret"}}`)
+	if method != "notifications/message" || id != "" {
+		t.Fatalf("unexpected summary: method=%q id=%q", method, id)
+	}
+}
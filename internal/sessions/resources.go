@@ -0,0 +1,401 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcp-go 未定义资源订阅相关的方法常量
+const (
+	methodResourcesSubscribe   mcp.MCPMethod = "resources/subscribe"
+	methodResourcesUnsubscribe mcp.MCPMethod = "resources/unsubscribe"
+)
+
+// resourceNamespaceSeparator 分隔资源 URI 的服务前缀，与工具名的 mcpName_toolName 一致。
+const resourceNamespaceSeparator = "_"
+
+// namespaceResourceURI 给下游资源 URI 加上所属服务前缀。
+func namespaceResourceURI(mcpName McpName, uri string) string {
+	return mcpName + resourceNamespaceSeparator + uri
+}
+
+// namespaceResources 返回资源 URI 带服务前缀的 resources/list 结果，与读取、订阅和更新通知使用的 URI 一致。
+func namespaceResources(mcpName McpName, result *mcp.ListResourcesResult) *mcp.ListResourcesResult {
+	if result == nil {
+		return nil
+	}
+	namespaced := *result
+	namespaced.Resources = make([]mcp.Resource, len(result.Resources))
+	for i, resource := range result.Resources {
+		resource.URI = namespaceResourceURI(mcpName, resource.URI)
+		namespaced.Resources[i] = resource
+	}
+	return &namespaced
+}
+
+// namespaceResourceTemplates 给 resources/templates/list 结果中的 URI 模板加上服务前缀。
+func namespaceResourceTemplates(mcpName McpName, result *mcp.ListResourceTemplatesResult) *mcp.ListResourceTemplatesResult {
+	if result == nil {
+		return nil
+	}
+	namespaced := *result
+	namespaced.ResourceTemplates = make([]mcp.ResourceTemplate, len(result.ResourceTemplates))
+	for i, template := range result.ResourceTemplates {
+		if template.URITemplate != nil && template.URITemplate.Template != nil {
+			raw, _ := json.Marshal(namespaceResourceURI(mcpName, template.URITemplate.Raw()))
+			prefixed := &mcp.URITemplate{}
+			if err := prefixed.UnmarshalJSON(raw); err == nil {
+				template.URITemplate = prefixed
+			}
+		}
+		namespaced.ResourceTemplates[i] = template
+	}
+	return &namespaced
+}
+
+// namespaceResourceContents 给 resources/read 结果中各内容的 URI 加上服务前缀。
+func namespaceResourceContents(mcpName McpName, result *mcp.ReadResourceResult) *mcp.ReadResourceResult {
+	if result == nil {
+		return nil
+	}
+	namespaced := *result
+	namespaced.Contents = make([]mcp.ResourceContents, len(result.Contents))
+	for i, contents := range result.Contents {
+		switch c := contents.(type) {
+		case mcp.TextResourceContents:
+			c.URI = namespaceResourceURI(mcpName, c.URI)
+			contents = c
+		case mcp.BlobResourceContents:
+			c.URI = namespaceResourceURI(mcpName, c.URI)
+			contents = c
+		}
+		namespaced.Contents[i] = contents
+	}
+	return &namespaced
+}
+
+// resourceCovers 返回对 subscribed 的订阅是否覆盖更新的 uri：两者相同，或 uri 是以 / 分隔的子资源。
+func resourceCovers(subscribed, uri string) bool {
+	return uri == subscribed || strings.HasPrefix(uri, strings.TrimSuffix(subscribed, "/")+"/")
+}
+
+// splitResourceURI 拆出带前缀 URI 所属的服务与下游原始 URI，前缀不是当前 session
+// 的服务时返回 false，由调用方按未加前缀的 URI 处理。
+func (s *Session) splitResourceURI(uri string) (McpName, string, bool) {
+	name, raw, ok := strings.Cut(uri, resourceNamespaceSeparator)
+	if !ok || name == "" || raw == "" {
+		return "", "", false
+	}
+	s.mu.RLock()
+	_, known := s.mcpClients[name]
+	s.mu.RUnlock()
+	if !known {
+		return "", "", false
+	}
+	return name, raw, true
+}
+
+// routeResourceRequest 把资源请求路由到所属服务，返回改写为下游原始 URI 的请求体。
+// 带服务前缀的 URI 直接路由；未加前缀时订阅类请求按 resourceOwner 查找所属服务，
+// 找不到时返回错误，resources/read 则照旧发往所有服务。
+func (s *Session) routeResourceRequest(method string, content json.RawMessage) (McpName, json.RawMessage, error) {
+	var req struct {
+		JSONRPC string                 `json:"jsonrpc"`
+		ID      any                    `json:"id,omitempty"`
+		Method  string                 `json:"method"`
+		Params  map[string]interface{} `json:"params"`
+	}
+	if err := json.Unmarshal(content, &req); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal %s request: %w", method, err)
+	}
+	uri, _ := req.Params["uri"].(string)
+	mcpName, raw, ok := s.splitResourceURI(uri)
+	if !ok {
+		if mcp.MCPMethod(method) == mcp.MethodResourcesRead {
+			return "", content, nil
+		}
+		if mcpName, ok = s.resourceOwner(uri); !ok {
+			return "", nil, fmt.Errorf("cannot determine the service owning resource %q, use a service-prefixed URI such as <service>%s%s", uri, resourceNamespaceSeparator, uri)
+		}
+		return mcpName, content, nil
+	}
+	req.Params["uri"] = raw
+	updated, err := json.Marshal(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+	return mcpName, updated, nil
+}
+
+// resourceOwner 查找未加前缀的 URI 所属的服务：优先使用 resources/list 返回过该
+// URI 的服务，否则在只有一个服务支持资源订阅时使用该服务。
+func (s *Session) resourceOwner(uri string) (McpName, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if owner, ok := s.resourceOwners[uri]; ok {
+		return owner, true
+	}
+	var owner McpName
+	for mcpName, result := range s.mcpinitializeResults {
+		if result == nil || result.Capabilities.Resources == nil || !result.Capabilities.Resources.Subscribe {
+			continue
+		}
+		if owner != "" {
+			return "", false
+		}
+		owner = mcpName
+	}
+	return owner, owner != ""
+}
+
+// recordResourceOwners 记录 resources/list 结果中各资源所属的服务。
+func (s *Session) recordResourceOwners(mcpName McpName, result *mcp.ListResourcesResult) {
+	if result == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, resource := range result.Resources {
+		s.resourceOwners[resource.URI] = mcpName
+	}
+}
+
+// resourceHub 跟踪所有下游连接上的资源订阅。共享连接上同一 URI 被多个 session
+// 订阅时只向下游订阅一次，最后一个 session 退订时才向下游退订；下游推送的
+// notifications/resources/updated 按连接与 URI 分发给订阅的 session。
+type resourceHub struct {
+	mu    sync.Mutex
+	conns map[client.MCPClient]*connResources
+}
+
+// connResources 保存一条下游连接上的订阅，mu 串行化该连接上的订阅与退订调用。
+type connResources struct {
+	mu   sync.Mutex
+	uris map[string]map[*Session]McpName
+	// dead 表示该记录已从 hub 中移除，持有旧引用的调用方需要重新获取
+	dead bool
+}
+
+var resourceSubscriptions = &resourceHub{conns: make(map[client.MCPClient]*connResources)}
+
+// downstreamConn 返回共享连接句柄背后真正的下游连接，作为订阅的归属。
+func downstreamConn(cli client.MCPClient) client.MCPClient {
+	if shared, ok := cli.(*sharedClient); ok {
+		return shared.MCPClient
+	}
+	return cli
+}
+
+// lock 取得并锁住连接的订阅记录。
+func (h *resourceHub) lock(conn client.MCPClient) *connResources {
+	for {
+		h.mu.Lock()
+		entry := h.conns[conn]
+		if entry == nil {
+			entry = &connResources{uris: make(map[string]map[*Session]McpName)}
+			h.conns[conn] = entry
+		}
+		h.mu.Unlock()
+
+		entry.mu.Lock()
+		if !entry.dead {
+			return entry
+		}
+		entry.mu.Unlock()
+	}
+}
+
+// unlock 释放连接的订阅记录，没有任何订阅时把记录从 hub 中移除。
+func (h *resourceHub) unlock(conn client.MCPClient, entry *connResources) {
+	if len(entry.uris) == 0 {
+		h.mu.Lock()
+		if h.conns[conn] == entry {
+			delete(h.conns, conn)
+		}
+		h.mu.Unlock()
+		entry.dead = true
+	}
+	entry.mu.Unlock()
+}
+
+// subscribe 登记 session 对 uri 的订阅，该连接上首次订阅时向下游发出 resources/subscribe。
+func (h *resourceHub) subscribe(ctx context.Context, cli client.MCPClient, mcpName McpName, uri string, s *Session) error {
+	conn := downstreamConn(cli)
+	entry := h.lock(conn)
+	defer h.unlock(conn, entry)
+
+	subscribers := entry.uris[uri]
+	if len(subscribers) == 0 {
+		if err := cli.Subscribe(ctx, mcp.SubscribeRequest{Params: mcp.SubscribeParams{URI: uri}}); err != nil {
+			return err
+		}
+		subscribers = make(map[*Session]McpName)
+		entry.uris[uri] = subscribers
+	}
+	subscribers[s] = mcpName
+	return nil
+}
+
+// unsubscribe 取消 session 对 uri 的订阅，该连接上最后一个订阅者退订时向下游发出 resources/unsubscribe。
+func (h *resourceHub) unsubscribe(ctx context.Context, cli client.MCPClient, uri string, s *Session) error {
+	conn := downstreamConn(cli)
+	entry := h.lock(conn)
+	defer h.unlock(conn, entry)
+
+	if !entry.removeLocked(uri, s) {
+		return nil
+	}
+	return cli.Unsubscribe(ctx, mcp.UnsubscribeRequest{Params: mcp.UnsubscribeParams{URI: uri}})
+}
+
+// forget 只移除登记而不通知下游，用于已断开的连接。
+func (h *resourceHub) forget(cli client.MCPClient, uri string, s *Session) {
+	conn := downstreamConn(cli)
+	entry := h.lock(conn)
+	defer h.unlock(conn, entry)
+	entry.removeLocked(uri, s)
+}
+
+// removeLocked 移除 session 对 uri 的登记，返回该 URI 在连接上是否已没有订阅者。
+func (e *connResources) removeLocked(uri string, s *Session) bool {
+	subscribers := e.uris[uri]
+	if _, ok := subscribers[s]; !ok {
+		return false
+	}
+	delete(subscribers, s)
+	if len(subscribers) > 0 {
+		return false
+	}
+	delete(e.uris, uri)
+	return true
+}
+
+// dispatch 把下游连接推送的资源更新通知转发给订阅了该资源的 session。
+// 更新的 URI 可能是订阅资源的子资源，按 / 分隔的路径匹配。
+func (h *resourceHub) dispatch(conn client.MCPClient, notification mcp.JSONRPCNotification) {
+	if notification.Method != mcp.MethodNotificationResourceUpdated {
+		return
+	}
+	uri, _ := notification.Params.AdditionalFields["uri"].(string)
+	if uri == "" {
+		return
+	}
+
+	h.mu.Lock()
+	entry := h.conns[conn]
+	h.mu.Unlock()
+	if entry == nil {
+		return
+	}
+
+	targets := make(map[*Session]McpName)
+	entry.mu.Lock()
+	for subscribed, subscribers := range entry.uris {
+		if !resourceCovers(subscribed, uri) {
+			continue
+		}
+		for s, mcpName := range subscribers {
+			targets[s] = mcpName
+		}
+	}
+	entry.mu.Unlock()
+
+	for s, mcpName := range targets {
+		s.sendNotification(xlog.NewLogger("session-"+s.Id), mcp.MethodNotificationResourceUpdated, map[string]interface{}{
+			"uri": namespaceResourceURI(mcpName, uri),
+		})
+	}
+}
+
+// subscribeResource 处理 resources/subscribe，并记录在 session 上以便关闭时退订。
+func (s *Session) subscribeResource(ctx context.Context, cli client.MCPClient, mcpName McpName, uri string) error {
+	if err := resourceSubscriptions.subscribe(ctx, cli, mcpName, uri, s); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resourceSubs[mcpName] == nil {
+		s.resourceSubs[mcpName] = make(map[string]struct{})
+	}
+	s.resourceSubs[mcpName][uri] = struct{}{}
+	return nil
+}
+
+// unsubscribeResource 处理 resources/unsubscribe。
+func (s *Session) unsubscribeResource(ctx context.Context, cli client.MCPClient, mcpName McpName, uri string) error {
+	s.mu.Lock()
+	delete(s.resourceSubs[mcpName], uri)
+	s.mu.Unlock()
+	return resourceSubscriptions.unsubscribe(ctx, cli, uri, s)
+}
+
+// ResourceSubscriptions 返回 session 当前订阅的资源 URI（带服务前缀）。
+func (s *Session) ResourceSubscriptions() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	uris := make([]string, 0)
+	for mcpName, subs := range s.resourceSubs {
+		for uri := range subs {
+			uris = append(uris, namespaceResourceURI(mcpName, uri))
+		}
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// takeResourceSubscriptionsLocked 取出 session 的全部资源订阅及其所在连接，调用方需持有写锁。
+func (s *Session) takeResourceSubscriptionsLocked() map[client.MCPClient][]string {
+	owned := make(map[client.MCPClient][]string)
+	for mcpName, subs := range s.resourceSubs {
+		cli := s.mcpClients[mcpName]
+		if cli == nil {
+			continue
+		}
+		for uri := range subs {
+			owned[cli] = append(owned[cli], uri)
+		}
+	}
+	s.resourceSubs = make(map[McpName]map[string]struct{})
+	return owned
+}
+
+// releaseResourceSubscriptions 在 session 关闭时退订下游资源，必须在释放下游连接之前调用。
+func (s *Session) releaseResourceSubscriptions(xl xlog.Logger, owned map[client.MCPClient][]string) {
+	for cli, uris := range owned {
+		for _, uri := range uris {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := resourceSubscriptions.unsubscribe(ctx, cli, uri, s); err != nil {
+				xl.Warnf("failed to unsubscribe resource %s: %v", uri, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// resubscribeResources 在下游重连后把 session 的资源订阅迁移到新连接上。
+func (s *Session) resubscribeResources(xl xlog.Logger, mcpName McpName, dead, cli client.MCPClient) {
+	s.mu.RLock()
+	uris := make([]string, 0, len(s.resourceSubs[mcpName]))
+	for uri := range s.resourceSubs[mcpName] {
+		uris = append(uris, uri)
+	}
+	s.mu.RUnlock()
+
+	for _, uri := range uris {
+		resourceSubscriptions.forget(dead, uri, s)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := resourceSubscriptions.subscribe(ctx, cli, mcpName, uri, s); err != nil {
+			xl.Warnf("failed to resubscribe resource %s on %s: %v", uri, mcpName, err)
+		}
+		cancel()
+	}
+}
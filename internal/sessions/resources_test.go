@@ -0,0 +1,186 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resourceClient 记录下游收到的资源订阅与退订。
+type resourceClient struct {
+	client.MCPClient
+	mu           sync.Mutex
+	subscribed   []string
+	unsubscribed []string
+}
+
+func (c *resourceClient) Subscribe(_ context.Context, req mcp.SubscribeRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed = append(c.subscribed, req.Params.URI)
+	return nil
+}
+
+func (c *resourceClient) Unsubscribe(_ context.Context, req mcp.UnsubscribeRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unsubscribed = append(c.unsubscribed, req.Params.URI)
+	return nil
+}
+
+func (c *resourceClient) ListResources(context.Context, mcp.ListResourcesRequest) (*mcp.ListResourcesResult, error) {
+	return &mcp.ListResourcesResult{Resources: []mcp.Resource{mcp.NewResource("file:///notes.md", "notes")}}, nil
+}
+
+func (c *resourceClient) ReadResource(_ context.Context, req mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	return &mcp.ReadResourceResult{Contents: []mcp.ResourceContents{mcp.TextResourceContents{URI: req.Params.URI, Text: "# notes"}}}, nil
+}
+
+func (c *resourceClient) Close() error { return nil }
+
+func (c *resourceClient) counts() (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subscribed), len(c.unsubscribed)
+}
+
+// sharedResourceSession 模拟连接池：session 通过共享连接句柄挂载同一条下游连接。
+func sharedResourceSession(t *testing.T, id string, downstream *resourceClient) (*Session, <-chan SessionMsg) {
+	t.Helper()
+	session := NewSession(id)
+	session.attachClient("fs", &sharedClient{MCPClient: downstream, release: func() {}}, &mcp.InitializeResult{})
+	events, closeEvents := session.GetEventChanWithCloser()
+	t.Cleanup(closeEvents)
+	return session, events
+}
+
+func updatedNotification(uri string) mcp.JSONRPCNotification {
+	notification := mcp.JSONRPCNotification{JSONRPC: mcp.JSONRPC_VERSION}
+	notification.Method = mcp.MethodNotificationResourceUpdated
+	notification.Params.AdditionalFields = map[string]interface{}{"uri": uri}
+	return notification
+}
+
+func TestResourceSubscriptionsShareDownstreamAndFanOut(t *testing.T) {
+	downstream := &resourceClient{}
+	first, firstEvents := sharedResourceSession(t, "res-1", downstream)
+	second, secondEvents := sharedResourceSession(t, "res-2", downstream)
+
+	sendRPC(t, first, 1, "resources/subscribe", map[string]interface{}{"uri": "fs_file:///notes.md"})
+	waitEvent(t, firstEvents)
+	sendRPC(t, second, 1, "resources/subscribe", map[string]interface{}{"uri": "fs_file:///notes.md"})
+	waitEvent(t, secondEvents)
+	if subs, _ := downstream.counts(); subs != 1 || downstream.subscribed[0] != "file:///notes.md" {
+		t.Fatalf("shared connection should subscribe downstream once with the raw URI, got %v", downstream.subscribed)
+	}
+
+	resourceSubscriptions.dispatch(downstream, updatedNotification("file:///notes.md"))
+	for _, events := range []<-chan SessionMsg{firstEvents, secondEvents} {
+		var msg mcp.JSONRPCNotification
+		if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Method != mcp.MethodNotificationResourceUpdated || msg.Params.AdditionalFields["uri"] != "fs_file:///notes.md" {
+			t.Fatalf("expected namespaced update notification, got %+v", msg)
+		}
+	}
+
+	first.Close()
+	if _, unsubs := downstream.counts(); unsubs != 0 {
+		t.Fatalf("downstream must stay subscribed while another session listens, got %v", downstream.unsubscribed)
+	}
+	second.Close()
+	if _, unsubs := downstream.counts(); unsubs != 1 {
+		t.Fatalf("last session close should unsubscribe downstream, got %v", downstream.unsubscribed)
+	}
+	if len(resourceSubscriptions.conns) != 0 {
+		t.Fatalf("hub should drop connections without subscriptions")
+	}
+}
+
+func TestResourceSubscribeRoutesToOwningService(t *testing.T) {
+	downstream := &resourceClient{}
+	other := &resourceClient{}
+	session, events := sharedResourceSession(t, "res-route", downstream)
+	session.attachClient("notes", other, &mcp.InitializeResult{})
+	defer session.Close()
+
+	sendRPC(t, session, 1, "resources/subscribe", map[string]interface{}{"uri": "file:///notes.md"})
+	if evt := waitEvent(t, events); !strings.Contains(evt.Data, "cannot determine the service") {
+		t.Fatalf("ambiguous raw URI should be rejected: %s", evt.Data)
+	}
+
+	// resources/list 之后未加前缀的 URI 可以按所属服务路由
+	session.recordResourceOwners("fs", &mcp.ListResourcesResult{Resources: []mcp.Resource{mcp.NewResource("file:///notes.md", "notes")}})
+	sendRPC(t, session, 2, "resources/subscribe", map[string]interface{}{"uri": "file:///notes.md"})
+	waitEvent(t, events)
+	sendRPC(t, session, 3, "resources/subscribe", map[string]interface{}{"uri": "notes_memo://today"})
+	waitEvent(t, events)
+	if got := session.ResourceSubscriptions(); len(got) != 2 || got[0] != "fs_file:///notes.md" || got[1] != "notes_memo://today" {
+		t.Fatalf("unexpected session subscriptions: %v", got)
+	}
+	if subs, _ := other.counts(); subs != 1 || other.subscribed[0] != "memo://today" {
+		t.Fatalf("prefixed URI should reach its service without the prefix, got %v", other.subscribed)
+	}
+
+	sendRPC(t, session, 4, "resources/unsubscribe", map[string]interface{}{"uri": "fs_file:///notes.md"})
+	waitEvent(t, events)
+	if subs, unsubs := downstream.counts(); subs != 1 || unsubs != 1 {
+		t.Fatalf("unexpected downstream calls: subscribe=%d unsubscribe=%d", subs, unsubs)
+	}
+}
+
+func TestResourceURIsRoundTripAndMatchOnPathBoundary(t *testing.T) {
+	downstream := &resourceClient{}
+	session, events := sharedResourceSession(t, "res-roundtrip", downstream)
+	defer session.Close()
+
+	sendRPC(t, session, 1, "resources/list", map[string]interface{}{})
+	var listed struct {
+		Result mcp.ListResourcesResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &listed); err != nil {
+		t.Fatal(err)
+	}
+	uri := listed.Result.Resources[0].URI
+	if uri != "fs_file:///notes.md" {
+		t.Fatalf("resources/list should return namespaced URIs, got %q", uri)
+	}
+
+	sendRPC(t, session, 2, "resources/read", map[string]interface{}{"uri": uri})
+	var read struct {
+		Result struct {
+			Contents []struct {
+				URI string `json:"uri"`
+			} `json:"contents"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Result.Contents) != 1 || read.Result.Contents[0].URI != uri {
+		t.Fatalf("resources/read should return the listed URI, got %+v", read.Result.Contents)
+	}
+
+	sendRPC(t, session, 3, "resources/subscribe", map[string]interface{}{"uri": uri})
+	waitEvent(t, events)
+
+	// 仅共享字符串前缀的其它资源不应触发通知
+	resourceSubscriptions.dispatch(downstream, updatedNotification("file:///notes.md.bak"))
+	resourceSubscriptions.dispatch(downstream, updatedNotification("file:///notes.md"))
+	resourceSubscriptions.dispatch(downstream, updatedNotification("file:///notes.md/section"))
+	for _, want := range []string{uri, uri + "/section"} {
+		var msg mcp.JSONRPCNotification
+		if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Params.AdditionalFields["uri"] != want {
+			t.Fatalf("expected update for %s, got %+v", want, msg.Params.AdditionalFields)
+		}
+	}
+}
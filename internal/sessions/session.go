@@ -5,12 +5,14 @@ package sessions
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/client/transport"
@@ -25,6 +27,12 @@ const (
 	sessionNoConnectionTTL = 1 * time.Minute
 	// session 不活跃检查间隔
 	sessionInactivityCheckInterval = 10 * time.Second
+	// 每个 session 保留的可重放事件数，用于断线重连时按 Last-Event-ID 补发
+	sessionReplayBufferSize = 256
+	// 单个请求的中间通知缓冲，请求方读取过慢时丢弃
+	requestStreamBufferSize = 64
+	// 下游调用的超时；进行中的请求每收到一条中间通知重新计时
+	downstreamCallTimeout = 10 * time.Second
 )
 
 const remoteOAuthAccessTokenEnv = "MCP_REMOTE_AUTH_ACCESS_TOKEN"
@@ -32,6 +40,10 @@ const remoteOAuthAccessTokenEnv = "MCP_REMOTE_AUTH_ACCESS_TOKEN"
 type CleanupConfig struct {
 	NoConnectionTTL         time.Duration
 	InactivityCheckInterval time.Duration
+	// EventBufferSize 每个事件订阅者的缓冲大小
+	EventBufferSize int
+	// OverflowPolicy 订阅者缓冲写满时的处理策略：OverflowDropNotification 或 OverflowDisconnect
+	OverflowPolicy string
 }
 
 func normalizeCleanupConfig(cfg CleanupConfig) CleanupConfig {
@@ -41,6 +53,12 @@ func normalizeCleanupConfig(cfg CleanupConfig) CleanupConfig {
 	if cfg.InactivityCheckInterval <= 0 {
 		cfg.InactivityCheckInterval = sessionInactivityCheckInterval
 	}
+	if cfg.EventBufferSize <= 0 {
+		cfg.EventBufferSize = defaultEventBufferSize
+	}
+	if cfg.OverflowPolicy != OverflowDisconnect {
+		cfg.OverflowPolicy = OverflowDropNotification
+	}
 	return cfg
 }
 
@@ -52,9 +70,16 @@ type Session struct {
 	CreatedAt       time.Time // 会话创建时间
 	LastReceiveTime time.Time // 最后一次接收消息的时间
 
-	// SSE事件通道 - 由主锁保护
-	eventChans []chan SessionMsg
-	doneChan   chan struct{}
+	// SSE事件订阅者 - 由主锁保护
+	eventChans []*eventSubscriber
+	// 按 JSON-RPC id 等待响应的请求方 - 由主锁保护
+	pendingResponses map[string][]chan SessionMsg
+	// 按 JSON-RPC id 接收请求中间通知的请求方 - 由主锁保护
+	requestStreams map[string]chan SessionMsg
+	// 投递统计
+	droppedEvents       atomic.Int64
+	overflowDisconnects atomic.Int64
+	doneChan            chan struct{}
 	// session清理配置
 	cleanupConfig CleanupConfig
 
@@ -63,16 +88,59 @@ type Session struct {
 
 	// 工具映射 - 由主锁保护
 	mcpToolsMap       map[McpName]map[McpToolName]mcp.Tool
-	pendingToolsList  sync.WaitGroup // 等待所有MCP工具列表响应
-	aggregatedTools   []mcp.Tool     // 聚合后的工具列表，工具名带MCP前缀
-	toolsListComplete atomic.Bool    // 标记工具列表是否已完成聚合
+	aggregatedTools   []mcp.Tool  // 聚合后的工具列表，工具名带MCP前缀
+	toolsListComplete atomic.Bool // 标记工具列表是否已完成聚合
+	// outputSchemas 聚合工具中声明了 outputSchema 的工具，按带前缀的工具名索引
+	outputSchemas atomic.Pointer[map[string]json.RawMessage]
 
 	// 避免重复返回 - 由主锁保护
 	lastMsg SessionMsg
 
+	// 事件 ID 与重放缓冲 - 由主锁保护
+	lastEventID int64
+	replayBuf   []SessionMsg
+
 	// V2
 	mcpClients           map[McpName]client.MCPClient
 	mcpinitializeResults map[McpName]*mcp.InitializeResult
+	// 每个下游服务的订阅状态 - 由主锁保护
+	subscriptions map[McpName]*ServiceSubscription
+	// 每个下游服务上订阅的资源 URI（下游原始 URI）- 由主锁保护
+	resourceSubs map[McpName]map[string]struct{}
+	// resources/list 返回过的资源 URI 所属服务 - 由主锁保护
+	resourceOwners map[string]McpName
+
+	// 网关组合工具，创建后只读
+	compositeTools map[string]config.CompositeToolConfig
+	// lazyTools 为 true 时 tools/list 只返回网关元工具
+	lazyTools atomic.Bool
+	// 工具结果大小限制，创建后只读；resource 模式下保存的完整结果由主锁保护
+	outputPolicy  config.OutputPolicyConfig
+	storedResults map[string]*storedResult
+	storedOrder   []string
+	// toolCache 为所属 workspace 的工具结果缓存，可能为 nil
+	toolCache *ToolCache
+	// virtual 非空时 session 属于该虚拟服务器，只暴露其所列的服务与工具
+	virtual *virtualServer
+	// policies 为所属 workspace 的工具参数策略，可能为 nil；caller 为创建 session 的调用方，由主锁保护
+	policies *toolPolicySet
+	caller   Caller
+	// admitTool 为组合工具步骤的准入检查，可能为 nil，由主锁保护
+	admitTool ToolAdmitter
+	// approvals 为所属 workspace 的审批队列，可能为 nil
+	approvals *ApprovalQueue
+	// redactResult 非空时遮盖返回给 client 的工具结果文本，创建后只读
+	redactResult func(string) string
+	// protocolVersion 为 initialize 时与 client 协商出的协议版本
+	protocolVersion atomic.Value
+
+	// 下游断线重连
+	redial      Redialer
+	reconnectMu sync.Mutex
+	// retryFailed 在重连失败后启动订阅失败服务的后台重试，由 SessionManager 在创建时设置
+	retryFailed func(xl xlog.Logger)
+	// retrying 表示后台重试协程正在运行
+	retrying atomic.Bool
 }
 
 func NewSession(id string) *Session {
@@ -86,7 +154,10 @@ func newSession(id string, cleanupConfig CleanupConfig) *Session {
 		Id:                   id,
 		CreatedAt:            now,
 		LastReceiveTime:      now,
-		eventChans:           make([]chan SessionMsg, 0),
+		eventChans:           make([]*eventSubscriber, 0),
+		pendingResponses:     make(map[string][]chan SessionMsg),
+		requestStreams:       make(map[string]chan SessionMsg),
+		replayBuf:            make([]SessionMsg, 0, sessionReplayBufferSize),
 		doneChan:             make(chan struct{}),
 		cleanupConfig:        cleanupConfig,
 		mcpToolsMap:          make(map[McpName]map[McpToolName]mcp.Tool),
@@ -94,6 +165,9 @@ func newSession(id string, cleanupConfig CleanupConfig) *Session {
 		toolsListComplete:    atomic.Bool{},
 		mcpClients:           make(map[McpName]client.MCPClient),
 		mcpinitializeResults: make(map[McpName]*mcp.InitializeResult),
+		subscriptions:        make(map[McpName]*ServiceSubscription),
+		resourceSubs:         make(map[McpName]map[string]struct{}),
+		resourceOwners:       make(map[string]McpName),
 	}
 
 	// 启动监控协程
@@ -127,7 +201,7 @@ func (s *Session) startInactivityMonitor() {
 // checkInactivity 检查session是否应该被清理
 func (s *Session) checkInactivity() {
 	s.mu.RLock()
-	hasActiveChans := len(s.eventChans) > 0
+	hasActiveChans := len(s.eventChans) > 0 || len(s.pendingResponses) > 0
 	lastActivity := s.LastReceiveTime
 	cleanupCallback := s.cleanupCallback
 	sessionId := s.Id
@@ -148,7 +222,17 @@ func (s *Session) GetId() string {
 	return s.Id
 }
 
+// Done 返回在会话关闭时关闭的 channel。
+func (s *Session) Done() <-chan struct{} {
+	return s.doneChan
+}
+
 func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err error) {
+	return s.SendMessageContext(context.Background(), xl, content)
+}
+
+// SendMessageContext 与 SendMessage 相同，ctx 为请求方连接的生命周期：ctx 结束时撤回仍在等待审批的调用。
+func (s *Session) SendMessageContext(ctx context.Context, xl xlog.Logger, content json.RawMessage) (err error) {
 	// 发送消息到 MCP 服务
 	var request mcp.JSONRPCRequest
 	if err = json.Unmarshal([]byte(content), &request); err != nil {
@@ -158,18 +242,41 @@ func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err erro
 	method := request.Method
 	xl = xlog.WithChildName(method, xl)
 
-	xl.Debugf("Sending request: %+v", request)
+	// 只记录 method 与 id，参数可能包含密钥与个人信息
+	xl.Debugf("Sending request: method=%s id=%s", request.Method, request.ID.String())
 
-	// xl.Infof("method: %s, content: %s", method, content)
 	var singleMcp McpName
+	var req mcp.CallToolRequest
+	// approval 非空时调用需等待人工审批，值为要求审批的规则
+	var toolName, approval string
 	switch mcp.MCPMethod(request.Method) {
 	case mcp.MethodToolsCall:
-		req := mcp.CallToolRequest{}
 		err := json.Unmarshal([]byte(content), &req)
 		if err != nil {
 			xl.Errorf("failed to unmarshal request: %v", err)
 			return fmt.Errorf("failed to unmarshal request: %w", err)
 		}
+		if !s.toolAllowed(req.Params.Name) {
+			s.sendErrorResponse(request.ID, fmt.Errorf("tool %s is not available on virtual server %s", req.Params.Name, s.VirtualServer()))
+			return nil
+		}
+		// lazy 模式的元工具只负责分发，gateway_call_tool 代为调用的工具会再次经过策略
+		if service, tool, _ := strings.Cut(req.Params.Name, "_"); !(service == GatewayNamespace && s.LazyTools() && isMetaTool(tool)) {
+			args, rule, err := s.applyToolPolicies(xl, req.Params.Name, req.GetArguments())
+			if err != nil {
+				s.sendErrorResponse(request.ID, err)
+				return nil
+			}
+			if args != nil {
+				req.Params.Arguments = args
+				if !strings.Contains(req.Params.Name, "_") {
+					if content, err = json.Marshal(req); err != nil {
+						return fmt.Errorf("failed to marshal rewritten request: %w", err)
+					}
+				}
+			}
+			toolName, approval = req.Params.Name, s.approvalRule(xl, req.Params.Name, rule)
+		}
 
 		// mcpName_toolName  ->  toolName
 		if names := strings.Split(req.Params.Name, "_"); len(names) >= 2 {
@@ -186,6 +293,78 @@ func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err erro
 		}
 	}
 
+	// 等待审批的调用在后台等待决定，批准后照常转发，响应通过 session 异步返回
+	if approval != "" {
+		go func() {
+			if err := s.waitForApproval(ctx, xl, request.ID, toolName, approval, req.GetArguments()); err != nil {
+				s.sendErrorResponse(request.ID, err)
+				return
+			}
+			_ = s.dispatchMessage(ctx, xl, request, content, singleMcp, req)
+		}()
+		return nil
+	}
+	return s.dispatchMessage(ctx, xl, request, content, singleMcp, req)
+}
+
+// dispatchMessage 在网关侧处理或把请求转发到下游。singleMcp 为空时发往所有下游服务，
+// tools/call 时 req 为去掉服务前缀后的调用。
+func (s *Session) dispatchMessage(ctx context.Context, xl xlog.Logger, request mcp.JSONRPCRequest, content json.RawMessage, singleMcp McpName, req mcp.CallToolRequest) (err error) {
+	method := request.Method
+
+	// gateway_<name> 是网关自身的元工具与组合工具，在网关侧执行
+	if singleMcp == GatewayNamespace {
+		if s.LazyTools() && isMetaTool(req.Params.Name) {
+			return s.handleMetaTool(ctx, xl, request, req.Params.Name, req.GetArguments())
+		}
+		if _, ok := s.compositeTools[req.Params.Name]; ok {
+			result, err := s.runCompositeTool(ctx, xl, request.ID, req.Params.Name, req.GetArguments())
+			if err != nil {
+				s.sendErrorResponse(request.ID, err)
+				return err
+			}
+			s.sendSuccessResponse(request.ID, s.limitToolResult(xl, GatewayNamespace+"_"+req.Params.Name, s.redactToolResult(result)))
+			return nil
+		}
+	}
+
+	// initialize 由网关协商协议版本并返回聚合结果，不转发到下游
+	if mcp.MCPMethod(method) == mcp.MethodInitialize {
+		var initReq mcp.InitializeRequest
+		if err := json.Unmarshal(content, &initReq); err != nil {
+			s.sendErrorResponse(request.ID, fmt.Errorf("failed to unmarshal initialize request: %w", err))
+			return nil
+		}
+		result, err := s.Initialize(initReq.Params)
+		if err != nil {
+			xl.Warnf("reject initialize: %v, requested version %q", err, initReq.Params.ProtocolVersion)
+			s.sendErrorResponse(request.ID, err)
+			return nil
+		}
+		s.sendSuccessResponse(request.ID, result)
+		return nil
+	}
+
+	// 网关保存的超大工具结果由网关自己分页返回
+	if mcp.MCPMethod(method) == mcp.MethodResourcesRead {
+		var readReq mcp.ReadResourceRequest
+		if err := json.Unmarshal(content, &readReq); err == nil && isStoredResultURI(readReq.Params.URI) {
+			s.readStoredResult(request.ID, readReq.Params.URI)
+			return nil
+		}
+	}
+
+	// 资源读取与订阅请求路由到 URI 所属的服务
+	switch mcp.MCPMethod(method) {
+	case mcp.MethodResourcesRead, methodResourcesSubscribe, methodResourcesUnsubscribe:
+		mcpName, routed, err := s.routeResourceRequest(method, content)
+		if err != nil {
+			s.sendErrorResponse(request.ID, err)
+			return nil
+		}
+		singleMcp, content = mcpName, routed
+	}
+
 	// 对所有 MCP 服务器发送消息
 	if singleMcp == "" {
 		// 如果是tools/list请求，需要特殊处理来聚合所有MCP的工具
@@ -215,7 +394,9 @@ func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err erro
 		}
 	} else {
 		// xl.Infof("send to single MCP server: %s, content: %s", singleMcp, content)
-		err = s.sendToMcp(xl, singleMcp, request, content)
+		ctx, routed, done := s.trackRequest(singleMcp, request, content)
+		defer done()
+		err = s.sendToMcpContext(ctx, xl, singleMcp, request, routed)
 		if err != nil {
 			xl.Errorf("failed to send to singlemcp: %v", err)
 			return err
@@ -226,11 +407,18 @@ func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err erro
 }
 
 func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPCRequest, reqRaw json.RawMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), downstreamCallTimeout)
+	defer cancel()
+	return s.sendToMcpContext(ctx, xl, mcpName, baseReq, reqRaw)
+}
+
+// sendToMcpContext 在给定 context 下把请求发往单个下游服务并回写响应。
+func (s *Session) sendToMcpContext(ctx context.Context, xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPCRequest, reqRaw json.RawMessage) error {
 	xl = xlog.WithChildName(mcpName, xl)
 	isNotification := baseReq.ID.IsNil() || strings.HasPrefix(baseReq.Method, "notifications/")
 
 	s.mu.RLock()
-	mCli, ok := s.mcpClients[mcpName]
+	_, ok := s.mcpClients[mcpName]
 	s.mu.RUnlock()
 	if !ok {
 		err := fmt.Errorf("failed to find mcpClient for %s", mcpName)
@@ -238,10 +426,10 @@ func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPC
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	result, err := s.handleMCPMethod(ctx, xl, mCli, mcpName, baseReq.Method, reqRaw)
+	retrySafe := s.isRetrySafe(mcpName, baseReq.Method, reqRaw)
+	result, err := s.callWithReconnect(ctx, xl, mcpName, retrySafe, func(ctx context.Context, mCli client.MCPClient) (interface{}, error) {
+		return s.handleMCPMethod(ctx, xl, mCli, mcpName, baseReq.Method, reqRaw)
+	})
 	if err != nil {
 		if isNotification {
 			xl.Warnf("Ignore notification %s error: %v", baseReq.Method, err)
@@ -252,6 +440,11 @@ func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPC
 		return err
 	}
 
+	if toolResult, ok := result.(*mcp.CallToolResult); ok {
+		var call mcp.CallToolRequest
+		_ = json.Unmarshal(reqRaw, &call)
+		result = s.limitToolResult(xl, mcpName+"_"+call.Params.Name, s.redactToolResult(toolResult))
+	}
 	if result != nil && !isNotification {
 		s.sendSuccessResponse(baseReq.ID, result)
 	}
@@ -261,34 +454,72 @@ func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPC
 
 // SubscribeSSE 订阅MCP服务的SSE事件
 func (s *Session) SubscribeSSE(xl xlog.Logger, mcpName McpName, sseUrl string, headers map[string]string) error {
-	options := []transport.ClientOption{}
-	if len(headers) > 0 {
-		options = append(options, client.WithHeaders(headers))
-	}
-	cli, err := client.NewSSEMCPClient(sseUrl, options...)
-	if err != nil {
-		return fmt.Errorf("failed to create SSE client: %w", err)
-	}
-	return s.subscribeMCPClient(xl, mcpName, cli, "SSE")
+	return s.subscribe(xl, downstreamSpec{Name: mcpName, URL: sseUrl, Protocol: downstreamProtocolSSE, Headers: headers})
 }
 
 // SubscribeStreamHTTP 订阅 Streamable HTTP MCP 服务。
 func (s *Session) SubscribeStreamHTTP(xl xlog.Logger, mcpName McpName, streamURL string, headers map[string]string) error {
-	options := []transport.StreamableHTTPCOption{}
-	if len(headers) > 0 {
-		options = append(options, transport.WithHTTPHeaders(headers))
-	}
-	cli, err := client.NewStreamableHttpClient(streamURL, options...)
+	return s.subscribe(xl, downstreamSpec{Name: mcpName, URL: streamURL, Protocol: downstreamProtocolStreamHTTP, Headers: headers})
+}
+
+// subscribe 为当前 session 建立一条独占的下游连接。
+func (s *Session) subscribe(xl xlog.Logger, spec downstreamSpec) error {
+	cli, result, err := dialDownstream(xl, spec)
 	if err != nil {
-		return fmt.Errorf("failed to create Streamable HTTP client: %w", err)
+		return err
 	}
-	return s.subscribeMCPClient(xl, mcpName, cli, "Streamable HTTP")
+	s.attachClient(spec.Name, cli, result)
+	return nil
+}
+
+// attachClient 把已完成 initialize 的下游客户端挂到 session 上。
+func (s *Session) attachClient(mcpName McpName, cli client.MCPClient, result *mcp.InitializeResult) {
+	// 优化：批量更新状态，减少锁竞争
+	s.mu.Lock()
+	s.mcpClients[mcpName] = cli
+	s.mcpinitializeResults[mcpName] = result
+	s.mu.Unlock()
+}
+
+const (
+	downstreamProtocolSSE        = "SSE"
+	downstreamProtocolStreamHTTP = "Streamable HTTP"
+)
+
+// downstreamSpec 描述如何连接一个下游 MCP 服务。
+type downstreamSpec struct {
+	Name     McpName
+	URL      string
+	Protocol string
+	Headers  map[string]string
 }
 
-func (s *Session) subscribeMCPClient(xl xlog.Logger, mcpName McpName, cli *client.Client, protocol string) error {
+// dialDownstream 创建下游客户端并完成 start / initialize / ping。
+func dialDownstream(xl xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+	versioned := &versionedTransport{}
+	var err error
+	switch spec.Protocol {
+	case downstreamProtocolSSE:
+		options := []transport.ClientOption{}
+		if len(spec.Headers) > 0 {
+			options = append(options, client.WithHeaders(spec.Headers))
+		}
+		versioned.Interface, err = transport.NewSSE(spec.URL, options...)
+	default:
+		options := []transport.StreamableHTTPCOption{transport.WithHTTPHeaderFunc(versioned.headers)}
+		if len(spec.Headers) > 0 {
+			options = append(options, transport.WithHTTPHeaders(spec.Headers))
+		}
+		versioned.Interface, err = transport.NewStreamableHTTP(spec.URL, options...)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s client: %w", spec.Protocol, err)
+	}
+	cli := client.NewClient(versioned)
+
 	if err := cli.Start(context.Background()); err != nil {
 		_ = cli.Close()
-		return fmt.Errorf("failed to start %s client: %w", protocol, err)
+		return nil, nil, fmt.Errorf("failed to start %s client: %w", spec.Protocol, err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -296,7 +527,7 @@ func (s *Session) subscribeMCPClient(xl xlog.Logger, mcpName McpName, cli *clien
 
 	result, err := cli.Initialize(ctx, mcp.InitializeRequest{
 		Params: mcp.InitializeParams{
-			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			ProtocolVersion: LatestProtocolVersion,
 			ClientInfo: mcp.Implementation{
 				Name:    "mcp-gateway-client",
 				Version: "1.0.0",
@@ -305,30 +536,44 @@ func (s *Session) subscribeMCPClient(xl xlog.Logger, mcpName McpName, cli *clien
 	})
 	if err != nil {
 		_ = cli.Close()
-		return fmt.Errorf("failed to initialize %s client: %w", protocol, err)
+		return nil, nil, fmt.Errorf("failed to initialize %s client: %w", spec.Protocol, err)
+	}
+	// 下游回应了网关客户端不支持的版本时直接失败，避免之后的请求静默出错
+	if !IsSupportedProtocolVersion(result.ProtocolVersion) {
+		_ = cli.Close()
+		return nil, nil, fmt.Errorf("%s negotiated unsupported protocol version %q, supported: %v", spec.Name, result.ProtocolVersion, SupportedProtocolVersions)
 	}
 
 	if err = cli.Ping(ctx); err != nil {
 		_ = cli.Close()
-		return fmt.Errorf("failed to ping %s client: %w", protocol, err)
+		return nil, nil, fmt.Errorf("failed to ping %s client: %w", spec.Protocol, err)
 	}
+	watchDownstreamNotifications(cli)
 
-	xl.Infof("%s client initialized and connected successfully", protocol)
-
-	// 优化：批量更新状态，减少锁竞争
-	s.mu.Lock()
-	s.mcpClients[mcpName] = cli
-	s.mcpinitializeResults[mcpName] = result
-	s.mu.Unlock()
-
-	return nil
+	xl.Infof("%s client initialized and connected successfully", spec.Protocol)
+	return cli, result, nil
 }
 
 type SessionMsg struct {
 	proxyId  int64
 	clientId int64
-	Event    string `json:"event"`
-	Data     string `json:"data"`
+	// ID 是 session 内单调递增的事件 ID，作为 SSE 的 `id:` 字段下发，
+	// 客户端重连时通过 Last-Event-ID 带回。
+	ID    int64  `json:"id,omitempty"`
+	Event string `json:"event"`
+	Data  string `json:"data"`
+}
+
+// eventSummary 返回事件中 JSON-RPC 消息的 method 与 id，供日志使用，不记录参数与结果。
+func eventSummary(data string) (method, id string) {
+	var msg struct {
+		Method string          `json:"method"`
+		ID     json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return "", ""
+	}
+	return msg.Method, string(msg.ID)
 }
 
 // check lastMsg is 重复的
@@ -350,6 +595,12 @@ func (s *Session) Close() {
 	xl := xlog.NewLogger("session-" + s.Id)
 	xl.Infof("Closing session: %s", s.Id)
 
+	// 先退订下游资源，退订需要在释放下游连接之前完成
+	s.mu.Lock()
+	owned := s.takeResourceSubscriptionsLocked()
+	s.mu.Unlock()
+	s.releaseResourceSubscriptions(xl, owned)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -370,11 +621,23 @@ func (s *Session) Close() {
 	}
 
 	// 关闭所有事件通道
-	for i, ch := range s.eventChans {
+	for i, sub := range s.eventChans {
 		xl.Infof("Closing event channel %d", i)
-		close(ch)
+		close(sub.ch)
 	}
 	s.eventChans = nil
+	for key, waiters := range s.pendingResponses {
+		for _, ch := range waiters {
+			close(ch)
+		}
+		delete(s.pendingResponses, key)
+	}
+	for key, ch := range s.requestStreams {
+		close(ch)
+		delete(s.requestStreams, key)
+	}
+	s.storedResults = nil
+	s.storedOrder = nil
 
 	xl.Infof("Session closed: %s", s.Id)
 }
@@ -382,67 +645,102 @@ func (s *Session) Close() {
 // SendEvent 发送SSE事件
 func (s *Session) SendEvent(event SessionMsg) {
 	xl := xlog.NewLogger("session-" + s.Id)
-	xl.Infof("Sending event: %s, data: %s", event.Event, event.Data)
+	method, id := eventSummary(event.Data)
+	xl.Infof("Sending event: %s, method: %s, id: %s", event.Event, method, id)
 
-	s.mu.RLock()
-	isDuplicate := s.lastMsg.isDuplicate(&event)
-	if isDuplicate {
-		s.mu.RUnlock()
+	// 分配事件 ID、写入重放缓冲与广播需要在同一把写锁内完成，保证
+	// GetEventChanSince 拿到的快照与后续通道中的事件不重不漏。
+	s.mu.Lock()
+	if s.lastMsg.isDuplicate(&event) {
+		s.mu.Unlock()
 		xl.Debugf("Event already sent: %s", event.Event)
 		return
 	}
 
-	// Keep the read lock while sending so Close/remove cannot close a channel
-	// between selecting it and sending to it.
-	totalChannels := len(s.eventChans)
-	sentToChannels := s.broadcastEvent(s.eventChans, event, xl)
-	s.mu.RUnlock()
+	// 有请求方在等待该 id 的响应时直接投递，不进入广播流与重放缓冲
+	if s.routeResponseLocked(event) {
+		s.lastMsg = event
+		s.LastReceiveTime = time.Now()
+		s.mu.Unlock()
+		xl.Debugf("Response routed to waiting request")
+		return
+	}
+
+	s.lastEventID++
+	event.ID = s.lastEventID
+	s.appendReplayLocked(event)
+	s.lastMsg = event
 
-	// 只在成功发送后更新状态
+	totalChannels := len(s.eventChans)
+	sentToChannels := s.broadcastEventLocked(event, xl)
 	if sentToChannels > 0 {
-		s.mu.Lock()
 		s.LastReceiveTime = time.Now()
-		s.lastMsg = event
-		s.mu.Unlock()
-		xl.Infof("Event sent to %d channels", sentToChannels)
+	}
+	s.mu.Unlock()
+
+	if sentToChannels > 0 {
+		xl.Infof("Event %d sent to %d channels", event.ID, sentToChannels)
 	} else {
-		xl.Warnf("Event not sent to any channels (total channels: %d)", totalChannels)
+		xl.Warnf("Event %d not sent to any channels (total channels: %d)", event.ID, totalChannels)
 	}
 }
 
-// broadcastEvent 顺序广播事件到所有通道，避免为每个channel创建goroutine
-func (s *Session) broadcastEvent(eventChans []chan SessionMsg, event SessionMsg, xl xlog.Logger) int {
-	sentCount := 0
+// appendReplayLocked 把事件写入有界重放缓冲，超出容量时丢弃最旧的事件。调用方需持有写锁。
+func (s *Session) appendReplayLocked(event SessionMsg) {
+	if len(s.replayBuf) >= sessionReplayBufferSize {
+		copy(s.replayBuf, s.replayBuf[1:])
+		s.replayBuf = s.replayBuf[:len(s.replayBuf)-1]
+	}
+	s.replayBuf = append(s.replayBuf, event)
+}
 
-	for i, eventChan := range eventChans {
-		select {
-		case eventChan <- event:
-			sentCount++
-			xl.Debugf("Sent event to channel %d", i)
-		default:
-			xl.Warnf("Channel %d is full, dropping event", i)
-		}
+// eventsSinceLocked 返回重放缓冲中 ID 大于 lastEventID 的事件副本。调用方需持有锁。
+func (s *Session) eventsSinceLocked(lastEventID int64) []SessionMsg {
+	idx := len(s.replayBuf)
+	for idx > 0 && s.replayBuf[idx-1].ID > lastEventID {
+		idx--
 	}
+	out := make([]SessionMsg, len(s.replayBuf)-idx)
+	copy(out, s.replayBuf[idx:])
+	return out
+}
 
-	return sentCount
+// EventsSince 返回 ID 大于 lastEventID、仍在重放缓冲中的事件。
+func (s *Session) EventsSince(lastEventID int64) []SessionMsg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.eventsSinceLocked(lastEventID)
 }
 
 // GetEventChan 获取事件通道
 func (s *Session) GetEventChan() <-chan SessionMsg {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	curChan := make(chan SessionMsg, 100)
-	s.eventChans = append(s.eventChans, curChan)
+	curChan := make(chan SessionMsg, s.cleanupConfig.EventBufferSize)
+	s.eventChans = append(s.eventChans, &eventSubscriber{ch: curChan})
 
 	return curChan
 }
 
 // GetEventChanWithCloser 获取事件通道并返回关闭函数
 func (s *Session) GetEventChanWithCloser() (<-chan SessionMsg, func()) {
+	ch, _, closer := s.GetEventChanSince(-1)
+	return ch, closer
+}
+
+// GetEventChanSince 订阅事件通道，并原子地返回重放缓冲中 ID 大于 lastEventID 的事件，
+// 用于按 Last-Event-ID 恢复 SSE 流。lastEventID < 0 表示不需要重放。
+// 重放事件与通道中的事件不会重复：快照与订阅在同一把锁内完成。
+func (s *Session) GetEventChanSince(lastEventID int64) (<-chan SessionMsg, []SessionMsg, func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	curChan := make(chan SessionMsg, 100)
-	s.eventChans = append(s.eventChans, curChan)
+	curChan := make(chan SessionMsg, s.cleanupConfig.EventBufferSize)
+	s.eventChans = append(s.eventChans, &eventSubscriber{ch: curChan})
+
+	var replay []SessionMsg
+	if lastEventID >= 0 {
+		replay = s.eventsSinceLocked(lastEventID)
+	}
 
 	closer := func() {
 		// Per-request channels are owned by the session. Removing the channel is
@@ -450,7 +748,7 @@ func (s *Session) GetEventChanWithCloser() (<-chan SessionMsg, func()) {
 		s.removeEventChan(curChan)
 	}
 
-	return curChan, closer
+	return curChan, replay, closer
 }
 
 // removeEventChan 从事件通道列表中移除指定通道
@@ -459,8 +757,8 @@ func (s *Session) removeEventChan(targetChan chan SessionMsg) {
 	var shouldScheduleCleanup bool
 	var sessionId string
 
-	for i, ch := range s.eventChans {
-		if ch == targetChan {
+	for i, sub := range s.eventChans {
+		if sub.ch == targetChan {
 			// 移除通道
 			s.eventChans = append(s.eventChans[:i], s.eventChans[i+1:]...)
 			break
@@ -517,7 +815,13 @@ func (s *Session) sendResponse(requestId interface{}, result interface{}, err er
 	reqId := mcp.NewRequestId(requestId)
 
 	if err != nil {
-		// 发送错误响应
+		// 发送错误响应，带错误码的错误按其错误码响应
+		code, message := mcp.INTERNAL_ERROR, err.Error()
+		var data any
+		var rpcErr rpcError
+		if errors.As(err, &rpcErr) {
+			code, data = rpcErr.RPCCode(), rpcErr.RPCData()
+		}
 		response := mcp.JSONRPCError{
 			JSONRPC: "2.0",
 			ID:      reqId,
@@ -526,8 +830,9 @@ func (s *Session) sendResponse(requestId interface{}, result interface{}, err er
 				Message string `json:"message"`
 				Data    any    `json:"data,omitempty"`
 			}{
-				Code:    mcp.INTERNAL_ERROR,
-				Message: err.Error(),
+				Code:    code,
+				Message: message,
+				Data:    data,
 			},
 		}
 		responseData, marshalErr = json.Marshal(response)
@@ -536,7 +841,7 @@ func (s *Session) sendResponse(requestId interface{}, result interface{}, err er
 		response := mcp.JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      reqId,
-			Result:  result,
+			Result:  s.adaptResult(result),
 		}
 		responseData, marshalErr = json.Marshal(response)
 	}
@@ -567,47 +872,26 @@ func (s *Session) sendErrorResponse(requestId interface{}, err error) {
 func (s *Session) handleToolsListRequest(xl xlog.Logger, request mcp.JSONRPCRequest) error {
 	xl.Debugf("Handling tools list request for all MCPs")
 
-	// 重置工具列表状态
-	s.mu.Lock()
-	s.mcpToolsMap = make(map[McpName]map[McpToolName]mcp.Tool)
-	s.aggregatedTools = make([]mcp.Tool, 0)
-	s.toolsListComplete.Store(false)
-
-	// 获取所有MCP客户端
-	mcpNames := make([]McpName, 0, len(s.mcpClients))
-	for mcpName := range s.mcpClients {
-		mcpNames = append(mcpNames, mcpName)
-	}
-	s.mu.Unlock()
-
-	if len(mcpNames) == 0 {
-		xl.Warn("No MCP clients available for tools list request")
-		// 发送空工具列表响应
-		emptyResult := &mcp.ListToolsResult{Tools: []mcp.Tool{}}
-		s.sendSuccessResponse(request.ID, emptyResult)
+	// lazy 模式只暴露网关元工具，完整目录通过 gateway_search_tools 按需检索
+	if s.LazyTools() {
+		s.sendSuccessResponse(request.ID, &mcp.ListToolsResult{Tools: metaToolDefs()})
 		return nil
 	}
 
-	// 设置等待计数
-	s.pendingToolsList.Add(len(mcpNames))
-
 	// 使用单个goroutine处理所有工具列表请求和响应聚合
-	go s.handleAllToolsRequests(xl, request.ID, mcpNames, request)
+	go func() {
+		tools := s.refreshToolCatalog(xl)
+		xl.Infof("Sending aggregated tools response with %d tools", len(tools))
+		s.sendSuccessResponse(request.ID, &mcp.ListToolsResult{Tools: tools})
+	}()
 
 	return nil
 }
 
 // sendToolsListToMcp 向单个MCP发送工具列表请求
-func (s *Session) sendToolsListToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPCRequest) error {
+func (s *Session) sendToolsListToMcp(xl xlog.Logger, mcpName McpName) error {
 	xl = xlog.WithChildName(mcpName, xl)
 
-	s.mu.RLock()
-	mCli, ok := s.mcpClients[mcpName]
-	s.mu.RUnlock()
-	if !ok {
-		return fmt.Errorf("failed to find mcpClient for %s", mcpName)
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
 
@@ -619,87 +903,78 @@ func (s *Session) sendToolsListToMcp(xl xlog.Logger, mcpName McpName, baseReq mc
 		},
 	}
 
-	result, err := mCli.ListTools(ctx, request)
+	raw, err := s.callWithReconnect(ctx, xl, mcpName, true, func(ctx context.Context, mCli client.MCPClient) (interface{}, error) {
+		return mCli.ListTools(ctx, request)
+	})
 	if err != nil {
 		xl.Errorf("Failed to list tools from MCP %s: %v", mcpName, err)
 		return err
 	}
-
-	// 处理工具列表响应
-	s.mu.Lock()
-	if s.mcpToolsMap[mcpName] == nil {
-		s.mcpToolsMap[mcpName] = make(map[McpToolName]mcp.Tool)
-	}
-	for _, tool := range result.Tools {
-		s.mcpToolsMap[mcpName][tool.Name] = tool
-	}
-	s.mu.Unlock()
+	result := raw.(*mcp.ListToolsResult)
+	s.updateToolsMap(mcpName, result)
 
 	xl.Debugf("Received %d tools from MCP %s", len(result.Tools), mcpName)
-
-	// 标记该MCP的工具列表已完成
-	s.pendingToolsList.Done()
 	return nil
 }
 
-// handleAllToolsRequests 在单个goroutine中处理所有工具列表请求和响应聚合
-func (s *Session) handleAllToolsRequests(xl xlog.Logger, requestId interface{}, mcpNames []McpName, request mcp.JSONRPCRequest) {
+// refreshToolCatalog 依次向所有下游请求工具列表并重新聚合，返回带 MCP 前缀的工具列表
+// （包含网关组合工具）。
+func (s *Session) refreshToolCatalog(xl xlog.Logger) []mcp.Tool {
 	xl.Info("Processing all MCP tools list requests...")
 
-	// 顺序向所有MCP发送工具列表请求
+	// 重置工具列表状态
+	s.mu.Lock()
+	s.mcpToolsMap = make(map[McpName]map[McpToolName]mcp.Tool)
+	s.aggregatedTools = make([]mcp.Tool, 0)
+	s.toolsListComplete.Store(false)
+
+	mcpNames := make([]McpName, 0, len(s.mcpClients))
+	for mcpName := range s.mcpClients {
+		mcpNames = append(mcpNames, mcpName)
+	}
+	s.mu.Unlock()
+
+	if len(mcpNames) == 0 {
+		xl.Warn("No MCP clients available for tools list request")
+	}
+	// 顺序向所有MCP发送工具列表请求，单个失败不影响其他服务
 	for _, mcpName := range mcpNames {
-		if err := s.sendToolsListToMcp(xl, mcpName, request); err != nil {
+		if err := s.sendToolsListToMcp(xl, mcpName); err != nil {
 			xl.Errorf("Failed to send tools list request to %s: %v", mcpName, err)
-			// 如果发送失败，需要手动调用Done来平衡WaitGroup
-			s.pendingToolsList.Done()
 		}
-		// sendToolsListToMcp内部已经调用了Done()，这里不需要重复调用
-	}
-
-	// 等待所有MCP响应完成（带超时）
-	done := make(chan struct{})
-	go func() {
-		s.pendingToolsList.Wait()
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		xl.Info("All MCP tools list responses received")
-	case <-time.After(30 * time.Second):
-		xl.Warn("Timeout waiting for MCP tools list responses")
 	}
 
 	// 聚合所有工具并添加MCP名称前缀
 	s.mu.Lock()
 	s.aggregatedTools = make([]mcp.Tool, 0)
+	schemas := make(map[string]json.RawMessage)
 	for mcpName, tools := range s.mcpToolsMap {
 		for _, tool := range tools {
+			if !s.toolAllowed(fmt.Sprintf("%s_%s", mcpName, tool.Name)) {
+				continue
+			}
 			// 创建带前缀的工具副本
 			prefixedTool := mcp.Tool{
 				Name:        fmt.Sprintf("%s_%s", mcpName, tool.Name),
-				Description: fmt.Sprintf("[%s] %s", mcpName, tool.Description),
+				Description: s.namespacedDescriptionLocked(mcpName, tool.Description),
 				InputSchema: tool.InputSchema,
 			}
 			s.aggregatedTools = append(s.aggregatedTools, prefixedTool)
+			if schema := downstreamOutputSchema(s.mcpClients[mcpName], tool.Name); schema != nil {
+				schemas[prefixedTool.Name] = schema
+			}
 		}
 	}
+	s.outputSchemas.Store(&schemas)
+	s.aggregatedTools = append(s.aggregatedTools, s.compositeToolDefs()...)
+	tools := make([]mcp.Tool, len(s.aggregatedTools))
+	copy(tools, s.aggregatedTools)
+	mcpCount := len(s.mcpToolsMap)
 	s.mu.Unlock()
 
 	s.toolsListComplete.Store(true)
-
-	xl.Infof("Aggregated %d tools from %d MCPs", len(s.aggregatedTools), len(s.mcpToolsMap))
-
-	// 聚合的工具已经是mcp.Tool格式，直接使用
-	mcpTools := s.aggregatedTools
-
-	// 发送聚合后的工具列表响应
-	result := &mcp.ListToolsResult{
-		Tools: mcpTools,
-	}
-
-	xl.Infof("Sending aggregated tools response with %d tools", len(mcpTools))
-	s.sendSuccessResponse(requestId, result)
+	xl.Infof("Aggregated %d tools from %d MCPs", len(tools), mcpCount)
+	return tools
 }
 
 // GetAllTools 获取所有聚合后的工具列表（带MCP前缀）
@@ -749,21 +1024,48 @@ func (s *Session) handleMCPMethod(ctx context.Context, xl xlog.Logger, mCli clie
 		if err := json.Unmarshal(reqRaw, &request); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal listResources request: %w", err)
 		}
-		return mCli.ListResources(ctx, request)
+		result, err := mCli.ListResources(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		s.recordResourceOwners(mcpName, result)
+		return namespaceResources(mcpName, result), nil
 
 	case mcp.MethodResourcesTemplatesList:
 		var request mcp.ListResourceTemplatesRequest
 		if err := json.Unmarshal(reqRaw, &request); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal listResourceTemplates request: %w", err)
 		}
-		return mCli.ListResourceTemplates(ctx, request)
+		result, err := mCli.ListResourceTemplates(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return namespaceResourceTemplates(mcpName, result), nil
 
 	case mcp.MethodResourcesRead:
 		var request mcp.ReadResourceRequest
 		if err := json.Unmarshal(reqRaw, &request); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal readResource request: %w", err)
 		}
-		return mCli.ReadResource(ctx, request)
+		result, err := mCli.ReadResource(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return namespaceResourceContents(mcpName, result), nil
+
+	case methodResourcesSubscribe:
+		var request mcp.SubscribeRequest
+		if err := json.Unmarshal(reqRaw, &request); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal subscribe request: %w", err)
+		}
+		return &mcp.EmptyResult{}, s.subscribeResource(ctx, mCli, mcpName, request.Params.URI)
+
+	case methodResourcesUnsubscribe:
+		var request mcp.UnsubscribeRequest
+		if err := json.Unmarshal(reqRaw, &request); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal unsubscribe request: %w", err)
+		}
+		return &mcp.EmptyResult{}, s.unsubscribeResource(ctx, mCli, mcpName, request.Params.URI)
 
 	case mcp.MethodPromptsList:
 		var request mcp.ListPromptsRequest
@@ -795,7 +1097,7 @@ func (s *Session) handleMCPMethod(ctx context.Context, xl xlog.Logger, mCli clie
 		if err := json.Unmarshal(reqRaw, &request); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal callTool request: %w", err)
 		}
-		return mCli.CallTool(ctx, request)
+		return s.callToolCached(ctx, mCli, mcpName, request)
 
 	default:
 		return nil, fmt.Errorf("unsupported method: %s", method)
@@ -845,6 +1147,13 @@ func (s *Session) AggregateCapabilities() mcp.ServerCapabilities {
 		}
 		mergeCapabilities(&caps, r.Capabilities)
 	}
+	// resource 模式下超大结果通过 resources/read 读取
+	if s.outputPolicy.Enabled() && s.outputPolicy.WithDefaults().Mode == config.OutputModeResource && caps.Resources == nil {
+		caps.Resources = &struct {
+			Subscribe   bool `json:"subscribe,omitempty"`
+			ListChanged bool `json:"listChanged,omitempty"`
+		}{}
+	}
 	return caps
 }
 
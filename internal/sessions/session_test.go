@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -473,3 +474,59 @@ func TestSession_AggregateCapabilities_IgnoresNilResults(t *testing.T) {
 		t.Errorf("expected Tools.ListChanged=true, nil result should be skipped; got %+v", caps.Tools)
 	}
 }
+
+func TestSessionEventIDsAreMonotonicAndReplayable(t *testing.T) {
+	session := NewSession("event-ids")
+	defer session.Close()
+
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"n":1}`})
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"n":2}`})
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"n":3}`})
+
+	all := session.EventsSince(0)
+	if len(all) != 3 {
+		t.Fatalf("expected 3 buffered events, got %d", len(all))
+	}
+	for i, evt := range all {
+		if evt.ID != int64(i+1) {
+			t.Fatalf("expected event %d to have id %d, got %d", i, i+1, evt.ID)
+		}
+	}
+
+	eventChan, replay, closeChan := session.GetEventChanSince(1)
+	defer closeChan()
+	if len(replay) != 2 || replay[0].Data != `{"n":2}` || replay[1].Data != `{"n":3}` {
+		t.Fatalf("unexpected replay after id 1: %+v", replay)
+	}
+
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"n":4}`})
+	select {
+	case evt := <-eventChan:
+		if evt.ID != 4 {
+			t.Fatalf("expected live event id 4, got %d", evt.ID)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("expected live event after replay")
+	}
+}
+
+func TestSessionReplayBufferIsBounded(t *testing.T) {
+	session := NewSession("replay-bounded")
+	defer session.Close()
+
+	total := sessionReplayBufferSize + 10
+	for i := 1; i <= total; i++ {
+		session.SendEvent(SessionMsg{Event: "message", Data: fmt.Sprintf(`{"n":%d}`, i)})
+	}
+
+	events := session.EventsSince(0)
+	if len(events) != sessionReplayBufferSize {
+		t.Fatalf("expected %d buffered events, got %d", sessionReplayBufferSize, len(events))
+	}
+	if events[0].ID != int64(total-sessionReplayBufferSize+1) {
+		t.Fatalf("expected oldest events to be evicted, first id=%d", events[0].ID)
+	}
+	if got := session.EventsSince(int64(total)); len(got) != 0 {
+		t.Fatalf("expected no events after latest id, got %d", len(got))
+	}
+}
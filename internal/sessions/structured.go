@@ -0,0 +1,259 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 下游连接按网关支持的最新版本协商，但 mcp-go 的类型没有 2025-06-18 新增的字段：解析时会丢弃
+// structuredContent 与 outputSchema，遇到 resource_link 内容直接报错。versionedTransport 在
+// mcp-go 解析前把这些内容放进结果的 _meta，由 adaptResult 按 client 协商的版本还原或降级。
+const (
+	structuredContentMetaKey = "agentx.gateway/structuredContent"
+	resourceLinksMetaKey     = "agentx.gateway/resourceLinks"
+)
+
+// versionedTransport 包装下游 transport，记录协商出的版本与工具的 outputSchema，
+// 并改写 tools/call 结果中 mcp-go 无法解析的字段。
+type versionedTransport struct {
+	transport.Interface
+	version atomic.Value
+
+	mu            sync.RWMutex
+	outputSchemas map[string]json.RawMessage
+}
+
+func (t *versionedTransport) SendRequest(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	resp, err := t.Interface.SendRequest(ctx, request)
+	if err != nil {
+		return nil, &transportError{err: err}
+	}
+	if resp == nil || resp.Error != nil || len(resp.Result) == 0 {
+		return resp, nil
+	}
+	switch request.Method {
+	case string(mcp.MethodInitialize):
+		var result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if json.Unmarshal(resp.Result, &result) == nil {
+			t.version.Store(result.ProtocolVersion)
+		}
+	case string(mcp.MethodToolsList):
+		t.recordOutputSchemas(resp.Result)
+	case string(mcp.MethodToolsCall):
+		if carried, ok := carryToolResultFields(resp.Result); ok {
+			resp.Result = carried
+		}
+	}
+	return resp, nil
+}
+
+// headers 在 Streamable HTTP 请求上携带 2025-06-18 要求的协议版本头。
+func (t *versionedTransport) headers(context.Context) map[string]string {
+	if version, _ := t.version.Load().(string); version == ProtocolVersion20250618 {
+		return map[string]string{"MCP-Protocol-Version": version}
+	}
+	return nil
+}
+
+func (t *versionedTransport) recordOutputSchemas(raw json.RawMessage) {
+	var result struct {
+		Tools []struct {
+			Name         string          `json:"name"`
+			OutputSchema json.RawMessage `json:"outputSchema"`
+		} `json:"tools"`
+	}
+	if json.Unmarshal(raw, &result) != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.outputSchemas == nil {
+		t.outputSchemas = make(map[string]json.RawMessage)
+	}
+	for _, tool := range result.Tools {
+		if len(tool.OutputSchema) > 0 {
+			t.outputSchemas[tool.Name] = tool.OutputSchema
+		} else {
+			delete(t.outputSchemas, tool.Name)
+		}
+	}
+}
+
+// downstreamOutputSchema 返回下游工具声明的 outputSchema，没有声明时返回 nil。
+func downstreamOutputSchema(cli client.MCPClient, tool string) json.RawMessage {
+	c, ok := cli.(*client.Client)
+	if !ok {
+		return nil
+	}
+	t, ok := c.GetTransport().(*versionedTransport)
+	if !ok {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.outputSchemas[tool]
+}
+
+// carryToolResultFields 把 structuredContent 移入 _meta，并把 resource_link 内容替换为提示文本、
+// 原内容按位置记录在 _meta 中。结果中没有这些内容时返回 false。
+func carryToolResultFields(raw json.RawMessage) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return nil, false
+	}
+	structured, hasStructured := fields["structuredContent"]
+	var content []json.RawMessage
+	_ = json.Unmarshal(fields["content"], &content)
+	links := make(map[string]json.RawMessage)
+	for i, block := range content {
+		var link resourceLink
+		if json.Unmarshal(block, &link) != nil || link.Type != "resource_link" {
+			continue
+		}
+		links[strconv.Itoa(i)] = block
+		content[i], _ = json.Marshal(mcp.NewTextContent(resourceLinkText(link)))
+	}
+	if !hasStructured && len(links) == 0 {
+		return nil, false
+	}
+
+	meta := make(map[string]json.RawMessage)
+	if existing, ok := fields["_meta"]; ok {
+		_ = json.Unmarshal(existing, &meta)
+	}
+	if hasStructured && string(structured) != "null" {
+		meta[structuredContentMetaKey] = structured
+	}
+	if len(links) > 0 {
+		meta[resourceLinksMetaKey], _ = json.Marshal(links)
+		fields["content"], _ = json.Marshal(content)
+	}
+	delete(fields, "structuredContent")
+	fields["_meta"], _ = json.Marshal(meta)
+	carried, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return carried, true
+}
+
+// resourceLinkText 是 resource_link 对不支持它的 client 展示的文本。
+func resourceLinkText(link resourceLink) string {
+	return fmt.Sprintf("[%s] resource link %s: %s", GatewayNamespace, link.Name, link.URI)
+}
+
+// carriedFields 取出结果 _meta 中携带的 structuredContent 与 resource_link，返回去掉这些字段的 Result。
+func carriedFields(result mcp.Result) (mcp.Result, any, map[int]json.RawMessage) {
+	structured, hasStructured := result.Meta[structuredContentMetaKey]
+	rawLinks, hasLinks := result.Meta[resourceLinksMetaKey]
+	if !hasStructured && !hasLinks {
+		return result, nil, nil
+	}
+	meta := make(map[string]any, len(result.Meta))
+	for k, v := range result.Meta {
+		if k != structuredContentMetaKey && k != resourceLinksMetaKey {
+			meta[k] = v
+		}
+	}
+	result.Meta = nil
+	if len(meta) > 0 {
+		result.Meta = meta
+	}
+
+	var links map[int]json.RawMessage
+	if byIndex, ok := rawLinks.(map[string]any); ok {
+		links = make(map[int]json.RawMessage, len(byIndex))
+		for key, link := range byIndex {
+			i, err := strconv.Atoi(key)
+			if err != nil {
+				continue
+			}
+			if data, err := json.Marshal(link); err == nil {
+				links[i] = data
+			}
+		}
+	}
+	return result, structured, links
+}
+
+// withoutStructuredContent 返回不含 structuredContent 的结果副本，用于超出大小限制的结果。
+func withoutStructuredContent(result *mcp.CallToolResult) *mcp.CallToolResult {
+	if _, ok := result.Meta[structuredContentMetaKey]; !ok {
+		return result
+	}
+	stripped := *result
+	stripped.Meta = make(map[string]any, len(result.Meta))
+	for k, v := range result.Meta {
+		if k != structuredContentMetaKey {
+			stripped.Meta[k] = v
+		}
+	}
+	return &stripped
+}
+
+// structuredContentSize 返回结果携带的 structuredContent 序列化后的字节数。
+func structuredContentSize(result *mcp.CallToolResult) int {
+	structured, ok := result.Meta[structuredContentMetaKey]
+	if !ok {
+		return 0
+	}
+	data, _ := json.Marshal(structured)
+	return len(data)
+}
+
+// schemaTool 是带 outputSchema 的工具定义，mcp.Tool 本身没有这个字段。
+type schemaTool struct {
+	mcp.Tool
+	OutputSchema json.RawMessage
+}
+
+func (t schemaTool) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(t.Tool)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["outputSchema"] = t.OutputSchema
+	return json.Marshal(fields)
+}
+
+// toolListResult 与 mcp.ListToolsResult 的结构一致，工具可以带 outputSchema。
+type toolListResult struct {
+	mcp.PaginatedResult
+	Tools []interface{} `json:"tools"`
+}
+
+// redactValue 遮盖 structuredContent 中的字符串值，返回副本。
+func redactValue(v any, redact func(string) string) any {
+	switch value := v.(type) {
+	case string:
+		return redact(value)
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for k, item := range value {
+			redacted[k] = redactValue(item, redact)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = redactValue(item, redact)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
@@ -0,0 +1,153 @@
+package sessions
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 下游服务在 session 中的订阅状态
+const (
+	SubscriptionConnected = "connected"
+	SubscriptionFailed    = "failed"
+)
+
+// 网关在下游服务延迟加入 session 时下发的通知
+const methodNotificationServiceJoined = "notifications/gateway/service_joined"
+
+// ServiceSubscription 记录 session 对单个下游服务的订阅情况。
+type ServiceSubscription struct {
+	Name          McpName   `json:"name"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	ConnectedAt   time.Time `json:"connected_at,omitempty"`
+	// Reconnects 为 session 存活期间因连接断开而自动重连的次数
+	Reconnects int `json:"reconnects"`
+	// ProtocolVersion 为与该下游协商出的协议版本
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+}
+
+// recordSubscription 记录一次订阅尝试的结果。
+func (s *Session) recordSubscription(mcpName McpName, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.subscriptions[mcpName]
+	if sub == nil {
+		sub = &ServiceSubscription{Name: mcpName}
+		s.subscriptions[mcpName] = sub
+	}
+	now := time.Now()
+	sub.Attempts++
+	sub.LastAttemptAt = now
+	if err != nil {
+		sub.Status = SubscriptionFailed
+		sub.LastError = err.Error()
+		return
+	}
+	sub.Status = SubscriptionConnected
+	sub.LastError = ""
+	sub.ConnectedAt = now
+}
+
+// forgetSubscription 在服务已被删除或停止时移除其订阅记录。
+func (s *Session) forgetSubscription(mcpName McpName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, mcpName)
+}
+
+// Subscriptions 返回按服务名排序的订阅状态快照。
+func (s *Session) Subscriptions() []ServiceSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]ServiceSubscription, 0, len(s.subscriptions))
+	for name, sub := range s.subscriptions {
+		view := *sub
+		if result := s.mcpinitializeResults[name]; result != nil {
+			view.ProtocolVersion = result.ProtocolVersion
+		}
+		out = append(out, view)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// FailedSubscriptions 返回当前订阅失败、仍在后台重试的服务名。
+func (s *Session) FailedSubscriptions() []McpName {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]McpName, 0)
+	for name, sub := range s.subscriptions {
+		if sub.Status == SubscriptionFailed {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsDegraded 表示 session 中至少有一个下游服务未能订阅成功。
+func (s *Session) IsDegraded() bool {
+	return len(s.FailedSubscriptions()) > 0
+}
+
+// isClosed 判断 session 是否已关闭。
+func (s *Session) isClosed() bool {
+	select {
+	case <-s.doneChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// attachLateClient 把后台重试成功的客户端挂到 session 上，并通知客户端刷新工具列表。
+// session 已关闭时直接释放客户端并返回 false。
+func (s *Session) attachLateClient(xl xlog.Logger, mcpName McpName, cli client.MCPClient, result *mcp.InitializeResult) bool {
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		_ = cli.Close()
+		return false
+	}
+	if old, ok := s.mcpClients[mcpName]; ok {
+		_ = old.Close()
+	}
+	s.mcpClients[mcpName] = cli
+	s.mcpinitializeResults[mcpName] = result
+	s.mu.Unlock()
+
+	s.recordSubscription(mcpName, nil)
+	s.sendNotification(xl, methodNotificationServiceJoined, map[string]interface{}{"service": mcpName})
+	s.sendNotification(xl, string(mcp.MethodNotificationToolsListChanged), nil)
+	return true
+}
+
+// sendNotification 向 session 的客户端推送一条 JSON-RPC 通知。
+func (s *Session) sendNotification(xl xlog.Logger, method string, params map[string]interface{}) {
+	notification := map[string]interface{}{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"method":  method,
+	}
+	if params != nil {
+		notification["params"] = params
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		xl.Errorf("failed to marshal notification %s: %v", method, err)
+		return
+	}
+	s.SendEvent(SessionMsg{
+		Event: "message",
+		Data:  string(data),
+	})
+}
@@ -0,0 +1,93 @@
+package sessions
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func runningRemoteService(name string) *runtime.McpService {
+	svc := runtime.NewMcpService(name, config.MCPServerConfig{
+		Workspace: "default",
+		URL:       "http://" + name + ".invalid/sse",
+	}, runtime.NewPortManager())
+	svc.Status = runtime.Running
+	return svc
+}
+
+func TestSessionManagerCreatesDegradedSessionAndRetries(t *testing.T) {
+	services := []*runtime.McpService{runningRemoteService("good"), runningRemoteService("flaky")}
+	manager := NewSessionManager(func() []*runtime.McpService { return services }, CleanupConfig{})
+	manager.retryInterval = 10 * time.Millisecond
+	manager.maxRetryInterval = 20 * time.Millisecond
+
+	var flakyAttempts atomic.Int32
+	manager.dialDownstream = func(_ xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		if spec.Name == "flaky" && flakyAttempts.Add(1) < 3 {
+			return nil, nil, errors.New("connection refused")
+		}
+		return &fakePoolClient{}, &mcp.InitializeResult{}, nil
+	}
+
+	session, err := manager.CreateSession(xlog.NewLogger("test-degraded"))
+	if err != nil {
+		t.Fatalf("CreateSession should succeed with a partially failing workspace: %v", err)
+	}
+	defer session.Close()
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	if !session.IsDegraded() {
+		t.Fatal("session should be degraded while flaky service is failing")
+	}
+	subs := session.Subscriptions()
+	if len(subs) != 2 || subs[0].Name != "flaky" || subs[0].Status != SubscriptionFailed || subs[1].Status != SubscriptionConnected {
+		t.Fatalf("unexpected subscriptions: %+v", subs)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case evt := <-events:
+			if !strings.Contains(evt.Data, methodNotificationServiceJoined) {
+				continue
+			}
+			if !strings.Contains(evt.Data, `"service":"flaky"`) {
+				t.Fatalf("unexpected join notification: %s", evt.Data)
+			}
+			if session.IsDegraded() {
+				t.Fatal("session should no longer be degraded after late join")
+			}
+			if sub := session.Subscriptions()[0]; sub.Attempts != 3 || sub.LastError != "" {
+				t.Fatalf("unexpected subscription after retry: %+v", sub)
+			}
+			return
+		case <-deadline:
+			t.Fatal("timed out waiting for late join notification")
+		}
+	}
+}
+
+func TestSessionManagerFailsWhenNoServiceSubscribes(t *testing.T) {
+	manager := NewSessionManager(func() []*runtime.McpService {
+		return []*runtime.McpService{runningRemoteService("down")}
+	}, CleanupConfig{})
+	manager.dialDownstream = func(_ xlog.Logger, _ downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		return nil, nil, errors.New("connection refused")
+	}
+
+	if _, err := manager.CreateSession(xlog.NewLogger("test-all-failed")); err == nil {
+		t.Fatal("CreateSession should fail when every running service fails")
+	}
+	if got := len(manager.GetAllSessions(xlog.NewLogger("test-all-failed"))); got != 0 {
+		t.Fatalf("failed session must not be registered, got %d", got)
+	}
+}
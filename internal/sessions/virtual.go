@@ -0,0 +1,91 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+)
+
+// ErrVirtualServerNotFound 表示 workspace 没有声明该虚拟服务器。
+var ErrVirtualServerNotFound = errors.New("virtual server not found")
+
+// virtualServer 限定 session 可见的服务与工具，创建后只读。
+type virtualServer struct {
+	name     string
+	services map[McpName]struct{}
+	// tools 为空时允许所列服务的全部工具
+	tools        map[string]struct{}
+	instructions string
+}
+
+func newVirtualServer(cfg config.VirtualServerConfig) *virtualServer {
+	vs := &virtualServer{
+		name:         cfg.Name,
+		services:     make(map[McpName]struct{}, len(cfg.Services)),
+		tools:        make(map[string]struct{}, len(cfg.Tools)),
+		instructions: cfg.Instructions,
+	}
+	for _, service := range cfg.Services {
+		vs.services[service] = struct{}{}
+	}
+	for _, tool := range cfg.Tools {
+		vs.tools[tool] = struct{}{}
+	}
+	return vs
+}
+
+// includesService 返回虚拟服务器是否订阅该服务。
+func (vs *virtualServer) includesService(name McpName) bool {
+	_, ok := vs.services[name]
+	return ok
+}
+
+// SetVirtualServers 设置 workspace 声明的虚拟服务器，无效的声明会被跳过。
+func (m *SessionManager) SetVirtualServers(xl xlog.Logger, servers []config.VirtualServerConfig) {
+	valid := make(map[string]*virtualServer, len(servers))
+	for _, server := range servers {
+		if err := server.Validate(); err != nil {
+			xl.Warnf("skip invalid virtual server: %v", err)
+			continue
+		}
+		valid[server.Name] = newVirtualServer(server)
+	}
+	m.virtualServers = valid
+}
+
+// CreateVirtualSession 创建只订阅虚拟服务器所列服务的 session。
+func (m *SessionManager) CreateVirtualSession(xl xlog.Logger, name string) (*Session, error) {
+	vs, ok := m.virtualServers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrVirtualServerNotFound, name)
+	}
+	return m.createSession(xl, vs)
+}
+
+// VirtualServer 返回 session 所属的虚拟服务器名，普通 session 返回空字符串。
+func (s *Session) VirtualServer() string {
+	if s.virtual == nil {
+		return ""
+	}
+	return s.virtual.name
+}
+
+// toolAllowed 判断带服务前缀的工具是否对该 session 可见。lazy 模式的网关元工具始终可用；
+// 组合工具需要在 services 中列出 gateway，或在 tools 中显式列出。
+func (s *Session) toolAllowed(name string) bool {
+	if s.virtual == nil {
+		return true
+	}
+	service, tool, _ := strings.Cut(name, "_")
+	if service == GatewayNamespace && s.LazyTools() && isMetaTool(tool) {
+		return true
+	}
+	if len(s.virtual.tools) == 0 {
+		return s.virtual.includesService(service)
+	}
+	_, ok := s.virtual.tools[name]
+	return ok
+}
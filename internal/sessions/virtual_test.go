@@ -0,0 +1,89 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func virtualSessionManager(t *testing.T) (*SessionManager, map[string]*recordingClient) {
+	t.Helper()
+	clients := map[string]*recordingClient{
+		"github": {tools: []mcp.Tool{{Name: "search_issues"}, {Name: "create_issue"}}},
+		"fs":     {tools: []mcp.Tool{{Name: "write_file"}}},
+	}
+	services := []*runtime.McpService{runningRemoteService("github"), runningRemoteService("fs")}
+	manager := NewSessionManager(func() []*runtime.McpService { return services }, CleanupConfig{})
+	manager.dialDownstream = func(_ xlog.Logger, spec downstreamSpec) (client.MCPClient, *mcp.InitializeResult, error) {
+		return clients[spec.Name], &mcp.InitializeResult{}, nil
+	}
+	manager.SetVirtualServers(xlog.NewLogger("test-virtual"), []config.VirtualServerConfig{
+		{Name: "triage", Services: []string{"github"}, Tools: []string{"github_search_issues"}, Instructions: "read-only issue triage"},
+		{Name: "broken"},
+	})
+	return manager, clients
+}
+
+func TestVirtualSessionExposesOnlyListedTools(t *testing.T) {
+	manager, clients := virtualSessionManager(t)
+	session, err := manager.CreateVirtualSession(xlog.NewLogger("test-virtual"), "triage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+
+	if session.VirtualServer() != "triage" {
+		t.Fatalf("unexpected virtual server %q", session.VirtualServer())
+	}
+	if subs := session.Subscriptions(); len(subs) != 1 || subs[0].Name != "github" {
+		t.Fatalf("virtual session should only subscribe listed services: %+v", subs)
+	}
+
+	sendRPC(t, session, 1, "initialize", map[string]interface{}{"protocolVersion": "2025-03-26"})
+	var initResp struct {
+		Result mcp.InitializeResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &initResp); err != nil {
+		t.Fatal(err)
+	}
+	if initResp.Result.ServerInfo.Name != "mcp-gateway/triage" || initResp.Result.Instructions != "read-only issue triage" {
+		t.Fatalf("unexpected initialize result: %+v", initResp.Result)
+	}
+
+	sendRPC(t, session, 2, "tools/list", map[string]interface{}{})
+	var listResp struct {
+		Result mcp.ListToolsResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(waitEvent(t, events).Data), &listResp); err != nil {
+		t.Fatal(err)
+	}
+	if len(listResp.Result.Tools) != 1 || listResp.Result.Tools[0].Name != "github_search_issues" {
+		t.Fatalf("unexpected tools: %+v", listResp.Result.Tools)
+	}
+
+	sendRPC(t, session, 3, "tools/call", map[string]interface{}{"name": "github_create_issue", "arguments": map[string]interface{}{}})
+	if data := waitEvent(t, events).Data; !strings.Contains(data, "not available on virtual server triage") {
+		t.Fatalf("hidden tool should be rejected: %s", data)
+	}
+	if len(clients["github"].calls) != 0 {
+		t.Fatalf("hidden tool must not reach the downstream service: %+v", clients["github"].calls)
+	}
+}
+
+func TestCreateVirtualSessionUnknownName(t *testing.T) {
+	manager, _ := virtualSessionManager(t)
+	for _, name := range []string{"missing", "broken"} {
+		if _, err := manager.CreateVirtualSession(xlog.NewLogger("test-virtual"), name); !errors.Is(err, ErrVirtualServerNotFound) {
+			t.Fatalf("%s: expected ErrVirtualServerNotFound, got %v", name, err)
+		}
+	}
+}
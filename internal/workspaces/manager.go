@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/redact"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
@@ -16,10 +17,59 @@ type WorkspaceManager struct {
 
 	cfg         config.Config
 	portManager runtime.PortManagerI
+
+	// toolPolicies 为各 workspace 当前的工具参数策略，初始值来自配置，可在运行时替换
+	toolPolicies   map[string][]config.ToolPolicyRule
+	toolPoliciesMu sync.RWMutex
+
+	// redactor 遮盖工具结果，由主锁保护
+	redactor *redact.Redactor
 }
 
 func NewWorkspaceManager(cfg config.Config, portManager runtime.PortManagerI) *WorkspaceManager {
-	return &WorkspaceManager{workspaces: make(map[string]*WorkSpace), cfg: cfg, portManager: portManager}
+	toolPolicies := make(map[string][]config.ToolPolicyRule, len(cfg.ToolPolicies))
+	for workId, rules := range cfg.ToolPolicies {
+		toolPolicies[workId] = rules
+	}
+	return &WorkspaceManager{workspaces: make(map[string]*WorkSpace), cfg: cfg, portManager: portManager, toolPolicies: toolPolicies}
+}
+
+// SetToolPolicies 替换 workspace 的工具参数策略，已创建的 workspace 立即生效，之后创建的 workspace 也会使用。
+func (m *WorkspaceManager) SetToolPolicies(xl xlog.Logger, workId string, rules []config.ToolPolicyRule) {
+	m.toolPoliciesMu.Lock()
+	m.toolPolicies[workId] = rules
+	m.toolPoliciesMu.Unlock()
+	if workspace, ok := m.GetWorkspace(xl, workId, false); ok {
+		workspace.sessionMgr.SetToolPolicies(xl, rules)
+	}
+}
+
+// SetRedactor 设置工具结果的遮盖规则，对之后创建的 session 生效。
+func (m *WorkspaceManager) SetRedactor(r *redact.Redactor) {
+	m.workspacesLock.Lock()
+	defer m.workspacesLock.Unlock()
+	m.redactor = r
+	for workId, workspace := range m.workspaces {
+		m.applyRedactorLocked(workId, workspace)
+	}
+}
+
+// applyRedactorLocked 为要求遮盖工具结果的 workspace 设置遮盖函数，调用方需持有主锁。
+func (m *WorkspaceManager) applyRedactorLocked(workId string, workspace *WorkSpace) {
+	if !m.redactor.ToolResults(workId) {
+		workspace.sessionMgr.SetResultRedactor(nil)
+		return
+	}
+	r := m.redactor
+	workspace.sessionMgr.SetResultRedactor(func(s string) string {
+		return r.RedactString(workId, s)
+	})
+}
+
+func (m *WorkspaceManager) workspaceToolPolicies(workId string) []config.ToolPolicyRule {
+	m.toolPoliciesMu.RLock()
+	defer m.toolPoliciesMu.RUnlock()
+	return m.toolPolicies[workId]
 }
 
 // GetWorkspace returns a workspace by id. If the workspace does not exist, it creates a new one.
@@ -53,12 +103,22 @@ func (m *WorkspaceManager) createWorkspace(xl xlog.Logger, workId string) *WorkS
 		},
 		McpServiceMgrConfig: m.cfg.McpServiceMgrConfig,
 		Servers:             make(map[string]config.MCPServerConfig),
+		DownstreamPoolSize:  m.cfg.DownstreamPoolSize,
+		CompositeTools:      m.cfg.CompositeTools[workId],
+		LazyTools:           m.cfg.LazyTools[workId],
+		OutputPolicy:        m.cfg.OutputPolicies[workId],
+		VirtualServers:      m.cfg.VirtualServers[workId],
+		ToolPolicies:        m.workspaceToolPolicies(workId),
+		Approval:            m.cfg.Approvals[workId],
 	}, m.portManager, sessions.CleanupConfig{
 		InactivityCheckInterval: m.cfg.SessionGCInterval,
 		NoConnectionTTL:         m.cfg.ProxySessionTimeout,
+		EventBufferSize:         m.cfg.SessionEventBuffer,
+		OverflowPolicy:          m.cfg.SessionOverflow,
 	})
 	m.workspacesLock.Lock()
 	m.workspaces[workspace.Id] = workspace
+	m.applyRedactorLocked(workspace.Id, workspace)
 	m.workspacesLock.Unlock()
 	return workspace
 }
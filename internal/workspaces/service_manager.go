@@ -5,6 +5,7 @@ import (
 
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/errs"
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/redact"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/platform/xlog"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/runtime"
 	"github.com/lucky-aeon/agentx/plugin-helper/internal/sessions"
@@ -22,6 +23,12 @@ type ServiceManagerI interface {
 	GetWorkspaceSessions(logger xlog.Logger, name NameArg) []*sessions.Session
 	CloseProxySession(logger xlog.Logger, name NameArg)
 	DeleteServer(logger xlog.Logger, name NameArg) error
+	SetToolPolicies(logger xlog.Logger, name NameArg, rules []config.ToolPolicyRule)
+	GuardsTool(logger xlog.Logger, name NameArg, tool string) bool
+	PoolStats(logger xlog.Logger, name NameArg) map[string]map[string]int
+	ToolCacheStats(logger xlog.Logger, name NameArg) map[string]sessions.ToolCacheStats
+	ListApprovals(logger xlog.Logger, name NameArg) []sessions.Approval
+	DecideApproval(logger xlog.Logger, name NameArg, id string, decision sessions.ApprovalDecision) (sessions.Approval, error)
 	Close()
 }
 
@@ -29,6 +36,8 @@ type NameArg struct {
 	Workspace string
 	Server    string
 	Session   string
+	// VirtualServer 非空时 CreateProxySession 创建只订阅该虚拟服务器所列服务的 session
+	VirtualServer string
 }
 
 type ServiceManager struct {
@@ -98,6 +107,9 @@ func (s *ServiceManager) GetMcpServices(logger xlog.Logger, name NameArg) map[st
 
 func (s *ServiceManager) CreateProxySession(logger xlog.Logger, name NameArg) (*sessions.Session, error) {
 	workspace, _ := s.getWorkspace(logger, name.Workspace)
+	if name.VirtualServer != "" {
+		return workspace.sessionMgr.CreateVirtualSession(logger, name.VirtualServer)
+	}
 	return workspace.sessionMgr.CreateSession(logger)
 }
 
@@ -130,6 +142,61 @@ func (s *ServiceManager) DeleteServer(logger xlog.Logger, name NameArg) error {
 	return nil
 }
 
+// SetToolPolicies 替换 workspace 的工具参数策略，对已有 session 立即生效。
+func (s *ServiceManager) SetToolPolicies(logger xlog.Logger, name NameArg, rules []config.ToolPolicyRule) {
+	s.workSpaceMgr.SetToolPolicies(logger, name.Workspace, rules)
+}
+
+// GuardsTool 返回 workspace 的策略或审批是否可能作用于该工具，workspace 不存在时返回 false。
+func (s *ServiceManager) GuardsTool(logger xlog.Logger, name NameArg, tool string) bool {
+	workspace, ok := s.workSpaceMgr.GetWorkspace(logger, name.Workspace, false)
+	if !ok {
+		return false
+	}
+	return workspace.sessionMgr.GuardsTool(tool)
+}
+
+// PoolStats 返回 workspace 内每个服务的共享连接数与 session 引用数，workspace 不存在或未启用连接复用时返回 nil。
+func (s *ServiceManager) PoolStats(logger xlog.Logger, name NameArg) map[string]map[string]int {
+	workspace, ok := s.workSpaceMgr.GetWorkspace(logger, name.Workspace, false)
+	if !ok {
+		return nil
+	}
+	return workspace.sessionMgr.PoolStats()
+}
+
+// ToolCacheStats 返回 workspace 内开启了结果缓存的服务的命中统计，workspace 不存在时返回 nil。
+func (s *ServiceManager) ToolCacheStats(logger xlog.Logger, name NameArg) map[string]sessions.ToolCacheStats {
+	workspace, ok := s.workSpaceMgr.GetWorkspace(logger, name.Workspace, false)
+	if !ok {
+		return nil
+	}
+	return workspace.sessionMgr.ToolCacheStats()
+}
+
+// SetRedactor 设置工具结果的遮盖规则。
+func (s *ServiceManager) SetRedactor(r *redact.Redactor) {
+	s.workSpaceMgr.SetRedactor(r)
+}
+
+// ListApprovals 返回 workspace 内等待审批的工具调用，workspace 不存在时返回空列表。
+func (s *ServiceManager) ListApprovals(logger xlog.Logger, name NameArg) []sessions.Approval {
+	workspace, ok := s.workSpaceMgr.GetWorkspace(logger, name.Workspace, false)
+	if !ok {
+		return []sessions.Approval{}
+	}
+	return workspace.sessionMgr.Approvals().List()
+}
+
+// DecideApproval 批准或拒绝 workspace 内一条等待审批的工具调用。
+func (s *ServiceManager) DecideApproval(logger xlog.Logger, name NameArg, id string, decision sessions.ApprovalDecision) (sessions.Approval, error) {
+	workspace, ok := s.workSpaceMgr.GetWorkspace(logger, name.Workspace, false)
+	if !ok {
+		return sessions.Approval{}, sessions.ErrApprovalNotFound
+	}
+	return workspace.sessionMgr.Approvals().Decide(id, decision)
+}
+
 func (s *ServiceManager) DeleteWorkspace(logger xlog.Logger, name NameArg) {
 	s.workSpaceMgr.DeleteWorkspace(logger, name.Workspace)
 }
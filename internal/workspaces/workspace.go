@@ -41,6 +41,21 @@ func NewWorkSpace(workId string, cfg config.WorkspaceConfig, portManager runtime
 	space := &WorkSpace{Id: workId, cfg: cfg, portManager: portManager, servers: make(map[string]*runtime.McpService)}
 	// init session manager, it will be used to create session for each workspace
 	space.sessionMgr = sessions.NewSessionManager(space.listMcpServices, sessionConfig)
+	if cfg.DownstreamPoolSize > 0 {
+		space.sessionMgr.SetClientPool(sessions.NewClientPool(cfg.DownstreamPoolSize))
+	}
+	if len(cfg.CompositeTools) > 0 {
+		space.sessionMgr.SetCompositeTools(xlog.NewLogger("workspace-"+workId), cfg.CompositeTools)
+	}
+	space.sessionMgr.SetLazyTools(cfg.LazyTools)
+	space.sessionMgr.SetOutputPolicy(cfg.OutputPolicy)
+	if len(cfg.VirtualServers) > 0 {
+		space.sessionMgr.SetVirtualServers(xlog.NewLogger("workspace-"+workId), cfg.VirtualServers)
+	}
+	if len(cfg.ToolPolicies) > 0 {
+		space.sessionMgr.SetToolPolicies(xlog.NewLogger("workspace-"+workId), cfg.ToolPolicies)
+	}
+	space.sessionMgr.SetApprovalConfig(cfg.Approval)
 	return space
 }
 
@@ -183,6 +198,7 @@ func (w *WorkSpace) RestartMcpService(xl xlog.Logger, serviceName string) error
 		return err
 	}
 	server.Restart(xl)
+	w.sessionMgr.InvalidateService(serviceName)
 	return nil
 }
 
@@ -195,6 +211,7 @@ func (w *WorkSpace) StopMcpService(xl xlog.Logger, serviceName string) error {
 		return err
 	}
 	server.Stop(xl)
+	w.sessionMgr.InvalidateService(serviceName)
 	return nil
 }
 
@@ -219,6 +236,7 @@ func (w *WorkSpace) removeMcpServiceInternal(xl xlog.Logger, serviceName string)
 
 	// 在锁外停止服务，避免死锁
 	server.Stop(xl)
+	w.sessionMgr.InvalidateService(serviceName)
 
 	// 最后从map中删除
 	w.serversMutex.Lock()
@@ -268,6 +286,7 @@ func (w *WorkSpace) Close(xl xlog.Logger) {
 			delete(w.servers, serverName)
 		}
 	}
+	w.sessionMgr.Close(xl)
 	xl.Infof("Workspace %s closed successfully", w.Id)
 }
 
@@ -0,0 +1,51 @@
+package e2e
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/internal/connect"
+)
+
+func TestConnectBridgeStdioToGatewayE2E(t *testing.T) {
+	source := mcpserver.NewTestStreamableHTTPServer(newMockSourceMCPServer(t))
+	t.Cleanup(func() {
+		source.CloseClientConnections()
+		source.Close()
+	})
+
+	gatewayURL := startInProcessGateway(t)
+	deployMockSource(t, gatewayURL, source.URL, "streamhttp")
+
+	bridge, err := connect.New(connect.Options{URL: gatewayURL + "/stream", Token: e2eAPIKey})
+	require.NoError(t, err)
+
+	// client 写入 bridge 的 stdin，从 bridge 的 stdout 读取
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := bridge.Run(context.Background(), stdinReader, stdoutWriter)
+		_ = stdoutWriter.Close()
+		done <- err
+	}()
+
+	cli := mcpclient.NewClient(transport.NewIO(stdoutReader, stdinWriter, io.NopCloser(nil)))
+	runMCPClientFlow(t, cli, "mcp-gateway")
+
+	// stdin 关闭后 bridge 删除远端 session 并退出
+	require.NoError(t, stdinWriter.Close())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge should exit after stdin is closed")
+	}
+}
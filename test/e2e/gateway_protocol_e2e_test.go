@@ -26,6 +26,8 @@ const (
 	e2eResourceURI    = "mock://resource/readme"
 	e2eToolName       = "echo"
 	e2eGatewayTool    = e2eServiceName + "_" + e2eToolName
+	e2eGatewayURI     = e2eServiceName + "_" + e2eResourceURI
+	e2eProgressTool   = "count"
 	e2eExpectedText   = "mock resource from source mcp server"
 	e2eGatewayAuthHdr = "Bearer " + e2eAPIKey
 )
@@ -126,6 +128,25 @@ func newMockSourceMCPServer(t *testing.T) *mcpserver.MCPServer {
 			return mcp.NewToolResultText("source echo: " + text), nil
 		},
 	)
+	srv.AddTool(
+		mcp.NewTool(
+			e2eProgressTool,
+			mcp.WithDescription("Counts to three, reporting progress for each step"),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if req.Params.Meta != nil && req.Params.Meta.ProgressToken != nil {
+				for step := 1; step <= 3; step++ {
+					_ = mcpserver.ServerFromContext(ctx).SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+						"progressToken": req.Params.Meta.ProgressToken,
+						"progress":      step,
+						"total":         3,
+					})
+					time.Sleep(20 * time.Millisecond)
+				}
+			}
+			return mcp.NewToolResultText("counted to 3"), nil
+		},
+	)
 	srv.AddResource(
 		mcp.NewResource(
 			e2eResourceURI,
@@ -219,10 +240,10 @@ func runMCPClientFlow(t *testing.T, cli *mcpclient.Client, expectedServerName st
 
 	resources, err := cli.ListResources(ctx, mcp.ListResourcesRequest{})
 	require.NoError(t, err)
-	require.Contains(t, resourceURIs(resources.Resources), e2eResourceURI)
+	require.Contains(t, resourceURIs(resources.Resources), e2eGatewayURI)
 
 	readResult, err := cli.ReadResource(ctx, mcp.ReadResourceRequest{
-		Params: mcp.ReadResourceParams{URI: e2eResourceURI},
+		Params: mcp.ReadResourceParams{URI: e2eGatewayURI},
 	})
 	require.NoError(t, err)
 	require.Len(t, readResult.Contents, 1)
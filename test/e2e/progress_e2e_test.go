@@ -0,0 +1,69 @@
+package e2e
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayStreamHTTPStreamsProgressE2E(t *testing.T) {
+	source := mcpserver.NewTestStreamableHTTPServer(newMockSourceMCPServer(t))
+	t.Cleanup(func() {
+		source.CloseClientConnections()
+		source.Close()
+	})
+
+	gatewayURL := startInProcessGateway(t)
+	deployMockSource(t, gatewayURL, source.URL, "streamhttp")
+
+	cli, err := mcpclient.NewStreamableHttpClient(
+		gatewayURL+"/stream",
+		transport.WithHTTPHeaders(map[string]string{"Authorization": e2eGatewayAuthHdr}),
+		transport.WithHTTPTimeout(10*time.Second),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cli.Close() })
+
+	var mu sync.Mutex
+	var progress []mcp.JSONRPCNotification
+	cli.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == "notifications/progress" {
+			mu.Lock()
+			progress = append(progress, notification)
+			mu.Unlock()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, cli.Start(ctx))
+	_, err = cli.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}})
+	require.NoError(t, err)
+
+	result, err := cli.CallTool(ctx, mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name: e2eServiceName + "_" + e2eProgressTool,
+			Meta: &mcp.Meta{ProgressToken: "client-token"},
+		},
+	})
+	require.NoError(t, err)
+	text, ok := mcp.AsTextContent(result.Content[0])
+	require.True(t, ok)
+	require.Equal(t, "counted to 3", text.Text)
+
+	// 进度通知在同一个 POST 响应的 SSE 流上先于最终结果送达，token 还原为 client 的原值
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, progress, 3)
+	for i, notification := range progress {
+		require.Equal(t, "client-token", notification.Params.AdditionalFields["progressToken"])
+		require.EqualValues(t, i+1, notification.Params.AdditionalFields["progress"])
+	}
+}